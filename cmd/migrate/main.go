@@ -0,0 +1,116 @@
+// Command migrate applies and inspects the SQLite schema migrations without
+// starting the service. The database is configured from the same DB_*
+// environment variables as the service, see sqlite.ConfigFromEnv.
+//
+// Usage:
+//
+//	migrate up       apply all pending migrations
+//	migrate status   list every migration and whether it is applied
+//	migrate version  print the latest applied migration version
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"paymentprocessor/internal/infrastructure/persistence/sqlite"
+)
+
+const usage = "usage: migrate up|status|version"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(usage)
+	}
+
+	config, err := sqlite.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	db, err := sqlite.NewDatabase(config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		if err := db.Initialize(ctx); err != nil {
+			return err
+		}
+		migrations, err := db.GetMigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "database is at version %d\n", currentVersion(migrations))
+		return err
+	case "status":
+		migrations, err := db.GetMigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		return renderStatus(out, migrations)
+	case "version":
+		migrations, err := db.GetMigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, currentVersion(migrations))
+		return err
+	default:
+		return fmt.Errorf("unknown command %q; %s", args[0], usage)
+	}
+}
+
+// renderStatus writes one row per migration, oldest first, followed by a
+// count of applied and pending migrations.
+func renderStatus(out io.Writer, migrations []sqlite.Migration) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+
+	applied := 0
+	for _, m := range migrations {
+		status, appliedAt := "pending", "-"
+		if m.AppliedAt != nil {
+			applied++
+			status, appliedAt = "applied", m.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%03d\t%s\t%s\t%s\n", m.Version, m.Name, status, appliedAt)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "%d applied, %d pending\n", applied, len(migrations)-applied)
+	return err
+}
+
+// currentVersion returns the highest applied migration version, or 0 for a
+// database without any.
+func currentVersion(migrations []sqlite.Migration) int {
+	version := 0
+	for _, m := range migrations {
+		if m.AppliedAt != nil && m.Version > version {
+			version = m.Version
+		}
+	}
+	return version
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/infrastructure/persistence/sqlite"
+)
+
+func TestRenderStatus(t *testing.T) {
+	t.Parallel()
+
+	appliedAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	migrations := []sqlite.Migration{
+		{Version: 1, Name: "create_payments_table", AppliedAt: &appliedAt},
+		{Version: 2, Name: "add_currency", AppliedAt: &appliedAt},
+		{Version: 3, Name: "add_reference"},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, renderStatus(&out, migrations))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 5)
+	assert.Equal(t, []string{"VERSION", "NAME", "STATUS", "APPLIED", "AT"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"001", "create_payments_table", "applied", "2024-03-01T12:30:00Z"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"002", "add_currency", "applied", "2024-03-01T12:30:00Z"}, strings.Fields(lines[2]))
+	assert.Equal(t, []string{"003", "add_reference", "pending", "-"}, strings.Fields(lines[3]))
+	assert.Equal(t, "2 applied, 1 pending", lines[4])
+
+	// Columns are aligned, so every status starts at the same offset.
+	statusColumn := strings.Index(lines[0], "STATUS")
+	for _, line := range lines[1:4] {
+		assert.Contains(t, []string{"applied", "pending"}, line[statusColumn:statusColumn+7])
+	}
+}
+
+func TestCurrentVersion(t *testing.T) {
+	t.Parallel()
+
+	appliedAt := time.Now()
+	tests := []struct {
+		name       string
+		migrations []sqlite.Migration
+		want       int
+	}{
+		{name: "no migrations", want: 0},
+		{name: "none applied", migrations: []sqlite.Migration{{Version: 1}, {Version: 2}}, want: 0},
+		{
+			name:       "some pending",
+			migrations: []sqlite.Migration{{Version: 1, AppliedAt: &appliedAt}, {Version: 2, AppliedAt: &appliedAt}, {Version: 3}},
+			want:       2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, currentVersion(tt.migrations))
+		})
+	}
+}
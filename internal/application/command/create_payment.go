@@ -1,5 +1,22 @@
 package command
 
+import (
+	"time"
+
+	"paymentprocessor/internal/domain/shared"
+)
+
+// CreatePaymentCommand carries the raw, unvalidated inputs of a payment request.
+// Only the amount arrives parsed, e.g. with shared.ParseAmount, so that money
+// never passes through floating point on its way in.
 type CreatePaymentCommand struct {
-	// TODO: Implement CreatePaymentCommand and handler
+	DebtorIBAN     string
+	DebtorName     string
+	CreditorIBAN   string
+	CreditorName   string
+	Amount         shared.Amount
+	IdempotencyKey string
+	Reference      string
+	ExecutionDate  time.Time // zero for immediate execution
+	Metadata       map[string]string
 }
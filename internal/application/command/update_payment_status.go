@@ -0,0 +1,8 @@
+package command
+
+// UpdatePaymentStatusCommand carries the raw, unvalidated inputs of a status
+// update, such as one reported by the bank.
+type UpdatePaymentStatusCommand struct {
+	PaymentID string
+	Status    string
+}
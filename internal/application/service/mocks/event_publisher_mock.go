@@ -0,0 +1,128 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: events.go
+//
+// Generated by this command:
+//
+//	mockgen -source=events.go -destination=../../application/service/mocks/event_publisher_mock.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	payment "paymentprocessor/internal/domain/payment"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockDomainEvent is a mock of DomainEvent interface.
+type MockDomainEvent struct {
+	ctrl     *gomock.Controller
+	recorder *MockDomainEventMockRecorder
+	isgomock struct{}
+}
+
+// MockDomainEventMockRecorder is the mock recorder for MockDomainEvent.
+type MockDomainEventMockRecorder struct {
+	mock *MockDomainEvent
+}
+
+// NewMockDomainEvent creates a new mock instance.
+func NewMockDomainEvent(ctrl *gomock.Controller) *MockDomainEvent {
+	mock := &MockDomainEvent{ctrl: ctrl}
+	mock.recorder = &MockDomainEventMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDomainEvent) EXPECT() *MockDomainEventMockRecorder {
+	return m.recorder
+}
+
+// EventType mocks base method.
+func (m *MockDomainEvent) EventType() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EventType")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// EventType indicates an expected call of EventType.
+func (mr *MockDomainEventMockRecorder) EventType() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EventType", reflect.TypeOf((*MockDomainEvent)(nil).EventType))
+}
+
+// OccurredAt mocks base method.
+func (m *MockDomainEvent) OccurredAt() time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OccurredAt")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// OccurredAt indicates an expected call of OccurredAt.
+func (mr *MockDomainEventMockRecorder) OccurredAt() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OccurredAt", reflect.TypeOf((*MockDomainEvent)(nil).OccurredAt))
+}
+
+// PaymentID mocks base method.
+func (m *MockDomainEvent) PaymentID() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PaymentID")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// PaymentID indicates an expected call of PaymentID.
+func (mr *MockDomainEventMockRecorder) PaymentID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PaymentID", reflect.TypeOf((*MockDomainEvent)(nil).PaymentID))
+}
+
+// MockEventPublisher is a mock of EventPublisher interface.
+type MockEventPublisher struct {
+	ctrl     *gomock.Controller
+	recorder *MockEventPublisherMockRecorder
+	isgomock struct{}
+}
+
+// MockEventPublisherMockRecorder is the mock recorder for MockEventPublisher.
+type MockEventPublisherMockRecorder struct {
+	mock *MockEventPublisher
+}
+
+// NewMockEventPublisher creates a new mock instance.
+func NewMockEventPublisher(ctrl *gomock.Controller) *MockEventPublisher {
+	mock := &MockEventPublisher{ctrl: ctrl}
+	mock.recorder = &MockEventPublisherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEventPublisher) EXPECT() *MockEventPublisherMockRecorder {
+	return m.recorder
+}
+
+// Publish mocks base method.
+func (m *MockEventPublisher) Publish(ctx context.Context, events ...payment.DomainEvent) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range events {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Publish", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockEventPublisherMockRecorder) Publish(ctx any, events ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, events...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockEventPublisher)(nil).Publish), varargs...)
+}
@@ -14,6 +14,7 @@ import (
 	payment "paymentprocessor/internal/domain/payment"
 	shared "paymentprocessor/internal/domain/shared"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -42,6 +43,96 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// Count mocks base method.
+func (m *MockRepository) Count(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockRepositoryMockRecorder) Count(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockRepository)(nil).Count), ctx)
+}
+
+// CountByStatus mocks base method.
+func (m *MockRepository) CountByStatus(ctx context.Context, status payment.PaymentStatus) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountByStatus", ctx, status)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountByStatus indicates an expected call of CountByStatus.
+func (mr *MockRepositoryMockRecorder) CountByStatus(ctx, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountByStatus", reflect.TypeOf((*MockRepository)(nil).CountByStatus), ctx, status)
+}
+
+// Exists mocks base method.
+func (m *MockRepository) Exists(ctx context.Context, id string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exists", ctx, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exists indicates an expected call of Exists.
+func (mr *MockRepositoryMockRecorder) Exists(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exists", reflect.TypeOf((*MockRepository)(nil).Exists), ctx, id)
+}
+
+// FindByAmountRange mocks base method.
+func (m *MockRepository) FindByAmountRange(ctx context.Context, currency shared.Currency, minCents, maxCents int64, limit int) ([]payment.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByAmountRange", ctx, currency, minCents, maxCents, limit)
+	ret0, _ := ret[0].([]payment.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByAmountRange indicates an expected call of FindByAmountRange.
+func (mr *MockRepositoryMockRecorder) FindByAmountRange(ctx, currency, minCents, maxCents, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByAmountRange", reflect.TypeOf((*MockRepository)(nil).FindByAmountRange), ctx, currency, minCents, maxCents, limit)
+}
+
+// FindByDateRange mocks base method.
+func (m *MockRepository) FindByDateRange(ctx context.Context, from, to time.Time, limit int) ([]payment.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByDateRange", ctx, from, to, limit)
+	ret0, _ := ret[0].([]payment.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByDateRange indicates an expected call of FindByDateRange.
+func (mr *MockRepositoryMockRecorder) FindByDateRange(ctx, from, to, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByDateRange", reflect.TypeOf((*MockRepository)(nil).FindByDateRange), ctx, from, to, limit)
+}
+
+// FindByDebtorIBANAndStatus mocks base method.
+func (m *MockRepository) FindByDebtorIBANAndStatus(ctx context.Context, iban shared.IBAN, status payment.PaymentStatus, limit int) ([]payment.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByDebtorIBANAndStatus", ctx, iban, status, limit)
+	ret0, _ := ret[0].([]payment.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByDebtorIBANAndStatus indicates an expected call of FindByDebtorIBANAndStatus.
+func (mr *MockRepositoryMockRecorder) FindByDebtorIBANAndStatus(ctx, iban, status, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByDebtorIBANAndStatus", reflect.TypeOf((*MockRepository)(nil).FindByDebtorIBANAndStatus), ctx, iban, status, limit)
+}
+
 // FindByID mocks base method.
 func (m *MockRepository) FindByID(ctx context.Context, id string) (payment.Payment, error) {
 	m.ctrl.T.Helper()
@@ -57,6 +148,21 @@ func (mr *MockRepositoryMockRecorder) FindByID(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByID", reflect.TypeOf((*MockRepository)(nil).FindByID), ctx, id)
 }
 
+// FindByIDs mocks base method.
+func (m *MockRepository) FindByIDs(ctx context.Context, ids []string) (map[string]payment.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByIDs", ctx, ids)
+	ret0, _ := ret[0].(map[string]payment.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByIDs indicates an expected call of FindByIDs.
+func (mr *MockRepositoryMockRecorder) FindByIDs(ctx, ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByIDs", reflect.TypeOf((*MockRepository)(nil).FindByIDs), ctx, ids)
+}
+
 // FindByIdempotencyKey mocks base method.
 func (m *MockRepository) FindByIdempotencyKey(ctx context.Context, key shared.IdempotencyKey) (payment.Payment, error) {
 	m.ctrl.T.Helper()
@@ -72,6 +178,109 @@ func (mr *MockRepositoryMockRecorder) FindByIdempotencyKey(ctx, key any) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).FindByIdempotencyKey), ctx, key)
 }
 
+// FindByStatus mocks base method.
+func (m *MockRepository) FindByStatus(ctx context.Context, status payment.PaymentStatus, limit int) ([]payment.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByStatus", ctx, status, limit)
+	ret0, _ := ret[0].([]payment.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByStatus indicates an expected call of FindByStatus.
+func (mr *MockRepositoryMockRecorder) FindByStatus(ctx, status, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByStatus", reflect.TypeOf((*MockRepository)(nil).FindByStatus), ctx, status, limit)
+}
+
+// FindDueForExecution mocks base method.
+func (m *MockRepository) FindDueForExecution(ctx context.Context, asOf time.Time, limit int) ([]payment.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDueForExecution", ctx, asOf, limit)
+	ret0, _ := ret[0].([]payment.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDueForExecution indicates an expected call of FindDueForExecution.
+func (mr *MockRepositoryMockRecorder) FindDueForExecution(ctx, asOf, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDueForExecution", reflect.TypeOf((*MockRepository)(nil).FindDueForExecution), ctx, asOf, limit)
+}
+
+// FindStatusHistory mocks base method.
+func (m *MockRepository) FindStatusHistory(ctx context.Context, id string) ([]payment.StatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindStatusHistory", ctx, id)
+	ret0, _ := ret[0].([]payment.StatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindStatusHistory indicates an expected call of FindStatusHistory.
+func (mr *MockRepositoryMockRecorder) FindStatusHistory(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindStatusHistory", reflect.TypeOf((*MockRepository)(nil).FindStatusHistory), ctx, id)
+}
+
+// HealthCheck mocks base method.
+func (m *MockRepository) HealthCheck(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HealthCheck", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HealthCheck indicates an expected call of HealthCheck.
+func (mr *MockRepositoryMockRecorder) HealthCheck(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HealthCheck", reflect.TypeOf((*MockRepository)(nil).HealthCheck), ctx)
+}
+
+// Iterate mocks base method.
+func (m *MockRepository) Iterate(ctx context.Context, filter payment.Filter, fn func(payment.Payment) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Iterate", ctx, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Iterate indicates an expected call of Iterate.
+func (mr *MockRepositoryMockRecorder) Iterate(ctx, filter, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Iterate", reflect.TypeOf((*MockRepository)(nil).Iterate), ctx, filter, fn)
+}
+
+// List mocks base method.
+func (m *MockRepository) List(ctx context.Context, offset, limit int) ([]payment.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, offset, limit)
+	ret0, _ := ret[0].([]payment.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockRepositoryMockRecorder) List(ctx, offset, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockRepository)(nil).List), ctx, offset, limit)
+}
+
+// ListAfter mocks base method.
+func (m *MockRepository) ListAfter(ctx context.Context, afterCreatedAt time.Time, afterID string, limit int) ([]payment.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAfter", ctx, afterCreatedAt, afterID, limit)
+	ret0, _ := ret[0].([]payment.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAfter indicates an expected call of ListAfter.
+func (mr *MockRepositoryMockRecorder) ListAfter(ctx, afterCreatedAt, afterID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAfter", reflect.TypeOf((*MockRepository)(nil).ListAfter), ctx, afterCreatedAt, afterID, limit)
+}
+
 // Save mocks base method.
 func (m *MockRepository) Save(ctx context.Context, arg1 payment.Payment) error {
 	m.ctrl.T.Helper()
@@ -86,16 +295,59 @@ func (mr *MockRepositoryMockRecorder) Save(ctx, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockRepository)(nil).Save), ctx, arg1)
 }
 
+// SaveBatch mocks base method.
+func (m *MockRepository) SaveBatch(ctx context.Context, payments []payment.Payment) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveBatch", ctx, payments)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveBatch indicates an expected call of SaveBatch.
+func (mr *MockRepositoryMockRecorder) SaveBatch(ctx, payments any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveBatch", reflect.TypeOf((*MockRepository)(nil).SaveBatch), ctx, payments)
+}
+
+// SoftDelete mocks base method.
+func (m *MockRepository) SoftDelete(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SoftDelete", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SoftDelete indicates an expected call of SoftDelete.
+func (mr *MockRepositoryMockRecorder) SoftDelete(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SoftDelete", reflect.TypeOf((*MockRepository)(nil).SoftDelete), ctx, id)
+}
+
 // UpdateStatus mocks base method.
-func (m *MockRepository) UpdateStatus(ctx context.Context, id string, status payment.PaymentStatus) error {
+func (m *MockRepository) UpdateStatus(ctx context.Context, id string, status payment.PaymentStatus, expectedVersion int, updatedAt time.Time) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateStatus", ctx, id, status)
+	ret := m.ctrl.Call(m, "UpdateStatus", ctx, id, status, expectedVersion, updatedAt)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateStatus indicates an expected call of UpdateStatus.
-func (mr *MockRepositoryMockRecorder) UpdateStatus(ctx, id, status any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) UpdateStatus(ctx, id, status, expectedVersion, updatedAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockRepository)(nil).UpdateStatus), ctx, id, status, expectedVersion, updatedAt)
+}
+
+// UpdateStatusBatch mocks base method.
+func (m *MockRepository) UpdateStatusBatch(ctx context.Context, ids []string, status payment.PaymentStatus) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStatusBatch", ctx, ids, status)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateStatusBatch indicates an expected call of UpdateStatusBatch.
+func (mr *MockRepositoryMockRecorder) UpdateStatusBatch(ctx, ids, status any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockRepository)(nil).UpdateStatus), ctx, id, status)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatusBatch", reflect.TypeOf((*MockRepository)(nil).UpdateStatusBatch), ctx, ids, status)
 }
@@ -3,55 +3,407 @@ package service
 import (
 	"context"
 	"errors"
-	"time"
+	"fmt"
+	"maps"
+	"strings"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"paymentprocessor/internal/application/command"
 	"paymentprocessor/internal/domain/payment"
 	"paymentprocessor/internal/domain/shared"
 )
 
 type PaymentService struct {
-	repository payment.Repository
+	repository  payment.Repository
+	unitOfWork  payment.UnitOfWork
+	clock       shared.Clock
+	idGenerator shared.IDGenerator
+	publisher   payment.EventPublisher
+	tracer      trace.Tracer
+	maxAmounts  map[string]shared.Amount // by currency code; absent means unlimited
+	allowed     map[string]bool          // IBAN country codes; empty allows all
+	blocked     map[string]bool          // IBAN country codes; wins over allowed
+
+	caseInsensitiveKeys bool
 }
 
-func NewPaymentService(repository payment.Repository) PaymentService {
+func NewPaymentService(
+	repository payment.Repository,
+	unitOfWork payment.UnitOfWork,
+	clock shared.Clock,
+	idGenerator shared.IDGenerator,
+	publisher payment.EventPublisher,
+) PaymentService {
 	return PaymentService{
-		repository: repository,
+		repository:  repository,
+		unitOfWork:  unitOfWork,
+		clock:       clock,
+		idGenerator: idGenerator,
+		publisher:   publisher,
+		tracer:      noop.NewTracerProvider().Tracer(""),
+	}
+}
+
+// WithTracer returns a copy of the service that records spans with tracer
+// instead of discarding them.
+func (s PaymentService) WithTracer(tracer trace.Tracer) PaymentService {
+	s.tracer = tracer
+	return s
+}
+
+// WithMaxPaymentAmount returns a copy of the service that rejects new payments
+// above limit with shared.ErrAmountExceedsLimit. The limit only applies to
+// payments in its own currency, so each currency gets its own call; a zero
+// limit lifts the cap for that currency.
+func (s PaymentService) WithMaxPaymentAmount(limit shared.Amount) PaymentService {
+	maxAmounts := maps.Clone(s.maxAmounts)
+	if maxAmounts == nil {
+		maxAmounts = make(map[string]shared.Amount)
+	}
+
+	if limit.IsZero() {
+		delete(maxAmounts, limit.Currency().Code())
+	} else {
+		maxAmounts[limit.Currency().Code()] = limit
+	}
+
+	s.maxAmounts = maxAmounts
+	return s
+}
+
+// CreatePayment validates the raw command, enforces idempotency and persists a
+// new pending payment. On key reuse the existing payment is returned together
+// with a payment.DuplicatePaymentError. If publishing the creation event fails, the
+// saved payment is still returned alongside the error.
+func (s PaymentService) CreatePayment(ctx context.Context, cmd command.CreatePaymentCommand) (_ payment.Payment, err error) {
+	ctx, span := s.tracer.Start(ctx, "PaymentService.CreatePayment",
+		trace.WithAttributes(attribute.String("payment.idempotency_key", cmd.IdempotencyKey)))
+	defer func() { endSpan(span, err) }()
+
+	debtorIBAN, err := shared.NewIBAN(cmd.DebtorIBAN)
+	if err != nil {
+		return payment.Payment{}, payment.IBANError{Party: payment.PartyDebtor, Err: err}
+	}
+
+	creditorIBAN, err := shared.NewIBAN(cmd.CreditorIBAN)
+	if err != nil {
+		return payment.Payment{}, payment.IBANError{Party: payment.PartyCreditor, Err: err}
+	}
+
+	if err := s.checkCountry(payment.PartyDebtor, debtorIBAN); err != nil {
+		return payment.Payment{}, err
+	}
+
+	if err := s.checkCountry(payment.PartyCreditor, creditorIBAN); err != nil {
+		return payment.Payment{}, err
+	}
+
+	// Amounts built by the shared constructors always have a currency; the
+	// zero Amount does not.
+	amount := cmd.Amount
+	if amount.Currency().Code() == "" {
+		return payment.Payment{}, fmt.Errorf("%w: amount has no currency", shared.ErrInvalidCurrency)
+	}
+
+	if err := s.checkAmountLimit(amount); err != nil {
+		return payment.Payment{}, err
+	}
+
+	idempotencyKey, err := s.ParseIdempotencyKey(cmd.IdempotencyKey)
+	if err != nil {
+		return payment.Payment{}, err
+	}
+
+	existingPayment, err := s.EnsureIdempotency(ctx, idempotencyKey)
+	if err != nil {
+		return existingPayment, err
+	}
+
+	id, err := s.idGenerator.NewID()
+	if err != nil {
+		return payment.Payment{}, err
+	}
+
+	now := s.clock.Now()
+	newPayment, err := payment.NewPayment(
+		id,
+		debtorIBAN,
+		cmd.DebtorName,
+		creditorIBAN,
+		cmd.CreditorName,
+		amount,
+		idempotencyKey,
+		cmd.Reference,
+		cmd.ExecutionDate,
+		cmd.Metadata,
+		now,
+		now,
+	)
+	if err != nil {
+		return payment.Payment{}, err
+	}
+	span.SetAttributes(attribute.String("payment.id", id))
+
+	if err := s.repository.Save(ctx, newPayment); err != nil {
+		if errors.Is(err, shared.ErrDuplicateIdempotencyKey) {
+			return s.recoverDuplicate(ctx, idempotencyKey, err)
+		}
+		return payment.Payment{}, err
 	}
+
+	if err := s.publishEvents(ctx, &newPayment); err != nil {
+		return newPayment, err
+	}
+
+	return newPayment, nil
 }
 
+// EnsureIdempotency returns a payment.DuplicatePaymentError carrying the
+// existing payment, which is also returned, if key has already been used.
 func (s PaymentService) EnsureIdempotency(ctx context.Context, key shared.IdempotencyKey) (payment.Payment, error) {
 	existingPayment, err := s.repository.FindByIdempotencyKey(ctx, key)
-	if err != nil && !errors.Is(err, shared.ErrPaymentNotFound) {
+	if errors.Is(err, shared.ErrPaymentNotFound) {
+		return payment.Payment{}, nil
+	}
+	if err != nil {
 		return payment.Payment{}, err
 	}
 
-	if err == nil {
-		return existingPayment, shared.ErrDuplicatePayment
+	return existingPayment, payment.DuplicatePaymentError{Existing: existingPayment}
+}
+
+// recoverDuplicate handles a Save that lost the race for key to a concurrent
+// create after EnsureIdempotency had passed. The winning payment is loaded and
+// returned with a payment.DuplicatePaymentError, as if the pre-check had seen
+// it; saveErr is returned if it cannot be found.
+func (s PaymentService) recoverDuplicate(ctx context.Context, key shared.IdempotencyKey, saveErr error) (payment.Payment, error) {
+	existingPayment, err := s.repository.FindByIdempotencyKey(ctx, key)
+	if err != nil {
+		return payment.Payment{}, saveErr
 	}
 
-	return payment.Payment{}, nil
+	return existingPayment, payment.DuplicatePaymentError{Existing: existingPayment}
+}
+
+// GetPayment returns the payment with the given id, or shared.ErrPaymentNotFound.
+func (s PaymentService) GetPayment(ctx context.Context, id string) (payment.Payment, error) {
+	return s.repository.FindByID(ctx, id)
+}
+
+// HealthCheck reports whether the payment repository can be queried, e.g. for
+// a readiness probe.
+func (s PaymentService) HealthCheck(ctx context.Context) error {
+	return s.repository.HealthCheck(ctx)
 }
 
-func (s PaymentService) ProcessStatusUpdate(ctx context.Context, paymentID string, newStatus payment.PaymentStatus, updatedAt time.Time) error {
-	existingPayment, err := s.repository.FindByID(ctx, paymentID)
+// UpdatePaymentStatus parses the raw status of cmd and applies it through
+// ProcessStatusUpdate. An unknown status fails with
+// shared.ErrInvalidPaymentStatus before the payment is loaded.
+func (s PaymentService) UpdatePaymentStatus(ctx context.Context, cmd command.UpdatePaymentStatusCommand) (payment.Payment, error) {
+	status, err := payment.ParseStatus(cmd.Status)
 	if err != nil {
-		return err
+		return payment.Payment{}, err
 	}
 
-	switch newStatus {
-	case payment.StatusProcessed:
-		err = existingPayment.MarkAsProcessed(updatedAt)
+	return s.ProcessStatusUpdate(ctx, cmd.PaymentID, status)
+}
+
+// ProcessStatusUpdate applies a bank status to a payment, stamping the change
+// with the service clock, and returns the updated payment. Events are published
+// only once the transaction has committed.
+func (s PaymentService) ProcessStatusUpdate(ctx context.Context, paymentID string, newStatus payment.PaymentStatus) (_ payment.Payment, err error) {
+	ctx, span := s.tracer.Start(ctx, "PaymentService.ProcessStatusUpdate", trace.WithAttributes(
+		attribute.String("payment.id", paymentID),
+		attribute.String("payment.status", string(newStatus)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	updatedAt := s.clock.Now()
+
+	var updatedPayment payment.Payment
+	err = s.unitOfWork.WithTransaction(ctx, func(repo payment.Repository) error {
+		existingPayment, err := repo.FindByID(ctx, paymentID)
+		if err != nil {
+			return err
+		}
+
+		switch newStatus {
+		case payment.StatusProcessing:
+			updatedPayment, err = existingPayment.MarkAsProcessing(updatedAt)
+			if err != nil {
+				return err
+			}
+		case payment.StatusProcessed:
+			updatedPayment, err = existingPayment.MarkAsProcessed(updatedAt)
+			if err != nil {
+				return err
+			}
+		case payment.StatusFailed:
+			updatedPayment, err = existingPayment.MarkAsFailed(updatedAt)
+			if err != nil {
+				return err
+			}
+		default:
+			return shared.ErrInvalidPaymentStatus
+		}
+
+		return repo.UpdateStatus(ctx, updatedPayment.ID(), updatedPayment.Status(), updatedPayment.Version(), updatedPayment.UpdatedAt())
+	})
+	if err != nil {
+		return payment.Payment{}, err
+	}
+
+	if err := s.publishEvents(ctx, &updatedPayment); err != nil {
+		return updatedPayment, err
+	}
+
+	return updatedPayment, nil
+}
+
+// ReversePayment charges back a processed payment. In one transaction the
+// original is marked reversed and a reversal moving the funds back is saved;
+// both are returned. Events are published only once the transaction has
+// committed.
+func (s PaymentService) ReversePayment(ctx context.Context, paymentID string) (_ payment.Payment, _ payment.Payment, err error) {
+	ctx, span := s.tracer.Start(ctx, "PaymentService.ReversePayment", trace.WithAttributes(
+		attribute.String("payment.id", paymentID),
+	))
+	defer func() { endSpan(span, err) }()
+
+	reversalID, err := s.idGenerator.NewID()
+	if err != nil {
+		return payment.Payment{}, payment.Payment{}, err
+	}
+
+	reversalKey, err := shared.GenerateIdempotencyKey()
+	if err != nil {
+		return payment.Payment{}, payment.Payment{}, err
+	}
+
+	updatedAt := s.clock.Now()
+
+	var reversed, reversal payment.Payment
+	err = s.unitOfWork.WithTransaction(ctx, func(repo payment.Repository) error {
+		original, err := repo.FindByID(ctx, paymentID)
 		if err != nil {
 			return err
 		}
-	case payment.StatusFailed:
-		err = existingPayment.MarkAsFailed(updatedAt)
+
+		reversed, reversal, err = original.Reverse(reversalID, reversalKey, updatedAt)
 		if err != nil {
 			return err
 		}
-	default:
-		return shared.ErrInvalidPaymentStatus
+
+		if err := repo.UpdateStatus(ctx, reversed.ID(), reversed.Status(), reversed.Version(), reversed.UpdatedAt()); err != nil {
+			return err
+		}
+
+		return repo.Save(ctx, reversal)
+	})
+	if err != nil {
+		return payment.Payment{}, payment.Payment{}, err
+	}
+
+	if err := s.publishEvents(ctx, &reversed); err != nil {
+		return reversed, reversal, err
 	}
 
-	return s.repository.Save(ctx, existingPayment)
+	if err := s.publishEvents(ctx, &reversal); err != nil {
+		return reversed, reversal, err
+	}
+
+	return reversed, reversal, nil
+}
+
+// WithCaseInsensitiveIdempotencyKeys returns a copy of the service that treats
+// idempotency keys differing only in case as the same key, by building them
+// with shared.NewIdempotencyKeyCaseInsensitive. Keys stored before this was
+// enabled are only matched if they were uppercase already.
+func (s PaymentService) WithCaseInsensitiveIdempotencyKeys() PaymentService {
+	s.caseInsensitiveKeys = true
+	return s
+}
+
+// ParseIdempotencyKey builds a client-supplied key the way the service
+// compares them, so that callers can store keys in the same form.
+func (s PaymentService) ParseIdempotencyKey(value string) (shared.IdempotencyKey, error) {
+	if s.caseInsensitiveKeys {
+		return shared.NewIdempotencyKeyCaseInsensitive(value)
+	}
+	return shared.NewIdempotencyKey(value)
+}
+
+// WithAllowedCountries returns a copy of the service that only creates payments
+// whose debtor and creditor IBANs are both from one of the given countries, as
+// ISO 3166 codes such as "DE". Without allowed countries every country is.
+func (s PaymentService) WithAllowedCountries(countries ...string) PaymentService {
+	s.allowed = countrySet(countries)
+	return s
+}
+
+// WithBlockedCountries returns a copy of the service that refuses payments to
+// or from any of the given countries, even ones that are also allowed.
+func (s PaymentService) WithBlockedCountries(countries ...string) PaymentService {
+	s.blocked = countrySet(countries)
+	return s
+}
+
+func countrySet(countries []string) map[string]bool {
+	set := make(map[string]bool, len(countries))
+	for _, country := range countries {
+		set[strings.ToUpper(strings.TrimSpace(country))] = true
+	}
+	return set
+}
+
+// checkCountry fails with a payment.IBANError for party wrapping
+// shared.ErrCountryNotAllowed unless the country of iban passes the blocked
+// and allowed lists.
+func (s PaymentService) checkCountry(party string, iban shared.IBAN) error {
+	country := iban.CountryCode()
+	if s.blocked[country] || (len(s.allowed) > 0 && !s.allowed[country]) {
+		return payment.IBANError{Party: party, Err: fmt.Errorf("%w: %s", shared.ErrCountryNotAllowed, country)}
+	}
+
+	return nil
+}
+
+// checkAmountLimit fails with shared.ErrAmountExceedsLimit if amount is above
+// the limit configured for its currency.
+func (s PaymentService) checkAmountLimit(amount shared.Amount) error {
+	limit, ok := s.maxAmounts[amount.Currency().Code()]
+	if ok && amount.GreaterThan(limit) {
+		return fmt.Errorf("%w: %s is above %s", shared.ErrAmountExceedsLimit, amount, limit)
+	}
+
+	return nil
+}
+
+// publishEvents drains the payment's recorded events and hands them to the
+// publisher.
+func (s PaymentService) publishEvents(ctx context.Context, p *payment.Payment) error {
+	events := p.PullEvents()
+	if len(events) == 0 {
+		return nil
+	}
+
+	if err := s.publisher.Publish(ctx, events...); err != nil {
+		return fmt.Errorf("failed to publish payment events: %w", err)
+	}
+
+	return nil
+}
+
+// endSpan ends span, marking it as failed when err is set. A duplicate payment
+// is an expected outcome of idempotent retries and is not treated as a failure.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, shared.ErrDuplicatePayment) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
 }
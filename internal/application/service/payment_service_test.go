@@ -2,15 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/mock/gomock"
 
+	"paymentprocessor/internal/application/command"
 	"paymentprocessor/internal/application/service/mocks"
 	"paymentprocessor/internal/domain/payment"
 	"paymentprocessor/internal/domain/shared"
+	"paymentprocessor/internal/infrastructure/persistence/sqlite"
+	"paymentprocessor/internal/infrastructure/system"
 )
 
 func TestPaymentService_EnsureIdempotency(t *testing.T) {
@@ -23,6 +33,7 @@ func TestPaymentService_EnsureIdempotency(t *testing.T) {
 	amount, _ := shared.NewAmount(100.50)
 	existingKey, _ := shared.NewIdempotencyKey("abc123XYZ0")
 	newKey, _ := shared.NewIdempotencyKey("xyz789ABC1")
+	lookupErr := errors.New("database unavailable")
 
 	now := time.Now()
 	existingPayment, _ := payment.NewPayment(
@@ -33,6 +44,9 @@ func TestPaymentService_EnsureIdempotency(t *testing.T) {
 		"Jane Smith",
 		amount,
 		existingKey,
+		"",
+		time.Time{},
+		nil,
 		now,
 		now,
 	)
@@ -66,6 +80,17 @@ func TestPaymentService_EnsureIdempotency(t *testing.T) {
 			expectPayment: false,
 			expectError:   nil,
 		},
+		{
+			name: "lookup failure",
+			key:  newKey,
+			setupMock: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().
+					FindByIdempotencyKey(ctx, newKey).
+					Return(payment.Payment{}, lookupErr)
+			},
+			expectPayment: false,
+			expectError:   lookupErr,
+		},
 	}
 
 	for _, tt := range tests {
@@ -75,15 +100,19 @@ func TestPaymentService_EnsureIdempotency(t *testing.T) {
 			defer ctrl.Finish()
 
 			mockRepo := mocks.NewMockRepository(ctrl)
-			service := NewPaymentService(mockRepo)
+			service := newTestPaymentService(mockRepo, mocks.NewMockUnitOfWork(ctrl), mocks.NewMockEventPublisher(ctrl))
 
 			tt.setupMock(mockRepo)
 
 			foundPayment, err := service.EnsureIdempotency(ctx, tt.key)
 			if tt.expectError != nil {
-				assert.Equal(t, tt.expectError, err, "expected specific error")
+				assert.ErrorIs(t, err, tt.expectError, "expected specific error")
 				if tt.expectPayment {
 					assert.Equal(t, existingPayment.ID(), foundPayment.ID(), "expected to find existing payment")
+
+					var duplicateErr payment.DuplicatePaymentError
+					require.ErrorAs(t, err, &duplicateErr)
+					assert.Equal(t, existingPayment.ID(), duplicateErr.Existing.ID(), "expected error to carry existing payment")
 				}
 			} else {
 				assert.NoError(t, err, "should not return error for new payment")
@@ -94,6 +123,64 @@ func TestPaymentService_EnsureIdempotency(t *testing.T) {
 	}
 }
 
+func TestPaymentService_GetPayment(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("returns the stored payment", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		debtorIBAN, _ := shared.NewIBAN("GB82WEST12345698765432")
+		creditorIBAN, _ := shared.NewIBAN("FR1420041010050500013M02606")
+		amount, _ := shared.NewAmount(100.50)
+		key, _ := shared.NewIdempotencyKey("abc123XYZ0")
+		stored, err := payment.NewPayment("payment-123", debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+			amount, key, "", time.Time{}, nil, testNow, testNow)
+		require.NoError(t, err)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().FindByID(ctx, "payment-123").Return(stored, nil)
+		service := newTestPaymentService(mockRepo, mocks.NewMockUnitOfWork(ctrl), mocks.NewMockEventPublisher(ctrl))
+
+		found, err := service.GetPayment(ctx, "payment-123")
+		require.NoError(t, err)
+		assert.True(t, stored.Equals(found), "expected the stored payment")
+	})
+
+	t.Run("returns not found for an unknown id", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().FindByID(ctx, "missing").Return(payment.Payment{}, shared.ErrPaymentNotFound)
+		service := newTestPaymentService(mockRepo, mocks.NewMockUnitOfWork(ctrl), mocks.NewMockEventPublisher(ctrl))
+
+		_, err := service.GetPayment(ctx, "missing")
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+	})
+}
+
+func TestPaymentService_HealthCheck(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	unavailable := errors.New("database is closed")
+	mockRepo := mocks.NewMockRepository(ctrl)
+	gomock.InOrder(
+		mockRepo.EXPECT().HealthCheck(ctx).Return(nil),
+		mockRepo.EXPECT().HealthCheck(ctx).Return(unavailable),
+	)
+	service := newTestPaymentService(mockRepo, mocks.NewMockUnitOfWork(ctrl), mocks.NewMockEventPublisher(ctrl))
+
+	assert.NoError(t, service.HealthCheck(ctx))
+	assert.ErrorIs(t, service.HealthCheck(ctx), unavailable)
+}
+
 func TestPaymentService_ProcessStatusUpdate(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
@@ -106,9 +193,9 @@ func TestPaymentService_ProcessStatusUpdate(t *testing.T) {
 
 	now := time.Now()
 
-	// Helper function to create a fresh payment for each test
+	// Helper function to create a fresh stored payment, already claimed for processing
 	createTestPayment := func() payment.Payment {
-		testPayment, _ := payment.NewPayment(
+		testPayment, _ := payment.ReconstitutePayment(
 			"payment-123",
 			debtorIBAN,
 			"John Doe",
@@ -116,6 +203,12 @@ func TestPaymentService_ProcessStatusUpdate(t *testing.T) {
 			"Jane Smith",
 			amount,
 			idempotencyKey,
+			"",
+			time.Time{},
+			nil,
+			"",
+			payment.StatusProcessing,
+			1,
 			now,
 			now,
 		)
@@ -123,11 +216,13 @@ func TestPaymentService_ProcessStatusUpdate(t *testing.T) {
 	}
 
 	tests := []struct {
-		name        string
-		paymentID   string
-		newStatus   payment.PaymentStatus
-		setupMock   func(mockRepo *mocks.MockRepository)
-		expectError bool
+		name          string
+		paymentID     string
+		newStatus     payment.PaymentStatus
+		setupMock     func(mockRepo *mocks.MockRepository)
+		expectError   bool
+		expectedErr   error
+		expectedEvent string
 	}{
 		{
 			name:      "valid transition to processed",
@@ -135,18 +230,14 @@ func TestPaymentService_ProcessStatusUpdate(t *testing.T) {
 			newStatus: payment.StatusProcessed,
 			setupMock: func(mockRepo *mocks.MockRepository) {
 				mockRepo.EXPECT().
-					FindByID(ctx, "payment-123").
+					FindByID(gomock.Any(), "payment-123").
 					Return(createTestPayment(), nil)
 				mockRepo.EXPECT().
-					Save(ctx, gomock.Cond(func(p interface{}) bool {
-						if pmt, ok := p.(payment.Payment); ok {
-							return pmt.ID() == "payment-123" && pmt.Status() == payment.StatusProcessed
-						}
-						return false
-					})).
+					UpdateStatus(gomock.Any(), "payment-123", payment.StatusProcessed, 1, testNow).
 					Return(nil)
 			},
-			expectError: false,
+			expectError:   false,
+			expectedEvent: payment.EventPaymentProcessed,
 		},
 		{
 			name:      "valid transition to failed",
@@ -154,18 +245,26 @@ func TestPaymentService_ProcessStatusUpdate(t *testing.T) {
 			newStatus: payment.StatusFailed,
 			setupMock: func(mockRepo *mocks.MockRepository) {
 				mockRepo.EXPECT().
-					FindByID(ctx, "payment-123").
+					FindByID(gomock.Any(), "payment-123").
 					Return(createTestPayment(), nil)
 				mockRepo.EXPECT().
-					Save(ctx, gomock.Cond(func(p interface{}) bool {
-						if pmt, ok := p.(payment.Payment); ok {
-							return pmt.ID() == "payment-123" && pmt.Status() == payment.StatusFailed
-						}
-						return false
-					})).
+					UpdateStatus(gomock.Any(), "payment-123", payment.StatusFailed, 1, testNow).
 					Return(nil)
 			},
-			expectError: false,
+			expectError:   false,
+			expectedEvent: payment.EventPaymentFailed,
+		},
+		{
+			name:      "rejects claiming a payment already in processing",
+			paymentID: "payment-123",
+			newStatus: payment.StatusProcessing,
+			setupMock: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().
+					FindByID(gomock.Any(), "payment-123").
+					Return(createTestPayment(), nil)
+			},
+			expectError: true,
+			expectedErr: shared.ErrInvalidStatusTransition,
 		},
 		{
 			name:      "payment not found",
@@ -173,10 +272,11 @@ func TestPaymentService_ProcessStatusUpdate(t *testing.T) {
 			newStatus: payment.StatusProcessed,
 			setupMock: func(mockRepo *mocks.MockRepository) {
 				mockRepo.EXPECT().
-					FindByID(ctx, "nonexistent").
+					FindByID(gomock.Any(), "nonexistent").
 					Return(payment.Payment{}, shared.ErrPaymentNotFound)
 			},
 			expectError: true,
+			expectedErr: shared.ErrPaymentNotFound,
 		},
 		{
 			name:      "invalid status",
@@ -184,9 +284,9 @@ func TestPaymentService_ProcessStatusUpdate(t *testing.T) {
 			newStatus: payment.PaymentStatus("INVALID"),
 			setupMock: func(mockRepo *mocks.MockRepository) {
 				mockRepo.EXPECT().
-					FindByID(ctx, "payment-123").
+					FindByID(gomock.Any(), "payment-123").
 					Return(createTestPayment(), nil)
-				// No Save call expected because the service should return error before calling Save
+				// No UpdateStatus call expected because the service should return error before persisting
 			},
 			expectError: true,
 		},
@@ -199,29 +299,737 @@ func TestPaymentService_ProcessStatusUpdate(t *testing.T) {
 			defer ctrl.Finish()
 
 			mockRepo := mocks.NewMockRepository(ctrl)
-			service := NewPaymentService(mockRepo)
+			mockUnitOfWork := mocks.NewMockUnitOfWork(ctrl)
+			mockPublisher := mocks.NewMockEventPublisher(ctrl)
+			service := newTestPaymentService(mockRepo, mockUnitOfWork, mockPublisher)
 
+			expectTransaction(mockUnitOfWork, mockRepo)
 			tt.setupMock(mockRepo)
+			if tt.expectedEvent != "" {
+				expectPublished(mockPublisher, tt.expectedEvent, tt.paymentID)
+			}
 
-			err := service.ProcessStatusUpdate(ctx, tt.paymentID, tt.newStatus, time.Now())
+			updated, err := service.ProcessStatusUpdate(ctx, tt.paymentID, tt.newStatus)
 
 			if tt.expectError {
 				assert.Error(t, err, "expected error but got none")
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr, "expected specific error")
+				}
 			} else {
 				assert.NoError(t, err, "unexpected error")
+				assert.Equal(t, tt.newStatus, updated.Status(), "status should match requested status")
+				assert.True(t, updated.UpdatedAt().Equal(testNow), "updatedAt should come from the injected clock")
 			}
 		})
 	}
 }
 
+func TestPaymentService_ProcessStatusUpdate_RejectsIllegalTransitionInDatabase(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	config := sqlite.DefaultInMemoryConfig()
+	config.DatabasePath = t.Name()
+	db, err := sqlite.NewDatabase(config)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Initialize(ctx))
+
+	clock := system.NewMockClock(testNow)
+	repo := sqlite.NewPaymentRepository(db, clock)
+	service := NewPaymentService(repo, repo, clock, fixedIDGenerator{}, mocks.NewMockEventPublisher(ctrl))
+
+	debtorIBAN, _ := shared.NewIBAN("GB82WEST12345698765432")
+	creditorIBAN, _ := shared.NewIBAN("FR1420041010050500013M02606")
+	amount, _ := shared.NewAmount(100.50)
+	key, _ := shared.NewIdempotencyKey("abc123XYZ0")
+	pending, err := payment.NewPayment("payment-123", debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+		amount, key, "", time.Time{}, nil, testNow, testNow)
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(ctx, pending))
+
+	clock.Advance(time.Hour)
+
+	// PENDING must be claimed as PROCESSING before it can be marked PROCESSED
+	_, err = service.ProcessStatusUpdate(ctx, pending.ID(), payment.StatusProcessed)
+	assert.ErrorIs(t, err, shared.ErrInvalidStatusTransition, "expected illegal transition to be rejected")
+
+	stored, err := repo.FindByID(ctx, pending.ID())
+	require.NoError(t, err)
+	assert.True(t, pending.Equals(stored), "stored payment should be unchanged")
+}
+
+func TestPaymentService_ReversePayment(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// newService returns a service over a fresh database holding a pending
+	// payment, and that payment.
+	newService := func(t *testing.T, publisher *mocks.MockEventPublisher) (PaymentService, sqlite.PaymentRepository, payment.Payment) {
+		config := sqlite.DefaultInMemoryConfig()
+		config.DatabasePath = t.Name()
+		db, err := sqlite.NewDatabase(config)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		require.NoError(t, db.Initialize(ctx))
+
+		clock := system.NewMockClock(testNow)
+		repo := sqlite.NewPaymentRepository(db, clock)
+		service := NewPaymentService(repo, repo, clock, fixedIDGenerator{}, publisher)
+
+		debtorIBAN, _ := shared.NewIBAN("GB82WEST12345698765432")
+		creditorIBAN, _ := shared.NewIBAN("FR1420041010050500013M02606")
+		amount, _ := shared.NewAmount(100.50)
+		key, _ := shared.NewIdempotencyKey("abc123XYZ0")
+		pending, err := payment.NewPayment("payment-123", debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+			amount, key, "", time.Time{}, nil, testNow, testNow)
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, pending))
+
+		return service, repo, pending
+	}
+
+	t.Run("marks the original reversed and saves the reversal", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockPublisher := mocks.NewMockEventPublisher(ctrl)
+		expectPublished(mockPublisher, payment.EventPaymentProcessed, "payment-123")
+		service, repo, pending := newService(t, mockPublisher)
+		_, err := service.ProcessStatusUpdate(ctx, pending.ID(), payment.StatusProcessing)
+		require.NoError(t, err)
+		_, err = service.ProcessStatusUpdate(ctx, pending.ID(), payment.StatusProcessed)
+		require.NoError(t, err)
+
+		expectPublished(mockPublisher, payment.EventPaymentReversed, pending.ID())
+		expectPublished(mockPublisher, payment.EventPaymentCreated, testID)
+		reversed, reversal, err := service.ReversePayment(ctx, pending.ID())
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusReversed, reversed.Status())
+
+		storedOriginal, err := repo.FindByID(ctx, pending.ID())
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusReversed, storedOriginal.Status())
+
+		storedReversal, err := repo.FindByID(ctx, reversal.ID())
+		require.NoError(t, err)
+		assert.True(t, reversal.Equals(storedReversal), "stored reversal should match the returned one")
+		assert.Equal(t, pending.ID(), storedReversal.ReversalOf())
+		assert.True(t, storedReversal.DebtorIBAN().Equals(pending.CreditorIBAN()))
+		assert.True(t, storedReversal.CreditorIBAN().Equals(pending.DebtorIBAN()))
+	})
+
+	t.Run("rejects a pending payment without saving anything", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		service, repo, pending := newService(t, mocks.NewMockEventPublisher(ctrl))
+
+		_, _, err := service.ReversePayment(ctx, pending.ID())
+		assert.ErrorIs(t, err, shared.ErrInvalidStatusTransition)
+
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count, "no reversal should be saved")
+	})
+}
+
+func TestPaymentService_UpdatePaymentStatus(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	debtorIBAN, _ := shared.NewIBAN("GB82WEST12345698765432")
+	creditorIBAN, _ := shared.NewIBAN("FR1420041010050500013M02606")
+	amount, _ := shared.NewAmount(100.50)
+	idempotencyKey, _ := shared.NewIdempotencyKey("abc123XYZ0")
+	processing, _ := payment.ReconstitutePayment("payment-123", debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+		amount, idempotencyKey, "", time.Time{}, nil, "", payment.StatusProcessing, 1, testNow, testNow)
+
+	t.Run("accepts a lowercase status", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockUnitOfWork := mocks.NewMockUnitOfWork(ctrl)
+		mockPublisher := mocks.NewMockEventPublisher(ctrl)
+		service := newTestPaymentService(mockRepo, mockUnitOfWork, mockPublisher)
+
+		expectTransaction(mockUnitOfWork, mockRepo)
+		mockRepo.EXPECT().FindByID(gomock.Any(), "payment-123").Return(processing, nil)
+		mockRepo.EXPECT().UpdateStatus(gomock.Any(), "payment-123", payment.StatusProcessed, 1, testNow).Return(nil)
+		expectPublished(mockPublisher, payment.EventPaymentProcessed, "payment-123")
+
+		updated, err := service.UpdatePaymentStatus(ctx, command.UpdatePaymentStatusCommand{
+			PaymentID: "payment-123",
+			Status:    "processed",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusProcessed, updated.Status())
+	})
+
+	t.Run("rejects an unknown status before loading the payment", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		// No repository or unit of work calls are expected
+		service := newTestPaymentService(mocks.NewMockRepository(ctrl), mocks.NewMockUnitOfWork(ctrl),
+			mocks.NewMockEventPublisher(ctrl))
+
+		_, err := service.UpdatePaymentStatus(ctx, command.UpdatePaymentStatusCommand{
+			PaymentID: "payment-123",
+			Status:    "settled",
+		})
+
+		assert.ErrorIs(t, err, shared.ErrInvalidPaymentStatus)
+	})
+}
+
 func TestNewPaymentService(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockRepo := mocks.NewMockRepository(ctrl)
-	service := NewPaymentService(mockRepo)
+	mockUnitOfWork := mocks.NewMockUnitOfWork(ctrl)
+	mockPublisher := mocks.NewMockEventPublisher(ctrl)
+	service := NewPaymentService(mockRepo, mockUnitOfWork, system.NewMockClock(testNow), fixedIDGenerator{}, mockPublisher)
 
 	// Test that service is created as value type
 	assert.NotNil(t, service.repository, "expected repository to be set")
+	assert.NotNil(t, service.unitOfWork, "expected unit of work to be set")
+	assert.NotNil(t, service.clock, "expected clock to be set")
+	assert.NotNil(t, service.idGenerator, "expected id generator to be set")
+	assert.NotNil(t, service.publisher, "expected event publisher to be set")
+}
+
+func TestPaymentService_CreatePayment(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	validCommand := command.CreatePaymentCommand{
+		DebtorIBAN:     "GB82 WEST 1234 5698 7654 32",
+		DebtorName:     "John Doe",
+		CreditorIBAN:   "FR1420041010050500013M02606",
+		CreditorName:   "Jane Smith",
+		Amount:         parseAmount(t, "42.99"),
+		IdempotencyKey: "abc123XYZ0",
+		Reference:      "Invoice 42",
+	}
+
+	withCommand := func(mutate func(cmd *command.CreatePaymentCommand)) command.CreatePaymentCommand {
+		cmd := validCommand
+		mutate(&cmd)
+		return cmd
+	}
+
+	debtorIBAN, _ := shared.NewIBAN(validCommand.DebtorIBAN)
+	creditorIBAN, _ := shared.NewIBAN(validCommand.CreditorIBAN)
+	amount := validCommand.Amount
+	key, _ := shared.NewIdempotencyKey(validCommand.IdempotencyKey)
+	existingPayment, _ := payment.NewPayment(
+		"existing-payment",
+		debtorIBAN,
+		"John Doe",
+		creditorIBAN,
+		"Jane Smith",
+		amount,
+		key,
+		"",
+		time.Time{},
+		nil,
+		testNow,
+		testNow,
+	)
+
+	tests := []struct {
+		name          string
+		cmd           command.CreatePaymentCommand
+		setupMock     func(mockRepo *mocks.MockRepository)
+		expectedErr   error
+		expectedID    string
+		expectedEvent string
+	}{
+		{
+			name: "creates a new payment",
+			cmd:  validCommand,
+			setupMock: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().
+					FindByIdempotencyKey(gomock.Any(), key).
+					Return(payment.Payment{}, shared.ErrPaymentNotFound)
+				mockRepo.EXPECT().
+					Save(gomock.Any(), gomock.Cond(func(p interface{}) bool {
+						pmt, ok := p.(payment.Payment)
+						return ok && pmt.ID() == testID && pmt.Status() == payment.StatusPending &&
+							pmt.CreatedAt().Equal(testNow) && pmt.Amount().Equals(amount) &&
+							pmt.Reference() == "Invoice 42"
+					})).
+					Return(nil)
+			},
+			expectedID:    testID,
+			expectedEvent: payment.EventPaymentCreated,
+		},
+		{
+			name: "returns existing payment on duplicate key",
+			cmd:  validCommand,
+			setupMock: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().
+					FindByIdempotencyKey(gomock.Any(), key).
+					Return(existingPayment, nil)
+			},
+			expectedErr: shared.ErrDuplicatePayment,
+			expectedID:  "existing-payment",
+		},
+		{
+			name: "returns the existing payment when a concurrent create wins the key",
+			cmd:  validCommand,
+			setupMock: func(mockRepo *mocks.MockRepository) {
+				// The pre-check passes, then the other create commits first.
+				mockRepo.EXPECT().
+					FindByIdempotencyKey(gomock.Any(), key).
+					Return(payment.Payment{}, shared.ErrPaymentNotFound)
+				mockRepo.EXPECT().
+					Save(gomock.Any(), gomock.Any()).
+					Return(shared.ErrDuplicateIdempotencyKey)
+				mockRepo.EXPECT().
+					FindByIdempotencyKey(gomock.Any(), key).
+					Return(existingPayment, nil)
+			},
+			expectedErr: shared.ErrDuplicatePayment,
+			expectedID:  "existing-payment",
+		},
+		{
+			name: "keeps the save error when the winning payment cannot be loaded",
+			cmd:  validCommand,
+			setupMock: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().
+					FindByIdempotencyKey(gomock.Any(), key).
+					Return(payment.Payment{}, shared.ErrPaymentNotFound)
+				mockRepo.EXPECT().
+					Save(gomock.Any(), gomock.Any()).
+					Return(shared.ErrDuplicateIdempotencyKey)
+				mockRepo.EXPECT().
+					FindByIdempotencyKey(gomock.Any(), key).
+					Return(payment.Payment{}, shared.ErrPaymentNotFound)
+			},
+			expectedErr: shared.ErrDuplicateIdempotencyKey,
+		},
+		{
+			name:        "rejects invalid debtor IBAN",
+			cmd:         withCommand(func(cmd *command.CreatePaymentCommand) { cmd.DebtorIBAN = "GB00WEST12345698765432" }),
+			setupMock:   func(mockRepo *mocks.MockRepository) {},
+			expectedErr: shared.ErrInvalidIBAN,
+		},
+		{
+			name:        "rejects invalid creditor IBAN",
+			cmd:         withCommand(func(cmd *command.CreatePaymentCommand) { cmd.CreditorIBAN = "invalid" }),
+			setupMock:   func(mockRepo *mocks.MockRepository) {},
+			expectedErr: shared.ErrInvalidIBAN,
+		},
+		{
+			name:        "rejects an amount without a currency",
+			cmd:         withCommand(func(cmd *command.CreatePaymentCommand) { cmd.Amount = shared.Amount{} }),
+			setupMock:   func(mockRepo *mocks.MockRepository) {},
+			expectedErr: shared.ErrInvalidCurrency,
+		},
+		{
+			name: "rejects zero amount",
+			cmd:  withCommand(func(cmd *command.CreatePaymentCommand) { cmd.Amount = parseAmount(t, "0.00") }),
+			setupMock: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().
+					FindByIdempotencyKey(gomock.Any(), key).
+					Return(payment.Payment{}, shared.ErrPaymentNotFound)
+			},
+			expectedErr: shared.ErrZeroAmount,
+		},
+		{
+			name: "rejects over-length reference",
+			cmd:  withCommand(func(cmd *command.CreatePaymentCommand) { cmd.Reference = strings.Repeat("x", 141) }),
+			setupMock: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().
+					FindByIdempotencyKey(gomock.Any(), key).
+					Return(payment.Payment{}, shared.ErrPaymentNotFound)
+			},
+			expectedErr: shared.ErrInvalidReference,
+		},
+		{
+			name:        "rejects invalid idempotency key",
+			cmd:         withCommand(func(cmd *command.CreatePaymentCommand) { cmd.IdempotencyKey = "short" }),
+			setupMock:   func(mockRepo *mocks.MockRepository) {},
+			expectedErr: shared.ErrInvalidIdempotencyKey,
+		},
+		{
+			name: "rejects short debtor name",
+			cmd:  withCommand(func(cmd *command.CreatePaymentCommand) { cmd.DebtorName = "Jo" }),
+			setupMock: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().
+					FindByIdempotencyKey(gomock.Any(), key).
+					Return(payment.Payment{}, shared.ErrPaymentNotFound)
+			},
+			expectedErr: shared.ErrInvalidDebtorName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			mockPublisher := mocks.NewMockEventPublisher(ctrl)
+			service := newTestPaymentService(mockRepo, mocks.NewMockUnitOfWork(ctrl), mockPublisher)
+
+			tt.setupMock(mockRepo)
+			if tt.expectedEvent != "" {
+				expectPublished(mockPublisher, tt.expectedEvent, tt.expectedID)
+			}
+
+			created, err := service.CreatePayment(ctx, tt.cmd)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr, "expected specific error")
+			} else {
+				assert.NoError(t, err, "unexpected error")
+			}
+			assert.Equal(t, tt.expectedID, created.ID(), "unexpected payment ID")
+		})
+	}
+}
+
+func TestPaymentService_CreatePayment_AmountLimit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	eurLimit, err := shared.NewAmount(15000)
+	require.NoError(t, err)
+	noLimit, err := shared.NewAmount(0)
+	require.NoError(t, err)
+	usd, err := shared.NewCurrency("USD")
+	require.NoError(t, err)
+	usdLimit, err := shared.NewAmountWithCurrency(100, usd)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		limits      []shared.Amount
+		amount      string
+		expectedErr error
+	}{
+		{name: "accepts an amount just below the limit", limits: []shared.Amount{eurLimit}, amount: "14999.99"},
+		{name: "accepts an amount at the limit", limits: []shared.Amount{eurLimit}, amount: "15000"},
+		{name: "rejects an amount above the limit", limits: []shared.Amount{eurLimit}, amount: "15000.01", expectedErr: shared.ErrAmountExceedsLimit},
+		{name: "treats a zero limit as unlimited", limits: []shared.Amount{noLimit}, amount: "1000000"},
+		{name: "lifts a limit set to zero", limits: []shared.Amount{eurLimit, noLimit}, amount: "1000000"},
+		{name: "ignores limits in other currencies", limits: []shared.Amount{usdLimit}, amount: "15000.01"},
+		{name: "applies the limit of the amount's currency", limits: []shared.Amount{eurLimit, usdLimit}, amount: "15000.01 USD", expectedErr: shared.ErrAmountExceedsLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			mockPublisher := mocks.NewMockEventPublisher(ctrl)
+			service := newTestPaymentService(mockRepo, mocks.NewMockUnitOfWork(ctrl), mockPublisher)
+			for _, limit := range tt.limits {
+				service = service.WithMaxPaymentAmount(limit)
+			}
+
+			if tt.expectedErr == nil {
+				mockRepo.EXPECT().FindByIdempotencyKey(gomock.Any(), gomock.Any()).Return(payment.Payment{}, shared.ErrPaymentNotFound)
+				mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+				expectPublished(mockPublisher, payment.EventPaymentCreated, testID)
+			}
+
+			_, err := service.CreatePayment(ctx, command.CreatePaymentCommand{
+				DebtorIBAN:     "GB82WEST12345698765432",
+				DebtorName:     "John Doe",
+				CreditorIBAN:   "FR1420041010050500013M02606",
+				CreditorName:   "Jane Smith",
+				Amount:         parseAmount(t, tt.amount),
+				IdempotencyKey: "abc123XYZ0",
+			})
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPaymentService_CreatePayment_Countries(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// The debtor IBAN is from GB, the creditor IBAN from FR.
+	tests := []struct {
+		name          string
+		allowed       []string
+		blocked       []string
+		rejectedParty string
+	}{
+		{name: "allows every country by default"},
+		{name: "allows listed countries", allowed: []string{"GB", "FR"}},
+		{name: "matches country codes case-insensitively", allowed: []string{"gb", " fr "}},
+		{name: "rejects an unlisted debtor country", allowed: []string{"FR"}, rejectedParty: payment.PartyDebtor},
+		{name: "rejects an unlisted creditor country", allowed: []string{"GB", "DE"}, rejectedParty: payment.PartyCreditor},
+		{name: "allows countries that are not blocked", blocked: []string{"DE"}},
+		{name: "rejects a blocked debtor country", blocked: []string{"GB"}, rejectedParty: payment.PartyDebtor},
+		{name: "rejects a blocked creditor country", blocked: []string{"FR"}, rejectedParty: payment.PartyCreditor},
+		{name: "lets the block list win over the allow list", allowed: []string{"GB", "FR"}, blocked: []string{"FR"}, rejectedParty: payment.PartyCreditor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			mockPublisher := mocks.NewMockEventPublisher(ctrl)
+			service := newTestPaymentService(mockRepo, mocks.NewMockUnitOfWork(ctrl), mockPublisher).
+				WithAllowedCountries(tt.allowed...).
+				WithBlockedCountries(tt.blocked...)
+
+			if tt.rejectedParty == "" {
+				mockRepo.EXPECT().FindByIdempotencyKey(gomock.Any(), gomock.Any()).Return(payment.Payment{}, shared.ErrPaymentNotFound)
+				mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+				expectPublished(mockPublisher, payment.EventPaymentCreated, testID)
+			}
+
+			_, err := service.CreatePayment(ctx, command.CreatePaymentCommand{
+				DebtorIBAN:     "GB82WEST12345698765432",
+				DebtorName:     "John Doe",
+				CreditorIBAN:   "FR1420041010050500013M02606",
+				CreditorName:   "Jane Smith",
+				Amount:         parseAmount(t, "42.99"),
+				IdempotencyKey: "abc123XYZ0",
+			})
+
+			if tt.rejectedParty != "" {
+				assert.ErrorIs(t, err, shared.ErrCountryNotAllowed)
+				var ibanErr payment.IBANError
+				require.ErrorAs(t, err, &ibanErr)
+				assert.Equal(t, tt.rejectedParty, ibanErr.Party)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPaymentService_CreatePayment_IdempotencyKeyCase(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		expectedKey     string
+	}{
+		{name: "keeps the key as sent by default", expectedKey: "abc123XYZ0"},
+		{name: "uppercases the key when case-insensitive", caseInsensitive: true, expectedKey: "ABC123XYZ0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			mockPublisher := mocks.NewMockEventPublisher(ctrl)
+			service := newTestPaymentService(mockRepo, mocks.NewMockUnitOfWork(ctrl), mockPublisher)
+			if tt.caseInsensitive {
+				service = service.WithCaseInsensitiveIdempotencyKeys()
+			}
+
+			key, err := shared.NewIdempotencyKey(tt.expectedKey)
+			require.NoError(t, err)
+			mockRepo.EXPECT().FindByIdempotencyKey(gomock.Any(), key).Return(payment.Payment{}, shared.ErrPaymentNotFound)
+			mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+			expectPublished(mockPublisher, payment.EventPaymentCreated, testID)
+
+			created, err := service.CreatePayment(ctx, command.CreatePaymentCommand{
+				DebtorIBAN:     "GB82WEST12345698765432",
+				DebtorName:     "John Doe",
+				CreditorIBAN:   "FR1420041010050500013M02606",
+				CreditorName:   "Jane Smith",
+				Amount:         parseAmount(t, "42.99"),
+				IdempotencyKey: "abc123XYZ0",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedKey, created.IdempotencyKey().Value())
+		})
+	}
+
+	t.Run("treats keys differing in case as duplicates when case-insensitive", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+
+		config := sqlite.DefaultInMemoryConfig()
+		db, err := sqlite.NewDatabase(config)
+		require.NoError(t, err)
+		defer db.Close()
+		require.NoError(t, db.Initialize(ctx))
+
+		clock := system.NewMockClock(testNow)
+		repo := sqlite.NewPaymentRepository(db, clock)
+		publisher := mocks.NewMockEventPublisher(ctrl)
+		publisher.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		service := NewPaymentService(repo, repo, clock, system.NewULIDGenerator(clock), publisher).
+			WithCaseInsensitiveIdempotencyKeys()
+
+		cmd := command.CreatePaymentCommand{
+			DebtorIBAN:     "GB82WEST12345698765432",
+			DebtorName:     "John Doe",
+			CreditorIBAN:   "FR1420041010050500013M02606",
+			CreditorName:   "Jane Smith",
+			Amount:         parseAmount(t, "42.99"),
+			IdempotencyKey: "abc123XYZ0",
+		}
+		created, err := service.CreatePayment(ctx, cmd)
+		require.NoError(t, err)
+
+		cmd.IdempotencyKey = "ABC123xyz0"
+		existing, err := service.CreatePayment(ctx, cmd)
+		assert.ErrorIs(t, err, shared.ErrDuplicatePayment)
+		assert.Equal(t, created.ID(), existing.ID())
+	})
+}
+
+func TestPaymentService_CreatePayment_PublishFailure(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	mockPublisher := mocks.NewMockEventPublisher(ctrl)
+	service := newTestPaymentService(mockRepo, mocks.NewMockUnitOfWork(ctrl), mockPublisher)
+
+	publishErr := errors.New("broker unavailable")
+	mockRepo.EXPECT().FindByIdempotencyKey(gomock.Any(), gomock.Any()).Return(payment.Payment{}, shared.ErrPaymentNotFound)
+	mockRepo.EXPECT().Save(gomock.Any(), gomock.Any()).Return(nil)
+	mockPublisher.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(publishErr)
+
+	created, err := service.CreatePayment(ctx, command.CreatePaymentCommand{
+		DebtorIBAN:     "GB82WEST12345698765432",
+		DebtorName:     "John Doe",
+		CreditorIBAN:   "FR1420041010050500013M02606",
+		CreditorName:   "Jane Smith",
+		Amount:         parseAmount(t, "42.99"),
+		IdempotencyKey: "abc123XYZ0",
+	})
+
+	assert.ErrorIs(t, err, publishErr, "expected publish error")
+	assert.Equal(t, testID, created.ID(), "saved payment should still be returned")
+}
+
+func TestPaymentService_CreatePayment_Tracing(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	config := sqlite.DefaultInMemoryConfig()
+	config.DatabasePath = t.Name()
+	db, err := sqlite.NewDatabase(config)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Initialize(ctx))
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	clock := system.NewMockClock(testNow)
+	repo := sqlite.NewPaymentRepository(db, clock).WithTracer(tracer)
+	mockPublisher := mocks.NewMockEventPublisher(ctrl)
+	mockPublisher.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil)
+	service := NewPaymentService(repo, repo, clock, fixedIDGenerator{}, mockPublisher).WithTracer(tracer)
+
+	_, err = service.CreatePayment(ctx, command.CreatePaymentCommand{
+		DebtorIBAN:     "GB82WEST12345698765432",
+		DebtorName:     "John Doe",
+		CreditorIBAN:   "FR1420041010050500013M02606",
+		CreditorName:   "Jane Smith",
+		Amount:         parseAmount(t, "42.99"),
+		IdempotencyKey: "abc123XYZ0",
+	})
+	require.NoError(t, err)
+
+	_, err = service.CreatePayment(ctx, command.CreatePaymentCommand{DebtorIBAN: "invalid", IdempotencyKey: "abc123XYZ1"})
+	require.ErrorIs(t, err, shared.ErrInvalidIBAN)
+
+	spans := recorder.Ended()
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name()
+	}
+	assert.Equal(t, []string{
+		"PaymentRepository.FindByIdempotencyKey",
+		"PaymentRepository.Save",
+		"PaymentService.CreatePayment",
+		"PaymentService.CreatePayment",
+	}, names)
+
+	lookup, save, created, rejected := spans[0], spans[1], spans[2], spans[3]
+	assert.Equal(t, codes.Unset, lookup.Status().Code, "a missing idempotency key is not a failure")
+	assert.Equal(t, created.SpanContext().SpanID(), save.Parent().SpanID(), "expected repository spans to be children of the service span")
+	assert.Equal(t, codes.Unset, created.Status().Code)
+	assert.Contains(t, created.Attributes(), attribute.String("payment.id", testID))
+	assert.Contains(t, created.Attributes(), attribute.String("payment.idempotency_key", "abc123XYZ0"))
+	assert.Equal(t, codes.Error, rejected.Status().Code)
+}
+
+const testID = "01JJ3V9Z8ZQ0000000000000AB"
+
+var testNow = time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+
+type fixedIDGenerator struct{}
+
+func (fixedIDGenerator) NewID() (string, error) { return testID, nil }
+
+// newTestPaymentService wires a service with deterministic time and IDs
+func newTestPaymentService(mockRepo *mocks.MockRepository, mockUnitOfWork *mocks.MockUnitOfWork, mockPublisher *mocks.MockEventPublisher) PaymentService {
+	return NewPaymentService(mockRepo, mockUnitOfWork, system.NewMockClock(testNow), fixedIDGenerator{}, mockPublisher)
+}
+
+// expectPublished expects exactly one event of eventType for paymentID, stamped by the test clock
+func expectPublished(mockPublisher *mocks.MockEventPublisher, eventType, paymentID string) {
+	mockPublisher.EXPECT().
+		Publish(gomock.Any(), gomock.Cond(func(e interface{}) bool {
+			event, ok := e.(payment.DomainEvent)
+			return ok && event.EventType() == eventType && event.PaymentID() == paymentID &&
+				event.OccurredAt().Equal(testNow)
+		})).
+		Return(nil)
+}
+
+// expectTransaction makes the unit of work run its callback against the mock repository
+func expectTransaction(mockUnitOfWork *mocks.MockUnitOfWork, mockRepo *mocks.MockRepository) {
+	mockUnitOfWork.EXPECT().
+		WithTransaction(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(payment.Repository) error) error {
+			return fn(mockRepo)
+		})
+}
+
+// parseAmount parses s with shared.ParseAmount, failing the test on error.
+func parseAmount(t *testing.T, s string) shared.Amount {
+	t.Helper()
+
+	amount, err := shared.ParseAmount(s)
+	require.NoError(t, err)
+	return amount
 }
@@ -0,0 +1,52 @@
+package payment
+
+import (
+	"fmt"
+
+	"paymentprocessor/internal/domain/shared"
+)
+
+// DuplicatePaymentError reports that a payment with the same idempotency key
+// already exists and carries it, so callers can answer with the original
+// payment without loading it again. It matches shared.ErrDuplicatePayment
+// under errors.Is.
+type DuplicatePaymentError struct {
+	Existing Payment
+}
+
+func (e DuplicatePaymentError) Error() string {
+	return fmt.Sprintf("%s: idempotency key %s already used by payment %s",
+		shared.ErrDuplicatePayment, e.Existing.IdempotencyKey(), e.Existing.ID())
+}
+
+func (e DuplicatePaymentError) Unwrap() error {
+	return shared.ErrDuplicatePayment
+}
+
+// The parties of a payment, as named by IBANError.
+const (
+	PartyDebtor   = "debtor"
+	PartyCreditor = "creditor"
+)
+
+// IBANError attributes an IBAN failure, such as shared.ErrInvalidIBAN or
+// shared.ErrCountryNotAllowed, to one party of a payment. Err stays reachable
+// under errors.Is.
+type IBANError struct {
+	Party string
+	Err   error
+}
+
+func (e IBANError) Error() string {
+	return fmt.Sprintf("%s IBAN: %s", e.Party, e.Err)
+}
+
+func (e IBANError) Unwrap() error {
+	return e.Err
+}
+
+// Field names the request field holding the IBAN, debtor_iban or
+// creditor_iban.
+func (e IBANError) Field() string {
+	return e.Party + "_iban"
+}
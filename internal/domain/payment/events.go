@@ -0,0 +1,69 @@
+package payment
+
+import (
+	"context"
+	"time"
+
+	"paymentprocessor/internal/domain/shared"
+)
+
+//go:generate mockgen -source=events.go -destination=../../application/service/mocks/event_publisher_mock.go -package=mocks
+
+const (
+	EventPaymentCreated   = "payment.created"
+	EventPaymentProcessed = "payment.processed"
+	EventPaymentFailed    = "payment.failed"
+	EventPaymentReversed  = "payment.reversed"
+)
+
+// DomainEvent records something that happened to a payment.
+type DomainEvent interface {
+	EventType() string
+	PaymentID() string
+	OccurredAt() time.Time
+}
+
+// EventPublisher delivers domain events to downstream systems.
+type EventPublisher interface {
+	Publish(ctx context.Context, events ...DomainEvent) error
+}
+
+type PaymentCreated struct {
+	ID        string
+	Amount    shared.Amount
+	CreatedAt time.Time
+}
+
+func (e PaymentCreated) EventType() string     { return EventPaymentCreated }
+func (e PaymentCreated) PaymentID() string     { return e.ID }
+func (e PaymentCreated) OccurredAt() time.Time { return e.CreatedAt }
+
+type PaymentProcessed struct {
+	ID          string
+	ProcessedAt time.Time
+}
+
+func (e PaymentProcessed) EventType() string     { return EventPaymentProcessed }
+func (e PaymentProcessed) PaymentID() string     { return e.ID }
+func (e PaymentProcessed) OccurredAt() time.Time { return e.ProcessedAt }
+
+type PaymentFailed struct {
+	ID       string
+	FailedAt time.Time
+}
+
+func (e PaymentFailed) EventType() string     { return EventPaymentFailed }
+func (e PaymentFailed) PaymentID() string     { return e.ID }
+func (e PaymentFailed) OccurredAt() time.Time { return e.FailedAt }
+
+// PaymentReversed is recorded on the original payment; ReversalID names the
+// payment that returns its funds.
+type PaymentReversed struct {
+	ID         string
+	ReversalID string
+	ReversedAt time.Time
+}
+
+func (e PaymentReversed) EventType() string     { return EventPaymentReversed }
+func (e PaymentReversed) PaymentID() string     { return e.ID }
+func (e PaymentReversed) OccurredAt() time.Time { return e.ReversedAt }
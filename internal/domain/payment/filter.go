@@ -0,0 +1,31 @@
+package payment
+
+import (
+	"fmt"
+	"time"
+
+	"paymentprocessor/internal/domain/shared"
+)
+
+// Filter selects payments for Repository.Iterate. Zero fields do not restrict
+// the selection: From and To bound the creation time to [From, To), either
+// side may be left open, and Status matches a single status.
+type Filter struct {
+	From   time.Time
+	To     time.Time
+	Status PaymentStatus
+}
+
+// Validate fails with shared.ErrInvalidDateRange for an empty creation window
+// and shared.ErrInvalidPaymentStatus for an unknown status.
+func (f Filter) Validate() error {
+	if !f.From.IsZero() && !f.To.IsZero() && !f.To.After(f.From) {
+		return fmt.Errorf("%w: to (%s) must be after from (%s)", shared.ErrInvalidDateRange, f.To, f.From)
+	}
+
+	if f.Status != "" && !f.Status.IsValid() {
+		return shared.ErrInvalidPaymentStatus
+	}
+
+	return nil
+}
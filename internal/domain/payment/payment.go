@@ -1,7 +1,12 @@
 package payment
 
 import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"paymentprocessor/internal/domain/shared"
 )
@@ -14,11 +19,36 @@ type Payment struct {
 	creditorName   string
 	amount         shared.Amount
 	idempotencyKey shared.IdempotencyKey
+	reference      string
+	executionDate  time.Time // zero when the payment should execute immediately
+	metadata       map[string]string
+	reversalOf     string // id of the payment this one reverses, if any
 	status         PaymentStatus
+	version        int
 	createdAt      time.Time
 	updatedAt      time.Time
+	events         []DomainEvent
 }
 
+// initialVersion is the version of a payment that has not been modified since creation.
+const initialVersion = 1
+
+// Length limits for debtor and creditor names; SEPA allows at most 70 characters.
+const (
+	MinNameLength = 3
+	MaxNameLength = 70
+)
+
+// MaxReferenceLength is the longest remittance reference SEPA allows, in characters.
+const MaxReferenceLength = 140
+
+// Limits on the free-form metadata integrations can attach to a payment.
+const (
+	MaxMetadataEntries     = 20
+	MaxMetadataKeyLength   = 64
+	MaxMetadataValueLength = 256
+)
+
 func NewPayment(
 	id string,
 	debtorIBAN shared.IBAN,
@@ -27,14 +57,17 @@ func NewPayment(
 	creditorName string,
 	amount shared.Amount,
 	idempotencyKey shared.IdempotencyKey,
+	reference string,
+	executionDate time.Time,
+	metadata map[string]string,
 	createdAt time.Time,
 	updatedAt time.Time,
 ) (Payment, error) {
-	if err := validatePaymentData(debtorName, creditorName, amount); err != nil {
-		return Payment{}, err
+	if errs := violations(debtorIBAN, debtorName, creditorIBAN, creditorName, amount, reference, executionDate, metadata, createdAt); len(errs) > 0 {
+		return Payment{}, errs[0]
 	}
 
-	return Payment{
+	p := Payment{
 		id:             id,
 		debtorIBAN:     debtorIBAN,
 		debtorName:     debtorName,
@@ -42,66 +75,308 @@ func NewPayment(
 		creditorName:   creditorName,
 		amount:         amount,
 		idempotencyKey: idempotencyKey,
+		reference:      reference,
+		executionDate:  executionDate,
+		metadata:       copyMetadata(metadata),
 		status:         StatusPending,
+		version:        initialVersion,
+		createdAt:      createdAt,
+		updatedAt:      updatedAt,
+	}
+	p.record(PaymentCreated{ID: id, Amount: amount, CreatedAt: createdAt})
+	return p, nil
+}
+
+// ReconstitutePayment rebuilds a Payment from persisted state. Unlike NewPayment
+// it accepts the stored status as-is and skips the transition rules, since the
+// payment already went through them when it was first recorded. Identical
+// debtor and creditor IBANs are still rejected to surface bad legacy rows.
+func ReconstitutePayment(
+	id string,
+	debtorIBAN shared.IBAN,
+	debtorName string,
+	creditorIBAN shared.IBAN,
+	creditorName string,
+	amount shared.Amount,
+	idempotencyKey shared.IdempotencyKey,
+	reference string,
+	executionDate time.Time,
+	metadata map[string]string,
+	reversalOf string,
+	status PaymentStatus,
+	version int,
+	createdAt time.Time,
+	updatedAt time.Time,
+) (Payment, error) {
+	if !status.IsValid() {
+		return Payment{}, shared.ErrInvalidPaymentStatus
+	}
+
+	if debtorIBAN.Equals(creditorIBAN) {
+		return Payment{}, shared.ErrSameDebtorCreditor
+	}
+
+	return Payment{
+		id:             id,
+		debtorIBAN:     debtorIBAN,
+		debtorName:     debtorName,
+		creditorIBAN:   creditorIBAN,
+		creditorName:   creditorName,
+		amount:         amount,
+		idempotencyKey: idempotencyKey,
+		reference:      reference,
+		executionDate:  executionDate,
+		metadata:       copyMetadata(metadata),
+		reversalOf:     reversalOf,
+		status:         status,
+		version:        version,
 		createdAt:      createdAt,
 		updatedAt:      updatedAt,
 	}, nil
 }
 
-func (p *Payment) MarkAsProcessed(updatedAt time.Time) error {
+// MarkAsProcessing claims a pending payment for processing, so that no other
+// worker can pick it up again.
+func (p Payment) MarkAsProcessing(updatedAt time.Time) (Payment, error) {
+	if !p.canTransitionTo(StatusProcessing) {
+		return Payment{}, shared.ErrInvalidStatusTransition
+	}
+
+	p.status = StatusProcessing
+	p.updatedAt = updatedAt
+	return p, nil
+}
+
+func (p Payment) MarkAsProcessed(updatedAt time.Time) (Payment, error) {
 	if !p.canTransitionTo(StatusProcessed) {
-		return shared.ErrInvalidStatusTransition
+		return Payment{}, shared.ErrInvalidStatusTransition
 	}
 
 	p.status = StatusProcessed
 	p.updatedAt = updatedAt
-	return nil
+	p.record(PaymentProcessed{ID: p.id, ProcessedAt: updatedAt})
+	return p, nil
 }
 
-func (p *Payment) MarkAsFailed(updatedAt time.Time) error {
+func (p Payment) MarkAsFailed(updatedAt time.Time) (Payment, error) {
 	if !p.canTransitionTo(StatusFailed) {
-		return shared.ErrInvalidStatusTransition
+		return Payment{}, shared.ErrInvalidStatusTransition
 	}
 
 	p.status = StatusFailed
 	p.updatedAt = updatedAt
-	return nil
+	p.record(PaymentFailed{ID: p.id, FailedAt: updatedAt})
+	return p, nil
+}
+
+// Cancel aborts a payment that has not been processed yet.
+func (p Payment) Cancel(updatedAt time.Time) (Payment, error) {
+	if !p.canTransitionTo(StatusCancelled) {
+		return Payment{}, shared.ErrInvalidStatusTransition
+	}
+
+	p.status = StatusCancelled
+	p.updatedAt = updatedAt
+	return p, nil
+}
+
+// Reverse charges back a processed payment. It returns the original marked as
+// reversed and a new pending payment, identified by reversalID and
+// idempotencyKey, that moves the same amount from the creditor back to the
+// debtor and links to the original through ReversalOf.
+func (p Payment) Reverse(reversalID string, idempotencyKey shared.IdempotencyKey, updatedAt time.Time) (Payment, Payment, error) {
+	if !p.canTransitionTo(StatusReversed) {
+		return Payment{}, Payment{}, shared.ErrInvalidStatusTransition
+	}
+
+	reversal, err := NewPayment(
+		reversalID,
+		p.creditorIBAN,
+		p.creditorName,
+		p.debtorIBAN,
+		p.debtorName,
+		p.amount,
+		idempotencyKey,
+		p.reference,
+		time.Time{},
+		nil,
+		updatedAt,
+		updatedAt,
+	)
+	if err != nil {
+		return Payment{}, Payment{}, err
+	}
+	reversal.reversalOf = p.id
+
+	p.status = StatusReversed
+	p.updatedAt = updatedAt
+	p.record(PaymentReversed{ID: p.id, ReversalID: reversalID, ReversedAt: updatedAt})
+	return p, reversal, nil
+}
+
+// PullEvents returns the events recorded since the last pull and clears them.
+func (p *Payment) PullEvents() []DomainEvent {
+	events := p.events
+	p.events = nil
+	return events
+}
+
+// record appends an event without sharing the backing array with the copy the
+// transition was called on.
+func (p *Payment) record(event DomainEvent) {
+	events := make([]DomainEvent, len(p.events), len(p.events)+1)
+	copy(events, p.events)
+	p.events = append(events, event)
+}
+
+func (p Payment) canTransitionTo(newStatus PaymentStatus) bool {
+	return p.status.CanTransitionTo(newStatus)
+}
+
+func (p Payment) ID() string                            { return p.id }
+func (p Payment) DebtorIBAN() shared.IBAN               { return p.debtorIBAN }
+func (p Payment) DebtorName() string                    { return p.debtorName }
+func (p Payment) CreditorIBAN() shared.IBAN             { return p.creditorIBAN }
+func (p Payment) CreditorName() string                  { return p.creditorName }
+func (p Payment) Amount() shared.Amount                 { return p.amount }
+func (p Payment) IdempotencyKey() shared.IdempotencyKey { return p.idempotencyKey }
+func (p Payment) Reference() string                     { return p.reference }
+func (p Payment) ExecutionDate() time.Time              { return p.executionDate }
+func (p Payment) Metadata() map[string]string           { return copyMetadata(p.metadata) }
+func (p Payment) ReversalOf() string                    { return p.reversalOf }
+func (p Payment) Status() PaymentStatus                 { return p.status }
+func (p Payment) Version() int                          { return p.version }
+func (p Payment) CreatedAt() time.Time                  { return p.createdAt }
+func (p Payment) UpdatedAt() time.Time                  { return p.updatedAt }
+
+// Equals reports whether both payments hold the same data. Timestamps are
+// compared in UTC at second precision, which is what SQLite round-trips;
+// pending domain events are ignored.
+func (p Payment) Equals(other Payment) bool {
+	return p.id == other.id &&
+		p.debtorIBAN.Equals(other.debtorIBAN) &&
+		p.debtorName == other.debtorName &&
+		p.creditorIBAN.Equals(other.creditorIBAN) &&
+		p.creditorName == other.creditorName &&
+		p.amount.Equals(other.amount) &&
+		p.idempotencyKey.Equals(other.idempotencyKey) &&
+		p.reference == other.reference &&
+		sameInstant(p.executionDate, other.executionDate) &&
+		maps.Equal(p.metadata, other.metadata) &&
+		p.reversalOf == other.reversalOf &&
+		p.status == other.status &&
+		p.version == other.version &&
+		sameInstant(p.createdAt, other.createdAt) &&
+		sameInstant(p.updatedAt, other.updatedAt)
+}
+
+func sameInstant(a, b time.Time) bool {
+	return a.UTC().Truncate(time.Second).Equal(b.UTC().Truncate(time.Second))
+}
+
+// MetadataValue returns the metadata value stored under key, if any.
+func (p Payment) MetadataValue(key string) (string, bool) {
+	value, ok := p.metadata[key]
+	return value, ok
 }
 
-func (p *Payment) canTransitionTo(newStatus PaymentStatus) bool {
-	switch p.status {
-	case StatusPending:
-		return newStatus == StatusProcessed || newStatus == StatusFailed
-	case StatusProcessed, StatusFailed:
-		return false
-	default:
-		return false
+// Validate checks p against the rules NewPayment enforces and reports every
+// rule it breaks, joined with errors.Join, or nil. A stored payment can break
+// rules that were introduced after it was created.
+func Validate(p Payment) error {
+	return errors.Join(violations(p.debtorIBAN, p.debtorName, p.creditorIBAN, p.creditorName, p.amount,
+		p.reference, p.executionDate, p.metadata, p.createdAt)...)
+}
+
+// violations returns the rules of NewPayment the given data breaks, in the
+// order NewPayment reports them.
+func violations(
+	debtorIBAN shared.IBAN,
+	debtorName string,
+	creditorIBAN shared.IBAN,
+	creditorName string,
+	amount shared.Amount,
+	reference string,
+	executionDate time.Time,
+	metadata map[string]string,
+	createdAt time.Time,
+) []error {
+	var errs []error
+	if debtorIBAN.Equals(creditorIBAN) {
+		errs = append(errs, shared.ErrSameDebtorCreditor)
+	}
+
+	if !isValidPartyName(debtorName) {
+		errs = append(errs, shared.ErrInvalidDebtorName)
+	}
+
+	if !isValidPartyName(creditorName) {
+		errs = append(errs, shared.ErrInvalidCreditorName)
+	}
+
+	if !amount.IsPositive() {
+		errs = append(errs, shared.ErrZeroAmount)
+	}
+
+	if err := validateReference(reference); err != nil {
+		errs = append(errs, err)
+	}
+
+	if !executionDate.IsZero() && executionDate.Before(createdAt) {
+		errs = append(errs, fmt.Errorf("%w: %s is before creation at %s", shared.ErrInvalidExecutionDate, executionDate, createdAt))
 	}
+
+	if err := validateMetadata(metadata); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
 }
 
-func (p *Payment) ID() string                            { return p.id }
-func (p *Payment) DebtorIBAN() shared.IBAN               { return p.debtorIBAN }
-func (p *Payment) DebtorName() string                    { return p.debtorName }
-func (p *Payment) CreditorIBAN() shared.IBAN             { return p.creditorIBAN }
-func (p *Payment) CreditorName() string                  { return p.creditorName }
-func (p *Payment) Amount() shared.Amount                 { return p.amount }
-func (p *Payment) IdempotencyKey() shared.IdempotencyKey { return p.idempotencyKey }
-func (p *Payment) Status() PaymentStatus                 { return p.status }
-func (p *Payment) CreatedAt() time.Time                  { return p.createdAt }
-func (p *Payment) UpdatedAt() time.Time                  { return p.updatedAt }
+// isValidPartyName checks a debtor or creditor name against the SEPA length
+// limits, ignoring surrounding whitespace.
+func isValidPartyName(name string) bool {
+	length := utf8.RuneCountInString(strings.TrimSpace(name))
+	return length >= MinNameLength && length <= MaxNameLength
+}
 
-func validatePaymentData(debtorName, creditorName string, amount shared.Amount) error {
-	if len(debtorName) < 3 {
-		return shared.ErrInvalidAmount
+// validateReference allows an empty reference and rejects ones longer than
+// MaxReferenceLength characters.
+func validateReference(reference string) error {
+	if utf8.RuneCountInString(reference) > MaxReferenceLength {
+		return fmt.Errorf("%w: longer than %d characters", shared.ErrInvalidReference, MaxReferenceLength)
 	}
 
-	if len(creditorName) < 3 {
-		return shared.ErrInvalidAmount
+	return nil
+}
+
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataEntries {
+		return fmt.Errorf("%w: more than %d entries", shared.ErrInvalidMetadata, MaxMetadataEntries)
 	}
 
-	if amount.IsZero() {
-		return shared.ErrInvalidAmount
+	for key, value := range metadata {
+		if key == "" || utf8.RuneCountInString(key) > MaxMetadataKeyLength {
+			return fmt.Errorf("%w: key %q must be 1 to %d characters", shared.ErrInvalidMetadata, key, MaxMetadataKeyLength)
+		}
+		if utf8.RuneCountInString(value) > MaxMetadataValueLength {
+			return fmt.Errorf("%w: value for %q longer than %d characters", shared.ErrInvalidMetadata, key, MaxMetadataValueLength)
+		}
 	}
 
 	return nil
 }
+
+// copyMetadata keeps callers from mutating a payment through a shared map.
+// Empty metadata is normalized to nil.
+func copyMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	copied := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	return copied
+}
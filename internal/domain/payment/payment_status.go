@@ -1,26 +1,77 @@
 package payment
 
+import (
+	"strings"
+
+	"paymentprocessor/internal/domain/shared"
+)
+
 type PaymentStatus string
 
 const (
-	StatusPending   PaymentStatus = "PENDING"
-	StatusProcessed PaymentStatus = "PROCESSED"
-	StatusFailed    PaymentStatus = "FAILED"
+	StatusPending    PaymentStatus = "PENDING"
+	StatusProcessing PaymentStatus = "PROCESSING"
+	StatusProcessed  PaymentStatus = "PROCESSED"
+	StatusFailed     PaymentStatus = "FAILED"
+	StatusCancelled  PaymentStatus = "CANCELLED"
+	StatusReversed   PaymentStatus = "REVERSED"
 )
 
+// ParseStatus converts a raw status string such as "processed" into a
+// PaymentStatus, failing with shared.ErrInvalidPaymentStatus for anything
+// outside the known set.
+func ParseStatus(s string) (PaymentStatus, error) {
+	status := PaymentStatus(strings.ToUpper(strings.TrimSpace(s)))
+	if !status.IsValid() {
+		return "", shared.ErrInvalidPaymentStatus
+	}
+	return status, nil
+}
+
 func (s PaymentStatus) String() string {
 	return string(s)
 }
 
 func (s PaymentStatus) IsValid() bool {
 	switch s {
-	case StatusPending, StatusProcessed, StatusFailed:
+	case StatusPending, StatusProcessing, StatusProcessed, StatusFailed, StatusCancelled, StatusReversed:
 		return true
 	default:
 		return false
 	}
 }
 
+// IsFinal reports whether processing of the payment has ended. A processed
+// payment is final even though it can still be reversed.
 func (s PaymentStatus) IsFinal() bool {
-	return s == StatusProcessed || s == StatusFailed
+	return s == StatusProcessed || s == StatusFailed || s == StatusCancelled || s == StatusReversed
+}
+
+// CanTransitionTo reports whether the status graph allows moving from s to
+// target: a pending payment is either picked up or cancelled, a processing one
+// ends as processed or failed, and a processed one can only be reversed.
+func (s PaymentStatus) CanTransitionTo(target PaymentStatus) bool {
+	switch s {
+	case StatusPending:
+		return target == StatusProcessing || target == StatusCancelled
+	case StatusProcessing:
+		return target == StatusProcessed || target == StatusFailed
+	case StatusProcessed:
+		return target == StatusReversed
+	default:
+		return false
+	}
+}
+
+// StatusesLeadingTo returns the statuses that may move to target, in the order
+// of the status graph, so that bulk updates can restrict themselves to legal
+// transitions.
+func StatusesLeadingTo(target PaymentStatus) []PaymentStatus {
+	var sources []PaymentStatus
+	for _, s := range []PaymentStatus{StatusPending, StatusProcessing, StatusProcessed, StatusFailed, StatusCancelled, StatusReversed} {
+		if s.CanTransitionTo(target) {
+			sources = append(sources, s)
+		}
+	}
+	return sources
 }
@@ -0,0 +1,94 @@
+package payment
+
+import (
+	"fmt"
+	"testing"
+
+	"paymentprocessor/internal/domain/shared"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentStatus_CanTransitionTo(t *testing.T) {
+	t.Parallel()
+
+	statuses := []PaymentStatus{StatusPending, StatusProcessing, StatusProcessed, StatusFailed, StatusCancelled, StatusReversed}
+	legal := map[PaymentStatus][]PaymentStatus{
+		StatusPending:    {StatusProcessing, StatusCancelled},
+		StatusProcessing: {StatusProcessed, StatusFailed},
+		StatusProcessed:  {StatusReversed},
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			expected := false
+			for _, allowed := range legal[from] {
+				if allowed == to {
+					expected = true
+				}
+			}
+
+			t.Run(fmt.Sprintf("%s to %s", from, to), func(t *testing.T) {
+				t.Parallel()
+				assert.Equal(t, expected, from.CanTransitionTo(to))
+			})
+		}
+	}
+
+	t.Run("unknown statuses never transition", func(t *testing.T) {
+		t.Parallel()
+		unknown := PaymentStatus("UNKNOWN")
+
+		assert.False(t, unknown.CanTransitionTo(StatusProcessing))
+		assert.False(t, StatusPending.CanTransitionTo(unknown))
+	})
+}
+
+func TestStatusesLeadingTo(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []PaymentStatus{StatusPending}, StatusesLeadingTo(StatusProcessing))
+	assert.Equal(t, []PaymentStatus{StatusPending}, StatusesLeadingTo(StatusCancelled))
+	assert.Equal(t, []PaymentStatus{StatusProcessing}, StatusesLeadingTo(StatusFailed))
+	assert.Equal(t, []PaymentStatus{StatusProcessed}, StatusesLeadingTo(StatusReversed))
+	assert.Empty(t, StatusesLeadingTo(StatusPending))
+	assert.Empty(t, StatusesLeadingTo(PaymentStatus("UNKNOWN")))
+}
+
+func TestParseStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input       string
+		expected    PaymentStatus
+		expectError bool
+	}{
+		{input: "PENDING", expected: StatusPending},
+		{input: "PROCESSING", expected: StatusProcessing},
+		{input: "PROCESSED", expected: StatusProcessed},
+		{input: "FAILED", expected: StatusFailed},
+		{input: "CANCELLED", expected: StatusCancelled},
+		{input: "REVERSED", expected: StatusReversed},
+		{input: "processed", expected: StatusProcessed},
+		{input: " Failed ", expected: StatusFailed},
+		{input: "INVALID", expectError: true},
+		{input: "CANCELED", expectError: true},
+		{input: "", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q", tt.input), func(t *testing.T) {
+			t.Parallel()
+
+			status, err := ParseStatus(tt.input)
+
+			if tt.expectError {
+				assert.ErrorIs(t, err, shared.ErrInvalidPaymentStatus)
+				assert.Empty(t, status)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, status)
+			}
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package payment
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"paymentprocessor/internal/domain/shared"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewPayment(t *testing.T) {
@@ -27,9 +31,12 @@ func TestNewPayment(t *testing.T) {
 		creditorName   string
 		amount         shared.Amount
 		idempotencyKey shared.IdempotencyKey
+		reference      string
+		executionDate  time.Time
 		createdAt      time.Time
 		updatedAt      time.Time
 		expectError    bool
+		expectedErr    error
 	}{
 		{
 			name:           "valid payment",
@@ -56,6 +63,7 @@ func TestNewPayment(t *testing.T) {
 			createdAt:      now,
 			updatedAt:      now,
 			expectError:    true,
+			expectedErr:    shared.ErrInvalidDebtorName,
 		},
 		{
 			name:           "invalid creditor name too short",
@@ -69,6 +77,7 @@ func TestNewPayment(t *testing.T) {
 			createdAt:      now,
 			updatedAt:      now,
 			expectError:    true,
+			expectedErr:    shared.ErrInvalidCreditorName,
 		},
 		{
 			name:           "invalid zero amount",
@@ -82,6 +91,162 @@ func TestNewPayment(t *testing.T) {
 			createdAt:      now,
 			updatedAt:      now,
 			expectError:    true,
+			expectedErr:    shared.ErrZeroAmount,
+		},
+		{
+			name:           "valid payment with reference",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     "John Doe",
+			creditorIBAN:   creditorIBAN,
+			creditorName:   "Jane Smith",
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			reference:      "Invoice 2025-0042",
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    false,
+		},
+		{
+			name:           "reference at maximum length",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     "John Doe",
+			creditorIBAN:   creditorIBAN,
+			creditorName:   "Jane Smith",
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			reference:      strings.Repeat("ä", MaxReferenceLength),
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    false,
+		},
+		{
+			name:           "invalid reference too long",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     "John Doe",
+			creditorIBAN:   creditorIBAN,
+			creditorName:   "Jane Smith",
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			reference:      strings.Repeat("a", MaxReferenceLength+1),
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    true,
+			expectedErr:    shared.ErrInvalidReference,
+		},
+		{
+			name:           "valid future execution date",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     "John Doe",
+			creditorIBAN:   creditorIBAN,
+			creditorName:   "Jane Smith",
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			executionDate:  now.Add(72 * time.Hour),
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    false,
+		},
+		{
+			name:           "invalid execution date before creation",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     "John Doe",
+			creditorIBAN:   creditorIBAN,
+			creditorName:   "Jane Smith",
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			executionDate:  now.Add(-24 * time.Hour),
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    true,
+			expectedErr:    shared.ErrInvalidExecutionDate,
+		},
+		{
+			name:           "invalid debtor name too long",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     strings.Repeat("a", MaxNameLength+1),
+			creditorIBAN:   creditorIBAN,
+			creditorName:   "Jane Smith",
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    true,
+			expectedErr:    shared.ErrInvalidDebtorName,
+		},
+		{
+			name:           "invalid creditor name too long",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     "John Doe",
+			creditorIBAN:   creditorIBAN,
+			creditorName:   strings.Repeat("b", MaxNameLength+1),
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    true,
+			expectedErr:    shared.ErrInvalidCreditorName,
+		},
+		{
+			name:           "invalid whitespace-only debtor name",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     "     ",
+			creditorIBAN:   creditorIBAN,
+			creditorName:   "Jane Smith",
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    true,
+			expectedErr:    shared.ErrInvalidDebtorName,
+		},
+		{
+			name:           "invalid whitespace-only creditor name",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     "John Doe",
+			creditorIBAN:   creditorIBAN,
+			creditorName:   "\t \n ",
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    true,
+			expectedErr:    shared.ErrInvalidCreditorName,
+		},
+		{
+			name:           "names at maximum length",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     strings.Repeat("é", MaxNameLength),
+			creditorIBAN:   creditorIBAN,
+			creditorName:   strings.Repeat("b", MaxNameLength),
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    false,
+		},
+		{
+			name:           "invalid identical debtor and creditor IBAN",
+			id:             "payment-123",
+			debtorIBAN:     debtorIBAN,
+			debtorName:     "John Doe",
+			creditorIBAN:   debtorIBAN,
+			creditorName:   "Jane Smith",
+			amount:         amount,
+			idempotencyKey: idempotencyKey,
+			createdAt:      now,
+			updatedAt:      now,
+			expectError:    true,
+			expectedErr:    shared.ErrSameDebtorCreditor,
 		},
 	}
 
@@ -96,12 +261,18 @@ func TestNewPayment(t *testing.T) {
 				tt.creditorName,
 				tt.amount,
 				tt.idempotencyKey,
+				tt.reference,
+				tt.executionDate,
+				nil,
 				tt.createdAt,
 				tt.updatedAt,
 			)
 
 			if tt.expectError {
 				assert.Error(t, err, "expected error but got none")
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr, "expected specific error")
+				}
 			} else {
 				assert.NoError(t, err, "unexpected error")
 
@@ -113,7 +284,10 @@ func TestNewPayment(t *testing.T) {
 				assert.Equal(t, tt.creditorName, payment.CreditorName(), "creditor name should match")
 				assert.True(t, payment.Amount().Equals(tt.amount), "amount should match")
 				assert.True(t, payment.IdempotencyKey().Equals(tt.idempotencyKey), "idempotency key should match")
+				assert.Equal(t, tt.reference, payment.Reference(), "reference should match")
+				assert.True(t, payment.ExecutionDate().Equal(tt.executionDate), "execution date should match")
 				assert.Equal(t, StatusPending, payment.Status(), "status should be pending")
+				assert.Equal(t, 1, payment.Version(), "version should start at 1")
 				assert.True(t, payment.CreatedAt().Equal(tt.createdAt), "createdAt should match")
 				assert.True(t, payment.UpdatedAt().Equal(tt.updatedAt), "updatedAt should match")
 			}
@@ -121,40 +295,217 @@ func TestNewPayment(t *testing.T) {
 	}
 }
 
-func TestPayment_MarkAsProcessed(t *testing.T) {
+func TestNewPayment_Metadata(t *testing.T) {
+	t.Parallel()
+	debtorIBAN, _ := shared.NewIBAN("GB82WEST12345698765432")
+	creditorIBAN, _ := shared.NewIBAN("FR1420041010050500013M02606")
+	amount, _ := shared.NewAmount(100.50)
+	idempotencyKey, _ := shared.NewIdempotencyKey("abc123XYZ0")
+	now := time.Now()
+
+	tooMany := make(map[string]string, MaxMetadataEntries+1)
+	for i := 0; i <= MaxMetadataEntries; i++ {
+		tooMany[fmt.Sprintf("key%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name        string
+		metadata    map[string]string
+		expectError bool
+	}{
+		{name: "nil metadata", metadata: nil},
+		{name: "empty metadata", metadata: map[string]string{}},
+		{name: "valid metadata", metadata: map[string]string{"order_id": "A-1001", "channel": "web"}},
+		{name: "too many entries", metadata: tooMany, expectError: true},
+		{name: "empty key", metadata: map[string]string{"": "value"}, expectError: true},
+		{name: "key too long", metadata: map[string]string{strings.Repeat("k", MaxMetadataKeyLength+1): "value"}, expectError: true},
+		{name: "value too long", metadata: map[string]string{"note": strings.Repeat("v", MaxMetadataValueLength+1)}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			payment, err := NewPayment(
+				"payment-123",
+				debtorIBAN,
+				"John Doe",
+				creditorIBAN,
+				"Jane Smith",
+				amount,
+				idempotencyKey,
+				"",
+				time.Time{},
+				tt.metadata,
+				now,
+				now,
+			)
+
+			if tt.expectError {
+				assert.ErrorIs(t, err, shared.ErrInvalidMetadata, "expected invalid metadata error")
+				return
+			}
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Len(t, payment.Metadata(), len(tt.metadata), "metadata size should match")
+			for key, want := range tt.metadata {
+				got, ok := payment.MetadataValue(key)
+				assert.True(t, ok, "expected key %q to be present", key)
+				assert.Equal(t, want, got, "unexpected value for key %q", key)
+			}
+		})
+	}
+
+	t.Run("is isolated from caller mutations", func(t *testing.T) {
+		t.Parallel()
+		metadata := map[string]string{"channel": "web"}
+		payment, err := NewPayment("payment-123", debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+			amount, idempotencyKey, "", time.Time{}, metadata, now, now)
+		assert.NoError(t, err, "unexpected error")
+
+		metadata["channel"] = "mobile"
+		payment.Metadata()["channel"] = "api"
+
+		got, _ := payment.MetadataValue("channel")
+		assert.Equal(t, "web", got, "metadata should not change through shared maps")
+	})
+}
+
+func TestPayment_MarkAsProcessing(t *testing.T) {
 	t.Parallel()
-	// Create a valid payment
 	payment := createValidPayment(t)
 	updatedAt := time.Now().Add(time.Hour)
 
+	processing, err := payment.MarkAsProcessing(updatedAt)
+	assert.NoError(t, err, "should claim a pending payment")
+	assert.Equal(t, StatusProcessing, processing.Status(), "status should be processing")
+	assert.False(t, processing.Status().IsFinal(), "processing should not be a final status")
+	assert.True(t, processing.UpdatedAt().Equal(updatedAt), "updatedAt should match")
+	assert.Equal(t, StatusPending, payment.Status(), "original payment should stay pending")
+
+	// A second worker cannot claim the same payment
+	_, err = processing.MarkAsProcessing(updatedAt)
+	assert.Equal(t, shared.ErrInvalidStatusTransition, err, "should return invalid status transition error")
+}
+
+func TestPayment_MarkAsProcessed(t *testing.T) {
+	t.Parallel()
+	// Create a payment that has been claimed for processing
+	payment := createProcessingPayment(t)
+	updatedAt := time.Now().Add(time.Hour)
+
 	// Test successful transition
-	err := payment.MarkAsProcessed(updatedAt)
+	processed, err := payment.MarkAsProcessed(updatedAt)
 	assert.NoError(t, err, "should successfully mark payment as processed")
-	assert.Equal(t, StatusProcessed, payment.Status(), "status should be processed")
-	assert.True(t, payment.UpdatedAt().Equal(updatedAt), "updatedAt should match")
+	assert.Equal(t, StatusProcessed, processed.Status(), "status should be processed")
+	assert.True(t, processed.UpdatedAt().Equal(updatedAt), "updatedAt should match")
+
+	// The original value is left untouched
+	assert.Equal(t, StatusProcessing, payment.Status(), "original payment should stay processing")
 
 	// Test invalid transition from processed state
-	err = payment.MarkAsProcessed(updatedAt)
+	_, err = processed.MarkAsProcessed(updatedAt)
 	assert.Equal(t, shared.ErrInvalidStatusTransition, err, "should return invalid status transition error")
 }
 
 func TestPayment_MarkAsFailed(t *testing.T) {
 	t.Parallel()
-	// Create a valid payment
-	payment := createValidPayment(t)
+	// Create a payment that has been claimed for processing
+	payment := createProcessingPayment(t)
 	updatedAt := time.Now().Add(time.Hour)
 
 	// Test successful transition
-	err := payment.MarkAsFailed(updatedAt)
+	failed, err := payment.MarkAsFailed(updatedAt)
 	assert.NoError(t, err, "should successfully mark payment as failed")
-	assert.Equal(t, StatusFailed, payment.Status(), "status should be failed")
-	assert.True(t, payment.UpdatedAt().Equal(updatedAt), "updatedAt should match")
+	assert.Equal(t, StatusFailed, failed.Status(), "status should be failed")
+	assert.True(t, failed.UpdatedAt().Equal(updatedAt), "updatedAt should match")
+
+	// The original value is left untouched
+	assert.Equal(t, StatusProcessing, payment.Status(), "original payment should stay processing")
 
 	// Test invalid transition from failed state
-	err = payment.MarkAsFailed(updatedAt)
+	_, err = failed.MarkAsFailed(updatedAt)
 	assert.Equal(t, shared.ErrInvalidStatusTransition, err, "should return invalid status transition error")
 }
 
+func TestPayment_Cancel(t *testing.T) {
+	t.Parallel()
+	payment := createValidPayment(t)
+	updatedAt := time.Now().Add(time.Hour)
+
+	cancelled, err := payment.Cancel(updatedAt)
+
+	assert.NoError(t, err, "should cancel a pending payment")
+	assert.Equal(t, StatusCancelled, cancelled.Status(), "status should be cancelled")
+	assert.True(t, cancelled.Status().IsFinal(), "cancelled should be a final status")
+	assert.True(t, cancelled.UpdatedAt().Equal(updatedAt), "updatedAt should match")
+	assert.Equal(t, StatusPending, payment.Status(), "original payment should be unchanged")
+}
+
+func TestPayment_Reverse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("swaps the parties of a processed payment", func(t *testing.T) {
+		t.Parallel()
+		processed, err := createProcessingPayment(t).MarkAsProcessed(time.Now())
+		require.NoError(t, err)
+		processed.PullEvents()
+		reversalKey, _ := shared.NewIdempotencyKey("reverse123")
+		updatedAt := time.Now().Add(time.Hour)
+
+		reversed, reversal, err := processed.Reverse("reversal-123", reversalKey, updatedAt)
+
+		require.NoError(t, err)
+		assert.Equal(t, StatusReversed, reversed.Status(), "original should be reversed")
+		assert.True(t, reversed.Status().IsFinal(), "reversed should be a final status")
+		assert.True(t, reversed.UpdatedAt().Equal(updatedAt), "updatedAt should match")
+		assert.Equal(t, StatusProcessed, processed.Status(), "original value should stay processed")
+
+		assert.Equal(t, "reversal-123", reversal.ID())
+		assert.Equal(t, processed.ID(), reversal.ReversalOf(), "reversal should link to the original")
+		assert.Empty(t, reversed.ReversalOf(), "original should not link anywhere")
+		assert.True(t, reversal.DebtorIBAN().Equals(processed.CreditorIBAN()), "creditor should pay the funds back")
+		assert.Equal(t, processed.CreditorName(), reversal.DebtorName())
+		assert.True(t, reversal.CreditorIBAN().Equals(processed.DebtorIBAN()), "debtor should get the funds back")
+		assert.Equal(t, processed.DebtorName(), reversal.CreditorName())
+		assert.True(t, reversal.Amount().Equals(processed.Amount()), "amount should be unchanged")
+		assert.Equal(t, reversalKey, reversal.IdempotencyKey())
+		assert.Equal(t, StatusPending, reversal.Status(), "reversal should start pending")
+		assert.True(t, reversal.CreatedAt().Equal(updatedAt))
+
+		events := reversed.PullEvents()
+		require.Len(t, events, 1)
+		assert.Equal(t, PaymentReversed{ID: processed.ID(), ReversalID: "reversal-123", ReversedAt: updatedAt}, events[0])
+		created := reversal.PullEvents()
+		require.Len(t, created, 1)
+		assert.Equal(t, EventPaymentCreated, created[0].EventType())
+	})
+
+	t.Run("rejects payments that are not processed", func(t *testing.T) {
+		t.Parallel()
+		reversalKey, _ := shared.NewIdempotencyKey("reverse123")
+		failed, err := createProcessingPayment(t).MarkAsFailed(time.Now())
+		require.NoError(t, err)
+
+		for _, p := range []Payment{createValidPayment(t), createProcessingPayment(t), failed} {
+			_, _, err := p.Reverse("reversal-123", reversalKey, time.Now())
+			assert.ErrorIs(t, err, shared.ErrInvalidStatusTransition, "should not reverse a %s payment", p.Status())
+		}
+	})
+
+	t.Run("cannot reverse twice", func(t *testing.T) {
+		t.Parallel()
+		processed, err := createProcessingPayment(t).MarkAsProcessed(time.Now())
+		require.NoError(t, err)
+		reversalKey, _ := shared.NewIdempotencyKey("reverse123")
+
+		reversed, _, err := processed.Reverse("reversal-123", reversalKey, time.Now())
+		require.NoError(t, err)
+
+		_, _, err = reversed.Reverse("reversal-456", reversalKey, time.Now())
+		assert.ErrorIs(t, err, shared.ErrInvalidStatusTransition)
+	})
+}
+
 func TestPayment_StatusTransitions(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -164,17 +515,47 @@ func TestPayment_StatusTransitions(t *testing.T) {
 		expectError   bool
 	}{
 		{
-			name:          "pending to processed",
+			name:          "pending to processing",
 			initialStatus: StatusPending,
-			targetStatus:  StatusProcessed,
+			targetStatus:  StatusProcessing,
 			expectError:   false,
 		},
 		{
-			name:          "pending to failed",
+			name:          "pending to processed (invalid, must go through processing)",
+			initialStatus: StatusPending,
+			targetStatus:  StatusProcessed,
+			expectError:   true,
+		},
+		{
+			name:          "pending to failed (invalid, must go through processing)",
 			initialStatus: StatusPending,
 			targetStatus:  StatusFailed,
+			expectError:   true,
+		},
+		{
+			name:          "processing to processed",
+			initialStatus: StatusProcessing,
+			targetStatus:  StatusProcessed,
+			expectError:   false,
+		},
+		{
+			name:          "processing to failed",
+			initialStatus: StatusProcessing,
+			targetStatus:  StatusFailed,
 			expectError:   false,
 		},
+		{
+			name:          "processing to processing (invalid)",
+			initialStatus: StatusProcessing,
+			targetStatus:  StatusProcessing,
+			expectError:   true,
+		},
+		{
+			name:          "processing to cancelled (invalid)",
+			initialStatus: StatusProcessing,
+			targetStatus:  StatusCancelled,
+			expectError:   true,
+		},
 		{
 			name:          "processed to failed (invalid)",
 			initialStatus: StatusProcessed,
@@ -187,6 +568,36 @@ func TestPayment_StatusTransitions(t *testing.T) {
 			targetStatus:  StatusProcessed,
 			expectError:   true,
 		},
+		{
+			name:          "pending to cancelled",
+			initialStatus: StatusPending,
+			targetStatus:  StatusCancelled,
+			expectError:   false,
+		},
+		{
+			name:          "processed to cancelled (invalid)",
+			initialStatus: StatusProcessed,
+			targetStatus:  StatusCancelled,
+			expectError:   true,
+		},
+		{
+			name:          "failed to cancelled (invalid)",
+			initialStatus: StatusFailed,
+			targetStatus:  StatusCancelled,
+			expectError:   true,
+		},
+		{
+			name:          "cancelled to processing (invalid)",
+			initialStatus: StatusCancelled,
+			targetStatus:  StatusProcessing,
+			expectError:   true,
+		},
+		{
+			name:          "cancelled to cancelled (invalid)",
+			initialStatus: StatusCancelled,
+			targetStatus:  StatusCancelled,
+			expectError:   true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -196,31 +607,345 @@ func TestPayment_StatusTransitions(t *testing.T) {
 			updatedAt := time.Now().Add(time.Hour)
 
 			// Set initial status
-			if tt.initialStatus == StatusProcessed {
-				_ = payment.MarkAsProcessed(updatedAt)
-			} else if tt.initialStatus == StatusFailed {
-				_ = payment.MarkAsFailed(updatedAt)
+			switch tt.initialStatus {
+			case StatusProcessing:
+				payment, _ = payment.MarkAsProcessing(updatedAt)
+			case StatusProcessed:
+				payment, _ = payment.MarkAsProcessing(updatedAt)
+				payment, _ = payment.MarkAsProcessed(updatedAt)
+			case StatusFailed:
+				payment, _ = payment.MarkAsProcessing(updatedAt)
+				payment, _ = payment.MarkAsFailed(updatedAt)
+			case StatusCancelled:
+				payment, _ = payment.Cancel(updatedAt)
 			}
 
 			// Attempt transition
-			var err error
-			if tt.targetStatus == StatusProcessed {
-				err = payment.MarkAsProcessed(updatedAt)
-			} else if tt.targetStatus == StatusFailed {
-				err = payment.MarkAsFailed(updatedAt)
+			var (
+				result Payment
+				err    error
+			)
+			switch tt.targetStatus {
+			case StatusProcessing:
+				result, err = payment.MarkAsProcessing(updatedAt)
+			case StatusProcessed:
+				result, err = payment.MarkAsProcessed(updatedAt)
+			case StatusFailed:
+				result, err = payment.MarkAsFailed(updatedAt)
+			case StatusCancelled:
+				result, err = payment.Cancel(updatedAt)
 			}
 
 			if tt.expectError {
 				assert.Equal(t, shared.ErrInvalidStatusTransition, err, "should return invalid status transition error")
 			} else {
 				assert.NoError(t, err, "should successfully transition status")
-				assert.Equal(t, tt.targetStatus, payment.Status(), "status should match target status")
+				assert.Equal(t, tt.targetStatus, result.Status(), "status should match target status")
 			}
 		})
 	}
 }
 
+func TestPayment_Equals(t *testing.T) {
+	t.Parallel()
+	createdAt := time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+
+	reconstitute := func(t *testing.T, status PaymentStatus, createdAt time.Time) Payment {
+		debtorIBAN, _ := shared.NewIBAN("GB82WEST12345698765432")
+		creditorIBAN, _ := shared.NewIBAN("FR1420041010050500013M02606")
+		amount, _ := shared.NewAmount(100.50)
+		idempotencyKey, _ := shared.NewIdempotencyKey("abc123XYZ0")
+
+		payment, err := ReconstitutePayment(
+			"payment-123",
+			debtorIBAN,
+			"John Doe",
+			creditorIBAN,
+			"Jane Smith",
+			amount,
+			idempotencyKey,
+			"Invoice 42",
+			time.Time{},
+			map[string]string{"channel": "web"},
+			"",
+			status,
+			1,
+			createdAt,
+			createdAt,
+		)
+		if err != nil {
+			t.Fatalf("failed to reconstitute payment: %v", err)
+		}
+		return payment
+	}
+
+	t.Run("equal payments", func(t *testing.T) {
+		t.Parallel()
+		a := reconstitute(t, StatusPending, createdAt)
+		b := reconstitute(t, StatusPending, createdAt)
+
+		assert.True(t, a.Equals(b), "identical payments should be equal")
+		assert.True(t, b.Equals(a), "equality should be symmetric")
+	})
+
+	t.Run("differing only in status", func(t *testing.T) {
+		t.Parallel()
+		a := reconstitute(t, StatusPending, createdAt)
+		b := reconstitute(t, StatusProcessing, createdAt)
+
+		assert.False(t, a.Equals(b), "payments with different statuses should differ")
+	})
+
+	t.Run("differing only in sub-second precision", func(t *testing.T) {
+		t.Parallel()
+		a := reconstitute(t, StatusPending, createdAt)
+		b := reconstitute(t, StatusPending, createdAt.Add(250*time.Millisecond))
+
+		assert.True(t, a.Equals(b), "sub-second differences should be ignored")
+	})
+
+	t.Run("same instant in another time zone", func(t *testing.T) {
+		t.Parallel()
+		a := reconstitute(t, StatusPending, createdAt)
+		b := reconstitute(t, StatusPending, createdAt.In(time.FixedZone("CET", 3600)))
+
+		assert.True(t, a.Equals(b), "time zones should be normalized")
+	})
+
+	t.Run("differing by a full second", func(t *testing.T) {
+		t.Parallel()
+		a := reconstitute(t, StatusPending, createdAt)
+		b := reconstitute(t, StatusPending, createdAt.Add(time.Second))
+
+		assert.False(t, a.Equals(b), "whole-second differences should be detected")
+	})
+}
+
+func TestReconstitutePayment(t *testing.T) {
+	t.Parallel()
+	debtorIBAN, _ := shared.NewIBAN("GB82WEST12345698765432")
+	creditorIBAN, _ := shared.NewIBAN("FR1420041010050500013M02606")
+	amount, _ := shared.NewAmount(100.50)
+	idempotencyKey, _ := shared.NewIdempotencyKey("abc123XYZ0")
+	createdAt := time.Now().Add(-time.Hour)
+	updatedAt := time.Now()
+
+	t.Run("restores final status without running transitions", func(t *testing.T) {
+		t.Parallel()
+		payment, err := ReconstitutePayment(
+			"payment-123",
+			debtorIBAN,
+			"John Doe",
+			creditorIBAN,
+			"Jane Smith",
+			amount,
+			idempotencyKey,
+			"",
+			time.Time{},
+			nil,
+			"",
+			StatusFailed,
+			3,
+			createdAt,
+			updatedAt,
+		)
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, StatusFailed, payment.Status(), "status should be restored as failed")
+		assert.Equal(t, 3, payment.Version(), "version should be restored")
+		assert.True(t, payment.CreatedAt().Equal(createdAt), "createdAt should match")
+		assert.True(t, payment.UpdatedAt().Equal(updatedAt), "updatedAt should match")
+
+		// A failed payment is final, so the transition rules still apply afterwards
+		_, err = payment.MarkAsProcessed(updatedAt)
+		assert.Equal(t, shared.ErrInvalidStatusTransition, err, "should return invalid status transition error")
+	})
+
+	t.Run("rejects identical debtor and creditor IBAN", func(t *testing.T) {
+		t.Parallel()
+		_, err := ReconstitutePayment(
+			"payment-123",
+			debtorIBAN,
+			"John Doe",
+			debtorIBAN,
+			"Jane Smith",
+			amount,
+			idempotencyKey,
+			"",
+			time.Time{},
+			nil,
+			"",
+			StatusPending,
+			1,
+			createdAt,
+			updatedAt,
+		)
+
+		assert.Equal(t, shared.ErrSameDebtorCreditor, err, "should return same debtor and creditor error")
+	})
+
+	t.Run("rejects unknown status", func(t *testing.T) {
+		t.Parallel()
+		_, err := ReconstitutePayment(
+			"payment-123",
+			debtorIBAN,
+			"John Doe",
+			creditorIBAN,
+			"Jane Smith",
+			amount,
+			idempotencyKey,
+			"",
+			time.Time{},
+			nil,
+			"",
+			PaymentStatus("UNKNOWN"),
+			1,
+			createdAt,
+			updatedAt,
+		)
+
+		assert.Equal(t, shared.ErrInvalidPaymentStatus, err, "should return invalid payment status error")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	debtorIBAN, _ := shared.NewIBAN("GB82WEST12345698765432")
+	creditorIBAN, _ := shared.NewIBAN("FR1420041010050500013M02606")
+	amount, _ := shared.NewAmount(100.50)
+	idempotencyKey, _ := shared.NewIdempotencyKey("abc123XYZ0")
+	createdAt := time.Now()
+
+	t.Run("accepts a payment NewPayment accepts", func(t *testing.T) {
+		t.Parallel()
+		payment, err := NewPayment("payment-123", debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+			amount, idempotencyKey, "Invoice 42", time.Time{}, map[string]string{"order": "42"}, createdAt, createdAt)
+		require.NoError(t, err)
+
+		assert.NoError(t, Validate(payment))
+	})
+
+	t.Run("reports every rule a stored payment breaks", func(t *testing.T) {
+		t.Parallel()
+		payment, err := ReconstitutePayment("payment-123", debtorIBAN, "Jo", creditorIBAN, "Jane Smith",
+			shared.Amount{}, idempotencyKey, strings.Repeat("r", MaxReferenceLength+1), createdAt.Add(-time.Hour), nil,
+			"", StatusPending, 1, createdAt, createdAt)
+		require.NoError(t, err)
+
+		err = Validate(payment)
+		assert.ErrorIs(t, err, shared.ErrInvalidDebtorName)
+		assert.ErrorIs(t, err, shared.ErrZeroAmount)
+		assert.ErrorIs(t, err, shared.ErrInvalidReference)
+		assert.ErrorIs(t, err, shared.ErrInvalidExecutionDate)
+		assert.NotErrorIs(t, err, shared.ErrInvalidCreditorName)
+	})
+}
+
+func TestPayment_PullEvents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records creation", func(t *testing.T) {
+		t.Parallel()
+		payment := createValidPayment(t)
+
+		events := payment.PullEvents()
+
+		assert.Len(t, events, 1, "expected one event")
+		created, ok := events[0].(PaymentCreated)
+		assert.True(t, ok, "expected PaymentCreated, got %T", events[0])
+		assert.Equal(t, EventPaymentCreated, created.EventType())
+		assert.Equal(t, payment.ID(), created.PaymentID())
+		assert.True(t, created.Amount.Equals(payment.Amount()), "amount should match")
+		assert.True(t, created.OccurredAt().Equal(payment.CreatedAt()), "occurredAt should match createdAt")
+	})
+
+	t.Run("records processing", func(t *testing.T) {
+		t.Parallel()
+		payment := createProcessingPayment(t)
+		payment.PullEvents()
+		processedAt := time.Now().Add(time.Minute)
+
+		processed, err := payment.MarkAsProcessed(processedAt)
+		assert.NoError(t, err, "unexpected error")
+
+		events := processed.PullEvents()
+		assert.Len(t, events, 1, "expected one event")
+		assert.Equal(t, PaymentProcessed{ID: payment.ID(), ProcessedAt: processedAt}, events[0])
+	})
+
+	t.Run("records failure", func(t *testing.T) {
+		t.Parallel()
+		payment := createProcessingPayment(t)
+		payment.PullEvents()
+		failedAt := time.Now().Add(time.Minute)
+
+		failed, err := payment.MarkAsFailed(failedAt)
+		assert.NoError(t, err, "unexpected error")
+
+		events := failed.PullEvents()
+		assert.Len(t, events, 1, "expected one event")
+		assert.Equal(t, PaymentFailed{ID: payment.ID(), FailedAt: failedAt}, events[0])
+	})
+
+	t.Run("drains events so a second pull is empty", func(t *testing.T) {
+		t.Parallel()
+		payment := createValidPayment(t)
+
+		assert.NotEmpty(t, payment.PullEvents(), "first pull should return events")
+		assert.Empty(t, payment.PullEvents(), "second pull should return nothing")
+	})
+
+	t.Run("does not leak events into the original payment", func(t *testing.T) {
+		t.Parallel()
+		payment := createProcessingPayment(t)
+
+		_, err := payment.MarkAsProcessed(time.Now())
+		assert.NoError(t, err, "unexpected error")
+
+		assert.Len(t, payment.PullEvents(), 1, "original should only hold its creation event")
+	})
+
+	t.Run("reconstituted payments carry no events", func(t *testing.T) {
+		t.Parallel()
+		original := createValidPayment(t)
+		payment, err := ReconstitutePayment(
+			original.ID(),
+			original.DebtorIBAN(),
+			original.DebtorName(),
+			original.CreditorIBAN(),
+			original.CreditorName(),
+			original.Amount(),
+			original.IdempotencyKey(),
+			"",
+			time.Time{},
+			nil,
+			"",
+			StatusPending,
+			1,
+			original.CreatedAt(),
+			original.UpdatedAt(),
+		)
+		assert.NoError(t, err, "unexpected error")
+
+		assert.Empty(t, payment.PullEvents(), "reconstitution should not record events")
+	})
+}
+
 // Helper function to create a valid payment for testing
+func TestDuplicatePaymentError(t *testing.T) {
+	t.Parallel()
+	existing := createValidPayment(t)
+
+	err := fmt.Errorf("create payment: %w", DuplicatePaymentError{Existing: existing})
+
+	assert.ErrorIs(t, err, shared.ErrDuplicatePayment)
+	assert.Contains(t, err.Error(), existing.ID())
+
+	var duplicateErr DuplicatePaymentError
+	assert.True(t, errors.As(err, &duplicateErr), "expected DuplicatePaymentError in chain")
+	assert.Equal(t, existing.ID(), duplicateErr.Existing.ID())
+}
+
 func createValidPayment(t *testing.T) Payment {
 	debtorIBAN, _ := shared.NewIBAN("GB82WEST12345698765432")
 	creditorIBAN, _ := shared.NewIBAN("FR1420041010050500013M02606")
@@ -236,6 +961,9 @@ func createValidPayment(t *testing.T) Payment {
 		"Jane Smith",
 		amount,
 		idempotencyKey,
+		"",
+		time.Time{},
+		nil,
 		now,
 		now,
 	)
@@ -244,3 +972,12 @@ func createValidPayment(t *testing.T) Payment {
 	}
 	return payment
 }
+
+// Helper function to create a payment that has been claimed for processing
+func createProcessingPayment(t *testing.T) Payment {
+	payment, err := createValidPayment(t).MarkAsProcessing(time.Now())
+	if err != nil {
+		t.Fatalf("failed to mark payment as processing: %v", err)
+	}
+	return payment
+}
@@ -2,15 +2,63 @@ package payment
 
 import (
 	"context"
+	"time"
 
 	"paymentprocessor/internal/domain/shared"
 )
 
 //go:generate mockgen -source=repository.go -destination=../../application/service/mocks/payment_repository_mock.go -package=mocks
 
+// Repository persists Payment values. Lookups that match nothing return a zero
+// Payment together with shared.ErrPaymentNotFound.
 type Repository interface {
 	Save(ctx context.Context, payment Payment) error
+	SaveBatch(ctx context.Context, payments []Payment) error
 	FindByID(ctx context.Context, id string) (Payment, error)
+	// FindByIDs looks up many payments at once, keyed by id. Ids FindByID
+	// would not find are absent from the map rather than an error.
+	FindByIDs(ctx context.Context, ids []string) (map[string]Payment, error)
+	// Exists reports whether FindByID would find the payment, without loading it.
+	Exists(ctx context.Context, id string) (bool, error)
+	// FindByIdempotencyKey returns the payment holding key. A soft-deleted
+	// payment releases its key, so it is only found by repositories that
+	// include deleted payments.
 	FindByIdempotencyKey(ctx context.Context, key shared.IdempotencyKey) (Payment, error)
-	UpdateStatus(ctx context.Context, id string, status PaymentStatus) error
+	FindByStatus(ctx context.Context, status PaymentStatus, limit int) ([]Payment, error)
+	FindByDebtorIBANAndStatus(ctx context.Context, iban shared.IBAN, status PaymentStatus, limit int) ([]Payment, error)
+	FindByDateRange(ctx context.Context, from, to time.Time, limit int) ([]Payment, error)
+	// FindByAmountRange returns payments in currency with minCents <= amount
+	// <= maxCents, smallest first. A negative maxCents leaves the range
+	// open-ended.
+	FindByAmountRange(ctx context.Context, currency shared.Currency, minCents, maxCents int64, limit int) ([]Payment, error)
+	// FindDueForExecution returns up to limit pending payments whose execution
+	// date is at or before asOf, earliest first.
+	FindDueForExecution(ctx context.Context, asOf time.Time, limit int) ([]Payment, error)
+	List(ctx context.Context, offset, limit int) ([]Payment, error)
+	// ListAfter returns payments ordered by (created_at, id) that come strictly
+	// after the given cursor. Pass the last payment of a page to get the next.
+	ListAfter(ctx context.Context, afterCreatedAt time.Time, afterID string, limit int) ([]Payment, error)
+	// Iterate calls fn for every payment matching filter, oldest first, without
+	// loading them all at once. It stops at the first error fn returns, which
+	// it passes through, or once ctx is done. fn runs while the query is still
+	// open, so it should not call back into the repository.
+	Iterate(ctx context.Context, filter Filter, fn func(Payment) error) error
+	Count(ctx context.Context) (int, error)
+	CountByStatus(ctx context.Context, status PaymentStatus) (int, error)
+	// UpdateStatus persists a status without checking transition rules; apply the
+	// transition on the loaded Payment first and pass its UpdatedAt, so the
+	// stored row matches it. Each update is recorded in the status history,
+	// attributed to ActorFromContext(ctx).
+	UpdateStatus(ctx context.Context, id string, status PaymentStatus, expectedVersion int, updatedAt time.Time) error
+	// UpdateStatusBatch sets status on every listed payment that may legally
+	// move to it, skipping soft-deleted ones, without version checks, and
+	// returns how many changed. Changes are recorded in the status history like
+	// UpdateStatus.
+	UpdateStatusBatch(ctx context.Context, ids []string, status PaymentStatus) (int, error)
+	// FindStatusHistory returns the recorded status changes of a payment, oldest first.
+	FindStatusHistory(ctx context.Context, id string) ([]StatusChange, error)
+	// SoftDelete hides a payment from default lookups while retaining it.
+	SoftDelete(ctx context.Context, id string) error
+	// HealthCheck fails unless the payments store can be queried.
+	HealthCheck(ctx context.Context) error
 }
@@ -0,0 +1,35 @@
+package payment
+
+import (
+	"context"
+	"time"
+)
+
+// SystemActor is recorded for status changes made without an actor in the
+// context, such as those applied by background workers.
+const SystemActor = "system"
+
+// StatusChange is one entry in the audit trail of a payment's status.
+type StatusChange struct {
+	PaymentID string
+	From      PaymentStatus
+	To        PaymentStatus
+	ChangedAt time.Time
+	Actor     string
+}
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes status changes made with it to
+// actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set with WithActor, or SystemActor.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
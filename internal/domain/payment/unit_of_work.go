@@ -0,0 +1,10 @@
+package payment
+
+import "context"
+
+//go:generate mockgen -source=unit_of_work.go -destination=../../application/service/mocks/unit_of_work_mock.go -package=mocks
+
+// UnitOfWork runs a callback atomically against a Repository bound to a single transaction.
+type UnitOfWork interface {
+	WithTransaction(ctx context.Context, fn func(repo Repository) error) error
+}
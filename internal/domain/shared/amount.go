@@ -1,34 +1,143 @@
 package shared
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
+// MaxAmount is the largest amount, in cents, that arithmetic is allowed to produce.
+// It leaves headroom below math.MaxInt64 so intermediate results cannot wrap negative.
+const MaxAmount int64 = math.MaxInt64 / 100
+
 type Amount struct {
-	value int64 // Store as cents to avoid floating point issues
+	value    int64 // Store as cents to avoid floating point issues
+	currency Currency
 }
 
 func NewAmount(value float64) (Amount, error) {
+	return NewAmountWithCurrency(value, EUR)
+}
+
+func NewAmountWithCurrency(value float64, currency Currency) (Amount, error) {
 	if value < 0 {
 		return Amount{}, ErrInvalidAmount
 	}
 
-	if value > math.MaxInt64/100 {
+	if value > float64(MaxAmount)/100 {
 		return Amount{}, ErrInvalidAmount
 	}
 
 	cents := int64(math.Round(value * 100))
 
-	return Amount{value: cents}, nil
+	return Amount{value: cents, currency: currency}, nil
 }
 
 func NewAmountFromCents(cents int64) (Amount, error) {
-	if cents < 0 {
+	return NewAmountFromCentsWithCurrency(cents, EUR)
+}
+
+func NewAmountFromCentsWithCurrency(cents int64, currency Currency) (Amount, error) {
+	if cents < 0 || cents > MaxAmount {
 		return Amount{}, ErrInvalidAmount
 	}
 
-	return Amount{value: cents}, nil
+	return Amount{value: cents, currency: currency}, nil
+}
+
+// ParseAmount builds an Amount from a decimal string such as "100.50",
+// "1,234.99" or "12.00 USD" without going through float arithmetic.
+// Amounts without a currency suffix default to EUR.
+func ParseAmount(s string) (Amount, error) {
+	trimmed := strings.TrimSpace(s)
+
+	numberPart, currencyPart := trimmed, ""
+	if idx := strings.IndexFunc(trimmed, unicode.IsLetter); idx >= 0 {
+		numberPart = strings.TrimSpace(trimmed[:idx])
+		currencyPart = trimmed[idx:]
+	}
+
+	cents, err := parseCents(numberPart)
+	if err != nil {
+		return Amount{}, err
+	}
+
+	currency := EUR
+	if currencyPart != "" {
+		parsed, err := NewCurrency(currencyPart)
+		if err != nil {
+			return Amount{}, err
+		}
+		currency = parsed
+	}
+
+	return NewAmountFromCentsWithCurrency(cents, currency)
+}
+
+func parseCents(s string) (int64, error) {
+	integerPart, fractionalPart, hasFraction := strings.Cut(s, ".")
+
+	if hasFraction && (len(fractionalPart) == 0 || len(fractionalPart) > 2) {
+		return 0, ErrInvalidAmount
+	}
+
+	integerDigits, err := stripThousandsSeparators(integerPart)
+	if err != nil {
+		return 0, err
+	}
+
+	if !isDigits(integerDigits) || (hasFraction && !isDigits(fractionalPart)) {
+		return 0, ErrInvalidAmount
+	}
+
+	units, err := strconv.ParseInt(integerDigits, 10, 64)
+	if err != nil || units > MaxAmount/100 {
+		return 0, ErrInvalidAmount
+	}
+
+	var fraction int64
+	if hasFraction {
+		fraction, _ = strconv.ParseInt(fractionalPart, 10, 64)
+		if len(fractionalPart) == 1 {
+			fraction *= 10
+		}
+	}
+
+	return units*100 + fraction, nil
+}
+
+func stripThousandsSeparators(s string) (string, error) {
+	if !strings.Contains(s, ",") {
+		return s, nil
+	}
+
+	groups := strings.Split(s, ",")
+	if len(groups[0]) == 0 || len(groups[0]) > 3 {
+		return "", ErrInvalidAmount
+	}
+	for _, group := range groups[1:] {
+		if len(group) != 3 {
+			return "", ErrInvalidAmount
+		}
+	}
+
+	return strings.Join(groups, ""), nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 func (a Amount) Value() float64 {
@@ -39,25 +148,215 @@ func (a Amount) Cents() int64 {
 	return a.value
 }
 
+func (a Amount) Currency() Currency {
+	return a.currency
+}
+
 func (a Amount) String() string {
 	return fmt.Sprintf("%.2f", a.Value())
 }
 
+// amountJSON is the wire form of an Amount. The amount is a decimal string
+// rather than a number so that no precision is lost to float parsing.
+type amountJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as {"amount":"100.50","currency":"EUR"}.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(amountJSON{
+		Amount:   fmt.Sprintf("%d.%02d", a.value/100, a.value%100),
+		Currency: a.currency.Code(),
+	})
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON. The amount must be a
+// string accepted by ParseAmount without a currency suffix, and the currency
+// is required.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var raw amountJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return ErrInvalidAmount
+	}
+
+	if strings.IndexFunc(raw.Amount, unicode.IsLetter) >= 0 {
+		return ErrInvalidAmount
+	}
+
+	currency, err := NewCurrency(raw.Currency)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := ParseAmount(raw.Amount)
+	if err != nil {
+		return err
+	}
+
+	amount, err := NewAmountFromCentsWithCurrency(parsed.Cents(), currency)
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+var currencySymbols = map[string]string{
+	"EUR": "€",
+	"USD": "$",
+	"GBP": "£",
+}
+
+// Format renders the amount for display with grouped thousands, e.g. "€1,234.56".
+// Currencies without a known symbol are prefixed with their ISO code instead, e.g. "CHF 1,234.56".
+func (a Amount) Format(currency Currency) string {
+	units := strconv.FormatInt(a.value/100, 10)
+
+	var grouped strings.Builder
+	for i, r := range units {
+		if i > 0 && (len(units)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(r)
+	}
+
+	number := fmt.Sprintf("%s.%02d", grouped.String(), a.value%100)
+
+	if symbol, ok := currencySymbols[currency.Code()]; ok {
+		return symbol + number
+	}
+
+	return currency.Code() + " " + number
+}
+
 func (a Amount) Equals(other Amount) bool {
-	return a.value == other.value
+	return a.value == other.value && a.currency.Equals(other.currency)
+}
+
+// Compare returns -1, 0 or 1 depending on whether a is less than, equal to or
+// greater than other. Amounts in different currencies are not ordered and compare as 0.
+func (a Amount) Compare(other Amount) int {
+	if !a.currency.Equals(other.currency) {
+		return 0
+	}
+
+	switch {
+	case a.value < other.value:
+		return -1
+	case a.value > other.value:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (a Amount) GreaterThan(other Amount) bool {
+	return a.currency.Equals(other.currency) && a.value > other.value
+}
+
+func (a Amount) LessThan(other Amount) bool {
+	return a.currency.Equals(other.currency) && a.value < other.value
 }
 
 func (a Amount) IsZero() bool {
 	return a.value == 0
 }
 
-func (a Amount) Add(other Amount) Amount {
-	return Amount{value: a.value + other.value}
+// IsPositive reports whether the amount is at least one cent.
+func (a Amount) IsPositive() bool {
+	return a.value > 0
+}
+
+func (a Amount) Add(other Amount) (Amount, error) {
+	if !a.currency.Equals(other.currency) {
+		return Amount{}, ErrCurrencyMismatch
+	}
+	if a.value > MaxAmount-other.value {
+		return Amount{}, ErrAmountOverflow
+	}
+	return Amount{value: a.value + other.value, currency: a.currency}, nil
 }
 
 func (a Amount) Subtract(other Amount) (Amount, error) {
+	if !a.currency.Equals(other.currency) {
+		return Amount{}, ErrCurrencyMismatch
+	}
 	if a.value < other.value {
 		return Amount{}, fmt.Errorf("cannot subtract, result would be negative")
 	}
-	return Amount{value: a.value - other.value}, nil
+	return Amount{value: a.value - other.value, currency: a.currency}, nil
+}
+
+// Allocate splits the amount into n parts that sum exactly to the original,
+// handing the remainder cents out one by one to the first parts.
+func (a Amount) Allocate(n int) ([]Amount, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("cannot allocate, number of parts must be positive")
+	}
+
+	share := a.value / int64(n)
+	remainder := a.value % int64(n)
+
+	parts := make([]Amount, n)
+	for i := range parts {
+		cents := share
+		if int64(i) < remainder {
+			cents++
+		}
+		parts[i] = Amount{value: cents, currency: a.currency}
+	}
+
+	return parts, nil
+}
+
+// Multiply scales the amount by factor, rounding half-to-even to the nearest cent.
+// Negative factors yield a zero amount since amounts cannot be negative, and
+// results above MaxAmount are capped at MaxAmount.
+func (a Amount) Multiply(factor float64) Amount {
+	if factor <= 0 {
+		return Amount{value: 0, currency: a.currency}
+	}
+
+	cents := math.RoundToEven(float64(a.value) * factor)
+	if cents >= float64(MaxAmount) {
+		return Amount{value: MaxAmount, currency: a.currency}
+	}
+
+	return Amount{value: int64(cents), currency: a.currency}
+}
+
+// basisPointsPerUnit is the number of basis points in the whole amount.
+const basisPointsPerUnit = 10000
+
+// Percentage returns the fraction of the amount expressed in basis points,
+// e.g. 250 bps is 2.5%, rounding half-to-even to the nearest cent. It computes
+// in exact integer arithmetic, so even amounts beyond float64 precision come
+// out right. Negative basis points yield a zero amount, and results above
+// MaxAmount are capped at MaxAmount.
+func (a Amount) Percentage(bps int) Amount {
+	if bps <= 0 {
+		return Amount{value: 0, currency: a.currency}
+	}
+
+	divisor := big.NewInt(basisPointsPerUnit)
+	product := new(big.Int).Mul(big.NewInt(a.value), big.NewInt(int64(bps)))
+	cents, remainder := new(big.Int).QuoRem(product, divisor, new(big.Int))
+
+	// Round up past the half, and at exactly the half only from odd cents.
+	switch remainder.Lsh(remainder, 1).Cmp(divisor) {
+	case 1:
+		cents.Add(cents, big.NewInt(1))
+	case 0:
+		if cents.Bit(0) == 1 {
+			cents.Add(cents, big.NewInt(1))
+		}
+	}
+
+	if !cents.IsInt64() || cents.Int64() >= MaxAmount {
+		return Amount{value: MaxAmount, currency: a.currency}
+	}
+
+	return Amount{value: cents.Int64(), currency: a.currency}
 }
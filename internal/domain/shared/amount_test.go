@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -64,6 +65,216 @@ func TestNewAmount(t *testing.T) {
 	}
 }
 
+func TestAmount_Compare(t *testing.T) {
+	tests := []struct {
+		name            string
+		amount1         float64
+		amount2         float64
+		expectedCompare int
+		expectedGreater bool
+		expectedLess    bool
+	}{
+		{
+			name:            "equal amounts",
+			amount1:         10.50,
+			amount2:         10.50,
+			expectedCompare: 0,
+			expectedGreater: false,
+			expectedLess:    false,
+		},
+		{
+			name:            "greater amount",
+			amount1:         10.51,
+			amount2:         10.50,
+			expectedCompare: 1,
+			expectedGreater: true,
+			expectedLess:    false,
+		},
+		{
+			name:            "lesser amount",
+			amount1:         0.01,
+			amount2:         1000.00,
+			expectedCompare: -1,
+			expectedGreater: false,
+			expectedLess:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount1, _ := NewAmount(tt.amount1)
+			amount2, _ := NewAmount(tt.amount2)
+
+			assert.Equal(t, tt.expectedCompare, amount1.Compare(amount2))
+			assert.Equal(t, tt.expectedGreater, amount1.GreaterThan(amount2))
+			assert.Equal(t, tt.expectedLess, amount1.LessThan(amount2))
+		})
+	}
+}
+
+func TestAmount_Compare_CurrencyMismatch(t *testing.T) {
+	usd, _ := NewCurrency("USD")
+	eurAmount, _ := NewAmount(10.00)
+	usdAmount, _ := NewAmountWithCurrency(20.00, usd)
+
+	assert.Equal(t, 0, eurAmount.Compare(usdAmount), "expected different currencies to compare as 0")
+	assert.False(t, eurAmount.GreaterThan(usdAmount), "expected GreaterThan to be false across currencies")
+	assert.False(t, eurAmount.LessThan(usdAmount), "expected LessThan to be false across currencies")
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            string
+		expectError      error
+		expectedCents    int64
+		expectedCurrency string
+	}{
+		{name: "smallest unit", input: "0.01", expectedCents: 1, expectedCurrency: "EUR"},
+		{name: "large amount", input: "999999.99", expectedCents: 99999999, expectedCurrency: "EUR"},
+		{name: "thousands separators", input: "1,234.99", expectedCents: 123499, expectedCurrency: "EUR"},
+		{name: "integer only", input: "100", expectedCents: 10000, expectedCurrency: "EUR"},
+		{name: "single decimal", input: "100.5", expectedCents: 10050, expectedCurrency: "EUR"},
+		{name: "surrounding whitespace", input: "  100.50\t", expectedCents: 10050, expectedCurrency: "EUR"},
+		{name: "currency suffix", input: "12.00 USD", expectedCents: 1200, expectedCurrency: "USD"},
+		{name: "currency suffix without space", input: "12.00gbp", expectedCents: 1200, expectedCurrency: "GBP"},
+		{name: "too many decimals", input: "1.999", expectError: ErrInvalidAmount},
+		{name: "empty string", input: "", expectError: ErrInvalidAmount},
+		{name: "non-numeric", input: "abc", expectError: ErrInvalidAmount},
+		{name: "negative", input: "-1.00", expectError: ErrInvalidAmount},
+		{name: "trailing dot", input: "1.", expectError: ErrInvalidAmount},
+		{name: "missing integer part", input: ".50", expectError: ErrInvalidAmount},
+		{name: "misplaced thousands separator", input: "12,34.00", expectError: ErrInvalidAmount},
+		{name: "invalid currency suffix", input: "12.00 EURO", expectError: ErrInvalidCurrency},
+		{name: "overflow", input: "999999999999999999999", expectError: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := ParseAmount(tt.input)
+
+			if tt.expectError != nil {
+				assert.Equal(t, tt.expectError, err, "expected error for input %q", tt.input)
+			} else {
+				assert.NoError(t, err, "unexpected error for input %q", tt.input)
+				assert.Equal(t, tt.expectedCents, amount.Cents(), "expected %d, got %d", tt.expectedCents, amount.Cents())
+				assert.Equal(t, tt.expectedCurrency, amount.Currency().Code())
+			}
+		})
+	}
+}
+
+func TestAmount_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		cents    int64
+		currency string
+		expected string
+	}{
+		{name: "euros and cents", cents: 10050, currency: "EUR", expected: `{"amount":"100.50","currency":"EUR"}`},
+		{name: "zero", cents: 0, currency: "EUR", expected: `{"amount":"0.00","currency":"EUR"}`},
+		{name: "single cent", cents: 1, currency: "USD", expected: `{"amount":"0.01","currency":"USD"}`},
+		{name: "maximum amount", cents: MaxAmount, currency: "EUR", expected: `{"amount":"922337203685477.58","currency":"EUR"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currency, err := NewCurrency(tt.currency)
+			assert.NoError(t, err)
+
+			amount, err := NewAmountFromCentsWithCurrency(tt.cents, currency)
+			assert.NoError(t, err)
+
+			data, err := json.Marshal(amount)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(data))
+		})
+	}
+}
+
+func TestAmount_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            string
+		expectError      error
+		expectedCents    int64
+		expectedCurrency string
+	}{
+		{name: "two decimals", input: `{"amount":"100.50","currency":"EUR"}`, expectedCents: 10050, expectedCurrency: "EUR"},
+		{name: "lowercase currency", input: `{"amount":"12","currency":"usd"}`, expectedCents: 1200, expectedCurrency: "USD"},
+		{name: "thousands separators", input: `{"amount":"1,234.99","currency":"GBP"}`, expectedCents: 123499, expectedCurrency: "GBP"},
+		{name: "negative", input: `{"amount":"-1.00","currency":"EUR"}`, expectError: ErrInvalidAmount},
+		{name: "too many decimals", input: `{"amount":"1.999","currency":"EUR"}`, expectError: ErrInvalidAmount},
+		{name: "overflow", input: `{"amount":"999999999999999999999","currency":"EUR"}`, expectError: ErrInvalidAmount},
+		{name: "numeric amount", input: `{"amount":100.50,"currency":"EUR"}`, expectError: ErrInvalidAmount},
+		{name: "currency suffix in amount", input: `{"amount":"12.00 USD","currency":"EUR"}`, expectError: ErrInvalidAmount},
+		{name: "missing currency", input: `{"amount":"12.00"}`, expectError: ErrInvalidCurrency},
+		{name: "unsupported currency", input: `{"amount":"12.00","currency":"EURO"}`, expectError: ErrInvalidCurrency},
+		{name: "not an object", input: `"100.50"`, expectError: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var amount Amount
+			err := json.Unmarshal([]byte(tt.input), &amount)
+
+			if tt.expectError != nil {
+				assert.ErrorIs(t, err, tt.expectError, "expected error for input %s", tt.input)
+			} else {
+				assert.NoError(t, err, "unexpected error for input %s", tt.input)
+				assert.Equal(t, tt.expectedCents, amount.Cents())
+				assert.Equal(t, tt.expectedCurrency, amount.Currency().Code())
+			}
+		})
+	}
+}
+
+func TestAmount_JSONRoundTrip(t *testing.T) {
+	usd, err := NewCurrency("USD")
+	assert.NoError(t, err)
+
+	for _, cents := range []int64{0, 1, 10050, 123456789, MaxAmount} {
+		original, err := NewAmountFromCentsWithCurrency(cents, usd)
+		assert.NoError(t, err)
+
+		data, err := json.Marshal(original)
+		assert.NoError(t, err)
+
+		var decoded Amount
+		err = json.Unmarshal(data, &decoded)
+		assert.NoError(t, err)
+		assert.True(t, original.Equals(decoded), "expected %s to round-trip, got %s", data, decoded)
+	}
+}
+
+func TestAmount_Format(t *testing.T) {
+	usd, _ := NewCurrency("USD")
+	chf, _ := NewCurrency("CHF")
+
+	tests := []struct {
+		name     string
+		cents    int64
+		currency Currency
+		expected string
+	}{
+		{name: "euro above 1000", cents: 123456, currency: EUR, expected: "€1,234.56"},
+		{name: "dollar above 1000", cents: 123456, currency: usd, expected: "$1,234.56"},
+		{name: "euro below 1000", cents: 99999, currency: EUR, expected: "€999.99"},
+		{name: "millions", cents: 123456789012, currency: EUR, expected: "€1,234,567,890.12"},
+		{name: "zero", cents: 0, currency: EUR, expected: "€0.00"},
+		{name: "unknown symbol above 1000", cents: 123456, currency: chf, expected: "CHF 1,234.56"},
+		{name: "unknown symbol below 1000", cents: 505, currency: chf, expected: "CHF 5.05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, _ := NewAmountFromCents(tt.cents)
+
+			assert.Equal(t, tt.expected, amount.Format(tt.currency))
+		})
+	}
+}
+
 func TestAmount_IsZero(t *testing.T) {
 	zeroAmount, _ := NewAmount(0.0)
 	nonZeroAmount, _ := NewAmount(10.50)
@@ -72,16 +283,54 @@ func TestAmount_IsZero(t *testing.T) {
 	assert.False(t, nonZeroAmount.IsZero(), "expected non-zero amount to return false for IsZero()")
 }
 
+func TestAmount_IsPositive(t *testing.T) {
+	zeroAmount, _ := NewAmount(0.0)
+	oneCent, _ := NewAmountFromCents(1)
+
+	assert.False(t, zeroAmount.IsPositive(), "expected zero amount not to be positive")
+	assert.True(t, oneCent.IsPositive(), "expected one cent to be positive")
+
+	_, err := NewAmount(-1)
+	assert.ErrorIs(t, err, ErrInvalidAmount, "expected a negative amount to stay invalid rather than zero")
+	assert.NotErrorIs(t, err, ErrZeroAmount)
+}
+
 func TestAmount_Add(t *testing.T) {
 	amount1, _ := NewAmount(10.50)
 	amount2, _ := NewAmount(5.25)
 	
-	result := amount1.Add(amount2)
+	result, err := amount1.Add(amount2)
 	expected := 15.75
 
+	assert.NoError(t, err, "unexpected error adding same-currency amounts")
 	assert.Equal(t, expected, result.Value(), "expected %f, got %f", expected, result.Value())
 }
 
+func TestAmount_Add_Overflow(t *testing.T) {
+	nearMax, err := NewAmountFromCents(MaxAmount - 1)
+	assert.NoError(t, err)
+
+	_, err = nearMax.Add(nearMax)
+	assert.Equal(t, ErrAmountOverflow, err, "expected ErrAmountOverflow when adding near-max amounts")
+
+	one, _ := NewAmountFromCents(1)
+	result, err := nearMax.Add(one)
+	assert.NoError(t, err, "adding up to MaxAmount should succeed")
+	assert.Equal(t, MaxAmount, result.Cents())
+}
+
+func TestNewAmountFromCents_AboveMax(t *testing.T) {
+	_, err := NewAmountFromCents(MaxAmount + 1)
+	assert.Equal(t, ErrInvalidAmount, err, "expected ErrInvalidAmount above MaxAmount")
+}
+
+func TestAmount_Multiply_CapsAtMax(t *testing.T) {
+	nearMax, _ := NewAmountFromCents(MaxAmount - 1)
+
+	result := nearMax.Multiply(3)
+	assert.Equal(t, MaxAmount, result.Cents(), "expected multiply to cap at MaxAmount")
+}
+
 func TestAmount_Subtract(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -137,3 +386,140 @@ func TestAmount_Equals(t *testing.T) {
 	assert.True(t, amount1.Equals(amount2), "expected equal amounts to return true for Equals()")
 	assert.False(t, amount1.Equals(amount3), "expected different amounts to return false for Equals()")
 }
+
+func TestNewAmountWithCurrency(t *testing.T) {
+	usd, _ := NewCurrency("USD")
+
+	amount, err := NewAmountWithCurrency(42.10, usd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4210), amount.Cents())
+	assert.True(t, amount.Currency().Equals(usd), "expected USD currency")
+
+	_, err = NewAmountWithCurrency(-1, usd)
+	assert.Equal(t, ErrInvalidAmount, err, "expected ErrInvalidAmount")
+}
+
+func TestNewAmount_DefaultsToEUR(t *testing.T) {
+	fromValue, _ := NewAmount(10.00)
+	fromCents, _ := NewAmountFromCents(1000)
+
+	assert.True(t, fromValue.Currency().Equals(EUR), "expected NewAmount to default to EUR")
+	assert.True(t, fromCents.Currency().Equals(EUR), "expected NewAmountFromCents to default to EUR")
+}
+
+func TestAmount_CurrencyMismatch(t *testing.T) {
+	usd, _ := NewCurrency("USD")
+	eurAmount, _ := NewAmount(10.00)
+	usdAmount, _ := NewAmountWithCurrency(10.00, usd)
+
+	assert.False(t, eurAmount.Equals(usdAmount), "expected amounts in different currencies to differ")
+
+	_, err := eurAmount.Add(usdAmount)
+	assert.Equal(t, ErrCurrencyMismatch, err, "expected ErrCurrencyMismatch when adding different currencies")
+
+	_, err = eurAmount.Subtract(usdAmount)
+	assert.Equal(t, ErrCurrencyMismatch, err, "expected ErrCurrencyMismatch when subtracting different currencies")
+}
+
+func TestAmount_Multiply(t *testing.T) {
+	tests := []struct {
+		name     string
+		cents    int64
+		factor   float64
+		expected int64
+	}{
+		{name: "whole factor", cents: 1050, factor: 2, expected: 2100},
+		{name: "fractional factor", cents: 10000, factor: 0.015, expected: 150},
+		{name: "half cent rounds down to even", cents: 1, factor: 0.5, expected: 0},
+		{name: "half cent rounds up to even", cents: 3, factor: 0.5, expected: 2},
+		{name: "below half cent rounds down", cents: 1, factor: 0.49, expected: 0},
+		{name: "above half cent rounds up", cents: 1, factor: 0.51, expected: 1},
+		{name: "zero factor", cents: 1050, factor: 0, expected: 0},
+		{name: "negative factor clamps to zero", cents: 1050, factor: -1, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, _ := NewAmountFromCents(tt.cents)
+			result := amount.Multiply(tt.factor)
+
+			assert.Equal(t, tt.expected, result.Cents(), "expected %d, got %d", tt.expected, result.Cents())
+			assert.True(t, result.Currency().Equals(amount.Currency()), "expected currency to be preserved")
+		})
+	}
+}
+
+func TestAmount_Percentage(t *testing.T) {
+	tests := []struct {
+		name     string
+		cents    int64
+		bps      int
+		expected int64
+	}{
+		{name: "250 bps of 100.00", cents: 10000, bps: 250, expected: 250},
+		{name: "100 bps of 12.34", cents: 1234, bps: 100, expected: 12},
+		{name: "250 bps of 1.00 rounds half to even", cents: 100, bps: 250, expected: 2},
+		{name: "250 bps of 1.40 rounds half to even", cents: 140, bps: 250, expected: 4},
+		{name: "10000 bps is the full amount", cents: 4321, bps: 10000, expected: 4321},
+		{name: "half a cent rounds down to even", cents: 1, bps: 5000, expected: 0},
+		{name: "one and a half cents round up to even", cents: 3, bps: 5000, expected: 2},
+		{name: "just above half a cent rounds up", cents: 5001, bps: 1, expected: 1},
+		{name: "just below half a cent rounds down", cents: 4999, bps: 1, expected: 0},
+		{name: "exact beyond float64 precision", cents: 1<<53 + 1, bps: 10000, expected: 1<<53 + 1},
+		{name: "zero bps", cents: 4321, bps: 0, expected: 0},
+		{name: "negative bps clamps to zero", cents: 4321, bps: -250, expected: 0},
+		{name: "caps at the maximum amount", cents: MaxAmount, bps: 20000, expected: MaxAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, _ := NewAmountFromCents(tt.cents)
+			result := amount.Percentage(tt.bps)
+
+			assert.Equal(t, tt.expected, result.Cents(), "expected %d, got %d", tt.expected, result.Cents())
+		})
+	}
+}
+
+func TestAmount_Allocate(t *testing.T) {
+	tests := []struct {
+		name     string
+		cents    int64
+		parts    int
+		expected []int64
+	}{
+		{name: "10.00 into 3", cents: 1000, parts: 3, expected: []int64{334, 333, 333}},
+		{name: "even split", cents: 1000, parts: 4, expected: []int64{250, 250, 250, 250}},
+		{name: "single part", cents: 1234, parts: 1, expected: []int64{1234}},
+		{name: "more parts than cents", cents: 2, parts: 3, expected: []int64{1, 1, 0}},
+		{name: "remainder of two", cents: 1001, parts: 3, expected: []int64{334, 334, 333}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, _ := NewAmountFromCents(tt.cents)
+
+			parts, err := amount.Allocate(tt.parts)
+			assert.NoError(t, err)
+			assert.Len(t, parts, tt.parts)
+
+			var sum int64
+			for i, part := range parts {
+				assert.Equal(t, tt.expected[i], part.Cents(), "unexpected cents for part %d", i)
+				assert.True(t, part.Currency().Equals(amount.Currency()), "expected currency to be preserved")
+				sum += part.Cents()
+			}
+			assert.Equal(t, tt.cents, sum, "parts should sum back to the original")
+		})
+	}
+}
+
+func TestAmount_Allocate_InvalidParts(t *testing.T) {
+	amount, _ := NewAmountFromCents(1000)
+
+	for _, n := range []int{0, -1} {
+		parts, err := amount.Allocate(n)
+		assert.Error(t, err, "expected error for %d parts", n)
+		assert.Nil(t, parts)
+	}
+}
@@ -2,6 +2,6 @@ package shared
 
 import "time"
 
-type TimeProvider interface {
+type Clock interface {
 	Now() time.Time
 }
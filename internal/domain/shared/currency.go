@@ -0,0 +1,58 @@
+package shared
+
+import "strings"
+
+type Currency struct {
+	code string
+}
+
+// iso4217Codes are the active ISO 4217 currency codes, including fund codes
+// but not precious metals or the testing and no-currency codes.
+var iso4217Codes = func() map[string]struct{} {
+	codes := strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND
+		BOB BOV BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY COP COU
+		CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP
+		GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES
+		KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD
+		MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD NGN NIO NOK NPR NZD OMR
+		PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD
+		SHP SLE SLL SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD
+		TZS UAH UGX USD USN UYI UYU UYW UZS VED VES VND VUV WST XAF XCD XCG XOF
+		XPF YER ZAR ZMW ZWG ZWL
+	`)
+
+	set := make(map[string]struct{}, len(codes))
+	for _, code := range codes {
+		set[code] = struct{}{}
+	}
+	return set
+}()
+
+// EUR is the default currency for amounts created without an explicit currency.
+var EUR = Currency{code: "EUR"}
+
+// NewCurrency returns the currency for an ISO 4217 code, ignoring case and
+// surrounding spaces. Codes that are not active ISO 4217 currencies fail with
+// ErrInvalidCurrency.
+func NewCurrency(code string) (Currency, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(code))
+
+	if _, ok := iso4217Codes[normalized]; !ok {
+		return Currency{}, ErrInvalidCurrency
+	}
+
+	return Currency{code: normalized}, nil
+}
+
+func (c Currency) Code() string {
+	return c.code
+}
+
+func (c Currency) String() string {
+	return c.code
+}
+
+func (c Currency) Equals(other Currency) bool {
+	return c.code == other.code
+}
@@ -0,0 +1,86 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectError bool
+		expected    string
+	}{
+		{
+			name:        "valid uppercase code",
+			input:       "EUR",
+			expectError: false,
+			expected:    "EUR",
+		},
+		{
+			name:        "valid lowercase code",
+			input:       "usd",
+			expectError: false,
+			expected:    "USD",
+		},
+		{
+			name:        "valid code with surrounding spaces",
+			input:       " GBP ",
+			expectError: false,
+			expected:    "GBP",
+		},
+		{
+			name:        "invalid too short",
+			input:       "EU",
+			expectError: true,
+		},
+		{
+			name:        "invalid too long",
+			input:       "EURO",
+			expectError: true,
+		},
+		{
+			name:        "invalid with digits",
+			input:       "E1R",
+			expectError: true,
+		},
+		{
+			name:        "empty string",
+			input:       "",
+			expectError: true,
+		},
+		{
+			name:        "well-formed but unknown code",
+			input:       "ABC",
+			expectError: true,
+		},
+		{
+			name:        "no-currency code",
+			input:       "XXX",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currency, err := NewCurrency(tt.input)
+
+			if tt.expectError {
+				assert.Equal(t, ErrInvalidCurrency, err, "expected ErrInvalidCurrency")
+			} else {
+				assert.NoError(t, err, "unexpected error for input %q", tt.input)
+				assert.Equal(t, tt.expected, currency.Code(), "expected %q, got %q", tt.expected, currency.Code())
+			}
+		})
+	}
+}
+
+func TestCurrency_Equals(t *testing.T) {
+	eur, _ := NewCurrency("eur")
+	usd, _ := NewCurrency("USD")
+
+	assert.True(t, eur.Equals(EUR), "expected normalized EUR to equal EUR")
+	assert.False(t, eur.Equals(usd), "expected different currencies to be different")
+}
@@ -3,12 +3,28 @@ package shared
 import "errors"
 
 var (
-	ErrInvalidIBAN               = errors.New("invalid IBAN format")
-	ErrInvalidAmount             = errors.New("invalid amount")
-	ErrInvalidIdempotencyKey     = errors.New("invalid idempotency key")
-	ErrInvalidPaymentStatus      = errors.New("invalid payment status")
-	ErrInvalidStatusTransition   = errors.New("invalid status transition")
-	ErrPaymentNotFound           = errors.New("payment not found")
-	ErrDuplicatePayment          = errors.New("duplicate payment")
-	ErrDuplicateIdempotencyKey   = errors.New("duplicate idempotency key")
+	ErrInvalidIBAN             = errors.New("invalid IBAN format")
+	ErrInvalidAmount           = errors.New("invalid amount")
+	ErrInvalidCurrency         = errors.New("invalid currency")
+	ErrCurrencyMismatch        = errors.New("currency mismatch")
+	ErrAmountOverflow          = errors.New("amount overflow")
+	ErrZeroAmount              = errors.New("amount must be greater than zero")
+	ErrAmountExceedsLimit      = errors.New("amount exceeds limit")
+	ErrInvalidIdempotencyKey   = errors.New("invalid idempotency key")
+	ErrInvalidDebtorName       = errors.New("invalid debtor name")
+	ErrInvalidCreditorName     = errors.New("invalid creditor name")
+	ErrSameDebtorCreditor      = errors.New("debtor and creditor IBAN are identical")
+	ErrCountryNotAllowed       = errors.New("country not allowed")
+	ErrInvalidReference        = errors.New("invalid payment reference")
+	ErrInvalidExecutionDate    = errors.New("invalid execution date")
+	ErrInvalidMetadata         = errors.New("invalid payment metadata")
+	ErrInvalidPaymentStatus    = errors.New("invalid payment status")
+	ErrInvalidStatusTransition = errors.New("invalid status transition")
+	ErrInvalidDateRange        = errors.New("invalid date range")
+	ErrInvalidAmountRange      = errors.New("invalid amount range")
+	ErrPaymentNotFound         = errors.New("payment not found")
+	ErrDuplicatePayment        = errors.New("duplicate payment")
+	ErrDuplicateIdempotencyKey = errors.New("duplicate idempotency key")
+	ErrDuplicatePaymentID      = errors.New("duplicate payment id")
+	ErrConcurrentModification  = errors.New("concurrent modification")
 )
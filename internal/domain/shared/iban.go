@@ -1,6 +1,9 @@
 package shared
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"regexp"
 	"strings"
 )
@@ -11,6 +14,16 @@ type IBAN struct {
 
 var ibanRegex = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{4}[0-9]{7}([A-Z0-9]?){0,16}$`)
 
+// ibanLengths holds the registered IBAN length per country code for the SEPA area.
+var ibanLengths = map[string]int{
+	"AD": 24, "AT": 20, "BE": 16, "BG": 22, "CH": 21, "CY": 28, "CZ": 24,
+	"DE": 22, "DK": 18, "EE": 20, "ES": 24, "FI": 18, "FR": 27, "GB": 22,
+	"GI": 23, "GR": 27, "HR": 21, "HU": 28, "IE": 22, "IS": 26, "IT": 27,
+	"LI": 21, "LT": 20, "LU": 20, "LV": 21, "MC": 27, "MT": 31, "NL": 18,
+	"NO": 15, "PL": 28, "PT": 25, "RO": 24, "SE": 24, "SI": 19, "SK": 24,
+	"SM": 27, "VA": 22,
+}
+
 func NewIBAN(value string) (IBAN, error) {
 	normalized := strings.ToUpper(strings.ReplaceAll(value, " ", ""))
 
@@ -18,17 +31,132 @@ func NewIBAN(value string) (IBAN, error) {
 		return IBAN{}, ErrInvalidIBAN
 	}
 
+	expectedLength, known := ibanLengths[normalized[:2]]
+	if !known || len(normalized) != expectedLength {
+		return IBAN{}, ErrInvalidIBAN
+	}
+
+	if !hasValidChecksum(normalized) {
+		return IBAN{}, ErrInvalidIBAN
+	}
+
 	return IBAN{value: normalized}, nil
 }
 
-func (i IBAN) Value() string {
+func (i IBAN) String() string {
 	return i.value
 }
 
-func (i IBAN) String() string {
-	return i.value
+// Formatted returns the IBAN in the conventional print format, grouped in
+// blocks of four characters separated by a space.
+func (i IBAN) Formatted() string {
+	var builder strings.Builder
+	for idx, r := range i.value {
+		if idx > 0 && idx%4 == 0 {
+			builder.WriteByte(' ')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+func (i IBAN) CountryCode() string {
+	if len(i.value) < 2 {
+		return ""
+	}
+	return i.value[:2]
+}
+
+func (i IBAN) BBAN() string {
+	if len(i.value) < 4 {
+		return ""
+	}
+	return i.value[4:]
 }
 
 func (i IBAN) Equals(other IBAN) bool {
 	return i.value == other.value
 }
+
+func (i IBAN) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.value)
+}
+
+func (i *IBAN) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return ErrInvalidIBAN
+	}
+
+	parsed, err := NewIBAN(raw)
+	if err != nil {
+		return err
+	}
+
+	*i = parsed
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, which also lets an IBAN be
+// used as a map key in JSON and other text-based encodings.
+func (i IBAN) MarshalText() ([]byte, error) {
+	return []byte(i.value), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, running the text through
+// NewIBAN validation.
+func (i *IBAN) UnmarshalText(text []byte) error {
+	parsed, err := NewIBAN(string(text))
+	if err != nil {
+		return err
+	}
+
+	*i = parsed
+	return nil
+}
+
+// Value implements driver.Valuer so an IBAN can be passed directly as a query argument.
+func (i IBAN) Value() (driver.Value, error) {
+	return i.value, nil
+}
+
+// Scan implements sql.Scanner, running the stored value through NewIBAN validation.
+func (i *IBAN) Scan(src interface{}) error {
+	var raw string
+	switch v := src.(type) {
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("%w: cannot scan %T into IBAN", ErrInvalidIBAN, src)
+	}
+
+	parsed, err := NewIBAN(raw)
+	if err != nil {
+		return err
+	}
+
+	*i = parsed
+	return nil
+}
+
+// hasValidChecksum verifies the ISO 13616 mod-97 check digits. The remainder
+// is computed digit by digit so the rearranged number never has to fit in an int.
+func hasValidChecksum(iban string) bool {
+	rearranged := iban[4:] + iban[:4]
+
+	remainder := 0
+	for _, r := range rearranged {
+		switch {
+		case r >= '0' && r <= '9':
+			remainder = (remainder*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			remainder = (remainder*100 + int(r-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+
+	return remainder == 1
+}
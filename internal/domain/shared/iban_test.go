@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -46,6 +47,26 @@ func TestNewIBAN(t *testing.T) {
 			input:       "GB82-WEST-1234-5698-7654-32",
 			expectError: true,
 		},
+		{
+			name:        "invalid GB check digits",
+			input:       "GB00WEST12345698765432",
+			expectError: true,
+		},
+		{
+			name:        "invalid FR check digits",
+			input:       "FR1520041010050500013M02606",
+			expectError: true,
+		},
+		{
+			name:        "invalid DE check digits",
+			input:       "DE88370400440532013000",
+			expectError: true,
+		},
+		{
+			name:        "invalid BBAN with valid structure",
+			input:       "GB82WEST12345698765433",
+			expectError: true,
+		},
 		{
 			name:        "empty IBAN",
 			input:       "",
@@ -62,7 +83,41 @@ func TestNewIBAN(t *testing.T) {
 				assert.Equal(t, ErrInvalidIBAN, err, "expected ErrInvalidIBAN")
 			} else {
 				assert.NoError(t, err, "unexpected error for input %q", tt.input)
-				assert.Equal(t, tt.expected, iban.Value(), "expected %q, got %q", tt.expected, iban.Value())
+				assert.Equal(t, tt.expected, iban.String(), "expected %q, got %q", tt.expected, iban.String())
+			}
+		})
+	}
+}
+
+func TestNewIBAN_CountryLength(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectError bool
+	}{
+		{name: "valid BE (16)", input: "BE68539007547034", expectError: false},
+		{name: "valid NO (15)", input: "NO9386011117947", expectError: false},
+		{name: "valid NL (18)", input: "NL91ABNA0417164300", expectError: false},
+		{name: "valid DE (22)", input: "DE89370400440532013000", expectError: false},
+		{name: "valid ES (24)", input: "ES9121000418450200051332", expectError: false},
+		{name: "valid IT (27)", input: "IT60X0542811101000000123456", expectError: false},
+		{name: "valid MT (31)", input: "MT84MALT011000012345MTLCAST001S", expectError: false},
+		{name: "DE one character short", input: "DE8937040044053201300", expectError: true},
+		{name: "DE one character long", input: "DE893704004405320130000", expectError: true},
+		{name: "GB one character short", input: "GB82WEST1234569876543", expectError: true},
+		{name: "FR one character long", input: "FR1420041010050500013M026060", expectError: true},
+		{name: "NL one character long", input: "NL91ABNA04171643000", expectError: true},
+		{name: "unknown country code", input: "XX82WEST12345698765432", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewIBAN(tt.input)
+
+			if tt.expectError {
+				assert.Equal(t, ErrInvalidIBAN, err, "expected ErrInvalidIBAN for input %q", tt.input)
+			} else {
+				assert.NoError(t, err, "unexpected error for input %q", tt.input)
 			}
 		})
 	}
@@ -75,6 +130,86 @@ func TestIBAN_String(t *testing.T) {
 	assert.Equal(t, expected, iban.String(), "expected %q, got %q", expected, iban.String())
 }
 
+func TestIBAN_Formatted(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "length 22 leaves trailing group of two",
+			input:    "GB82WEST12345698765432",
+			expected: "GB82 WEST 1234 5698 7654 32",
+		},
+		{
+			name:     "length 27 leaves trailing group of three",
+			input:    "FR1420041010050500013M02606",
+			expected: "FR14 2004 1010 0505 0001 3M02 606",
+		},
+		{
+			name:     "length 15 leaves trailing group of three",
+			input:    "NO9386011117947",
+			expected: "NO93 8601 1117 947",
+		},
+		{
+			name:     "length 16 has no partial group",
+			input:    "BE68539007547034",
+			expected: "BE68 5390 0754 7034",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iban, err := NewIBAN(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, iban.Formatted(), "expected %q, got %q", tt.expected, iban.Formatted())
+			assert.Equal(t, tt.input, iban.String(), "String() should stay compact")
+		})
+	}
+}
+
+func TestIBAN_CountryCode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "GB82 WEST 1234 5698 7654 32", expected: "GB"},
+		{input: "FR1420041010050500013M02606", expected: "FR"},
+		{input: "de89370400440532013000", expected: "DE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			iban, err := NewIBAN(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, iban.CountryCode(), "expected %q, got %q", tt.expected, iban.CountryCode())
+		})
+	}
+
+	assert.Equal(t, "", IBAN{}.CountryCode(), "expected empty country code for zero value")
+}
+
+func TestIBAN_BBAN(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "GB82 WEST 1234 5698 7654 32", expected: "WEST12345698765432"},
+		{input: "FR1420041010050500013M02606", expected: "20041010050500013M02606"},
+		{input: "de89370400440532013000", expected: "370400440532013000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			iban, err := NewIBAN(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, iban.BBAN(), "expected %q, got %q", tt.expected, iban.BBAN())
+		})
+	}
+
+	assert.Equal(t, "", IBAN{}.BBAN(), "expected empty BBAN for zero value")
+}
+
 func TestIBAN_Equals(t *testing.T) {
 	iban1, _ := NewIBAN("GB82WEST12345698765432")
 	iban2, _ := NewIBAN("gb82 west 1234 5698 7654 32")
@@ -83,3 +218,203 @@ func TestIBAN_Equals(t *testing.T) {
 	assert.True(t, iban1.Equals(iban2), "expected IBANs to be equal (normalized)")
 	assert.False(t, iban1.Equals(iban3), "expected IBANs to be different")
 }
+
+func TestIBAN_MarshalJSON(t *testing.T) {
+	iban, _ := NewIBAN("GB82 WEST 1234 5698 7654 32")
+
+	data, err := json.Marshal(iban)
+	assert.NoError(t, err)
+	assert.Equal(t, `"GB82WEST12345698765432"`, string(data))
+
+	wrapped, err := json.Marshal(struct {
+		IBAN IBAN `json:"iban"`
+	}{IBAN: iban})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"iban":"GB82WEST12345698765432"}`, string(wrapped))
+}
+
+func TestIBAN_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectError bool
+		expected    string
+	}{
+		{
+			name:        "compact IBAN",
+			input:       `"GB82WEST12345698765432"`,
+			expectError: false,
+			expected:    "GB82WEST12345698765432",
+		},
+		{
+			name:        "IBAN with whitespace and lowercase",
+			input:       `"fr14 2004 1010 0505 0001 3m02 606"`,
+			expectError: false,
+			expected:    "FR1420041010050500013M02606",
+		},
+		{
+			name:        "invalid checksum",
+			input:       `"GB00WEST12345698765432"`,
+			expectError: true,
+		},
+		{
+			name:        "non-string value",
+			input:       `12345`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var iban IBAN
+			err := json.Unmarshal([]byte(tt.input), &iban)
+
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrInvalidIBAN)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, iban.String())
+			}
+		})
+	}
+}
+
+func TestIBAN_JSONRoundTrip(t *testing.T) {
+	original, _ := NewIBAN("DE89 3704 0044 0532 0130 00")
+
+	data, err := json.Marshal(original)
+	assert.NoError(t, err)
+
+	var decoded IBAN
+	err = json.Unmarshal(data, &decoded)
+	assert.NoError(t, err)
+	assert.True(t, original.Equals(decoded), "expected round-tripped IBAN to equal original")
+}
+
+func TestIBAN_TextRoundTrip(t *testing.T) {
+	original, _ := NewIBAN("DE89 3704 0044 0532 0130 00")
+
+	text, err := original.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "DE89370400440532013000", string(text))
+
+	var decoded IBAN
+	err = decoded.UnmarshalText(text)
+	assert.NoError(t, err)
+	assert.True(t, original.Equals(decoded), "expected round-tripped IBAN to equal original")
+}
+
+func TestIBAN_UnmarshalText(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectError bool
+		expected    string
+	}{
+		{
+			name:        "IBAN with whitespace and lowercase",
+			input:       "fr14 2004 1010 0505 0001 3m02 606",
+			expectError: false,
+			expected:    "FR1420041010050500013M02606",
+		},
+		{
+			name:        "invalid checksum",
+			input:       "GB00WEST12345698765432",
+			expectError: true,
+		},
+		{
+			name:        "empty text",
+			input:       "",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var iban IBAN
+			err := iban.UnmarshalText([]byte(tt.input))
+
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrInvalidIBAN)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, iban.String())
+			}
+		})
+	}
+}
+
+func TestIBAN_MapKey(t *testing.T) {
+	iban, _ := NewIBAN("GB82 WEST 1234 5698 7654 32")
+
+	data, err := json.Marshal(map[IBAN]string{iban: "Jane Smith"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"GB82WEST12345698765432":"Jane Smith"}`, string(data))
+
+	var decoded map[IBAN]string
+	err = json.Unmarshal(data, &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane Smith", decoded[iban])
+
+	err = json.Unmarshal([]byte(`{"GB00WEST12345698765432":"Jane Smith"}`), &decoded)
+	assert.ErrorIs(t, err, ErrInvalidIBAN)
+}
+
+func TestIBAN_DriverValue(t *testing.T) {
+	iban, _ := NewIBAN("GB82 WEST 1234 5698 7654 32")
+
+	value, err := iban.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "GB82WEST12345698765432", value)
+}
+
+func TestIBAN_Scan(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         interface{}
+		expectError bool
+		expected    string
+	}{
+		{
+			name:        "string source",
+			src:         "GB82WEST12345698765432",
+			expectError: false,
+			expected:    "GB82WEST12345698765432",
+		},
+		{
+			name:        "byte slice source",
+			src:         []byte("FR1420041010050500013M02606"),
+			expectError: false,
+			expected:    "FR1420041010050500013M02606",
+		},
+		{
+			name:        "invalid checksum",
+			src:         "GB00WEST12345698765432",
+			expectError: true,
+		},
+		{
+			name:        "nil source",
+			src:         nil,
+			expectError: true,
+		},
+		{
+			name:        "integer source",
+			src:         int64(42),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var iban IBAN
+			err := iban.Scan(tt.src)
+
+			if tt.expectError {
+				assert.ErrorIs(t, err, ErrInvalidIBAN)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, iban.String())
+			}
+		})
+	}
+}
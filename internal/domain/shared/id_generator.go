@@ -0,0 +1,5 @@
+package shared
+
+type IDGenerator interface {
+	NewID() (string, error)
+}
@@ -1,7 +1,11 @@
 package shared
 
 import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
 	"regexp"
+	"strings"
 )
 
 type IdempotencyKey struct {
@@ -10,6 +14,11 @@ type IdempotencyKey struct {
 
 var idempotencyKeyRegex = regexp.MustCompile(`^[A-Za-z0-9]{10}$`)
 
+const (
+	idempotencyKeyLength   = 10
+	idempotencyKeyAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
 func NewIdempotencyKey(value string) (IdempotencyKey, error) {
 	if !idempotencyKeyRegex.MatchString(value) {
 		return IdempotencyKey{}, ErrInvalidIdempotencyKey
@@ -18,6 +27,30 @@ func NewIdempotencyKey(value string) (IdempotencyKey, error) {
 	return IdempotencyKey{value: value}, nil
 }
 
+// NewIdempotencyKeyCaseInsensitive validates value like NewIdempotencyKey but
+// stores it uppercased, so keys differing only in case are equal and collide
+// on save. Lookups must build their key with this constructor too.
+func NewIdempotencyKeyCaseInsensitive(value string) (IdempotencyKey, error) {
+	return NewIdempotencyKey(strings.ToUpper(value))
+}
+
+// GenerateIdempotencyKey returns a random key for clients that did not supply one.
+// rand.Int samples uniformly, so every character of the alphabet is equally likely.
+func GenerateIdempotencyKey() (IdempotencyKey, error) {
+	alphabetSize := big.NewInt(int64(len(idempotencyKeyAlphabet)))
+
+	key := make([]byte, idempotencyKeyLength)
+	for i := range key {
+		idx, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return IdempotencyKey{}, fmt.Errorf("failed to generate idempotency key: %w", err)
+		}
+		key[i] = idempotencyKeyAlphabet[idx.Int64()]
+	}
+
+	return NewIdempotencyKey(string(key))
+}
+
 func (k IdempotencyKey) Value() string {
 	return k.value
 }
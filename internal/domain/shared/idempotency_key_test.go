@@ -99,3 +99,38 @@ func TestIdempotencyKey_Equals(t *testing.T) {
 	assert.True(t, key1.Equals(key2), "expected equal keys to return true for Equals()")
 	assert.False(t, key1.Equals(key3), "expected different keys to return false for Equals()")
 }
+
+func TestNewIdempotencyKeyCaseInsensitive(t *testing.T) {
+	key, err := NewIdempotencyKeyCaseInsensitive("abc123XYZ0")
+	assert.NoError(t, err)
+	assert.Equal(t, "ABC123XYZ0", key.Value())
+
+	_, err = NewIdempotencyKeyCaseInsensitive("abc-123XYZ")
+	assert.ErrorIs(t, err, ErrInvalidIdempotencyKey)
+}
+
+func TestIdempotencyKey_Equals_CaseSensitivity(t *testing.T) {
+	lower, _ := NewIdempotencyKey("abc123XYZ0")
+	upper, _ := NewIdempotencyKey("ABC123XYZ0")
+	assert.False(t, lower.Equals(upper), "expected default keys differing in case to be distinct")
+
+	lower, _ = NewIdempotencyKeyCaseInsensitive("abc123XYZ0")
+	upper, _ = NewIdempotencyKeyCaseInsensitive("ABC123XYZ0")
+	assert.True(t, lower.Equals(upper), "expected case-insensitive keys differing in case to be equal")
+}
+
+func TestGenerateIdempotencyKey(t *testing.T) {
+	const iterations = 1000
+	seen := make(map[string]bool, iterations)
+
+	for i := 0; i < iterations; i++ {
+		key, err := GenerateIdempotencyKey()
+		assert.NoError(t, err, "unexpected error generating key")
+
+		_, err = NewIdempotencyKey(key.Value())
+		assert.NoError(t, err, "generated key %q should pass validation", key.Value())
+
+		assert.False(t, seen[key.Value()], "generated duplicate key %q", key.Value())
+		seen[key.Value()] = true
+	}
+}
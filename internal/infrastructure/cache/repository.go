@@ -0,0 +1,300 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+// Defaults suited to absorbing client retries, which arrive within seconds.
+const (
+	DefaultSize = 10000
+	DefaultTTL  = time.Minute
+)
+
+// CachedRepository decorates a payment.Repository with an in-memory LRU cache
+// of FindByIdempotencyKey results. Only found payments are cached, so misses
+// cannot fill the cache. Entries are dropped when the payment is written
+// through this repository, including inside its transactions; writes made
+// elsewhere are only picked up once the entry expires after the TTL. Copies
+// share the same cache and are safe for concurrent use.
+type CachedRepository struct {
+	payment.Repository
+	clock shared.Clock
+	ttl   time.Duration
+	cache *lruCache
+}
+
+// NewCachedRepository wraps next with a cache holding at most size payments,
+// each for ttl as measured by clock.
+func NewCachedRepository(next payment.Repository, clock shared.Clock, size int, ttl time.Duration) (CachedRepository, error) {
+	if size <= 0 {
+		return CachedRepository{}, errors.New("cache size must be positive")
+	}
+	if ttl <= 0 {
+		return CachedRepository{}, errors.New("cache TTL must be positive")
+	}
+
+	return CachedRepository{
+		Repository: next,
+		clock:      clock,
+		ttl:        ttl,
+		cache:      newLRUCache(size),
+	}, nil
+}
+
+func (r CachedRepository) FindByIdempotencyKey(ctx context.Context, key shared.IdempotencyKey) (payment.Payment, error) {
+	if p, ok := r.cache.get(key.Value(), r.clock.Now()); ok {
+		return p, nil
+	}
+
+	// A write invalidating the payment while it is being read may have
+	// been made after the read, so the result is only cached if none did.
+	generation := r.cache.generation()
+	p, err := r.Repository.FindByIdempotencyKey(ctx, key)
+	if err != nil {
+		return payment.Payment{}, err
+	}
+
+	r.cache.put(key.Value(), p, r.clock.Now().Add(r.ttl), generation)
+	return p, nil
+}
+
+// WithTransaction runs fn in a transaction of the wrapped repository, which
+// must also be a payment.UnitOfWork. The payments fn writes are dropped from
+// the cache once the transaction has ended, so that lookups made meanwhile
+// cannot cache their state from before the commit.
+func (r CachedRepository) WithTransaction(ctx context.Context, fn func(repo payment.Repository) error) error {
+	unitOfWork, ok := r.Repository.(payment.UnitOfWork)
+	if !ok {
+		return errors.New("cached repository does not support transactions")
+	}
+
+	written := &writeRecorder{}
+	defer written.invalidate(r.cache)
+
+	return unitOfWork.WithTransaction(ctx, func(repo payment.Repository) error {
+		return fn(recordingRepository{Repository: repo, written: written})
+	})
+}
+
+func (r CachedRepository) Save(ctx context.Context, p payment.Payment) error {
+	defer r.cache.remove(p.IdempotencyKey().Value())
+	return r.Repository.Save(ctx, p)
+}
+
+func (r CachedRepository) SaveBatch(ctx context.Context, payments []payment.Payment) error {
+	defer func() {
+		for _, p := range payments {
+			r.cache.remove(p.IdempotencyKey().Value())
+		}
+	}()
+	return r.Repository.SaveBatch(ctx, payments)
+}
+
+func (r CachedRepository) UpdateStatus(ctx context.Context, id string, status payment.PaymentStatus, expectedVersion int, updatedAt time.Time) error {
+	defer r.cache.removeByID(id)
+	return r.Repository.UpdateStatus(ctx, id, status, expectedVersion, updatedAt)
+}
+
+func (r CachedRepository) UpdateStatusBatch(ctx context.Context, ids []string, status payment.PaymentStatus) (int, error) {
+	defer func() {
+		for _, id := range ids {
+			r.cache.removeByID(id)
+		}
+	}()
+	return r.Repository.UpdateStatusBatch(ctx, ids, status)
+}
+
+// SoftDelete also drops the cached entry, since a soft-deleted payment
+// releases its idempotency key.
+func (r CachedRepository) SoftDelete(ctx context.Context, id string) error {
+	defer r.cache.removeByID(id)
+	return r.Repository.SoftDelete(ctx, id)
+}
+
+// recordingRepository is handed to WithTransaction callbacks and notes which
+// payments they write, for invalidation once the transaction has ended.
+type recordingRepository struct {
+	payment.Repository
+	written *writeRecorder
+}
+
+func (r recordingRepository) Save(ctx context.Context, p payment.Payment) error {
+	r.written.key(p.IdempotencyKey().Value())
+	return r.Repository.Save(ctx, p)
+}
+
+func (r recordingRepository) SaveBatch(ctx context.Context, payments []payment.Payment) error {
+	for _, p := range payments {
+		r.written.key(p.IdempotencyKey().Value())
+	}
+	return r.Repository.SaveBatch(ctx, payments)
+}
+
+func (r recordingRepository) UpdateStatus(ctx context.Context, id string, status payment.PaymentStatus, expectedVersion int, updatedAt time.Time) error {
+	r.written.id(id)
+	return r.Repository.UpdateStatus(ctx, id, status, expectedVersion, updatedAt)
+}
+
+func (r recordingRepository) UpdateStatusBatch(ctx context.Context, ids []string, status payment.PaymentStatus) (int, error) {
+	for _, id := range ids {
+		r.written.id(id)
+	}
+	return r.Repository.UpdateStatusBatch(ctx, ids, status)
+}
+
+func (r recordingRepository) SoftDelete(ctx context.Context, id string) error {
+	r.written.id(id)
+	return r.Repository.SoftDelete(ctx, id)
+}
+
+// writeRecorder collects the idempotency keys and payment ids written in a
+// transaction.
+type writeRecorder struct {
+	mu   sync.Mutex
+	keys []string
+	ids  []string
+}
+
+func (w *writeRecorder) key(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.keys = append(w.keys, key)
+}
+
+func (w *writeRecorder) id(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ids = append(w.ids, id)
+}
+
+func (w *writeRecorder) invalidate(cache *lruCache) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, key := range w.keys {
+		cache.remove(key)
+	}
+	for _, id := range w.ids {
+		cache.removeByID(id)
+	}
+}
+
+// lruCache maps idempotency keys to payments, evicting the least recently used
+// entry once full. It also indexes entries by payment id so that writes, which
+// only know the id, can invalidate them. Every invalidation bumps gen, which
+// lets put refuse results read before it.
+type lruCache struct {
+	mu       sync.Mutex
+	size     int
+	order    *list.List // of *cacheEntry, most recently used first
+	entries  map[string]*list.Element
+	keysByID map[string]string
+	gen      uint64
+}
+
+type cacheEntry struct {
+	key       string
+	payment   payment.Payment
+	expiresAt time.Time
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:     size,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element, size),
+		keysByID: make(map[string]string, size),
+	}
+}
+
+// get returns the payment cached under key unless it has expired by now.
+func (c *lruCache) get(key string, now time.Time) (payment.Payment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return payment.Payment{}, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !now.Before(entry.expiresAt) {
+		c.removeElement(element)
+		return payment.Payment{}, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.payment, true
+}
+
+// generation returns a token for put, taken before reading the payment.
+func (c *lruCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gen
+}
+
+// put caches p under key unless an invalidation happened since generation
+// returned gen, in which case p may already be stale.
+func (c *lruCache) put(key string, p payment.Payment, expiresAt time.Time, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, payment: p, expiresAt: expiresAt})
+	c.keysByID[p.ID()] = key
+
+	if c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *lruCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *lruCache) removeByID(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if key, ok := c.keysByID[id]; ok {
+		c.removeElement(c.entries[key])
+	}
+}
+
+// removeElement drops an entry from all indexes. The caller holds mu.
+func (c *lruCache) removeElement(element *list.Element) {
+	entry := c.order.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	delete(c.keysByID, entry.payment.ID())
+}
+
+// len reports the number of cached entries, expired ones included.
+func (c *lruCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
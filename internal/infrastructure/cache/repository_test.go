@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"paymentprocessor/internal/application/service/mocks"
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+var (
+	_ payment.Repository = CachedRepository{}
+	_ payment.UnitOfWork = CachedRepository{}
+)
+
+var testNow = time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+func TestCachedRepository_FindByIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	t.Run("queries the underlying repository once for repeated lookups", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		p := createTestPayment(t, "payment-1", "abc123XYZ0")
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().FindByIdempotencyKey(ctx, p.IdempotencyKey()).Return(p, nil).Times(1)
+		repo, _ := newTestRepository(t, mockRepo, 10)
+
+		for range 3 {
+			found, err := repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+			require.NoError(t, err)
+			assert.True(t, p.Equals(found))
+		}
+	})
+
+	t.Run("does not cache misses", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		key, err := shared.NewIdempotencyKey("nonexist01")
+		require.NoError(t, err)
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().FindByIdempotencyKey(ctx, key).Return(payment.Payment{}, shared.ErrPaymentNotFound).Times(2)
+		repo, _ := newTestRepository(t, mockRepo, 10)
+
+		for range 2 {
+			_, err := repo.FindByIdempotencyKey(ctx, key)
+			assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+		}
+		assert.Zero(t, repo.cache.len())
+	})
+
+	t.Run("expires entries after the TTL", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		p := createTestPayment(t, "payment-1", "abc123XYZ0")
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().FindByIdempotencyKey(ctx, p.IdempotencyKey()).Return(p, nil).Times(2)
+		repo, clock := newTestRepository(t, mockRepo, 10)
+
+		_, err := repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+		require.NoError(t, err)
+
+		clock.Advance(DefaultTTL - time.Second)
+		_, err = repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+		require.NoError(t, err)
+
+		clock.Advance(time.Second)
+		_, err = repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+		require.NoError(t, err)
+	})
+
+	t.Run("evicts the least recently used entry once full", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		first := createTestPayment(t, "payment-1", "firstKey01")
+		second := createTestPayment(t, "payment-2", "secondKey1")
+		third := createTestPayment(t, "payment-3", "thirdKey01")
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().FindByIdempotencyKey(ctx, first.IdempotencyKey()).Return(first, nil).Times(1)
+		mockRepo.EXPECT().FindByIdempotencyKey(ctx, second.IdempotencyKey()).Return(second, nil).Times(2)
+		mockRepo.EXPECT().FindByIdempotencyKey(ctx, third.IdempotencyKey()).Return(third, nil).Times(1)
+		repo, _ := newTestRepository(t, mockRepo, 2)
+
+		for _, p := range []payment.Payment{first, second, first, third, second} {
+			_, err := repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, repo.cache.len())
+	})
+
+	t.Run("is safe for concurrent use", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		p := createTestPayment(t, "payment-1", "abc123XYZ0")
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().FindByIdempotencyKey(ctx, p.IdempotencyKey()).Return(p, nil).MinTimes(1)
+		mockRepo.EXPECT().UpdateStatus(ctx, p.ID(), payment.StatusProcessing, 1, testNow).Return(nil).AnyTimes()
+		repo, _ := newTestRepository(t, mockRepo, 10)
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+				assert.NoError(t, err)
+			}()
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, repo.UpdateStatus(ctx, p.ID(), payment.StatusProcessing, 1, testNow))
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestCachedRepository_Invalidation(t *testing.T) {
+	t.Parallel()
+
+	p := createTestPayment(t, "payment-1", "abc123XYZ0")
+
+	tests := []struct {
+		name   string
+		expect func(mockRepo *mocks.MockRepository)
+		write  func(ctx context.Context, repo payment.Repository) error
+	}{
+		{
+			name:   "save",
+			expect: func(mockRepo *mocks.MockRepository) { mockRepo.EXPECT().Save(gomock.Any(), p).Return(nil) },
+			write:  func(ctx context.Context, repo payment.Repository) error { return repo.Save(ctx, p) },
+		},
+		{
+			name: "save batch",
+			expect: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().SaveBatch(gomock.Any(), []payment.Payment{p}).Return(nil)
+			},
+			write: func(ctx context.Context, repo payment.Repository) error {
+				return repo.SaveBatch(ctx, []payment.Payment{p})
+			},
+		},
+		{
+			name: "update status",
+			expect: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().UpdateStatus(gomock.Any(), p.ID(), payment.StatusProcessing, 1, testNow).Return(nil)
+			},
+			write: func(ctx context.Context, repo payment.Repository) error {
+				return repo.UpdateStatus(ctx, p.ID(), payment.StatusProcessing, 1, testNow)
+			},
+		},
+		{
+			name: "update status batch",
+			expect: func(mockRepo *mocks.MockRepository) {
+				mockRepo.EXPECT().UpdateStatusBatch(gomock.Any(), []string{p.ID()}, payment.StatusFailed).Return(1, nil)
+			},
+			write: func(ctx context.Context, repo payment.Repository) error {
+				_, err := repo.UpdateStatusBatch(ctx, []string{p.ID()}, payment.StatusFailed)
+				return err
+			},
+		},
+		{
+			name:   "soft delete",
+			expect: func(mockRepo *mocks.MockRepository) { mockRepo.EXPECT().SoftDelete(gomock.Any(), p.ID()).Return(nil) },
+			write:  func(ctx context.Context, repo payment.Repository) error { return repo.SoftDelete(ctx, p.ID()) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			mockRepo.EXPECT().FindByIdempotencyKey(ctx, p.IdempotencyKey()).Return(p, nil).Times(2)
+			tt.expect(mockRepo)
+			repo, _ := newTestRepository(t, mockRepo, 10)
+
+			_, err := repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+			require.NoError(t, err)
+
+			require.NoError(t, tt.write(ctx, repo))
+			assert.Zero(t, repo.cache.len(), "expected the write to drop the entry")
+
+			_, err = repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+			require.NoError(t, err)
+		})
+
+		t.Run(tt.name+" in a transaction", func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			mockRepo.EXPECT().FindByIdempotencyKey(ctx, p.IdempotencyKey()).Return(p, nil)
+			tt.expect(mockRepo)
+			mockUnitOfWork := mocks.NewMockUnitOfWork(ctrl)
+			repo, _ := newTestRepository(t, transactionalRepository{mockRepo, mockUnitOfWork}, 10)
+
+			_, err := repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+			require.NoError(t, err)
+
+			mockUnitOfWork.EXPECT().WithTransaction(ctx, gomock.Any()).DoAndReturn(
+				func(ctx context.Context, fn func(payment.Repository) error) error {
+					require.NoError(t, fn(mockRepo))
+					assert.Equal(t, 1, repo.cache.len(), "expected the entry to stay until the transaction ends")
+					return nil
+				})
+			err = repo.WithTransaction(ctx, func(tx payment.Repository) error { return tt.write(ctx, tx) })
+			require.NoError(t, err)
+			assert.Zero(t, repo.cache.len(), "expected the transaction to drop the entry")
+		})
+	}
+
+	t.Run("does not cache a lookup that raced with a write", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		repo, _ := newTestRepository(t, mockRepo, 10)
+		mockRepo.EXPECT().UpdateStatus(ctx, p.ID(), payment.StatusProcessing, 1, testNow).Return(nil)
+		mockRepo.EXPECT().FindByIdempotencyKey(ctx, p.IdempotencyKey()).DoAndReturn(
+			func(ctx context.Context, key shared.IdempotencyKey) (payment.Payment, error) {
+				require.NoError(t, repo.UpdateStatus(ctx, p.ID(), payment.StatusProcessing, 1, testNow))
+				return p, nil
+			})
+
+		_, err := repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+		require.NoError(t, err)
+		assert.Zero(t, repo.cache.len())
+	})
+
+	t.Run("fails transactions when the wrapped repository has none", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+
+		repo, _ := newTestRepository(t, mocks.NewMockRepository(ctrl), 10)
+
+		err := repo.WithTransaction(context.Background(), func(payment.Repository) error {
+			t.Error("expected the callback not to run")
+			return nil
+		})
+		assert.ErrorContains(t, err, "does not support transactions")
+	})
+}
+
+func TestNewCachedRepository(t *testing.T) {
+	t.Parallel()
+
+	clock := system.NewMockClock(testNow)
+
+	_, err := NewCachedRepository(nil, clock, 0, DefaultTTL)
+	assert.ErrorContains(t, err, "cache size must be positive")
+
+	_, err = NewCachedRepository(nil, clock, DefaultSize, 0)
+	assert.ErrorContains(t, err, "cache TTL must be positive")
+}
+
+func newTestRepository(t *testing.T, next payment.Repository, size int) (CachedRepository, *system.FixedClock) {
+	t.Helper()
+
+	clock := system.NewMockClock(testNow)
+	repo, err := NewCachedRepository(next, clock, size, DefaultTTL)
+	require.NoError(t, err)
+	return repo, clock
+}
+
+func createTestPayment(t *testing.T, id, key string) payment.Payment {
+	t.Helper()
+
+	debtorIBAN, err := shared.NewIBAN("DE89370400440532013000")
+	require.NoError(t, err)
+	creditorIBAN, err := shared.NewIBAN("FR1420041010050500013M02606")
+	require.NoError(t, err)
+	amount, err := shared.NewAmountFromCents(10050)
+	require.NoError(t, err)
+	idempotencyKey, err := shared.NewIdempotencyKey(key)
+	require.NoError(t, err)
+
+	p, err := payment.NewPayment(id, debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+		amount, idempotencyKey, "", time.Time{}, nil, testNow, testNow)
+	require.NoError(t, err)
+	return p
+}
+
+// transactionalRepository combines repository and unit of work mocks, as
+// database-backed repositories implement both.
+type transactionalRepository struct {
+	*mocks.MockRepository
+	*mocks.MockUnitOfWork
+}
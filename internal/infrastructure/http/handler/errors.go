@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+// APIError is the machine-readable error body every handler responds with.
+// Code is stable across releases; Message is meant for humans. Field names the
+// offending request field when the error can be attributed to one.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+type errorResponse struct {
+	Error APIError `json:"error"`
+}
+
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+	field  string
+}
+
+// errorMappings translates domain errors into HTTP statuses and stable error
+// codes. The first mapping the error matches wins. Mappings without a field
+// take it from a payment.IBANError in the chain, if any.
+var errorMappings = []errorMapping{
+	{shared.ErrInvalidIdempotencyKey, http.StatusBadRequest, "invalid_idempotency_key", IdempotencyKeyHeader},
+	{shared.ErrInvalidDateRange, http.StatusBadRequest, "invalid_date_range", ""},
+	{shared.ErrInvalidAmountRange, http.StatusBadRequest, "invalid_amount_range", ""},
+	{shared.ErrInvalidIBAN, http.StatusUnprocessableEntity, "invalid_iban", ""},
+	{shared.ErrInvalidAmount, http.StatusUnprocessableEntity, "invalid_amount", "amount"},
+	{shared.ErrAmountOverflow, http.StatusUnprocessableEntity, "invalid_amount", "amount"},
+	{shared.ErrZeroAmount, http.StatusUnprocessableEntity, "zero_amount", "amount"},
+	{shared.ErrAmountExceedsLimit, http.StatusUnprocessableEntity, "amount_exceeds_limit", "amount"},
+	{shared.ErrInvalidCurrency, http.StatusUnprocessableEntity, "invalid_currency", "currency"},
+	{shared.ErrInvalidDebtorName, http.StatusUnprocessableEntity, "invalid_debtor_name", "debtor_name"},
+	{shared.ErrInvalidCreditorName, http.StatusUnprocessableEntity, "invalid_creditor_name", "creditor_name"},
+	{shared.ErrSameDebtorCreditor, http.StatusUnprocessableEntity, "same_debtor_creditor", "creditor_iban"},
+	{shared.ErrCountryNotAllowed, http.StatusUnprocessableEntity, "country_not_allowed", ""},
+	{shared.ErrInvalidReference, http.StatusUnprocessableEntity, "invalid_reference", "reference"},
+	{shared.ErrInvalidExecutionDate, http.StatusUnprocessableEntity, "invalid_execution_date", "execution_date"},
+	{shared.ErrInvalidMetadata, http.StatusUnprocessableEntity, "invalid_metadata", "metadata"},
+	{shared.ErrInvalidPaymentStatus, http.StatusUnprocessableEntity, "invalid_status", "status"},
+	{shared.ErrPaymentNotFound, http.StatusNotFound, "payment_not_found", ""},
+	{shared.ErrDuplicatePayment, http.StatusConflict, "duplicate_payment", ""},
+	{shared.ErrDuplicateIdempotencyKey, http.StatusConflict, "duplicate_idempotency_key", IdempotencyKeyHeader},
+	{shared.ErrInvalidStatusTransition, http.StatusConflict, "invalid_status_transition", "status"},
+	{shared.ErrConcurrentModification, http.StatusConflict, "concurrent_modification", ""},
+}
+
+// internalError is returned for any error without a mapping so that internal
+// details never reach the client.
+var internalError = APIError{Code: "internal_error", Message: "internal server error"}
+
+// mapError returns the HTTP status and API error for err.
+func mapError(err error) (int, APIError) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			field := m.field
+			var ibanErr payment.IBANError
+			if field == "" && errors.As(err, &ibanErr) {
+				field = ibanErr.Field()
+			}
+			return m.status, APIError{Code: m.code, Message: err.Error(), Field: field}
+		}
+	}
+
+	return http.StatusInternalServerError, internalError
+}
+
+// WriteError responds with the status and APIError mapped for err. Unmapped
+// errors become a generic 500.
+func WriteError(w http.ResponseWriter, err error) {
+	status, apiErr := mapError(err)
+	WriteAPIError(w, status, apiErr)
+}
+
+// WriteAPIError responds with apiErr for failures that have no domain error,
+// such as malformed requests.
+func WriteAPIError(w http.ResponseWriter, status int, apiErr APIError) {
+	writeJSON(w, status, errorResponse{Error: apiErr})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeRawJSON responds with an already encoded JSON body.
+func writeRawJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+func TestWriteError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{shared.ErrInvalidIdempotencyKey, http.StatusBadRequest, "invalid_idempotency_key"},
+		{shared.ErrInvalidDateRange, http.StatusBadRequest, "invalid_date_range"},
+		{shared.ErrInvalidAmountRange, http.StatusBadRequest, "invalid_amount_range"},
+		{shared.ErrInvalidIBAN, http.StatusUnprocessableEntity, "invalid_iban"},
+		{shared.ErrInvalidAmount, http.StatusUnprocessableEntity, "invalid_amount"},
+		{shared.ErrAmountOverflow, http.StatusUnprocessableEntity, "invalid_amount"},
+		{shared.ErrZeroAmount, http.StatusUnprocessableEntity, "zero_amount"},
+		{shared.ErrAmountExceedsLimit, http.StatusUnprocessableEntity, "amount_exceeds_limit"},
+		{shared.ErrInvalidCurrency, http.StatusUnprocessableEntity, "invalid_currency"},
+		{shared.ErrInvalidDebtorName, http.StatusUnprocessableEntity, "invalid_debtor_name"},
+		{shared.ErrInvalidCreditorName, http.StatusUnprocessableEntity, "invalid_creditor_name"},
+		{shared.ErrSameDebtorCreditor, http.StatusUnprocessableEntity, "same_debtor_creditor"},
+		{shared.ErrCountryNotAllowed, http.StatusUnprocessableEntity, "country_not_allowed"},
+		{shared.ErrInvalidReference, http.StatusUnprocessableEntity, "invalid_reference"},
+		{shared.ErrInvalidExecutionDate, http.StatusUnprocessableEntity, "invalid_execution_date"},
+		{shared.ErrInvalidMetadata, http.StatusUnprocessableEntity, "invalid_metadata"},
+		{shared.ErrInvalidPaymentStatus, http.StatusUnprocessableEntity, "invalid_status"},
+		{shared.ErrPaymentNotFound, http.StatusNotFound, "payment_not_found"},
+		{shared.ErrDuplicatePayment, http.StatusConflict, "duplicate_payment"},
+		{shared.ErrDuplicateIdempotencyKey, http.StatusConflict, "duplicate_idempotency_key"},
+		{shared.ErrInvalidStatusTransition, http.StatusConflict, "invalid_status_transition"},
+		{shared.ErrConcurrentModification, http.StatusConflict, "concurrent_modification"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			WriteError(rec, tt.err)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			apiErr := decodeAPIError(t, rec)
+			assert.Equal(t, tt.expectedCode, apiErr.Code)
+			assert.Equal(t, tt.err.Error(), apiErr.Message)
+		})
+	}
+
+	t.Run("maps wrapped sentinels and reports the offending field", func(t *testing.T) {
+		t.Parallel()
+
+		rec := httptest.NewRecorder()
+		WriteError(rec, fmt.Errorf("%w: longer than 140 characters", shared.ErrInvalidReference))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		apiErr := decodeAPIError(t, rec)
+		assert.Equal(t, "invalid_reference", apiErr.Code)
+		assert.Equal(t, "reference", apiErr.Field)
+		assert.Contains(t, apiErr.Message, "longer than 140 characters")
+	})
+
+	t.Run("attributes IBAN errors to the offending party", func(t *testing.T) {
+		t.Parallel()
+
+		tests := []struct {
+			err           error
+			expectedCode  string
+			expectedField string
+		}{
+			{payment.IBANError{Party: payment.PartyDebtor, Err: shared.ErrInvalidIBAN}, "invalid_iban", "debtor_iban"},
+			{payment.IBANError{Party: payment.PartyCreditor, Err: shared.ErrInvalidIBAN}, "invalid_iban", "creditor_iban"},
+			{payment.IBANError{Party: payment.PartyCreditor, Err: fmt.Errorf("%w: RU", shared.ErrCountryNotAllowed)}, "country_not_allowed", "creditor_iban"},
+		}
+
+		for _, tt := range tests {
+			rec := httptest.NewRecorder()
+			WriteError(rec, tt.err)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+			apiErr := decodeAPIError(t, rec)
+			assert.Equal(t, tt.expectedCode, apiErr.Code)
+			assert.Equal(t, tt.expectedField, apiErr.Field)
+			assert.Equal(t, tt.err.Error(), apiErr.Message)
+		}
+	})
+
+	t.Run("hides unknown errors behind a generic 500", func(t *testing.T) {
+		t.Parallel()
+
+		rec := httptest.NewRecorder()
+		WriteError(rec, errors.New("database is locked"))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		apiErr := decodeAPIError(t, rec)
+		assert.Equal(t, "internal_error", apiErr.Code)
+		assert.NotContains(t, apiErr.Message, "database", "expected internal details not to leak")
+		assert.Empty(t, apiErr.Field)
+	})
+}
+
+func decodeAPIError(t *testing.T, rec *httptest.ResponseRecorder) APIError {
+	t.Helper()
+
+	var resp errorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp.Error
+}
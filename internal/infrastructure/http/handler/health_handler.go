@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DefaultHealthCheckTimeout bounds how long a readiness probe waits on the
+// database.
+const DefaultHealthCheckTimeout = 2 * time.Second
+
+// HealthChecker reports whether a dependency is reachable. sqlite.Database
+// and service.PaymentService implement it.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// HealthHandler serves liveness and readiness probes. Liveness only shows that
+// the process can answer; readiness also requires the database to respond.
+type HealthHandler struct {
+	checker HealthChecker
+	timeout time.Duration
+}
+
+// NewHealthHandler returns a handler whose readiness checks time out after
+// timeout, or DefaultHealthCheckTimeout if it is not positive.
+func NewHealthHandler(checker HealthChecker, timeout time.Duration) *HealthHandler {
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+
+	return &HealthHandler{checker: checker, timeout: timeout}
+}
+
+// RegisterRoutes mounts the probes on mux. /healthz is an alias for readiness.
+func (h *HealthHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.Ready)
+	mux.HandleFunc("GET /healthz/live", h.Live)
+	mux.HandleFunc("GET /healthz/ready", h.Ready)
+}
+
+type healthResponse struct {
+	Status   string `json:"status"`
+	Database string `json:"database,omitempty"`
+}
+
+// Live always answers 200 while the process is serving requests.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Ready answers 200 when the database passes its health check and 503
+// otherwise.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	if err := h.checker.HealthCheck(ctx); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, healthResponse{Status: "unavailable", Database: "unavailable"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok", Database: "ok"})
+}
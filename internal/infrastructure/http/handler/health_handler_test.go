@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/infrastructure/persistence/sqlite"
+)
+
+func TestHealthHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports ready against a healthy database", func(t *testing.T) {
+		t.Parallel()
+
+		mux := newHealthMux(t, openTestDatabase(t))
+
+		for _, path := range []string{"/healthz", "/healthz/ready", "/healthz/live"} {
+			rec := getPath(mux, path)
+
+			assert.Equal(t, http.StatusOK, rec.Code, path)
+			assert.Equal(t, "ok", decodeHealth(t, rec).Status, path)
+		}
+	})
+
+	t.Run("reports unavailable but live against a closed database", func(t *testing.T) {
+		t.Parallel()
+
+		db := openTestDatabase(t)
+		require.NoError(t, db.Close())
+		mux := newHealthMux(t, db)
+
+		for _, path := range []string{"/healthz", "/healthz/ready"} {
+			rec := getPath(mux, path)
+
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code, path)
+			resp := decodeHealth(t, rec)
+			assert.Equal(t, "unavailable", resp.Status, path)
+			assert.Equal(t, "unavailable", resp.Database, path)
+		}
+
+		rec := getPath(mux, "/healthz/live")
+		assert.Equal(t, http.StatusOK, rec.Code, "expected liveness not to depend on the database")
+	})
+}
+
+func TestNewHealthHandler_DefaultsTimeout(t *testing.T) {
+	t.Parallel()
+
+	h := NewHealthHandler(nil, 0)
+
+	assert.Equal(t, DefaultHealthCheckTimeout, h.timeout)
+}
+
+func openTestDatabase(t *testing.T) sqlite.Database {
+	t.Helper()
+
+	config := sqlite.DefaultInMemoryConfig()
+	config.DatabasePath = t.Name()
+	db, err := sqlite.NewDatabase(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, db.Initialize(context.Background()))
+	return db
+}
+
+func newHealthMux(t *testing.T, db sqlite.Database) *http.ServeMux {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	NewHealthHandler(db, DefaultHealthCheckTimeout).RegisterRoutes(mux)
+	return mux
+}
+
+func getPath(handler http.Handler, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func decodeHealth(t *testing.T, rec *httptest.ResponseRecorder) healthResponse {
+	t.Helper()
+
+	var resp healthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
@@ -1,5 +1,287 @@
 package handler
 
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"paymentprocessor/internal/application/command"
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+// IdempotencyKeyHeader carries the client's idempotency key. When it is absent
+// a key is generated, which makes the request effectively non-idempotent.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// MaxRequestBodyBytes caps the size of JSON request bodies.
+const MaxRequestBodyBytes = 64 << 10
+
+// replayPollInterval is how often a retry polls for the response of an
+// identical request that is still being processed.
+const replayPollInterval = 20 * time.Millisecond
+
+// PaymentService is the application behaviour the HTTP handlers depend on.
+type PaymentService interface {
+	CreatePayment(ctx context.Context, cmd command.CreatePaymentCommand) (payment.Payment, error)
+	GetPayment(ctx context.Context, id string) (payment.Payment, error)
+	ParseIdempotencyKey(value string) (shared.IdempotencyKey, error)
+}
+
+// ResponseStore records the response to each create request by idempotency
+// key. sqlite.ResponseStore implements it.
+type ResponseStore interface {
+	// Reserve claims key for a request hashing to requestHash, reporting false
+	// while another request holds it or once its response has been stored.
+	Reserve(ctx context.Context, key, requestHash string) (bool, error)
+	Complete(ctx context.Context, key string, statusCode int, body []byte) error
+	Release(ctx context.Context, key string) error
+	// Find returns the hash of the request holding key, empty if there is
+	// none, and the stored response, with a zero status code if there is none
+	// yet.
+	Find(ctx context.Context, key string) (requestHash string, statusCode int, body []byte, err error)
+}
+
+// paymentIDPattern matches the ULIDs the service assigns to payments.
+var paymentIDPattern = regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`)
+
 type PaymentHandler struct {
-	// TODO: Implement payment HTTP handler
+	service   PaymentService
+	responses ResponseStore
+}
+
+func NewPaymentHandler(service PaymentService, responses ResponseStore) *PaymentHandler {
+	return &PaymentHandler{service: service, responses: responses}
+}
+
+// RegisterRoutes mounts the payment endpoints on mux.
+func (h *PaymentHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /payments", h.CreatePayment)
+	mux.HandleFunc("GET /payments/{id}", h.GetPayment)
+}
+
+type createPaymentRequest struct {
+	DebtorIBAN    string            `json:"debtor_iban"`
+	DebtorName    string            `json:"debtor_name"`
+	CreditorIBAN  string            `json:"creditor_iban"`
+	CreditorName  string            `json:"creditor_name"`
+	Amount        string            `json:"amount"`
+	Currency      string            `json:"currency"`
+	Reference     string            `json:"reference"`
+	ExecutionDate *time.Time        `json:"execution_date"`
+	Metadata      map[string]string `json:"metadata"`
+}
+
+// CreatePayment handles POST /payments. It responds 201 with a newly created
+// payment, or 200 with the existing one when the idempotency key was already
+// used by a payment without a stored response.
+//
+// The response to a client-supplied idempotency key is stored, and a retry
+// with the same key and body is answered with it verbatim, whether it was a
+// success or a rejection. Reusing the key with a different body is refused
+// with 422. A retry arriving while the original request is still being
+// processed waits for its response. Server errors, and bodies too large to
+// read, are not stored, so the request can be retried. A malformed key is
+// rejected with 400 before anything is stored under it.
+func (h *PaymentHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			WriteAPIError(w, http.StatusRequestEntityTooLarge, APIError{Code: "request_too_large", Message: "request body is too large"})
+			return
+		}
+		WriteAPIError(w, http.StatusBadRequest, APIError{Code: "invalid_request", Message: "request body could not be read"})
+		return
+	}
+
+	value := r.Header.Get(IdempotencyKeyHeader)
+	if value == "" {
+		generated, err := shared.GenerateIdempotencyKey()
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		// Nobody can retry with a key they were never given, so there is
+		// nothing worth storing.
+		w.Header().Set(IdempotencyKeyHeader, generated.Value())
+		status, body := h.createPayment(r.Context(), raw, generated.Value())
+		writeJSON(w, status, body)
+		return
+	}
+
+	// Responses are stored under the key as the service compares it, so that
+	// keys it treats as the same share one response.
+	parsed, err := h.service.ParseIdempotencyKey(value)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	key := parsed.Value()
+
+	w.Header().Set(IdempotencyKeyHeader, key)
+	sum := sha256.Sum256(raw)
+	requestHash := hex.EncodeToString(sum[:])
+	ticker := time.NewTicker(replayPollInterval)
+	defer ticker.Stop()
+	for {
+		reserved, err := h.responses.Reserve(r.Context(), key, requestHash)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		if reserved {
+			h.createAndStore(w, r, raw, key)
+			return
+		}
+
+		storedHash, status, body, err := h.responses.Find(r.Context(), key)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		// Responses stored before request hashes were recorded have none and
+		// are replayed to any request.
+		if storedHash != "" && storedHash != requestHash {
+			WriteAPIError(w, http.StatusUnprocessableEntity, APIError{
+				Code:    "idempotency_key_reused",
+				Message: "idempotency key was already used for a different request",
+				Field:   IdempotencyKeyHeader,
+			})
+			return
+		}
+		if status != 0 {
+			writeRawJSON(w, status, body)
+			return
+		}
+
+		// The key is reserved by a request still in flight, or was just
+		// released by one that failed; look again shortly.
+		select {
+		case <-r.Context().Done():
+			WriteAPIError(w, http.StatusConflict, APIError{
+				Code:    "request_in_progress",
+				Message: "a request with this idempotency key is still being processed",
+				Field:   IdempotencyKeyHeader,
+			})
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// createAndStore processes a create request whose key the caller reserved and
+// stores the response, or releases the key on a server error.
+func (h *PaymentHandler) createAndStore(w http.ResponseWriter, r *http.Request, raw []byte, key string) {
+	// The outcome must be recorded even if the client has gone away.
+	ctx := context.WithoutCancel(r.Context())
+
+	status, body := h.createPayment(r.Context(), raw, key)
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		_ = h.responses.Release(ctx, key)
+		WriteError(w, err)
+		return
+	}
+	encoded = append(encoded, '\n')
+
+	if status >= http.StatusInternalServerError {
+		_ = h.responses.Release(ctx, key)
+	} else {
+		// Failing to store leaves the reservation to expire, after which a
+		// retry is answered from the payment itself.
+		_ = h.responses.Complete(ctx, key, status, encoded)
+	}
+
+	writeRawJSON(w, status, encoded)
+}
+
+// createPayment decodes and processes the raw body of a create request,
+// returning the status and body to respond with.
+func (h *PaymentHandler) createPayment(ctx context.Context, raw []byte, key string) (int, interface{}) {
+	var req createPaymentRequest
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return http.StatusBadRequest, errorResponse{Error: APIError{Code: "invalid_request", Message: "request body must be a valid payment JSON object"}}
+	}
+
+	amount, err := parseRequestAmount(req.Amount, req.Currency)
+	if err != nil {
+		status, apiErr := mapError(err)
+		return status, errorResponse{Error: apiErr}
+	}
+
+	cmd := command.CreatePaymentCommand{
+		DebtorIBAN:     req.DebtorIBAN,
+		DebtorName:     req.DebtorName,
+		CreditorIBAN:   req.CreditorIBAN,
+		CreditorName:   req.CreditorName,
+		Amount:         amount,
+		IdempotencyKey: key,
+		Reference:      req.Reference,
+		Metadata:       req.Metadata,
+	}
+	if req.ExecutionDate != nil {
+		cmd.ExecutionDate = *req.ExecutionDate
+	}
+
+	p, err := h.service.CreatePayment(ctx, cmd)
+	var duplicate payment.DuplicatePaymentError
+	switch {
+	case errors.As(err, &duplicate):
+		return http.StatusOK, ToResponse(duplicate.Existing)
+	case err != nil:
+		status, apiErr := mapError(err)
+		return status, errorResponse{Error: apiErr}
+	default:
+		return http.StatusCreated, ToResponse(p)
+	}
+}
+
+// GetPayment handles GET /payments/{id}. Ids that cannot be ULIDs are rejected
+// with 400 without touching the database.
+func (h *PaymentHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !paymentIDPattern.MatchString(id) {
+		WriteAPIError(w, http.StatusBadRequest, APIError{Code: "invalid_payment_id", Message: "payment id must be a 26 character ULID", Field: "id"})
+		return
+	}
+
+	p, err := h.service.GetPayment(r.Context(), id)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ToResponse(p))
+}
+
+// parseRequestAmount builds an exact Amount from the decimal string and the
+// required ISO 4217 currency of a create request. The currency travels in its
+// own field, so an amount string carrying a currency suffix is rejected.
+func parseRequestAmount(value, code string) (shared.Amount, error) {
+	currency, err := shared.NewCurrency(code)
+	if err != nil {
+		return shared.Amount{}, err
+	}
+	if strings.IndexFunc(value, unicode.IsLetter) >= 0 {
+		return shared.Amount{}, shared.ErrInvalidAmount
+	}
+
+	parsed, err := shared.ParseAmount(value)
+	if err != nil {
+		return shared.Amount{}, err
+	}
+	return shared.NewAmountFromCentsWithCurrency(parsed.Cents(), currency)
 }
@@ -0,0 +1,410 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"paymentprocessor/internal/application/service"
+	"paymentprocessor/internal/application/service/mocks"
+	"paymentprocessor/internal/infrastructure/persistence/sqlite"
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+var testNow = time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+
+const validPaymentBody = `{
+	"debtor_iban": "GB82WEST12345698765432",
+	"debtor_name": "John Doe",
+	"creditor_iban": "FR1420041010050500013M02606",
+	"creditor_name": "Jane Smith",
+	"amount": "100.50",
+	"currency": "EUR"
+}`
+
+func TestPaymentHandler_CreatePayment(t *testing.T) {
+	t.Parallel()
+
+	t.Run("creates a payment and returns 201", func(t *testing.T) {
+		t.Parallel()
+
+		mux := newTestMux(t)
+		rec := postPayment(mux, validPaymentBody, "abc123XYZ0")
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "abc123XYZ0", rec.Header().Get(IdempotencyKeyHeader))
+
+		var resp PaymentResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.NotEmpty(t, resp.ID)
+		assert.Equal(t, "GB82WEST12345698765432", resp.DebtorIBAN)
+		assert.Equal(t, "Jane Smith", resp.CreditorName)
+		assert.Equal(t, "100.50", resp.Amount)
+		assert.Equal(t, "EUR", resp.Currency)
+		assert.Equal(t, "PENDING", resp.Status)
+		assert.Equal(t, "abc123XYZ0", resp.IdempotencyKey)
+		assert.Equal(t, "2025-01-21T10:00:00Z", resp.CreatedAt)
+	})
+
+	t.Run("keeps a decimal amount exact", func(t *testing.T) {
+		t.Parallel()
+
+		mux := newTestMux(t)
+		rec := postPayment(mux, strings.Replace(validPaymentBody, "100.50", "0.29", 1), "abc123XYZ0")
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var resp PaymentResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "0.29", resp.Amount)
+	})
+
+	t.Run("creates a payment in the requested currency", func(t *testing.T) {
+		t.Parallel()
+
+		mux := newTestMux(t)
+		rec := postPayment(mux, strings.Replace(validPaymentBody, `"EUR"`, `"usd"`, 1), "abc123XYZ0")
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var resp PaymentResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "100.50", resp.Amount)
+		assert.Equal(t, "USD", resp.Currency)
+	})
+
+	t.Run("replays the original 201 response on a retry", func(t *testing.T) {
+		t.Parallel()
+
+		mux := newTestMux(t)
+		first := postPayment(mux, validPaymentBody, "abc123XYZ0")
+		require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+
+		second := postPayment(mux, validPaymentBody, "abc123XYZ0")
+		require.Equal(t, http.StatusCreated, second.Code, second.Body.String())
+		assert.Equal(t, "application/json", second.Header().Get("Content-Type"))
+		assert.Equal(t, "abc123XYZ0", second.Header().Get(IdempotencyKeyHeader))
+		assert.Equal(t, first.Body.String(), second.Body.String(), "expected the original response to be replayed")
+	})
+
+	t.Run("replays a rejected request on a retry", func(t *testing.T) {
+		t.Parallel()
+
+		mux := newTestMux(t)
+		invalid := strings.Replace(validPaymentBody, "GB82WEST12345698765432", "GB00INVALID", 1)
+		first := postPayment(mux, invalid, "abc123XYZ0")
+		require.Equal(t, http.StatusUnprocessableEntity, first.Code, first.Body.String())
+
+		second := postPayment(mux, invalid, "abc123XYZ0")
+		assert.Equal(t, http.StatusUnprocessableEntity, second.Code, second.Body.String())
+		assert.Equal(t, first.Body.String(), second.Body.String(), "expected the original rejection to be replayed")
+	})
+
+	t.Run("refuses a key reused for a different request", func(t *testing.T) {
+		t.Parallel()
+
+		mux := newTestMux(t)
+		first := postPayment(mux, validPaymentBody, "abc123XYZ0")
+		require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+
+		second := postPayment(mux, strings.Replace(validPaymentBody, "100.50", "200.00", 1), "abc123XYZ0")
+		require.Equal(t, http.StatusUnprocessableEntity, second.Code, second.Body.String())
+
+		var resp errorResponse
+		require.NoError(t, json.Unmarshal(second.Body.Bytes(), &resp))
+		assert.Equal(t, "idempotency_key_reused", resp.Error.Code)
+		assert.Equal(t, IdempotencyKeyHeader, resp.Error.Field)
+	})
+
+	t.Run("replays the response to keys the service treats as the same", func(t *testing.T) {
+		t.Parallel()
+
+		mux, _ := newTestMuxWithDB(t, service.PaymentService.WithCaseInsensitiveIdempotencyKeys)
+		first := postPayment(mux, validPaymentBody, "abc123xyz0")
+		require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+		assert.Equal(t, "ABC123XYZ0", first.Header().Get(IdempotencyKeyHeader))
+
+		second := postPayment(mux, validPaymentBody, "ABC123xyz0")
+		require.Equal(t, http.StatusCreated, second.Code, second.Body.String())
+		assert.Equal(t, first.Body.String(), second.Body.String(), "expected the original response to be replayed")
+	})
+
+	t.Run("stores nothing under a malformed key", func(t *testing.T) {
+		t.Parallel()
+
+		mux, db := newTestMuxWithDB(t)
+		rec := postPayment(mux, validPaymentBody, "not a key!")
+		require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+		var stored int
+		require.NoError(t, db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM idempotency_responses").Scan(&stored))
+		assert.Zero(t, stored)
+	})
+
+	t.Run("gives concurrent duplicates the same response", func(t *testing.T) {
+		t.Parallel()
+
+		mux := newTestMux(t)
+		recs := make([]*httptest.ResponseRecorder, 5)
+		var wg sync.WaitGroup
+		for i := range recs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				recs[i] = postPayment(mux, validPaymentBody, "abc123XYZ0")
+			}()
+		}
+		wg.Wait()
+
+		for _, rec := range recs {
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+			assert.Equal(t, recs[0].Body.String(), rec.Body.String(), "expected every duplicate to get the same response")
+		}
+	})
+
+	t.Run("returns the existing payment with 200 when no response was stored", func(t *testing.T) {
+		t.Parallel()
+
+		mux, db := newTestMuxWithDB(t)
+		first := postPayment(mux, validPaymentBody, "abc123XYZ0")
+		require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+		_, err := db.ExecContext(context.Background(), "DELETE FROM idempotency_responses")
+		require.NoError(t, err)
+
+		second := postPayment(mux, validPaymentBody, "abc123XYZ0")
+		require.Equal(t, http.StatusOK, second.Code, second.Body.String())
+
+		var created, existing PaymentResponse
+		require.NoError(t, json.Unmarshal(first.Body.Bytes(), &created))
+		require.NoError(t, json.Unmarshal(second.Body.Bytes(), &existing))
+		assert.Equal(t, created.ID, existing.ID, "expected the original payment to be returned")
+	})
+
+	t.Run("generates an idempotency key when the header is missing", func(t *testing.T) {
+		t.Parallel()
+
+		mux := newTestMux(t)
+		rec := postPayment(mux, validPaymentBody, "")
+
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		key := rec.Header().Get(IdempotencyKeyHeader)
+		assert.Len(t, key, 10, "expected a generated key to be echoed back")
+
+		var resp PaymentResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, key, resp.IdempotencyKey)
+	})
+
+	tests := []struct {
+		name           string
+		body           string
+		key            string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "invalid debtor IBAN",
+			body:           strings.Replace(validPaymentBody, "GB82WEST12345698765432", "GB00INVALID", 1),
+			key:            "abc123XYZ0",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "invalid_iban",
+		},
+		{
+			name:           "negative amount",
+			body:           strings.Replace(validPaymentBody, "100.50", "-5", 1),
+			key:            "abc123XYZ0",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "invalid_amount",
+		},
+		{
+			name:           "zero amount",
+			body:           strings.Replace(validPaymentBody, "100.50", "0", 1),
+			key:            "abc123XYZ0",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "zero_amount",
+		},
+		{
+			name:           "numeric amount",
+			body:           strings.Replace(validPaymentBody, `"100.50"`, "100.50", 1),
+			key:            "abc123XYZ0",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "amount with a currency suffix",
+			body:           strings.Replace(validPaymentBody, "100.50", "100.50 USD", 1),
+			key:            "abc123XYZ0",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "invalid_amount",
+		},
+		{
+			name:           "unknown currency",
+			body:           strings.Replace(validPaymentBody, `"EUR"`, `"ABC"`, 1),
+			key:            "abc123XYZ0",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "invalid_currency",
+		},
+		{
+			name:           "missing currency",
+			body:           strings.Replace(validPaymentBody, `"currency": "EUR"`, `"reference": "Invoice 42"`, 1),
+			key:            "abc123XYZ0",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   "invalid_currency",
+		},
+		{
+			name:           "malformed JSON",
+			body:           `{"debtor_iban": `,
+			key:            "abc123XYZ0",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "unknown field",
+			body:           `{"debtor": "John Doe"}`,
+			key:            "abc123XYZ0",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "oversized body",
+			body:           `{"reference": "` + strings.Repeat("a", MaxRequestBodyBytes) + `"}`,
+			key:            "abc123XYZ0",
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedCode:   "request_too_large",
+		},
+		{
+			name:           "malformed idempotency key",
+			body:           validPaymentBody,
+			key:            "short",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_idempotency_key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := newTestMux(t)
+			rec := postPayment(mux, tt.body, tt.key)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code, rec.Body.String())
+
+			var resp errorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, tt.expectedCode, resp.Error.Code)
+			assert.NotEmpty(t, resp.Error.Message, "expected an error message")
+		})
+	}
+}
+
+func TestPaymentHandler_GetPayment(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns 200 with the payment", func(t *testing.T) {
+		t.Parallel()
+
+		mux := newTestMux(t)
+		created := postPayment(mux, validPaymentBody, "abc123XYZ0")
+		require.Equal(t, http.StatusCreated, created.Code, created.Body.String())
+		var createdResp PaymentResponse
+		require.NoError(t, json.Unmarshal(created.Body.Bytes(), &createdResp))
+
+		rec := getPayment(mux, createdResp.ID)
+
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp PaymentResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, createdResp.ID, resp.ID)
+		assert.Equal(t, "PENDING", resp.Status)
+		assert.Equal(t, "100.50", resp.Amount)
+		assert.Equal(t, "EUR", resp.Currency)
+		assert.Equal(t, "2025-01-21T10:00:00Z", resp.CreatedAt)
+		assert.Equal(t, "2025-01-21T10:00:00Z", resp.UpdatedAt)
+	})
+
+	t.Run("returns 404 for an unknown id", func(t *testing.T) {
+		t.Parallel()
+
+		rec := getPayment(newTestMux(t), "01JJ0000000000000000000000")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+		var resp errorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "payment_not_found", resp.Error.Code)
+	})
+
+	t.Run("returns 400 for a malformed id", func(t *testing.T) {
+		t.Parallel()
+
+		rec := getPayment(newTestMux(t), "not-a-ulid")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+		var resp errorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "invalid_payment_id", resp.Error.Code)
+	})
+}
+
+// newTestMux wires the handler to a real service backed by an in-memory database.
+func newTestMux(t *testing.T) *http.ServeMux {
+	t.Helper()
+	mux, _ := newTestMuxWithDB(t)
+	return mux
+}
+
+// newTestMuxWithDB is newTestMux that also returns the database, e.g.
+// to tamper with stored responses. Any configure funcs adjust the service.
+func newTestMuxWithDB(t *testing.T, configure ...func(service.PaymentService) service.PaymentService) (*http.ServeMux, sqlite.Database) {
+	t.Helper()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	config := sqlite.DefaultInMemoryConfig()
+	config.DatabasePath = t.Name()
+	db, err := sqlite.NewDatabase(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, db.Initialize(ctx))
+
+	publisher := mocks.NewMockEventPublisher(ctrl)
+	publisher.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	clock := system.NewMockClock(testNow)
+	repo := sqlite.NewPaymentRepository(db, clock)
+	svc := service.NewPaymentService(repo, repo, clock, system.NewULIDGenerator(clock), publisher)
+	for _, c := range configure {
+		svc = c(svc)
+	}
+
+	mux := http.NewServeMux()
+	NewPaymentHandler(svc, sqlite.NewResponseStore(db, clock, 0)).RegisterRoutes(mux)
+	return mux, db
+}
+
+func postPayment(handler http.Handler, body, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func getPayment(handler http.Handler, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/payments/"+id, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
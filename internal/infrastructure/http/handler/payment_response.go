@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"time"
+
+	"paymentprocessor/internal/domain/payment"
+)
+
+// PaymentResponse is the JSON representation of a payment. The amount is a
+// decimal string so that no precision is lost on the client side, and
+// timestamps are RFC 3339 strings in UTC.
+type PaymentResponse struct {
+	ID             string            `json:"id"`
+	DebtorIBAN     string            `json:"debtor_iban"`
+	DebtorName     string            `json:"debtor_name"`
+	CreditorIBAN   string            `json:"creditor_iban"`
+	CreditorName   string            `json:"creditor_name"`
+	Amount         string            `json:"amount"`
+	Currency       string            `json:"currency"`
+	IdempotencyKey string            `json:"idempotency_key"`
+	Reference      string            `json:"reference,omitempty"`
+	ExecutionDate  string            `json:"execution_date,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Status         string            `json:"status"`
+	Version        int               `json:"version"`
+	CreatedAt      string            `json:"created_at"`
+	UpdatedAt      string            `json:"updated_at"`
+}
+
+// ToResponse maps a domain payment to its JSON representation.
+func ToResponse(p payment.Payment) PaymentResponse {
+	resp := PaymentResponse{
+		ID:             p.ID(),
+		DebtorIBAN:     p.DebtorIBAN().String(),
+		DebtorName:     p.DebtorName(),
+		CreditorIBAN:   p.CreditorIBAN().String(),
+		CreditorName:   p.CreditorName(),
+		Amount:         p.Amount().String(),
+		Currency:       p.Amount().Currency().Code(),
+		IdempotencyKey: p.IdempotencyKey().Value(),
+		Reference:      p.Reference(),
+		Metadata:       p.Metadata(),
+		Status:         string(p.Status()),
+		Version:        p.Version(),
+		CreatedAt:      formatTimestamp(p.CreatedAt()),
+		UpdatedAt:      formatTimestamp(p.UpdatedAt()),
+	}
+	if executionDate := p.ExecutionDate(); !executionDate.IsZero() {
+		resp.ExecutionDate = formatTimestamp(executionDate)
+	}
+
+	return resp
+}
+
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
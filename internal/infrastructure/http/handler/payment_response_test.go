@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+func TestToResponse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("serializes a pending payment with the expected JSON shape", func(t *testing.T) {
+		t.Parallel()
+
+		p := createTestPayment(t, payment.StatusPending)
+
+		encoded, err := json.Marshal(ToResponse(p))
+		require.NoError(t, err)
+
+		assert.JSONEq(t, `{
+			"id": "01JJ0000000000000000000000",
+			"debtor_iban": "GB82WEST12345698765432",
+			"debtor_name": "John Doe",
+			"creditor_iban": "FR1420041010050500013M02606",
+			"creditor_name": "Jane Smith",
+			"amount": "1234.50",
+			"currency": "USD",
+			"idempotency_key": "abc123XYZ0",
+			"status": "PENDING",
+			"version": 1,
+			"created_at": "2025-01-21T10:00:00Z",
+			"updated_at": "2025-01-21T10:00:00Z"
+		}`, string(encoded))
+	})
+
+	t.Run("serializes the status of a processed payment", func(t *testing.T) {
+		t.Parallel()
+
+		p := createTestPayment(t, payment.StatusProcessed)
+
+		encoded, err := json.Marshal(ToResponse(p))
+		require.NoError(t, err)
+
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal(encoded, &fields))
+		assert.Equal(t, "PROCESSED", fields["status"])
+		assert.Equal(t, "2025-01-21T11:00:00Z", fields["updated_at"])
+	})
+
+	t.Run("includes optional fields and renders timestamps in UTC", func(t *testing.T) {
+		t.Parallel()
+
+		base := createTestPayment(t, payment.StatusPending)
+		paris := time.FixedZone("UTC+1", 60*60)
+		p, err := payment.ReconstitutePayment(
+			base.ID(),
+			base.DebtorIBAN(),
+			base.DebtorName(),
+			base.CreditorIBAN(),
+			base.CreditorName(),
+			base.Amount(),
+			base.IdempotencyKey(),
+			"Invoice 42",
+			time.Date(2025, 1, 25, 9, 0, 0, 0, paris),
+			map[string]string{"order_id": "42"},
+			"",
+			payment.StatusPending,
+			1,
+			time.Date(2025, 1, 21, 11, 0, 0, 0, paris),
+			time.Date(2025, 1, 21, 11, 0, 0, 0, paris),
+		)
+		require.NoError(t, err)
+
+		resp := ToResponse(p)
+
+		assert.Equal(t, "Invoice 42", resp.Reference)
+		assert.Equal(t, "2025-01-25T08:00:00Z", resp.ExecutionDate)
+		assert.Equal(t, map[string]string{"order_id": "42"}, resp.Metadata)
+		assert.Equal(t, "2025-01-21T10:00:00Z", resp.CreatedAt)
+	})
+}
+
+func createTestPayment(t *testing.T, status payment.PaymentStatus) payment.Payment {
+	t.Helper()
+
+	debtorIBAN, err := shared.NewIBAN("GB82WEST12345698765432")
+	require.NoError(t, err)
+	creditorIBAN, err := shared.NewIBAN("FR1420041010050500013M02606")
+	require.NoError(t, err)
+	usd, err := shared.NewCurrency("USD")
+	require.NoError(t, err)
+	amount, err := shared.NewAmountFromCentsWithCurrency(123450, usd)
+	require.NoError(t, err)
+	key, err := shared.NewIdempotencyKey("abc123XYZ0")
+	require.NoError(t, err)
+
+	updatedAt := testNow
+	if status != payment.StatusPending {
+		updatedAt = testNow.Add(time.Hour)
+	}
+
+	p, err := payment.ReconstitutePayment("01JJ0000000000000000000000", debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+		amount, key, "", time.Time{}, nil, "", status, 1, testNow, updatedAt)
+	require.NoError(t, err)
+
+	return p
+}
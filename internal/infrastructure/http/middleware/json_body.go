@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+
+	"paymentprocessor/internal/infrastructure/http/handler"
+)
+
+// DefaultMaxBodyBytes is the request body cap used for JSON endpoints, the
+// same one the handlers apply themselves.
+const DefaultMaxBodyBytes = handler.MaxRequestBodyBytes
+
+// JSONBody rejects requests that carry a body without a JSON content type
+// (415) and caps the body at maxBytes (413). Requests whose declared length is
+// already too large are rejected up front; bodies of unknown length are cut off
+// by http.MaxBytesReader while the wrapped handler reads them. GET, HEAD,
+// DELETE and OPTIONS requests pass through unchecked.
+func JSONBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !carriesBody(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				handler.WriteAPIError(w, http.StatusUnsupportedMediaType, handler.APIError{
+					Code:    "unsupported_media_type",
+					Message: "Content-Type must be application/json",
+					Field:   "Content-Type",
+				})
+				return
+			}
+
+			if r.ContentLength > maxBytes {
+				handler.WriteAPIError(w, http.StatusRequestEntityTooLarge, handler.APIError{
+					Code:    "request_too_large",
+					Message: "request body is too large",
+				})
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func carriesBody(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONBody(t *testing.T) {
+	t.Parallel()
+
+	const maxBytes = 64
+
+	// echo copies the request body back, answering 413 if the body was cut off.
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		_, _ = w.Write(body)
+	})
+	wrapped := JSONBody(maxBytes)(echo)
+
+	t.Run("passes JSON requests within the limit through", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{"amount": 10}`))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		rec := httptest.NewRecorder()
+
+		wrapped.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"amount": 10}`, rec.Body.String())
+	})
+
+	t.Run("passes requests without a body through", func(t *testing.T) {
+		t.Parallel()
+
+		rec := httptest.NewRecorder()
+		wrapped.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/payments/123", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	contentTypeTests := []struct {
+		name        string
+		contentType string
+	}{
+		{name: "missing content type", contentType: ""},
+		{name: "form content type", contentType: "application/x-www-form-urlencoded"},
+		{name: "plain text", contentType: "text/plain"},
+		{name: "malformed content type", contentType: "application/json; ="},
+	}
+
+	for _, tt := range contentTypeTests {
+		t.Run("rejects "+tt.name+" with 415", func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{}`))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			wrapped.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+			assert.Equal(t, "unsupported_media_type", decodeErrorCode(t, rec))
+		})
+	}
+
+	t.Run("rejects a declared oversized body with 413", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(strings.Repeat("a", maxBytes+1)))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		wrapped.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		assert.Equal(t, "request_too_large", decodeErrorCode(t, rec))
+	})
+
+	t.Run("cuts off an oversized body of unknown length", func(t *testing.T) {
+		t.Parallel()
+
+		// Wrapping the reader hides its length, as with a chunked upload.
+		body := io.MultiReader(strings.NewReader(strings.Repeat("a", maxBytes+1)))
+		req := httptest.NewRequest(http.MethodPost, "/payments", body)
+		req.Header.Set("Content-Type", "application/json")
+		require.EqualValues(t, -1, req.ContentLength, "expected an unknown content length")
+		rec := httptest.NewRecorder()
+
+		wrapped.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
+
+func decodeErrorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var resp struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp.Error.Code
+}
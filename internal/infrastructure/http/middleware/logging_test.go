@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+func TestLogging(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	wrapped := RequestID(system.NewULIDGenerator(system.NewMockClock(testNow)))(Logging(logger)(notFound))
+
+	req := httptest.NewRequest(http.MethodGet, "/payments/123", nil)
+	req.Header.Set(RequestIDHeader, "trace-abc-123")
+	wrapped.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := logs.String()
+	assert.Contains(t, line, "request_id=trace-abc-123")
+	assert.Contains(t, line, "method=GET")
+	assert.Contains(t, line, "path=/payments/123")
+	assert.Contains(t, line, "status=404")
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"paymentprocessor/internal/domain/shared"
+	"paymentprocessor/internal/infrastructure/http/handler"
+)
+
+type RateLimitConfig struct {
+	Rate            float64 // requests per second each client regains
+	Burst           int     // requests a client may make at once
+	CleanupInterval time.Duration
+}
+
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Rate:            5,
+		Burst:           10,
+		CleanupInterval: time.Minute,
+	}
+}
+
+// RateLimiter throttles requests per client IP with a token bucket. Buckets
+// that have refilled completely behave exactly like new ones, so they are
+// dropped every CleanupInterval to keep the map from growing without bound.
+//
+// The client is identified by the connection's remote address only;
+// X-Forwarded-For is not trusted.
+type RateLimiter struct {
+	config      RateLimitConfig
+	clock       shared.Clock
+	mu          sync.Mutex
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter for config. Non-positive values fall back
+// to DefaultRateLimitConfig.
+func NewRateLimiter(config RateLimitConfig, clock shared.Clock) *RateLimiter {
+	defaults := DefaultRateLimitConfig()
+	if config.Rate <= 0 {
+		config.Rate = defaults.Rate
+	}
+	if config.Burst <= 0 {
+		config.Burst = defaults.Burst
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = defaults.CleanupInterval
+	}
+
+	return &RateLimiter{
+		config:      config,
+		clock:       clock,
+		buckets:     make(map[string]*tokenBucket),
+		lastCleanup: clock.Now(),
+	}
+}
+
+// Middleware rejects requests over the limit with 429 and a Retry-After header
+// giving the number of seconds until the next request would be allowed.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := l.allow(clientIP(r))
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			handler.WriteAPIError(w, http.StatusTooManyRequests, handler.APIError{
+				Code:    "rate_limited",
+				Message: "too many requests",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes a token from key's bucket, or reports how long until one is
+// available.
+func (l *RateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.cleanup(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.config.Burst), last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = l.refilled(bucket, now)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := (1 - bucket.tokens) / l.config.Rate
+		return false, time.Duration(wait * float64(time.Second))
+	}
+
+	bucket.tokens--
+	return true, 0
+}
+
+func (l *RateLimiter) refilled(bucket *tokenBucket, now time.Time) float64 {
+	tokens := bucket.tokens + now.Sub(bucket.last).Seconds()*l.config.Rate
+	return math.Min(tokens, float64(l.config.Burst))
+}
+
+// cleanup drops full buckets once per CleanupInterval. Callers hold l.mu.
+func (l *RateLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < l.config.CleanupInterval {
+		return
+	}
+
+	for key, bucket := range l.buckets {
+		if l.refilled(bucket, now) >= float64(l.config.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastCleanup = now
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
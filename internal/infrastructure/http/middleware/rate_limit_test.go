@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+var testNow = time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	config := RateLimitConfig{Rate: 1, Burst: 3, CleanupInterval: time.Minute}
+
+	t.Run("rejects requests past the burst and recovers after the window", func(t *testing.T) {
+		t.Parallel()
+
+		clock := system.NewMockClock(testNow)
+		wrapped := NewRateLimiter(config, clock).Middleware(ok)
+
+		for i := 0; i < config.Burst; i++ {
+			assert.Equal(t, http.StatusOK, sendFrom(wrapped, "192.0.2.1:1234").Code, "expected request %d within the burst to pass", i+1)
+		}
+
+		rejected := sendFrom(wrapped, "192.0.2.1:1234")
+		assert.Equal(t, http.StatusTooManyRequests, rejected.Code)
+		assert.Equal(t, "1", rejected.Header().Get("Retry-After"))
+		assert.Equal(t, "rate_limited", decodeErrorCode(t, rejected))
+
+		clock.Advance(time.Second)
+		assert.Equal(t, http.StatusOK, sendFrom(wrapped, "192.0.2.1:1234").Code, "expected one token to be regained")
+		assert.Equal(t, http.StatusTooManyRequests, sendFrom(wrapped, "192.0.2.1:1234").Code)
+
+		clock.Advance(time.Duration(config.Burst) * time.Second)
+		for i := 0; i < config.Burst; i++ {
+			assert.Equal(t, http.StatusOK, sendFrom(wrapped, "192.0.2.1:1234").Code, "expected the full burst to be regained")
+		}
+	})
+
+	t.Run("tracks clients independently and ignores the source port", func(t *testing.T) {
+		t.Parallel()
+
+		wrapped := NewRateLimiter(config, system.NewMockClock(testNow)).Middleware(ok)
+
+		for i := 0; i < config.Burst; i++ {
+			sendFrom(wrapped, "192.0.2.1:1000")
+		}
+
+		assert.Equal(t, http.StatusTooManyRequests, sendFrom(wrapped, "192.0.2.1:2000").Code, "expected the same IP on another port to share the bucket")
+		assert.Equal(t, http.StatusOK, sendFrom(wrapped, "192.0.2.2:1000").Code, "expected another IP to have its own bucket")
+	})
+
+	t.Run("drops refilled buckets during cleanup", func(t *testing.T) {
+		t.Parallel()
+
+		clock := system.NewMockClock(testNow)
+		limiter := NewRateLimiter(config, clock)
+		wrapped := limiter.Middleware(ok)
+
+		sendFrom(wrapped, "192.0.2.1:1234")
+		for i := 0; i < config.Burst; i++ {
+			sendFrom(wrapped, "192.0.2.2:1234")
+		}
+		assert.Len(t, limiter.buckets, 2)
+
+		clock.Advance(config.CleanupInterval)
+		sendFrom(wrapped, "192.0.2.3:1234")
+
+		assert.Len(t, limiter.buckets, 1, "expected only the new client's bucket to remain")
+	})
+}
+
+func TestNewRateLimiter_AppliesDefaults(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(RateLimitConfig{}, system.NewMockClock(testNow))
+
+	assert.Equal(t, DefaultRateLimitConfig(), limiter.config)
+}
+
+func sendFrom(h http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"paymentprocessor/internal/infrastructure/http/handler"
+)
+
+// Recover turns a handler panic into a logged stack trace and a generic 500,
+// so that one bad request cannot take the server down. http.ErrAbortHandler is
+// re-panicked because net/http uses it to abort a response deliberately. When
+// the handler had already started its response, the panic is only logged, as a
+// 500 can no longer replace it. A nil logger uses slog.Default().
+func Recover(logger *slog.Logger) func(http.Handler) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tracker := &startTracker{ResponseWriter: w}
+
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(recovered)
+				}
+
+				logger.ErrorContext(r.Context(), "recovered from handler panic",
+					"request_id", RequestIDFromContext(r.Context()),
+					"panic", recovered,
+					"method", r.Method,
+					"path", r.URL.Path,
+					"response_started", tracker.started,
+					"stack", string(debug.Stack()),
+				)
+				if !tracker.started {
+					handler.WriteError(w, fmt.Errorf("handler panic: %v", recovered))
+				}
+			}()
+
+			next.ServeHTTP(tracker, r)
+		})
+	}
+}
+
+// startTracker remembers whether the wrapped handler has started its response,
+// either by writing the header or by writing part of the body.
+type startTracker struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (t *startTracker) WriteHeader(status int) {
+	t.started = true
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *startTracker) Write(b []byte) (int, error) {
+	t.started = true
+	return t.ResponseWriter.Write(b)
+}
+
+func (t *startTracker) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
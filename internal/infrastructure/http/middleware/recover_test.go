@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+
+	t.Run("answers 500 on panic and keeps serving", func(t *testing.T) {
+		t.Parallel()
+
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+		mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		server := httptest.NewServer(Recover(logger)(mux))
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/panic")
+		require.NoError(t, err)
+		var body struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "internal_error", body.Error.Code)
+		assert.Contains(t, logs.String(), "panic=boom")
+		assert.Contains(t, logs.String(), "recover_test.go", "expected the stack trace to be logged")
+
+		resp, err = http.Get(server.URL + "/ok")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "expected the server to keep serving")
+	})
+
+	t.Run("leaves a started response alone", func(t *testing.T) {
+		t.Parallel()
+
+		var logs bytes.Buffer
+		partial := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"status":`))
+			panic("boom")
+		})
+		recorder := httptest.NewRecorder()
+		Recover(slog.New(slog.NewTextHandler(&logs, nil)))(partial).
+			ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusAccepted, recorder.Code)
+		assert.Equal(t, `{"status":`, recorder.Body.String(), "expected no error body after the partial response")
+		assert.Contains(t, logs.String(), "panic=boom")
+		assert.Contains(t, logs.String(), "response_started=true")
+	})
+
+	t.Run("re-panics on http.ErrAbortHandler", func(t *testing.T) {
+		t.Parallel()
+
+		aborting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})
+		wrapped := Recover(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))(aborting)
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			wrapped.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	})
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"paymentprocessor/internal/domain/shared"
+	"paymentprocessor/internal/infrastructure/http/handler"
+)
+
+// RequestIDHeader carries the correlation id of a request in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied ids so they stay safe to log.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestID stores the incoming X-Request-ID, or a newly generated id when it
+// is missing or unusable, in the request context and echoes it in the
+// response.
+func RequestID(idGenerator shared.IDGenerator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !isValidRequestID(id) {
+				generated, err := idGenerator.NewID()
+				if err != nil {
+					handler.WriteError(w, err)
+					return
+				}
+				id = generated
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		})
+	}
+}
+
+// RequestIDFromContext returns the request id stored by RequestID, or "" if
+// there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// isValidRequestID accepts non-empty ids of printable ASCII up to
+// maxRequestIDLength characters.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
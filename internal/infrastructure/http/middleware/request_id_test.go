@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		incoming   string
+		expectKeep bool
+	}{
+		{name: "preserves an incoming id", incoming: "trace-abc-123", expectKeep: true},
+		{name: "generates an id when the header is missing", incoming: "", expectKeep: false},
+		{name: "replaces an id with control characters", incoming: "bad\tid", expectKeep: false},
+		{name: "replaces an overlong id", incoming: strings.Repeat("a", maxRequestIDLength+1), expectKeep: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var seen string
+			inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			})
+			wrapped := RequestID(system.NewULIDGenerator(system.NewMockClock(testNow)))(inner)
+
+			req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			wrapped.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Get(RequestIDHeader)
+			assert.Equal(t, seen, echoed, "expected the context id to be echoed back")
+			if tt.expectKeep {
+				assert.Equal(t, tt.incoming, echoed)
+			} else {
+				assert.Len(t, echoed, 26, "expected a generated ULID")
+			}
+		})
+	}
+}
+
+func TestRequestIDFromContext_ReturnsEmptyWithoutID(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, RequestIDFromContext(context.Background()))
+}
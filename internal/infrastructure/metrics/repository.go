@@ -0,0 +1,248 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+// Outcome label values. Lookups that match nothing are reported as not_found
+// rather than error, since they are an expected result.
+const (
+	outcomeSuccess  = "success"
+	outcomeNotFound = "not_found"
+	outcomeError    = "error"
+)
+
+// InstrumentedRepository decorates a payment.Repository with Prometheus
+// metrics for every call: a counter and a latency histogram labeled by
+// operation and outcome, plus a counter of failed calls by operation. When the
+// wrapped repository is also a payment.UnitOfWork, so is the decorator, and
+// calls made inside its transactions are recorded too.
+type InstrumentedRepository struct {
+	next       payment.Repository
+	operations *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+// NewInstrumentedRepository wraps next and registers its collectors with
+// registerer. It fails if the collectors are already registered.
+func NewInstrumentedRepository(next payment.Repository, registerer prometheus.Registerer) (InstrumentedRepository, error) {
+	r := InstrumentedRepository{
+		next: next,
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "payment",
+			Subsystem: "repository",
+			Name:      "operations_total",
+			Help:      "Repository calls by operation and outcome.",
+		}, []string{"operation", "outcome"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "payment",
+			Subsystem: "repository",
+			Name:      "errors_total",
+			Help:      "Repository calls that failed, by operation.",
+		}, []string{"operation"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "payment",
+			Subsystem: "repository",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of repository calls by operation and outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "outcome"}),
+	}
+
+	for _, collector := range []prometheus.Collector{r.operations, r.errors, r.duration} {
+		if err := registerer.Register(collector); err != nil {
+			return InstrumentedRepository{}, fmt.Errorf("failed to register repository metrics: %w", err)
+		}
+	}
+
+	return r, nil
+}
+
+// WithTransaction runs fn in a transaction of the wrapped repository, handing
+// it the transaction's repository instrumented with the same collectors. The
+// transaction as a whole is recorded as with_transaction.
+func (r InstrumentedRepository) WithTransaction(ctx context.Context, fn func(repo payment.Repository) error) error {
+	unitOfWork, ok := r.next.(payment.UnitOfWork)
+	if !ok {
+		return errors.New("instrumented repository does not support transactions")
+	}
+
+	started := time.Now()
+	err := unitOfWork.WithTransaction(ctx, func(repo payment.Repository) error {
+		txRepo := r
+		txRepo.next = repo
+		return fn(txRepo)
+	})
+	r.observe("with_transaction", started, err)
+	return err
+}
+
+func (r InstrumentedRepository) Save(ctx context.Context, p payment.Payment) error {
+	started := time.Now()
+	err := r.next.Save(ctx, p)
+	r.observe("save", started, err)
+	return err
+}
+
+func (r InstrumentedRepository) SaveBatch(ctx context.Context, payments []payment.Payment) error {
+	started := time.Now()
+	err := r.next.SaveBatch(ctx, payments)
+	r.observe("save_batch", started, err)
+	return err
+}
+
+func (r InstrumentedRepository) FindByID(ctx context.Context, id string) (payment.Payment, error) {
+	started := time.Now()
+	p, err := r.next.FindByID(ctx, id)
+	r.observe("find_by_id", started, err)
+	return p, err
+}
+
+func (r InstrumentedRepository) FindByIdempotencyKey(ctx context.Context, key shared.IdempotencyKey) (payment.Payment, error) {
+	started := time.Now()
+	p, err := r.next.FindByIdempotencyKey(ctx, key)
+	r.observe("find_by_idempotency_key", started, err)
+	return p, err
+}
+
+func (r InstrumentedRepository) FindByIDs(ctx context.Context, ids []string) (map[string]payment.Payment, error) {
+	started := time.Now()
+	found, err := r.next.FindByIDs(ctx, ids)
+	r.observe("find_by_ids", started, err)
+	return found, err
+}
+
+func (r InstrumentedRepository) Exists(ctx context.Context, id string) (bool, error) {
+	started := time.Now()
+	exists, err := r.next.Exists(ctx, id)
+	r.observe("exists", started, err)
+	return exists, err
+}
+
+func (r InstrumentedRepository) FindByStatus(ctx context.Context, status payment.PaymentStatus, limit int) ([]payment.Payment, error) {
+	started := time.Now()
+	payments, err := r.next.FindByStatus(ctx, status, limit)
+	r.observe("find_by_status", started, err)
+	return payments, err
+}
+
+func (r InstrumentedRepository) FindByDebtorIBANAndStatus(ctx context.Context, iban shared.IBAN, status payment.PaymentStatus, limit int) ([]payment.Payment, error) {
+	started := time.Now()
+	payments, err := r.next.FindByDebtorIBANAndStatus(ctx, iban, status, limit)
+	r.observe("find_by_debtor_iban_and_status", started, err)
+	return payments, err
+}
+
+func (r InstrumentedRepository) FindByDateRange(ctx context.Context, from, to time.Time, limit int) ([]payment.Payment, error) {
+	started := time.Now()
+	payments, err := r.next.FindByDateRange(ctx, from, to, limit)
+	r.observe("find_by_date_range", started, err)
+	return payments, err
+}
+
+func (r InstrumentedRepository) FindByAmountRange(ctx context.Context, currency shared.Currency, minCents, maxCents int64, limit int) ([]payment.Payment, error) {
+	started := time.Now()
+	payments, err := r.next.FindByAmountRange(ctx, currency, minCents, maxCents, limit)
+	r.observe("find_by_amount_range", started, err)
+	return payments, err
+}
+
+func (r InstrumentedRepository) FindDueForExecution(ctx context.Context, asOf time.Time, limit int) ([]payment.Payment, error) {
+	started := time.Now()
+	payments, err := r.next.FindDueForExecution(ctx, asOf, limit)
+	r.observe("find_due_for_execution", started, err)
+	return payments, err
+}
+
+func (r InstrumentedRepository) List(ctx context.Context, offset, limit int) ([]payment.Payment, error) {
+	started := time.Now()
+	payments, err := r.next.List(ctx, offset, limit)
+	r.observe("list", started, err)
+	return payments, err
+}
+
+func (r InstrumentedRepository) ListAfter(ctx context.Context, afterCreatedAt time.Time, afterID string, limit int) ([]payment.Payment, error) {
+	started := time.Now()
+	payments, err := r.next.ListAfter(ctx, afterCreatedAt, afterID, limit)
+	r.observe("list_after", started, err)
+	return payments, err
+}
+
+func (r InstrumentedRepository) Iterate(ctx context.Context, filter payment.Filter, fn func(payment.Payment) error) error {
+	started := time.Now()
+	err := r.next.Iterate(ctx, filter, fn)
+	r.observe("iterate", started, err)
+	return err
+}
+
+func (r InstrumentedRepository) Count(ctx context.Context) (int, error) {
+	started := time.Now()
+	count, err := r.next.Count(ctx)
+	r.observe("count", started, err)
+	return count, err
+}
+
+func (r InstrumentedRepository) CountByStatus(ctx context.Context, status payment.PaymentStatus) (int, error) {
+	started := time.Now()
+	count, err := r.next.CountByStatus(ctx, status)
+	r.observe("count_by_status", started, err)
+	return count, err
+}
+
+func (r InstrumentedRepository) UpdateStatus(ctx context.Context, id string, status payment.PaymentStatus, expectedVersion int, updatedAt time.Time) error {
+	started := time.Now()
+	err := r.next.UpdateStatus(ctx, id, status, expectedVersion, updatedAt)
+	r.observe("update_status", started, err)
+	return err
+}
+
+func (r InstrumentedRepository) UpdateStatusBatch(ctx context.Context, ids []string, status payment.PaymentStatus) (int, error) {
+	started := time.Now()
+	updated, err := r.next.UpdateStatusBatch(ctx, ids, status)
+	r.observe("update_status_batch", started, err)
+	return updated, err
+}
+
+func (r InstrumentedRepository) FindStatusHistory(ctx context.Context, id string) ([]payment.StatusChange, error) {
+	started := time.Now()
+	history, err := r.next.FindStatusHistory(ctx, id)
+	r.observe("find_status_history", started, err)
+	return history, err
+}
+
+func (r InstrumentedRepository) SoftDelete(ctx context.Context, id string) error {
+	started := time.Now()
+	err := r.next.SoftDelete(ctx, id)
+	r.observe("soft_delete", started, err)
+	return err
+}
+
+func (r InstrumentedRepository) HealthCheck(ctx context.Context) error {
+	started := time.Now()
+	err := r.next.HealthCheck(ctx)
+	r.observe("health_check", started, err)
+	return err
+}
+
+func (r InstrumentedRepository) observe(operation string, started time.Time, err error) {
+	outcome := outcomeSuccess
+	switch {
+	case errors.Is(err, shared.ErrPaymentNotFound):
+		outcome = outcomeNotFound
+	case err != nil:
+		outcome = outcomeError
+		r.errors.WithLabelValues(operation).Inc()
+	}
+
+	r.operations.WithLabelValues(operation, outcome).Inc()
+	r.duration.WithLabelValues(operation, outcome).Observe(time.Since(started).Seconds())
+}
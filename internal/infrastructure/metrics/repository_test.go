@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"paymentprocessor/internal/application/service/mocks"
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+var updatedAt = time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+
+var (
+	_ payment.Repository = InstrumentedRepository{}
+	_ payment.UnitOfWork = InstrumentedRepository{}
+)
+
+func TestInstrumentedRepository(t *testing.T) {
+	t.Parallel()
+
+	t.Run("counts successful calls", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().Save(ctx, gomock.Any()).Return(nil).Times(2)
+		mockRepo.EXPECT().UpdateStatus(ctx, "payment-1", payment.StatusProcessed, 1, updatedAt).Return(nil)
+		repo, registry := newTestRepository(t, mockRepo)
+
+		require.NoError(t, repo.Save(ctx, payment.Payment{}))
+		require.NoError(t, repo.Save(ctx, payment.Payment{}))
+		require.NoError(t, repo.UpdateStatus(ctx, "payment-1", payment.StatusProcessed, 1, updatedAt))
+
+		assert.Equal(t, 2.0, testutil.ToFloat64(repo.operations.WithLabelValues("save", outcomeSuccess)))
+		assert.Equal(t, 1.0, testutil.ToFloat64(repo.operations.WithLabelValues("update_status", outcomeSuccess)))
+		assert.Equal(t, 0, testutil.CollectAndCount(repo.errors))
+		assert.Equal(t, 2, testutil.CollectAndCount(repo.duration), "expected one histogram per operation and outcome")
+		assert.Equal(t, 2, testutil.CollectAndCount(registry, "payment_repository_operation_duration_seconds"))
+	})
+
+	t.Run("counts failed calls as errors", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().Save(ctx, gomock.Any()).Return(errors.New("database is locked"))
+		mockRepo.EXPECT().UpdateStatus(ctx, "payment-1", payment.StatusProcessed, 1, updatedAt).Return(shared.ErrConcurrentModification)
+		repo, _ := newTestRepository(t, mockRepo)
+
+		assert.Error(t, repo.Save(ctx, payment.Payment{}))
+		assert.ErrorIs(t, repo.UpdateStatus(ctx, "payment-1", payment.StatusProcessed, 1, updatedAt), shared.ErrConcurrentModification)
+
+		assert.Equal(t, 1.0, testutil.ToFloat64(repo.operations.WithLabelValues("save", outcomeError)))
+		assert.Equal(t, 1.0, testutil.ToFloat64(repo.errors.WithLabelValues("save")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(repo.errors.WithLabelValues("update_status")))
+	})
+
+	t.Run("reports missing payments as not found rather than errors", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().FindByID(ctx, "payment-1").Return(payment.Payment{}, shared.ErrPaymentNotFound)
+		repo, _ := newTestRepository(t, mockRepo)
+
+		_, err := repo.FindByID(ctx, "payment-1")
+
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+		assert.Equal(t, 1.0, testutil.ToFloat64(repo.operations.WithLabelValues("find_by_id", outcomeNotFound)))
+		assert.Equal(t, 0, testutil.CollectAndCount(repo.errors))
+	})
+
+	t.Run("records calls made inside a transaction", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockUnitOfWork := mocks.NewMockUnitOfWork(ctrl)
+		txRepo := mocks.NewMockRepository(ctrl)
+		mockUnitOfWork.EXPECT().WithTransaction(ctx, gomock.Any()).DoAndReturn(
+			func(ctx context.Context, fn func(payment.Repository) error) error { return fn(txRepo) })
+		txRepo.EXPECT().UpdateStatus(ctx, "payment-1", payment.StatusProcessed, 1, updatedAt).Return(nil)
+		repo, _ := newTestRepository(t, transactionalRepository{mockRepo, mockUnitOfWork})
+
+		err := repo.WithTransaction(ctx, func(tx payment.Repository) error {
+			return tx.UpdateStatus(ctx, "payment-1", payment.StatusProcessed, 1, updatedAt)
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1.0, testutil.ToFloat64(repo.operations.WithLabelValues("update_status", outcomeSuccess)))
+		assert.Equal(t, 1.0, testutil.ToFloat64(repo.operations.WithLabelValues("with_transaction", outcomeSuccess)))
+	})
+
+	t.Run("fails transactions when the wrapped repository has none", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+
+		repo, _ := newTestRepository(t, mocks.NewMockRepository(ctrl))
+
+		err := repo.WithTransaction(context.Background(), func(payment.Repository) error {
+			t.Error("expected the callback not to run")
+			return nil
+		})
+		assert.ErrorContains(t, err, "does not support transactions")
+	})
+
+	t.Run("refuses to register twice", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+
+		registry := prometheus.NewRegistry()
+		_, err := NewInstrumentedRepository(mocks.NewMockRepository(ctrl), registry)
+		require.NoError(t, err)
+
+		_, err = NewInstrumentedRepository(mocks.NewMockRepository(ctrl), registry)
+		assert.ErrorContains(t, err, "failed to register repository metrics")
+	})
+}
+
+func newTestRepository(t *testing.T, next payment.Repository) (InstrumentedRepository, *prometheus.Registry) {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	repo, err := NewInstrumentedRepository(next, registry)
+	require.NoError(t, err)
+	return repo, registry
+}
+
+// transactionalRepository combines repository and unit of work mocks, as
+// database-backed repositories implement both.
+type transactionalRepository struct {
+	*mocks.MockRepository
+	*mocks.MockUnitOfWork
+}
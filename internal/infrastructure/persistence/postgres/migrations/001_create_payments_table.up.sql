@@ -0,0 +1,27 @@
+-- Mirrors the SQLite schema as of its migration 010. Timestamps are
+-- TIMESTAMPTZ, so ordering and range queries compare instants directly.
+CREATE TABLE IF NOT EXISTS payments (
+    id TEXT PRIMARY KEY,
+    debtor_iban TEXT NOT NULL,
+    debtor_name TEXT NOT NULL,
+    creditor_iban TEXT NOT NULL,
+    creditor_name TEXT NOT NULL,
+    amount_cents BIGINT NOT NULL CHECK (amount_cents > 0),
+    currency TEXT NOT NULL DEFAULT 'EUR',
+    idempotency_key TEXT NOT NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    execution_date TIMESTAMPTZ NULL,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL CHECK (status IN ('PENDING', 'PROCESSING', 'PROCESSED', 'FAILED', 'CANCELLED')),
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMPTZ NULL,
+    CONSTRAINT payments_idempotency_key_key UNIQUE (idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status);
+CREATE INDEX IF NOT EXISTS idx_payments_created_at ON payments(created_at);
+CREATE INDEX IF NOT EXISTS idx_payments_updated_at ON payments(updated_at);
+CREATE INDEX IF NOT EXISTS idx_payments_debtor_iban ON payments(debtor_iban);
+CREATE INDEX IF NOT EXISTS idx_payments_creditor_iban ON payments(creditor_iban);
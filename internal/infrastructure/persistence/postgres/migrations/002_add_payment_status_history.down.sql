@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS payment_status_history;
+DROP FUNCTION IF EXISTS reject_payment_status_history_change();
@@ -0,0 +1,23 @@
+-- Append-only audit trail of payment status changes, mirroring SQLite
+-- migration 011.
+CREATE TABLE IF NOT EXISTS payment_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    payment_id TEXT NOT NULL REFERENCES payments(id),
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL,
+    actor TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_status_history_payment_id ON payment_status_history(payment_id, changed_at);
+
+CREATE OR REPLACE FUNCTION reject_payment_status_history_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'payment status history is immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS payment_status_history_immutable ON payment_status_history;
+CREATE TRIGGER payment_status_history_immutable
+    BEFORE UPDATE OR DELETE ON payment_status_history
+    FOR EACH ROW EXECUTE FUNCTION reject_payment_status_history_change();
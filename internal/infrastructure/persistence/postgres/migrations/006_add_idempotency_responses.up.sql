@@ -0,0 +1,9 @@
+-- Responses to create requests keyed by idempotency key, mirroring SQLite
+-- migration 015.
+CREATE TABLE IF NOT EXISTS idempotency_responses (
+    idempotency_key TEXT PRIMARY KEY,
+    status_code INTEGER,
+    body BYTEA,
+    reserved_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ
+);
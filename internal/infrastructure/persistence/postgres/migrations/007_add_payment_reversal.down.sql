@@ -0,0 +1,7 @@
+-- Fails on the CHECK constraint while any REVERSED payments remain.
+DROP INDEX IF EXISTS idx_payments_reversal_of;
+ALTER TABLE payments DROP COLUMN IF EXISTS reversal_of;
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_status_check;
+ALTER TABLE payments ADD CONSTRAINT payments_status_check
+    CHECK (status IN ('PENDING', 'PROCESSING', 'PROCESSED', 'FAILED', 'CANCELLED'));
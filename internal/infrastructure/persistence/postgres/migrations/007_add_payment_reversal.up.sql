@@ -0,0 +1,10 @@
+-- Adds REVERSED to the status CHECK constraint and the reversal_of link from a
+-- reversal to the payment it reverses, mirroring SQLite migration 016.
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_status_check;
+ALTER TABLE payments ADD CONSTRAINT payments_status_check
+    CHECK (status IN ('PENDING', 'PROCESSING', 'PROCESSED', 'FAILED', 'CANCELLED', 'REVERSED'));
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS reversal_of TEXT NULL REFERENCES payments(id);
+
+-- A payment can be reversed at most once.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_reversal_of ON payments(reversal_of) WHERE reversal_of IS NOT NULL;
@@ -0,0 +1,4 @@
+-- Fails while a key is held by a soft-deleted payment and a live one.
+DROP INDEX IF EXISTS idx_payments_idempotency_key;
+
+ALTER TABLE payments ADD CONSTRAINT payments_idempotency_key_key UNIQUE (idempotency_key);
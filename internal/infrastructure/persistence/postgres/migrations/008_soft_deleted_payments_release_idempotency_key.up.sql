@@ -0,0 +1,6 @@
+-- Replaces the table-wide unique constraint on idempotency_key with a partial
+-- unique index, so that a soft-deleted payment releases its key. At most one
+-- payment that is not soft-deleted may hold a key. Mirrors SQLite migration 017.
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_idempotency_key_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_idempotency_key ON payments(idempotency_key) WHERE deleted_at IS NULL;
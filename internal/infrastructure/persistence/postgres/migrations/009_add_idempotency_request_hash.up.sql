@@ -0,0 +1,5 @@
+-- Records a hash of the request each idempotency key was first used with and
+-- indexes completion times for purging, mirroring SQLite migration 018.
+ALTER TABLE idempotency_responses ADD COLUMN IF NOT EXISTS request_hash TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_responses_completed_at ON idempotency_responses(completed_at);
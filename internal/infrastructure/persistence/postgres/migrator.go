@@ -0,0 +1,232 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var ErrMigrationChecksumMismatch = errors.New("migration checksum mismatch")
+
+const (
+	upMigrationSuffix   = ".up.sql"
+	downMigrationSuffix = ".down.sql"
+
+	// migrationLockID keys the advisory lock that serializes migrators started
+	// concurrently by several instances.
+	migrationLockID = 7_245_301
+)
+
+type Migration struct {
+	Version  int
+	Name     string
+	SQL      string
+	DownSQL  string
+	Checksum string
+}
+
+// Migrator applies the embedded migrations. Postgres DDL is transactional, so
+// each run happens in a single transaction holding an advisory lock: either
+// every pending migration is applied or none is.
+type Migrator struct {
+	db    *sql.DB
+	files fs.FS
+}
+
+func NewMigrator(db *sql.DB) Migrator {
+	return Migrator{db: db, files: migrationFiles}
+}
+
+func (m Migrator) Migrate(ctx context.Context) error {
+	available, err := m.availableMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to get available migrations: %w", err)
+	}
+
+	return m.locked(ctx, func(tx *sql.Tx) error {
+		applied, err := appliedChecksums(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range available {
+			checksum, ok := applied[migration.Version]
+			if ok {
+				if checksum != migration.Checksum {
+					return fmt.Errorf("%w: version %d (%s)", ErrMigrationChecksumMismatch, migration.Version, migration.Name)
+				}
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+				return fmt.Errorf("failed to apply migration %d: %w", migration.Version, err)
+			}
+
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+				migration.Version, migration.Name, migration.Checksum)
+			if err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Rollback reverts the most recently applied migrations, newest first.
+func (m Migrator) Rollback(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("rollback steps must be positive, got %d", steps)
+	}
+
+	available, err := m.availableMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to get available migrations: %w", err)
+	}
+
+	return m.locked(ctx, func(tx *sql.Tx) error {
+		applied, err := appliedChecksums(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(available) - 1; i >= 0 && steps > 0; i-- {
+			migration := available[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, migration.DownSQL); err != nil {
+				return fmt.Errorf("failed to roll back migration %d: %w", migration.Version, err)
+			}
+
+			if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version); err != nil {
+				return fmt.Errorf("failed to unrecord migration %d: %w", migration.Version, err)
+			}
+
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// locked runs fn in a transaction that holds the migration advisory lock and
+// has made sure schema_migrations exists.
+func (m Migrator) locked(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func appliedChecksums(ctx context.Context, tx *sql.Tx) (map[int]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT version, checksum FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var (
+			version  int
+			checksum string
+		)
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, fmt.Errorf("failed to scan migration row: %w", err)
+		}
+		applied[version] = checksum
+	}
+
+	return applied, rows.Err()
+}
+
+// availableMigrations pairs the embedded NNN_name.up.sql and NNN_name.down.sql
+// files into migrations ordered by version. Every migration needs both.
+func (m Migrator) availableMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(m.files, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	byVersion := make(map[int]Migration)
+	for _, entry := range entries {
+		filename := entry.Name()
+		versionText, rest, found := strings.Cut(filename, "_")
+		version, err := strconv.Atoi(versionText)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid migration filename format: %s", filename)
+		}
+
+		contents, err := fs.ReadFile(m.files, path.Join("migrations", filename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file %s: %w", filename, err)
+		}
+
+		migration := byVersion[version]
+		migration.Version = version
+		switch {
+		case strings.HasSuffix(rest, upMigrationSuffix):
+			migration.Name = strings.TrimSuffix(rest, upMigrationSuffix)
+			migration.SQL = string(contents)
+			sum := sha256.Sum256(contents)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		case strings.HasSuffix(rest, downMigrationSuffix):
+			migration.DownSQL = string(contents)
+		default:
+			return nil, fmt.Errorf("migration file %s must end in %s or %s", filename, upMigrationSuffix, downMigrationSuffix)
+		}
+		byVersion[version] = migration
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.SQL == "" || migration.DownSQL == "" {
+			return nil, fmt.Errorf("migration %d needs both up and down files", migration.Version)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
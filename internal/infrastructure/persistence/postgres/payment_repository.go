@@ -0,0 +1,813 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+
+	// uniqueViolation is the SQLSTATE Postgres reports for unique and
+	// primary key conflicts.
+	uniqueViolation = "23505"
+
+	paymentsPrimaryKey        = "payments_pkey"
+	paymentsIdempotencyKeyIdx = "idx_payments_idempotency_key"
+)
+
+const selectPayment = `
+	SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+		   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+	FROM payments
+`
+
+// PaymentRepository implements payment.Repository and payment.UnitOfWork on
+// Postgres with the same semantics as the SQLite repository.
+type PaymentRepository struct {
+	db             Database
+	tx             *sql.Tx
+	clock          shared.Clock
+	includeDeleted bool
+}
+
+func NewPaymentRepository(db Database, clock shared.Clock) PaymentRepository {
+	return PaymentRepository{db: db, clock: clock}
+}
+
+// IncludeDeleted returns a copy of the repository whose lookups, listings and
+// counts also take soft-deleted payments into account.
+func (r PaymentRepository) IncludeDeleted() PaymentRepository {
+	r.includeDeleted = true
+	return r
+}
+
+// visibleFilter restricts a query to payments that are not soft-deleted,
+// joined to the query with keyword ("WHERE" or "AND").
+func (r PaymentRepository) visibleFilter(keyword string) string {
+	if r.includeDeleted {
+		return ""
+	}
+	return keyword + " deleted_at IS NULL"
+}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// querier returns the transaction the repository is bound to, if any, so that
+// repositories handed out by WithTransaction run inside it.
+func (r PaymentRepository) querier() querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// WithTransaction runs fn inside a single transaction with a repository bound
+// to it. The transaction is committed when fn returns nil and rolled back
+// otherwise.
+func (r PaymentRepository) WithTransaction(ctx context.Context, fn func(repo payment.Repository) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	txRepo := r
+	txRepo.tx = tx
+	if err := fn(txRepo); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// inTransaction runs fn in the transaction the repository is bound to or, if
+// there is none, in a new one.
+func (r PaymentRepository) inTransaction(ctx context.Context, fn func(q querier) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r PaymentRepository) Save(ctx context.Context, p payment.Payment) error {
+	if err := insertPayment(ctx, r.querier(), p); err != nil {
+		if duplicateErr := uniqueConstraintError(err); duplicateErr != nil {
+			return duplicateErr
+		}
+		return fmt.Errorf("failed to save payment: %w", err)
+	}
+
+	return nil
+}
+
+// SaveBatch inserts all payments in a single transaction. If any insert fails
+// the whole batch is rolled back and the error reports the offending index.
+// Cancelling ctx stops the batch before the next insert.
+func (r PaymentRepository) SaveBatch(ctx context.Context, payments []payment.Payment) error {
+	return r.inTransaction(ctx, func(q querier) error {
+		for i, p := range payments {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("payment batch aborted at index %d: %w", i, err)
+			}
+			if err := insertPayment(ctx, q, p); err != nil {
+				if duplicateErr := uniqueConstraintError(err); duplicateErr != nil {
+					return fmt.Errorf("%w: payment at index %d", duplicateErr, i)
+				}
+				return fmt.Errorf("failed to save payment at index %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+}
+
+func insertPayment(ctx context.Context, db querier, p payment.Payment) error {
+	query := `
+		INSERT INTO payments (
+			id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+			amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`
+
+	metadata := p.Metadata()
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	encodedMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, query,
+		p.ID(),
+		p.DebtorIBAN().String(),
+		p.DebtorName(),
+		p.CreditorIBAN().String(),
+		p.CreditorName(),
+		p.Amount().Cents(),
+		p.Amount().Currency().Code(),
+		p.IdempotencyKey().Value(),
+		p.Reference(),
+		nullableTime(p.ExecutionDate()),
+		string(encodedMetadata),
+		nullableString(p.ReversalOf()),
+		string(p.Status()),
+		p.Version(),
+		p.CreatedAt().UTC(),
+		p.UpdatedAt().UTC(),
+	)
+
+	return err
+}
+
+func (r PaymentRepository) FindByID(ctx context.Context, id string) (payment.Payment, error) {
+	row := r.querier().QueryRowContext(ctx, selectPayment+"WHERE id = $1 "+r.visibleFilter("AND"), id)
+
+	p, err := scanPayment(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return payment.Payment{}, shared.ErrPaymentNotFound
+		}
+		return payment.Payment{}, fmt.Errorf("failed to find payment by ID: %w", err)
+	}
+
+	return p, nil
+}
+
+// FindByIDs loads the payments with the given ids in one query. The ids are
+// bound as a single array, so the list needs no chunking.
+func (r PaymentRepository) FindByIDs(ctx context.Context, ids []string) (map[string]payment.Payment, error) {
+	found := make(map[string]payment.Payment, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	payments, err := r.queryPayments(ctx, selectPayment+"WHERE id = ANY($1) "+r.visibleFilter("AND"), pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments by IDs: %w", err)
+	}
+	for _, p := range payments {
+		found[p.ID()] = p
+	}
+
+	return found, nil
+}
+
+// Exists reports whether a payment with id is visible to FindByID, so
+// soft-deleted payments only count on an IncludeDeleted repository.
+func (r PaymentRepository) Exists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS(SELECT 1 FROM payments WHERE id = $1 " + r.visibleFilter("AND") + ")"
+	if err := r.querier().QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check payment existence: %w", err)
+	}
+
+	return exists, nil
+}
+
+// FindByIdempotencyKey returns the payment holding key. On an IncludeDeleted
+// repository several payments may have held it; the live one wins, then the
+// most recently created.
+func (r PaymentRepository) FindByIdempotencyKey(ctx context.Context, key shared.IdempotencyKey) (payment.Payment, error) {
+	query := selectPayment + "WHERE idempotency_key = $1 " + r.visibleFilter("AND") +
+		" ORDER BY deleted_at IS NOT NULL, created_at DESC LIMIT 1"
+	row := r.querier().QueryRowContext(ctx, query, key.Value())
+
+	p, err := scanPayment(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return payment.Payment{}, shared.ErrPaymentNotFound
+		}
+		return payment.Payment{}, fmt.Errorf("failed to find payment by idempotency key: %w", err)
+	}
+
+	return p, nil
+}
+
+func (r PaymentRepository) List(ctx context.Context, offset, limit int) ([]payment.Payment, error) {
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := selectPayment + r.visibleFilter("WHERE") + " ORDER BY created_at DESC, id LIMIT $1 OFFSET $2"
+	payments, err := r.queryPayments(ctx, query, boundLimit(limit), offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+// Iterate streams the payments matching filter, oldest first, to fn one row at
+// a time.
+func (r PaymentRepository) Iterate(ctx context.Context, filter payment.Filter, fn func(payment.Payment) error) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if !filter.From.IsZero() {
+		args = append(args, filter.From.UTC())
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To.UTC())
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if !r.includeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	query := selectPayment
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at, id"
+
+	rows, err := r.querier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to iterate payments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("failed to iterate payments: %w", err)
+		}
+
+		p, err := scanPayment(rows)
+		if err != nil {
+			return fmt.Errorf("failed to iterate payments: %w", err)
+		}
+
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate payments: %w", err)
+	}
+
+	return nil
+}
+
+// ListAfter pages through payments oldest first using the (created_at, id) of
+// the last payment seen as a cursor, which unlike an OFFSET costs the same on
+// every page. A zero afterCreatedAt starts from the beginning.
+func (r PaymentRepository) ListAfter(ctx context.Context, afterCreatedAt time.Time, afterID string, limit int) ([]payment.Payment, error) {
+	query := selectPayment + "WHERE (created_at, id) > ($1, $2) " + r.visibleFilter("AND") + " ORDER BY created_at, id LIMIT $3"
+	payments, err := r.queryPayments(ctx, query, afterCreatedAt.UTC(), afterID, boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments after cursor: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r PaymentRepository) FindByStatus(ctx context.Context, status payment.PaymentStatus, limit int) ([]payment.Payment, error) {
+	if !status.IsValid() {
+		return nil, shared.ErrInvalidPaymentStatus
+	}
+
+	query := selectPayment + "WHERE status = $1 " + r.visibleFilter("AND") + " ORDER BY created_at, id LIMIT $2"
+	payments, err := r.queryPayments(ctx, query, string(status), boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments by status: %w", err)
+	}
+
+	return payments, nil
+}
+
+// FindByDebtorIBANAndStatus returns a debtor's payments in the given status,
+// oldest first. The zero IBAN is rejected with shared.ErrInvalidIBAN.
+func (r PaymentRepository) FindByDebtorIBANAndStatus(ctx context.Context, iban shared.IBAN, status payment.PaymentStatus, limit int) ([]payment.Payment, error) {
+	if iban.String() == "" {
+		return nil, shared.ErrInvalidIBAN
+	}
+	if !status.IsValid() {
+		return nil, shared.ErrInvalidPaymentStatus
+	}
+
+	query := selectPayment + "WHERE debtor_iban = $1 AND status = $2 " + r.visibleFilter("AND") + " ORDER BY created_at, id LIMIT $3"
+	payments, err := r.queryPayments(ctx, query, iban.String(), string(status), boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments by debtor IBAN and status: %w", err)
+	}
+
+	return payments, nil
+}
+
+// FindByDateRange returns payments created in [from, to), oldest first.
+func (r PaymentRepository) FindByDateRange(ctx context.Context, from, to time.Time, limit int) ([]payment.Payment, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: to (%s) must be after from (%s)", shared.ErrInvalidDateRange, to, from)
+	}
+
+	query := selectPayment + "WHERE created_at >= $1 AND created_at < $2 " + r.visibleFilter("AND") + " ORDER BY created_at, id LIMIT $3"
+	payments, err := r.queryPayments(ctx, query, from.UTC(), to.UTC(), boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments by date range: %w", err)
+	}
+
+	return payments, nil
+}
+
+// FindByAmountRange returns payments whose amount in cents lies in
+// [minCents, maxCents] and whose currency is currency, ordered by amount and
+// then id. A negative maxCents means no upper bound.
+func (r PaymentRepository) FindByAmountRange(ctx context.Context, currency shared.Currency, minCents, maxCents int64, limit int) ([]payment.Payment, error) {
+	if err := validateAmountRange(currency, minCents, maxCents); err != nil {
+		return nil, err
+	}
+	if maxCents < 0 {
+		maxCents = shared.MaxAmount
+	}
+
+	query := selectPayment + "WHERE currency = $1 AND amount_cents BETWEEN $2 AND $3 " + r.visibleFilter("AND") + " ORDER BY amount_cents, id LIMIT $4"
+	payments, err := r.queryPayments(ctx, query, currency.Code(), minCents, maxCents, boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments by amount range: %w", err)
+	}
+
+	return payments, nil
+}
+
+// FindDueForExecution returns up to limit pending payments whose execution
+// date is at or before asOf, including those without an execution date,
+// earliest first. The limit is bounded like List's.
+func (r PaymentRepository) FindDueForExecution(ctx context.Context, asOf time.Time, limit int) ([]payment.Payment, error) {
+	query := selectPayment + "WHERE status = $1 AND (execution_date IS NULL OR execution_date <= $2) " +
+		r.visibleFilter("AND") + " ORDER BY COALESCE(execution_date, created_at), id LIMIT $3"
+	payments, err := r.queryPayments(ctx, query, string(payment.StatusPending), asOf.UTC(), boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments due for execution: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r PaymentRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	if err := r.querier().QueryRowContext(ctx, "SELECT COUNT(*) FROM payments "+r.visibleFilter("WHERE")).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count payments: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r PaymentRepository) CountByStatus(ctx context.Context, status payment.PaymentStatus) (int, error) {
+	if !status.IsValid() {
+		return 0, shared.ErrInvalidPaymentStatus
+	}
+
+	var count int
+	err := r.querier().QueryRowContext(ctx, "SELECT COUNT(*) FROM payments WHERE status = $1 "+r.visibleFilter("AND"), string(status)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count payments by status: %w", err)
+	}
+
+	return count, nil
+}
+
+// UpdateStatus changes the status only if the stored version still matches
+// expectedVersion, bumping the version on success. A stale version yields
+// shared.ErrConcurrentModification and an unknown or soft-deleted payment
+// shared.ErrPaymentNotFound. updated_at and the history entry are stamped
+// with updatedAt, and the change is appended to the status history, attributed to
+// payment.ActorFromContext(ctx), in the same transaction.
+//
+// The status is written as given without applying the domain transition rules;
+// application code should go through PaymentService.ProcessStatusUpdate, which
+// loads the payment and only persists legal transitions.
+func (r PaymentRepository) UpdateStatus(ctx context.Context, id string, status payment.PaymentStatus, expectedVersion int, updatedAt time.Time) error {
+	changedAt := updatedAt.UTC()
+	actor := payment.ActorFromContext(ctx)
+
+	return r.inTransaction(ctx, func(q querier) error {
+		// Lock the row so the status read for the history is the one replaced.
+		var fromStatus string
+		err := q.QueryRowContext(ctx,
+			`SELECT status FROM payments WHERE id = $1 AND version = $2 AND deleted_at IS NULL FOR UPDATE`,
+			id, expectedVersion,
+		).Scan(&fromStatus)
+		if errors.Is(err, sql.ErrNoRows) {
+			return missingOrStale(ctx, q, id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock payment: %w", err)
+		}
+
+		_, err = q.ExecContext(ctx,
+			`UPDATE payments SET status = $1, version = version + 1, updated_at = $2 WHERE id = $3`,
+			string(status), changedAt, id,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update payment status: %w", err)
+		}
+
+		_, err = q.ExecContext(ctx, `
+			INSERT INTO payment_status_history (payment_id, from_status, to_status, changed_at, actor)
+			VALUES ($1, $2, $3, $4, $5)
+		`, id, fromStatus, string(status), changedAt, actor)
+		if err != nil {
+			return fmt.Errorf("failed to record payment status change: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// UpdateStatusBatch moves every listed payment whose current status may
+// legally transition to status, bumping versions and recording each change in
+// the status history. Unknown, soft-deleted and ineligible payments are
+// skipped, so the returned count may be lower than len(ids).
+func (r PaymentRepository) UpdateStatusBatch(ctx context.Context, ids []string, status payment.PaymentStatus) (int, error) {
+	if !status.IsValid() {
+		return 0, shared.ErrInvalidPaymentStatus
+	}
+	sources := payment.StatusesLeadingTo(status)
+	if len(ids) == 0 || len(sources) == 0 {
+		return 0, nil
+	}
+
+	sourceStatuses := make([]string, len(sources))
+	for i, source := range sources {
+		sourceStatuses[i] = string(source)
+	}
+
+	// The locked rows carry the status being replaced into the history, and
+	// the whole change is a single statement.
+	query := `
+		WITH changed AS (
+			SELECT id, status FROM payments
+			WHERE id = ANY($1) AND status = ANY($5) AND deleted_at IS NULL
+			FOR UPDATE
+		), updated AS (
+			UPDATE payments p
+			SET status = $2, version = p.version + 1, updated_at = $3
+			FROM changed
+			WHERE p.id = changed.id
+		)
+		INSERT INTO payment_status_history (payment_id, from_status, to_status, changed_at, actor)
+		SELECT id, status, $2, $3, $4 FROM changed
+	`
+
+	result, err := r.querier().ExecContext(ctx, query,
+		pq.Array(ids), string(status), r.clock.Now().UTC(), payment.ActorFromContext(ctx), pq.Array(sourceStatuses))
+	if err != nil {
+		return 0, fmt.Errorf("failed to update payment statuses: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// FindStatusHistory returns the status changes recorded for a payment, oldest
+// first. An unknown id yields an empty history rather than an error.
+func (r PaymentRepository) FindStatusHistory(ctx context.Context, id string) ([]payment.StatusChange, error) {
+	query := `
+		SELECT payment_id, from_status, to_status, changed_at, actor
+		FROM payment_status_history
+		WHERE payment_id = $1
+		ORDER BY changed_at, id
+	`
+
+	rows, err := r.querier().QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment status history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []payment.StatusChange
+	for rows.Next() {
+		var (
+			change   payment.StatusChange
+			from, to string
+		)
+		if err := rows.Scan(&change.PaymentID, &from, &to, &change.ChangedAt, &change.Actor); err != nil {
+			return nil, fmt.Errorf("failed to scan payment status change: %w", err)
+		}
+		change.From = payment.PaymentStatus(from)
+		change.To = payment.PaymentStatus(to)
+		change.ChangedAt = change.ChangedAt.UTC()
+		history = append(history, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate payment status history: %w", err)
+	}
+
+	return history, nil
+}
+
+// SoftDelete hides a payment from default queries by stamping deleted_at from
+// the repository clock. The row itself is retained.
+func (r PaymentRepository) SoftDelete(ctx context.Context, id string) error {
+	result, err := r.querier().ExecContext(ctx,
+		`UPDATE payments SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`,
+		r.clock.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to soft-delete payment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return shared.ErrPaymentNotFound
+	}
+
+	return nil
+}
+
+// HealthCheck runs a trivial query against the payments table, so that it
+// fails when the database is unreachable or not migrated.
+func (r PaymentRepository) HealthCheck(ctx context.Context) error {
+	var one int
+	err := r.querier().QueryRowContext(ctx, `SELECT 1 FROM payments LIMIT 1`).Scan(&one)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("payments health check failed: %w", err)
+	}
+
+	return nil
+}
+
+// missingOrStale explains why a versioned update matched no rows. Soft-deleted
+// payments count as missing.
+func missingOrStale(ctx context.Context, q querier, id string) error {
+	var version int
+	err := q.QueryRowContext(ctx, `SELECT version FROM payments WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", shared.ErrPaymentNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check payment version: %w", err)
+	}
+
+	return shared.ErrConcurrentModification
+}
+
+// queryPayments scans every row query returns, giving up between rows once ctx
+// is done rather than reading the rest of a large result.
+func (r PaymentRepository) queryPayments(ctx context.Context, query string, args ...interface{}) ([]payment.Payment, error) {
+	rows, err := r.querier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []payment.Payment{}
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+
+	return payments, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayment(row rowScanner) (payment.Payment, error) {
+	var (
+		id             string
+		debtorIBAN     string
+		debtorName     string
+		creditorIBAN   string
+		creditorName   string
+		amountCents    int64
+		currency       string
+		idempotencyKey string
+		reference      string
+		executionDate  sql.NullTime
+		metadataJSON   []byte
+		reversalOf     sql.NullString
+		status         string
+		version        int
+		createdAt      time.Time
+		updatedAt      time.Time
+	)
+
+	err := row.Scan(
+		&id, &debtorIBAN, &debtorName, &creditorIBAN, &creditorName,
+		&amountCents, &currency, &idempotencyKey, &reference, &executionDate, &metadataJSON, &reversalOf, &status, &version, &createdAt, &updatedAt,
+	)
+	if err != nil {
+		return payment.Payment{}, err
+	}
+
+	debtorIBANObj, err := shared.NewIBAN(debtorIBAN)
+	if err != nil {
+		return payment.Payment{}, fmt.Errorf("invalid debtor IBAN in database: %w", err)
+	}
+
+	creditorIBANObj, err := shared.NewIBAN(creditorIBAN)
+	if err != nil {
+		return payment.Payment{}, fmt.Errorf("invalid creditor IBAN in database: %w", err)
+	}
+
+	currencyObj, err := shared.NewCurrency(currency)
+	if err != nil {
+		return payment.Payment{}, fmt.Errorf("invalid currency in database: %w", err)
+	}
+
+	amount, err := shared.NewAmountFromCentsWithCurrency(amountCents, currencyObj)
+	if err != nil {
+		return payment.Payment{}, fmt.Errorf("invalid amount in database: %w", err)
+	}
+
+	idempotencyKeyObj, err := shared.NewIdempotencyKey(idempotencyKey)
+	if err != nil {
+		return payment.Payment{}, fmt.Errorf("invalid idempotency key in database: %w", err)
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+		return payment.Payment{}, fmt.Errorf("invalid metadata in database: %w", err)
+	}
+
+	var scheduledFor time.Time
+	if executionDate.Valid {
+		scheduledFor = executionDate.Time.UTC()
+	}
+
+	p, err := payment.ReconstitutePayment(
+		id,
+		debtorIBANObj,
+		debtorName,
+		creditorIBANObj,
+		creditorName,
+		amount,
+		idempotencyKeyObj,
+		reference,
+		scheduledFor,
+		metadata,
+		reversalOf.String,
+		payment.PaymentStatus(status),
+		version,
+		createdAt.UTC(),
+		updatedAt.UTC(),
+	)
+	if err != nil {
+		return payment.Payment{}, fmt.Errorf("failed to reconstitute payment domain object: %w", err)
+	}
+
+	return p, nil
+}
+
+// nullableTime stores the zero time as NULL and everything else in UTC.
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// nullableString stores the empty string as NULL, e.g. for optional foreign keys.
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// validateAmountRange rejects a negative minimum and, unless the range is
+// open-ended, a minimum above the maximum.
+func validateAmountRange(currency shared.Currency, minCents, maxCents int64) error {
+	if currency.Code() == "" {
+		return fmt.Errorf("%w: a currency is required", shared.ErrInvalidCurrency)
+	}
+	if minCents < 0 {
+		return fmt.Errorf("%w: min (%d) must not be negative", shared.ErrInvalidAmountRange, minCents)
+	}
+	if maxCents >= 0 && minCents > maxCents {
+		return fmt.Errorf("%w: min (%d) must not exceed max (%d)", shared.ErrInvalidAmountRange, minCents, maxCents)
+	}
+	return nil
+}
+
+// boundLimit applies the default page size to non-positive limits and caps
+// larger ones so a single query cannot scan the whole table.
+func boundLimit(limit int) int {
+	if limit <= 0 {
+		return defaultListLimit
+	}
+	if limit > maxListLimit {
+		return maxListLimit
+	}
+	return limit
+}
+
+// uniqueConstraintError maps a unique violation on payments to the matching
+// domain error, or returns nil for any other error.
+func uniqueConstraintError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != uniqueViolation {
+		return nil
+	}
+
+	switch pqErr.Constraint {
+	case paymentsIdempotencyKeyIdx:
+		return shared.ErrDuplicateIdempotencyKey
+	case paymentsPrimaryKey:
+		return shared.ErrDuplicatePaymentID
+	}
+
+	return nil
+}
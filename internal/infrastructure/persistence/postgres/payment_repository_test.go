@@ -0,0 +1,369 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+var (
+	_ payment.Repository = PaymentRepository{}
+	_ payment.UnitOfWork = PaymentRepository{}
+)
+
+func TestUniqueConstraintError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "idempotency key conflict",
+			err:  &pq.Error{Code: uniqueViolation, Constraint: paymentsIdempotencyKeyIdx},
+			want: shared.ErrDuplicateIdempotencyKey,
+		},
+		{
+			name: "primary key conflict",
+			err:  &pq.Error{Code: uniqueViolation, Constraint: paymentsPrimaryKey},
+			want: shared.ErrDuplicatePaymentID,
+		},
+		{
+			name: "wrapped conflict",
+			err:  fmt.Errorf("insert: %w", &pq.Error{Code: uniqueViolation, Constraint: paymentsPrimaryKey}),
+			want: shared.ErrDuplicatePaymentID,
+		},
+		{
+			name: "unique violation on another constraint",
+			err:  &pq.Error{Code: uniqueViolation, Constraint: "other_key"},
+		},
+		{
+			name: "other postgres error",
+			err:  &pq.Error{Code: "23503", Constraint: paymentsPrimaryKey},
+		},
+		{
+			name: "non-postgres error",
+			err:  errors.New("connection reset"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, uniqueConstraintError(tt.err))
+		})
+	}
+}
+
+func TestPaymentRepository_Integration(t *testing.T) {
+	t.Parallel()
+
+	repo, clock := createTestRepository(t)
+	ctx := context.Background()
+
+	t.Run("saves and finds a payment", func(t *testing.T) {
+		t.Parallel()
+
+		p := createTestPayment(t)
+		require.NoError(t, repo.Save(ctx, p))
+
+		found, err := repo.FindByID(ctx, p.ID())
+		require.NoError(t, err)
+		assert.Equal(t, p.ID(), found.ID())
+		assert.True(t, p.Amount().Equals(found.Amount()))
+		assert.Equal(t, p.Metadata(), found.Metadata())
+		assert.Equal(t, payment.StatusPending, found.Status())
+
+		byKey, err := repo.FindByIdempotencyKey(ctx, p.IdempotencyKey())
+		require.NoError(t, err)
+		assert.Equal(t, p.ID(), byKey.ID())
+
+		exists, err := repo.Exists(ctx, p.ID())
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("finds a batch of payments by id", func(t *testing.T) {
+		t.Parallel()
+
+		first, second := createTestPayment(t), createTestPayment(t)
+		require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{first, second}))
+
+		found, err := repo.FindByIDs(ctx, []string{first.ID(), uniqueID("missing"), second.ID()})
+		require.NoError(t, err)
+		assert.Len(t, found, 2)
+		assert.Equal(t, first.ID(), found[first.ID()].ID())
+		assert.Equal(t, second.ID(), found[second.ID()].ID())
+
+		found, err = repo.FindByIDs(ctx, nil)
+		require.NoError(t, err)
+		assert.Empty(t, found)
+	})
+
+	t.Run("iterates over payments oldest first", func(t *testing.T) {
+		t.Parallel()
+
+		first := createTestPayment(t)
+		require.NoError(t, repo.Save(ctx, first))
+		second := createTestPayment(t)
+		require.NoError(t, repo.Save(ctx, second))
+
+		// Other tests share the database, so only the relative order is checked.
+		var ids []string
+		err := repo.Iterate(ctx, payment.Filter{From: first.CreatedAt()}, func(p payment.Payment) error {
+			ids = append(ids, p.ID())
+			return nil
+		})
+		require.NoError(t, err)
+		require.Contains(t, ids, first.ID())
+		require.Contains(t, ids, second.ID())
+		assert.Less(t, slices.Index(ids, first.ID()), slices.Index(ids, second.ID()))
+
+		stop := errors.New("stop")
+		calls := 0
+		err = repo.Iterate(ctx, payment.Filter{From: first.CreatedAt()}, func(payment.Payment) error {
+			calls++
+			return stop
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("reports unknown payments as not found", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := repo.FindByID(ctx, uniqueID("missing"))
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+
+		exists, err := repo.Exists(ctx, uniqueID("missing"))
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("rejects duplicate ids and idempotency keys", func(t *testing.T) {
+		t.Parallel()
+
+		p := createTestPayment(t)
+		require.NoError(t, repo.Save(ctx, p))
+
+		err := repo.Save(ctx, p)
+		assert.ErrorIs(t, err, shared.ErrDuplicatePaymentID)
+
+		sameKey := createTestPaymentWithKey(t, uniqueID("dup"), p.IdempotencyKey())
+		err = repo.Save(ctx, sameKey)
+		assert.ErrorIs(t, err, shared.ErrDuplicateIdempotencyKey)
+	})
+
+	t.Run("rolls back a batch containing a duplicate", func(t *testing.T) {
+		t.Parallel()
+
+		first := createTestPayment(t)
+		err := repo.SaveBatch(ctx, []payment.Payment{first, first})
+		assert.ErrorIs(t, err, shared.ErrDuplicatePaymentID)
+
+		_, err = repo.FindByID(ctx, first.ID())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+	})
+
+	t.Run("updates status and records history", func(t *testing.T) {
+		t.Parallel()
+
+		p := createTestPayment(t)
+		require.NoError(t, repo.Save(ctx, p))
+
+		actorCtx := payment.WithActor(ctx, "operator")
+		require.NoError(t, repo.UpdateStatus(actorCtx, p.ID(), payment.StatusProcessing, p.Version(), clock.Now()))
+
+		found, err := repo.FindByID(ctx, p.ID())
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusProcessing, found.Status())
+		assert.Equal(t, p.Version()+1, found.Version())
+		assert.Equal(t, clock.Now().UTC(), found.UpdatedAt())
+
+		history, err := repo.FindStatusHistory(ctx, p.ID())
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, payment.StatusPending, history[0].From)
+		assert.Equal(t, payment.StatusProcessing, history[0].To)
+		assert.Equal(t, "operator", history[0].Actor)
+	})
+
+	t.Run("rejects a stale version", func(t *testing.T) {
+		t.Parallel()
+
+		p := createTestPayment(t)
+		require.NoError(t, repo.Save(ctx, p))
+		require.NoError(t, repo.UpdateStatus(ctx, p.ID(), payment.StatusProcessing, p.Version(), clock.Now()))
+
+		err := repo.UpdateStatus(ctx, p.ID(), payment.StatusProcessed, p.Version(), clock.Now())
+		assert.ErrorIs(t, err, shared.ErrConcurrentModification)
+
+		err = repo.UpdateStatus(ctx, uniqueID("missing"), payment.StatusProcessed, 1, clock.Now())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+	})
+
+	t.Run("treats a soft-deleted payment as missing", func(t *testing.T) {
+		t.Parallel()
+
+		p := createTestPayment(t)
+		require.NoError(t, repo.Save(ctx, p))
+		require.NoError(t, repo.SoftDelete(ctx, p.ID()))
+
+		err := repo.UpdateStatus(ctx, p.ID(), payment.StatusProcessing, p.Version(), clock.Now())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+	})
+
+	t.Run("updates a batch of statuses", func(t *testing.T) {
+		t.Parallel()
+
+		first, second := createTestPayment(t), createTestPayment(t)
+		require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{first, second}))
+
+		updated, err := repo.UpdateStatusBatch(ctx, []string{first.ID(), uniqueID("missing"), second.ID()}, payment.StatusCancelled)
+		require.NoError(t, err)
+		assert.Equal(t, 2, updated)
+
+		history, err := repo.FindStatusHistory(ctx, second.ID())
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, payment.StatusPending, history[0].From)
+		assert.Equal(t, payment.StatusCancelled, history[0].To)
+
+		updated, err = repo.UpdateStatusBatch(ctx, []string{first.ID(), second.ID()}, payment.StatusPending)
+		require.NoError(t, err)
+		assert.Zero(t, updated, "cancelled payments cannot move back to pending")
+	})
+
+	t.Run("hides soft-deleted payments", func(t *testing.T) {
+		t.Parallel()
+
+		p := createTestPayment(t)
+		require.NoError(t, repo.Save(ctx, p))
+		require.NoError(t, repo.SoftDelete(ctx, p.ID()))
+
+		_, err := repo.FindByID(ctx, p.ID())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+
+		found, err := repo.IncludeDeleted().FindByID(ctx, p.ID())
+		require.NoError(t, err)
+		assert.Equal(t, p.ID(), found.ID())
+
+		assert.ErrorIs(t, repo.SoftDelete(ctx, p.ID()), shared.ErrPaymentNotFound)
+	})
+
+	t.Run("leaves soft-deleted payments out of date ranges", func(t *testing.T) {
+		t.Parallel()
+
+		p := createTestPayment(t)
+		require.NoError(t, repo.Save(ctx, p))
+		require.NoError(t, repo.SoftDelete(ctx, p.ID()))
+
+		from := p.CreatedAt().Add(-time.Microsecond)
+		to := p.CreatedAt().Add(time.Microsecond)
+		inRange := func(repo PaymentRepository) bool {
+			payments, err := repo.FindByDateRange(ctx, from, to, maxListLimit)
+			require.NoError(t, err)
+			return slices.ContainsFunc(payments, func(found payment.Payment) bool { return found.ID() == p.ID() })
+		}
+		assert.False(t, inRange(repo))
+		assert.True(t, inRange(repo.IncludeDeleted()))
+	})
+
+	t.Run("reuses the idempotency key of a soft-deleted payment", func(t *testing.T) {
+		t.Parallel()
+
+		deleted := createTestPayment(t)
+		require.NoError(t, repo.Save(ctx, deleted))
+		require.NoError(t, repo.SoftDelete(ctx, deleted.ID()))
+
+		reused := createTestPaymentWithKey(t, uniqueID("pg"), deleted.IdempotencyKey())
+		require.NoError(t, repo.Save(ctx, reused))
+
+		found, err := repo.FindByIdempotencyKey(ctx, deleted.IdempotencyKey())
+		require.NoError(t, err)
+		assert.Equal(t, reused.ID(), found.ID())
+
+		duplicate := createTestPaymentWithKey(t, uniqueID("pg"), deleted.IdempotencyKey())
+		assert.ErrorIs(t, repo.Save(ctx, duplicate), shared.ErrDuplicateIdempotencyKey)
+	})
+}
+
+// createTestRepository connects to the database named by POSTGRES_DSN and
+// migrates it, skipping the test when the variable is unset. Tests share the
+// database, so they use unique ids and keys rather than cleaning up.
+func createTestRepository(t *testing.T) (PaymentRepository, shared.Clock) {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN not set; skipping PostgreSQL integration test")
+	}
+
+	db, err := NewDatabase(DefaultConfig(dsn))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, db.Initialize(context.Background()))
+
+	// Postgres stores microseconds, so keep the clock at that precision.
+	clock := system.NewMockClock(time.Now().UTC().Truncate(time.Microsecond))
+	return NewPaymentRepository(db, clock), clock
+}
+
+func createTestPayment(t *testing.T) payment.Payment {
+	key, err := shared.GenerateIdempotencyKey()
+	require.NoError(t, err)
+
+	return createTestPaymentWithKey(t, uniqueID("pg"), key)
+}
+
+func createTestPaymentWithKey(t *testing.T, id string, key shared.IdempotencyKey) payment.Payment {
+	debtorIBAN, err := shared.NewIBAN("DE89370400440532013000")
+	require.NoError(t, err)
+
+	creditorIBAN, err := shared.NewIBAN("FR1420041010050500013M02606")
+	require.NoError(t, err)
+
+	amount, err := shared.NewAmountFromCents(10050)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	p, err := payment.NewPayment(
+		id,
+		debtorIBAN,
+		"John Doe",
+		creditorIBAN,
+		"Jane Smith",
+		amount,
+		key,
+		"",
+		time.Time{},
+		map[string]string{"source": "test"},
+		now,
+		now,
+	)
+	require.NoError(t, err)
+
+	return p
+}
+
+// uniqueID returns an id that will not collide with rows left by earlier runs.
+func uniqueID(prefix string) string {
+	key, err := shared.GenerateIdempotencyKey()
+	if err != nil {
+		panic(err)
+	}
+	return prefix + "_" + key.Value()
+}
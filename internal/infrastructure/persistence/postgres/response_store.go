@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"paymentprocessor/internal/domain/shared"
+)
+
+// DefaultReservationLease is how long a reserved idempotency key stays with
+// the request that reserved it before another request may take it over.
+const DefaultReservationLease = time.Minute
+
+// DefaultResponseRetention is how long a stored response is replayed before
+// its key may be used afresh.
+const DefaultResponseRetention = 24 * time.Hour
+
+// ResponseStore records the response to each create request by idempotency
+// key with the same semantics as the SQLite store.
+type ResponseStore struct {
+	db        Database
+	clock     shared.Clock
+	lease     time.Duration
+	retention time.Duration
+}
+
+// NewResponseStore returns a store whose reservations expire after lease, or
+// DefaultReservationLease if it is not positive. Responses are kept for
+// DefaultResponseRetention.
+func NewResponseStore(db Database, clock shared.Clock, lease time.Duration) ResponseStore {
+	if lease <= 0 {
+		lease = DefaultReservationLease
+	}
+
+	return ResponseStore{db: db, clock: clock, lease: lease, retention: DefaultResponseRetention}
+}
+
+// WithRetention returns a copy of the store that keeps responses for
+// retention, or DefaultResponseRetention if it is not positive.
+func (s ResponseStore) WithRetention(retention time.Duration) ResponseStore {
+	if retention <= 0 {
+		retention = DefaultResponseRetention
+	}
+	s.retention = retention
+	return s
+}
+
+// Reserve claims key for a request hashing to requestHash. It reports false
+// while another request holds the key and once a response has been stored for
+// it. A reservation older than the lease is taken over, as is a response
+// older than the retention.
+func (s ResponseStore) Reserve(ctx context.Context, key, requestHash string) (bool, error) {
+	now := s.clock.Now().UTC()
+	query := `
+		INSERT INTO idempotency_responses (idempotency_key, request_hash, reserved_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (idempotency_key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			reserved_at = EXCLUDED.reserved_at,
+			status_code = NULL,
+			body = NULL,
+			completed_at = NULL
+		WHERE (idempotency_responses.status_code IS NULL AND idempotency_responses.reserved_at < $4)
+			OR idempotency_responses.completed_at < $5
+	`
+
+	result, err := s.db.ExecContext(ctx, query, key, requestHash, now, now.Add(-s.lease), now.Add(-s.retention))
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	return rows == 1, nil
+}
+
+// Complete stores the response for a key the caller reserved.
+func (s ResponseStore) Complete(ctx context.Context, key string, statusCode int, body []byte) error {
+	query := `
+		UPDATE idempotency_responses
+		SET status_code = $1, body = $2, completed_at = $3
+		WHERE idempotency_key = $4 AND status_code IS NULL
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, statusCode, body, s.clock.Now().UTC(), key); err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+
+	return nil
+}
+
+// Release drops the caller's reservation of key without storing a response,
+// so that a retry is processed again.
+func (s ResponseStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_responses WHERE idempotency_key = $1 AND status_code IS NULL`, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// Find returns the hash of the request holding key and the response stored
+// for it. The hash is empty while the key is unknown, and the status code is
+// zero while the key is unknown or still reserved. Responses older than the
+// retention are treated as unknown.
+func (s ResponseStore) Find(ctx context.Context, key string) (requestHash string, statusCode int, body []byte, err error) {
+	query := `
+		SELECT request_hash, status_code, body FROM idempotency_responses
+		WHERE idempotency_key = $1 AND (completed_at IS NULL OR completed_at >= $2)
+	`
+
+	var code sql.NullInt64
+	err = s.db.QueryRowContext(ctx, query, key, s.clock.Now().UTC().Add(-s.retention)).
+		Scan(&requestHash, &code, &body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, nil, nil
+	}
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to find idempotent response: %w", err)
+	}
+
+	return requestHash, int(code.Int64), body, nil
+}
+
+// PurgeExpired deletes responses older than the retention and reservations
+// older than the lease, returning how many were deleted.
+func (s ResponseStore) PurgeExpired(ctx context.Context) (int, error) {
+	now := s.clock.Now().UTC()
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM idempotency_responses
+		WHERE completed_at < $1 OR (status_code IS NULL AND reserved_at < $2)
+	`, now.Add(-s.retention), now.Add(-s.lease))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotent responses: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotent responses: %w", err)
+	}
+
+	return int(rows), nil
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseStore_Integration(t *testing.T) {
+	t.Parallel()
+
+	repo, clock := createTestRepository(t)
+	store := NewResponseStore(repo.db, clock, 0)
+	ctx := context.Background()
+
+	t.Run("stores the response of a reserved key", func(t *testing.T) {
+		t.Parallel()
+
+		key := uniqueID("key")
+		reserved, err := store.Reserve(ctx, key, "hash-1")
+		require.NoError(t, err)
+		assert.True(t, reserved)
+
+		reserved, err = store.Reserve(ctx, key, "hash-1")
+		require.NoError(t, err)
+		assert.False(t, reserved, "expected a held key not to be reserved twice")
+
+		require.NoError(t, store.Complete(ctx, key, 201, []byte(`{"id":"1"}`)))
+
+		requestHash, status, body, err := store.Find(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, "hash-1", requestHash)
+		assert.Equal(t, 201, status)
+		assert.Equal(t, `{"id":"1"}`, string(body))
+	})
+
+	t.Run("lets a released key be reserved again", func(t *testing.T) {
+		t.Parallel()
+
+		key := uniqueID("key")
+		reserved, err := store.Reserve(ctx, key, "hash-1")
+		require.NoError(t, err)
+		require.True(t, reserved)
+		require.NoError(t, store.Release(ctx, key))
+
+		requestHash, status, _, err := store.Find(ctx, key)
+		require.NoError(t, err)
+		assert.Empty(t, requestHash)
+		assert.Zero(t, status)
+
+		reserved, err = store.Reserve(ctx, key, "hash-1")
+		require.NoError(t, err)
+		assert.True(t, reserved)
+	})
+}
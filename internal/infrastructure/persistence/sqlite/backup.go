@@ -0,0 +1,99 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Backup writes a consistent snapshot of the live database to destPath. It
+// uses the SQLite online backup API and falls back to VACUUM INTO. The copy is
+// written to a temporary file next to destPath and renamed into place, so
+// destPath never holds a partial backup. Shutdown waits for a backup in
+// progress, and Backup fails with ErrShuttingDown once Shutdown has been called.
+func (d Database) Backup(ctx context.Context, destPath string) error {
+	done, err := d.operations.start()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	tmp, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(destPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if err := d.onlineBackup(ctx, tmpPath); err != nil {
+		// VACUUM INTO refuses to overwrite an existing file
+		if err := os.Remove(tmpPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to reset backup file: %w", err)
+		}
+		if _, vacuumErr := d.db.ExecContext(ctx, "VACUUM INTO ?", tmpPath); vacuumErr != nil {
+			return fmt.Errorf("failed to back up database: %w (online backup: %v)", vacuumErr, err)
+		}
+	}
+
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		return fmt.Errorf("failed to move backup into place: %w", err)
+	}
+
+	return nil
+}
+
+// onlineBackup copies every page of the main database into destPath through a
+// dedicated destination connection.
+func (d Database) onlineBackup(ctx context.Context, destPath string) error {
+	destDB, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup destination: %w", err)
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to backup destination: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := d.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to source database: %w", err)
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			dest, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected destination connection type %T", destDriverConn)
+			}
+			src, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected source connection type %T", srcDriverConn)
+			}
+
+			backup, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+
+			// A single step of -1 copies all pages under one read lock
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return fmt.Errorf("failed to copy pages: %w", err)
+			}
+
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
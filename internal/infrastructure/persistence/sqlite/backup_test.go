@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+func TestDatabase_Backup(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes a snapshot that opens as a fresh database", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.Save(ctx, newTestPayment(t, withID(fmt.Sprintf("backup_payment_%d", i)))))
+		}
+
+		backupDir := t.TempDir()
+		backupPath := filepath.Join(backupDir, "backup.db")
+		err := db.Backup(ctx, backupPath)
+		require.NoError(t, err)
+
+		config := DefaultConfig()
+		config.DatabasePath = backupPath
+		restored, err := NewDatabase(config)
+		require.NoError(t, err)
+		defer restored.Close()
+		require.NoError(t, restored.Initialize(ctx))
+
+		restoredRepo := NewPaymentRepository(restored, system.NewTimeProvider())
+		count, err := restoredRepo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		found, err := restoredRepo.FindByID(ctx, "backup_payment_1")
+		require.NoError(t, err)
+		assert.Equal(t, "backup_payment_1", found.ID())
+
+		entries, err := os.ReadDir(backupDir)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			assert.NotContains(t, entry.Name(), ".tmp", "temporary backup file should not be left behind")
+		}
+	})
+
+	t.Run("backs up an in-memory database", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultInMemoryConfig()
+		config.DatabasePath = t.Name()
+		db, err := NewDatabase(config)
+		require.NoError(t, err)
+		defer db.Close()
+
+		ctx := context.Background()
+		require.NoError(t, db.Initialize(ctx))
+		repo := NewPaymentRepository(db, system.NewTimeProvider())
+		require.NoError(t, repo.Save(ctx, newTestPayment(t)))
+
+		backupPath := filepath.Join(t.TempDir(), "memory.db")
+		require.NoError(t, db.Backup(ctx, backupPath))
+
+		restoredConfig := DefaultConfig()
+		restoredConfig.DatabasePath = backupPath
+		restored, err := NewDatabase(restoredConfig)
+		require.NoError(t, err)
+		defer restored.Close()
+
+		count, err := NewPaymentRepository(restored, system.NewTimeProvider()).Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
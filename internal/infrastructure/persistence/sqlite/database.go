@@ -3,12 +3,20 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+var (
+	ErrInvalidConfig = errors.New("invalid database config")
+	ErrShuttingDown  = errors.New("database is shutting down")
+)
+
 type Config struct {
 	DatabasePath      string
 	MaxOpenConns      int
@@ -16,8 +24,22 @@ type Config struct {
 	ConnMaxLifetime   time.Duration
 	ConnMaxIdleTime   time.Duration
 	BusyTimeout       time.Duration
+	QueryTimeout      time.Duration
 	EnableWAL         bool
 	EnableForeignKeys bool
+	// Synchronous is the synchronous pragma: OFF, NORMAL or FULL. FULL
+	// survives power loss at the cost of write speed; empty keeps SQLite's
+	// own default.
+	Synchronous string
+	// CacheSizeKB is the page cache size per connection in KiB. Zero keeps
+	// SQLite's own default.
+	CacheSizeKB int
+	// InMemory keeps the database in memory. DatabasePath then names the
+	// shared-cache instance, so distinct names give isolated databases.
+	InMemory bool
+	// AutoVacuum switches the database to incremental auto-vacuum on
+	// Initialize, so that IncrementalVacuum can give freed pages back.
+	AutoVacuum bool
 }
 
 func DefaultConfig() Config {
@@ -28,18 +50,99 @@ func DefaultConfig() Config {
 		ConnMaxLifetime:   5 * time.Minute,
 		ConnMaxIdleTime:   1 * time.Minute,
 		BusyTimeout:       30 * time.Second,
+		QueryTimeout:      5 * time.Second,
 		EnableWAL:         true,
 		EnableForeignKeys: true,
+		Synchronous:       "NORMAL",
+		CacheSizeKB:       64000,
 	}
 }
 
+// inMemoryDatabases numbers the instances named by DefaultInMemoryConfig.
+var inMemoryDatabases atomic.Uint64
+
+// DefaultInMemoryConfig returns a config for an ephemeral in-memory database.
+// Each call names a fresh instance, so databases opened from separate calls
+// never see each other's data.
+func DefaultInMemoryConfig() Config {
+	config := DefaultConfig()
+	config.DatabasePath = fmt.Sprintf("memdb%d", inMemoryDatabases.Add(1))
+	config.EnableWAL = false
+	config.InMemory = true
+	return config
+}
+
+// synchronousModes are the accepted values of Config.Synchronous.
+var synchronousModes = map[string]struct{}{"": {}, "OFF": {}, "NORMAL": {}, "FULL": {}}
+
+// Validate reports every setting that would make the database misbehave,
+// each wrapping ErrInvalidConfig. A MaxOpenConns of zero means no limit, as
+// in database/sql, and zero durations disable the corresponding timeout.
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.DatabasePath == "" && !c.InMemory {
+		errs = append(errs, fmt.Errorf("%w: database path is required", ErrInvalidConfig))
+	}
+	if c.MaxOpenConns < 0 {
+		errs = append(errs, fmt.Errorf("%w: max open connections must not be negative, got %d", ErrInvalidConfig, c.MaxOpenConns))
+	}
+	if c.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("%w: max idle connections must not be negative, got %d", ErrInvalidConfig, c.MaxIdleConns))
+	}
+	if _, ok := synchronousModes[c.Synchronous]; !ok {
+		errs = append(errs, fmt.Errorf("%w: synchronous must be OFF, NORMAL or FULL, got %q", ErrInvalidConfig, c.Synchronous))
+	}
+	if c.CacheSizeKB < 0 {
+		errs = append(errs, fmt.Errorf("%w: cache size must not be negative, got %d", ErrInvalidConfig, c.CacheSizeKB))
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("%w: max idle connections (%d) must not exceed max open connections (%d)",
+			ErrInvalidConfig, c.MaxIdleConns, c.MaxOpenConns))
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"connection max lifetime", c.ConnMaxLifetime},
+		{"connection max idle time", c.ConnMaxIdleTime},
+		{"busy timeout", c.BusyTimeout},
+		{"query timeout", c.QueryTimeout},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%w: %s must not be negative, got %s", ErrInvalidConfig, d.name, d.value))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 type Database struct {
 	db       *sql.DB
 	config   Config
 	migrator Migrator
+	// operations is shared by all copies of the Database so that Shutdown
+	// sees work started through any of them.
+	operations *operationTracker
 }
 
+// NewDatabase opens a connection pool for config, which must be valid.
 func NewDatabase(config Config) (Database, error) {
+	if err := config.Validate(); err != nil {
+		return Database{}, err
+	}
+
+	if config.InMemory {
+		// Each new connection would get its own empty in-memory database, so
+		// pin the pool to a single connection that is never recycled.
+		config.MaxOpenConns = 1
+		config.MaxIdleConns = 1
+		config.ConnMaxLifetime = 0
+		config.ConnMaxIdleTime = 0
+	}
+
 	dsn := buildDSN(config)
 
 	db, err := sql.Open("sqlite3", dsn)
@@ -53,9 +156,10 @@ func NewDatabase(config Config) (Database, error) {
 	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
 
 	database := Database{
-		db:       db,
-		config:   config,
-		migrator: NewMigrator(db),
+		db:         db,
+		config:     config,
+		migrator:   NewMigrator(db),
+		operations: &operationTracker{},
 	}
 
 	return database, nil
@@ -63,14 +167,24 @@ func NewDatabase(config Config) (Database, error) {
 
 func buildDSN(config Config) string {
 	dsn := config.DatabasePath + "?"
+	if config.InMemory {
+		dsn = "file:" + config.DatabasePath + "?mode=memory&cache=shared&"
+	}
 	params := []string{
 		fmt.Sprintf("_busy_timeout=%d", int(config.BusyTimeout.Milliseconds())),
 		"_txlock=immediate",
-		"_synchronous=NORMAL",
-		"_cache_size=-64000",
 	}
 
-	if config.EnableWAL {
+	if config.Synchronous != "" {
+		params = append(params, "_synchronous="+config.Synchronous)
+	}
+
+	if config.CacheSizeKB > 0 {
+		// A negative cache size is read by SQLite as KiB rather than pages.
+		params = append(params, fmt.Sprintf("_cache_size=-%d", config.CacheSizeKB))
+	}
+
+	if config.EnableWAL && !config.InMemory {
 		params = append(params, "_journal_mode=WAL")
 	}
 
@@ -93,6 +207,12 @@ func (d Database) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	if d.config.AutoVacuum {
+		if err := d.enableIncrementalVacuum(ctx); err != nil {
+			return err
+		}
+	}
+
 	if err := d.migrator.Migrate(ctx); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
@@ -100,6 +220,88 @@ func (d Database) Initialize(ctx context.Context) error {
 	return nil
 }
 
+// autoVacuumIncremental is the value PRAGMA auto_vacuum reports in incremental mode.
+const autoVacuumIncremental = 2
+
+// enableIncrementalVacuum sets auto_vacuum to INCREMENTAL. SQLite only applies
+// the mode to a database that already has tables after a VACUUM, so one is run
+// when the pragma did not take effect straight away.
+func (d Database) enableIncrementalVacuum(ctx context.Context) error {
+	if _, err := d.db.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+		return fmt.Errorf("failed to enable incremental auto-vacuum: %w", err)
+	}
+
+	var mode int
+	if err := d.db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return fmt.Errorf("failed to read auto-vacuum mode: %w", err)
+	}
+
+	if mode != autoVacuumIncremental {
+		if err := d.Vacuum(ctx); err != nil {
+			return fmt.Errorf("failed to apply incremental auto-vacuum: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Vacuum rebuilds the database file, reclaiming the space left by deleted
+// rows. It runs on the pool rather than a transaction, which SQLite requires,
+// and is not bounded by QueryTimeout since it can take a while on large files.
+func (d Database) Vacuum(ctx context.Context) error {
+	done, err := d.operations.start()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if _, err := d.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("vacuum failed: %w", err)
+	}
+
+	return nil
+}
+
+// IncrementalVacuum releases the database's free pages. It only shrinks the
+// file when Config.AutoVacuum is set and is a no-op otherwise.
+func (d Database) IncrementalVacuum(ctx context.Context) error {
+	done, err := d.operations.start()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if _, err := d.db.ExecContext(ctx, "PRAGMA incremental_vacuum"); err != nil {
+		return fmt.Errorf("incremental vacuum failed: %w", err)
+	}
+
+	return nil
+}
+
+// StartIncrementalVacuum runs IncrementalVacuum every interval until ctx is
+// cancelled or the database shuts down, then returns nil. Any other failure
+// stops the loop and is returned. StartIncrementalVacuum blocks; run it in its
+// own goroutine.
+func (d Database) StartIncrementalVacuum(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			err := d.IncrementalVacuum(ctx)
+			if errors.Is(err, ErrShuttingDown) || ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
 func (d Database) DB() *sql.DB {
 	return d.db
 }
@@ -108,13 +310,21 @@ func (d Database) Ping(ctx context.Context) error {
 	return d.db.PingContext(ctx)
 }
 
+// HealthCheck fails with ErrShuttingDown once Shutdown has been called, so
+// readiness probes stop routing traffic to the instance.
 func (d Database) HealthCheck(ctx context.Context) error {
+	done, err := d.operations.start()
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	if err := d.Ping(ctx); err != nil {
 		return fmt.Errorf("ping failed: %w", err)
 	}
 
 	var result int
-	err := d.db.QueryRowContext(ctx, "SELECT 1").Scan(&result)
+	err = d.db.QueryRowContext(ctx, "SELECT 1").Scan(&result)
 	if err != nil {
 		return fmt.Errorf("query test failed: %w", err)
 	}
@@ -132,6 +342,55 @@ func (d Database) HealthCheck(ctx context.Context) error {
 	return nil
 }
 
+// HealthStatus is a point-in-time report of database health for monitoring.
+type HealthStatus struct {
+	Healthy           bool
+	PingLatency       time.Duration
+	OpenConnections   int
+	InUseConnections  int
+	IdleConnections   int
+	MigrationsApplied int
+	CheckedAt         time.Time
+	Errors            []string
+}
+
+// HealthReport runs every health sub-check and reports all of them, rather
+// than stopping at the first failure like HealthCheck. Once Shutdown has been
+// called it runs no checks and reports ErrShuttingDown as its only error.
+func (d Database) HealthReport(ctx context.Context) HealthStatus {
+	status := HealthStatus{CheckedAt: time.Now().UTC()}
+
+	done, err := d.operations.start()
+	if err != nil {
+		status.Errors = append(status.Errors, err.Error())
+		return status
+	}
+	defer done()
+
+	start := time.Now()
+	if err := d.Ping(ctx); err != nil {
+		status.Errors = append(status.Errors, fmt.Sprintf("ping failed: %v", err))
+	}
+	status.PingLatency = time.Since(start)
+
+	var count int
+	if err := d.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM payments").Scan(&count); err != nil {
+		status.Errors = append(status.Errors, fmt.Sprintf("payments table check failed: %v", err))
+	}
+
+	if err := d.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&status.MigrationsApplied); err != nil {
+		status.Errors = append(status.Errors, fmt.Sprintf("migrations check failed: %v", err))
+	}
+
+	stats := d.db.Stats()
+	status.OpenConnections = stats.OpenConnections
+	status.InUseConnections = stats.InUse
+	status.IdleConnections = stats.Idle
+
+	status.Healthy = len(status.Errors) == 0
+	return status
+}
+
 func (d Database) GetStats() sql.DBStats {
 	return d.db.Stats()
 }
@@ -140,6 +399,10 @@ func (d Database) GetMigrationStatus(ctx context.Context) ([]Migration, error) {
 	return d.migrator.GetMigrationStatus(ctx)
 }
 
+func (d Database) RollbackMigrations(ctx context.Context, steps int) error {
+	return d.migrator.Rollback(ctx, steps)
+}
+
 func (d Database) Close() error {
 	if d.db != nil {
 		return d.db.Close()
@@ -147,18 +410,190 @@ func (d Database) Close() error {
 	return nil
 }
 
+// Shutdown stops the database from accepting new operations, which then fail
+// with ErrShuttingDown, waits for operations already in progress and closes
+// it. Open transactions count until they are committed or rolled back, and
+// open rows until they are closed. If ctx ends first the database is closed
+// anyway and ctx's error is returned.
+func (d Database) Shutdown(ctx context.Context) error {
+	drained := d.operations.stop()
+
+	var waitErr error
+	select {
+	case <-drained:
+		waitErr = d.waitIdle(ctx)
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+	if waitErr != nil {
+		waitErr = fmt.Errorf("stopped waiting for in-flight operations: %w", waitErr)
+	}
+
+	if err := d.Close(); err != nil {
+		return errors.Join(waitErr, fmt.Errorf("failed to close database: %w", err))
+	}
+
+	return waitErr
+}
+
+// idlePollInterval is how often Shutdown checks whether connections are
+// still in use.
+const idlePollInterval = 10 * time.Millisecond
+
+// waitIdle waits until no connection is in use. Transactions and rows hold on
+// to their connection until they end, so this covers them as well as the
+// statements still running.
+func (d Database) waitIdle(ctx context.Context) error {
+	ticker := time.NewTicker(idlePollInterval)
+	defer ticker.Stop()
+
+	for d.db.Stats().InUse > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// BeginTx starts a transaction. Its statements are bounded by ctx, not by
+// Config.QueryTimeout, and Shutdown waits for it to be committed or rolled
+// back.
 func (d Database) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	done, err := d.operations.start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	return d.db.BeginTx(ctx, opts)
 }
 
+// withQueryTimeout bounds ctx by Config.QueryTimeout unless the caller already
+// set a deadline.
+func (d Database) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || d.config.QueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d.config.QueryTimeout)
+}
+
+// withReadTimeout is withQueryTimeout for results read after the call
+// returns, so the timeout spans reading them. *sql.Rows has no hook to run on
+// Close, so the timeout cannot be released early and expires on its own.
+func (d Database) withReadTimeout(ctx context.Context) context.Context {
+	ctx, cancel := d.withQueryTimeout(ctx)
+	_ = cancel
+	return ctx
+}
+
 func (d Database) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	done, err := d.operations.start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	ctx, cancel := d.withQueryTimeout(ctx)
+	defer cancel()
 	return d.db.ExecContext(ctx, query, args...)
 }
 
+// QueryContext hands back rows that are read after it returns, so the
+// timeout spans reading them. The query stays in progress for Shutdown until
+// the rows are closed.
 func (d Database) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	done, err := d.operations.start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return d.db.QueryContext(d.withReadTimeout(ctx), query, args...)
+}
+
+// StreamContext is QueryContext without Config.QueryTimeout, for results that
+// are consumed as they are read and may take longer than any one query should,
+// such as exports. Only ctx bounds it.
+func (d Database) StreamContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	done, err := d.operations.start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	return d.db.QueryContext(ctx, query, args...)
 }
 
+// QueryRowContext defers the query's errors, ErrShuttingDown included, to
+// Row.Scan, which also ends the query for Shutdown.
 func (d Database) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return d.db.QueryRowContext(ctx, query, args...)
+	done, err := d.operations.start()
+	if err != nil {
+		// database/sql reports a context that is already done by its Err,
+		// which is how the refusal reaches Row.Scan.
+		return d.db.QueryRowContext(refusedContext{Context: ctx, err: err}, query, args...)
+	}
+	defer done()
+
+	return d.db.QueryRowContext(d.withReadTimeout(ctx), query, args...)
+}
+
+// refusedContext is a context that is done from the start, with err as its
+// error.
+type refusedContext struct {
+	context.Context
+	err error
+}
+
+func (c refusedContext) Done() <-chan struct{} {
+	return closedChannel
+}
+
+func (c refusedContext) Err() error {
+	return c.err
+}
+
+var closedChannel = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// operationTracker counts operations in progress and refuses new ones once
+// stopped. The read lock makes checking the flag and registering an operation
+// atomic with respect to stop, so no operation slips in after the drain began.
+type operationTracker struct {
+	mu       sync.RWMutex
+	stopped  bool
+	inFlight sync.WaitGroup
+}
+
+// start registers an operation, returning the func that ends it, or
+// ErrShuttingDown once stop has been called.
+func (t *operationTracker) start() (func(), error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.stopped {
+		return nil, ErrShuttingDown
+	}
+	t.inFlight.Add(1)
+	return t.inFlight.Done, nil
+}
+
+// stop refuses new operations and returns a channel closed once those in
+// progress have finished.
+func (t *operationTracker) stop() <-chan struct{} {
+	t.mu.Lock()
+	t.stopped = true
+	t.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		t.inFlight.Wait()
+		close(drained)
+	}()
+	return drained
 }
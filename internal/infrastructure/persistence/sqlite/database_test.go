@@ -2,12 +2,17 @@ package sqlite
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/domain/shared"
+	"paymentprocessor/internal/infrastructure/system"
 )
 
 func TestNewDatabase(t *testing.T) {
@@ -58,6 +63,226 @@ func TestNewDatabase(t *testing.T) {
 		stats := db.GetStats()
 		assert.Equal(t, 10, stats.MaxOpenConnections)
 	})
+
+	t.Run("rejects an invalid config", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultConfig()
+		config.DatabasePath = filepath.Join(t.TempDir(), "invalid.db")
+		config.MaxIdleConns = config.MaxOpenConns + 1
+
+		_, err := NewDatabase(config)
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("accepts the defaults", func(t *testing.T) {
+		t.Parallel()
+
+		assert.NoError(t, DefaultConfig().Validate())
+		assert.NoError(t, DefaultInMemoryConfig().Validate())
+	})
+
+	tests := []struct {
+		name    string
+		mutate  func(config *Config)
+		message string
+	}{
+		{
+			name:    "empty path",
+			mutate:  func(config *Config) { config.DatabasePath = "" },
+			message: "database path is required",
+		},
+		{
+			name:    "negative max open connections",
+			mutate:  func(config *Config) { config.MaxOpenConns = -1 },
+			message: "max open connections must not be negative",
+		},
+		{
+			name:    "negative max idle connections",
+			mutate:  func(config *Config) { config.MaxIdleConns = -1 },
+			message: "max idle connections must not be negative",
+		},
+		{
+			name:    "more idle than open connections",
+			mutate:  func(config *Config) { config.MaxOpenConns, config.MaxIdleConns = 2, 5 },
+			message: "max idle connections (5) must not exceed max open connections (2)",
+		},
+		{
+			name:    "negative connection lifetime",
+			mutate:  func(config *Config) { config.ConnMaxLifetime = -time.Minute },
+			message: "connection max lifetime must not be negative",
+		},
+		{
+			name:    "negative connection idle time",
+			mutate:  func(config *Config) { config.ConnMaxIdleTime = -time.Minute },
+			message: "connection max idle time must not be negative",
+		},
+		{
+			name:    "negative busy timeout",
+			mutate:  func(config *Config) { config.BusyTimeout = -time.Second },
+			message: "busy timeout must not be negative",
+		},
+		{
+			name:    "negative query timeout",
+			mutate:  func(config *Config) { config.QueryTimeout = -time.Second },
+			message: "query timeout must not be negative",
+		},
+		{
+			name:    "unknown synchronous mode",
+			mutate:  func(config *Config) { config.Synchronous = "EXTRA" },
+			message: `synchronous must be OFF, NORMAL or FULL, got "EXTRA"`,
+		},
+		{
+			name:    "negative cache size",
+			mutate:  func(config *Config) { config.CacheSizeKB = -1 },
+			message: "cache size must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := DefaultConfig()
+			tt.mutate(&config)
+
+			err := config.Validate()
+			assert.ErrorIs(t, err, ErrInvalidConfig)
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+
+	t.Run("allows an empty path in memory", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultInMemoryConfig()
+		config.DatabasePath = ""
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("allows idle connections when open connections are unlimited", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultConfig()
+		config.MaxOpenConns = 0
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("reports every problem at once", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultConfig()
+		config.DatabasePath = ""
+		config.BusyTimeout = -time.Second
+
+		err := config.Validate()
+		assert.ErrorContains(t, err, "database path is required")
+		assert.ErrorContains(t, err, "busy timeout must not be negative")
+	})
+}
+
+func TestBuildDSN(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keeps the default pragmas", func(t *testing.T) {
+		t.Parallel()
+
+		dsn := buildDSN(DefaultConfig())
+		assert.Contains(t, dsn, "_synchronous=NORMAL")
+		assert.Contains(t, dsn, "_cache_size=-64000")
+	})
+
+	t.Run("uses the configured pragmas", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultConfig()
+		config.Synchronous = "FULL"
+		config.CacheSizeKB = 2048
+
+		dsn := buildDSN(config)
+		assert.Contains(t, dsn, "_synchronous=FULL")
+		assert.Contains(t, dsn, "_cache_size=-2048")
+		assert.NotContains(t, dsn, "_synchronous=NORMAL")
+	})
+
+	t.Run("leaves unset pragmas to SQLite", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultConfig()
+		config.Synchronous = ""
+		config.CacheSizeKB = 0
+
+		dsn := buildDSN(config)
+		assert.NotContains(t, dsn, "_synchronous")
+		assert.NotContains(t, dsn, "_cache_size")
+	})
+}
+
+func TestNewDatabase_InMemory(t *testing.T) {
+	t.Parallel()
+
+	t.Run("forces a single connection", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultInMemoryConfig()
+		config.DatabasePath = t.Name()
+		config.MaxOpenConns = 10
+
+		db, err := NewDatabase(config)
+		require.NoError(t, err)
+		defer db.Close()
+
+		stats := db.GetStats()
+		assert.Equal(t, 1, stats.MaxOpenConnections)
+	})
+
+	t.Run("round-trips a payment", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultInMemoryConfig()
+		config.DatabasePath = t.Name()
+
+		db, err := NewDatabase(config)
+		require.NoError(t, err)
+		defer db.Close()
+
+		ctx := context.Background()
+		require.NoError(t, db.Initialize(ctx))
+
+		repo := NewPaymentRepository(db, system.NewTimeProvider())
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		found, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, testPayment.ID(), found.ID())
+		assert.True(t, testPayment.Amount().Equals(found.Amount()))
+	})
+
+	t.Run("isolates databases from separate default configs", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		first, err := NewDatabase(DefaultInMemoryConfig())
+		require.NoError(t, err)
+		defer first.Close()
+		require.NoError(t, first.Initialize(ctx))
+
+		second, err := NewDatabase(DefaultInMemoryConfig())
+		require.NoError(t, err)
+		defer second.Close()
+		require.NoError(t, second.Initialize(ctx))
+
+		testPayment := newTestPayment(t)
+		require.NoError(t, NewPaymentRepository(first, system.NewTimeProvider()).Save(ctx, testPayment))
+
+		_, err = NewPaymentRepository(second, system.NewTimeProvider()).FindByID(ctx, testPayment.ID())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+	})
 }
 
 func TestDatabase_Initialize(t *testing.T) {
@@ -102,6 +327,333 @@ func TestDatabase_Initialize(t *testing.T) {
 	})
 }
 
+func TestDatabase_QueryTimeout(t *testing.T) {
+	t.Parallel()
+
+	const slowQuery = `
+		WITH RECURSIVE counter(n) AS (
+			SELECT 1 UNION ALL SELECT n + 1 FROM counter
+		)
+		SELECT COUNT(*) FROM counter`
+
+	newDatabase := func(t *testing.T, timeout time.Duration) *Database {
+		config := DefaultConfig()
+		config.DatabasePath = filepath.Join(t.TempDir(), "timeout.db")
+		config.QueryTimeout = timeout
+
+		db, err := NewDatabase(config)
+		require.NoError(t, err)
+		return &db
+	}
+
+	t.Run("aborts a runaway query without a caller deadline", func(t *testing.T) {
+		t.Parallel()
+
+		db := newDatabase(t, 50*time.Millisecond)
+		defer db.Close()
+
+		var count int
+		start := time.Now()
+		err := db.QueryRowContext(context.Background(), slowQuery).Scan(&count)
+
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second, "query should be interrupted by the timeout")
+	})
+
+	t.Run("applies to ExecContext", func(t *testing.T) {
+		t.Parallel()
+
+		db := newDatabase(t, 50*time.Millisecond)
+		defer db.Close()
+
+		_, err := db.ExecContext(context.Background(), slowQuery)
+		assert.Error(t, err)
+	})
+
+	t.Run("keeps the caller deadline when one is set", func(t *testing.T) {
+		t.Parallel()
+
+		db := newDatabase(t, time.Nanosecond)
+		defer db.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var result int
+		err := db.QueryRowContext(ctx, "SELECT 1").Scan(&result)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result)
+	})
+}
+
+func TestDatabase_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	// slowQuery keeps a connection busy for a few hundred milliseconds.
+	const slowQuery = `
+		WITH RECURSIVE counter(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM counter WHERE n < 1000000)
+		SELECT COUNT(*) FROM counter
+	`
+
+	openDatabase := func(t *testing.T) Database {
+		t.Helper()
+
+		config := DefaultConfig()
+		config.DatabasePath = filepath.Join(t.TempDir(), "shutdown.db")
+		db, err := NewDatabase(config)
+		require.NoError(t, err)
+		require.NoError(t, db.Initialize(context.Background()))
+		return db
+	}
+
+	startSlowQuery := func(t *testing.T, db Database) <-chan error {
+		t.Helper()
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := db.ExecContext(context.Background(), slowQuery)
+			result <- err
+		}()
+		require.Eventually(t, func() bool { return db.GetStats().InUse == 1 }, time.Second, time.Millisecond,
+			"expected the slow query to be running")
+		return result
+	}
+
+	t.Run("lets in-flight queries finish and rejects new ones", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		db := openDatabase(t)
+		slow := startSlowQuery(t, db)
+
+		shutdown := make(chan error, 1)
+		go func() { shutdown <- db.Shutdown(ctx) }()
+
+		require.Eventually(t, func() bool {
+			_, err := db.ExecContext(ctx, "SELECT 1")
+			return errors.Is(err, ErrShuttingDown)
+		}, time.Second, time.Millisecond, "expected new queries to be rejected while draining")
+		_, err := db.QueryContext(ctx, "SELECT 1")
+		assert.ErrorIs(t, err, ErrShuttingDown)
+		var one int
+		assert.ErrorIs(t, db.QueryRowContext(ctx, "SELECT 1").Scan(&one), ErrShuttingDown)
+		assert.ErrorIs(t, db.HealthCheck(ctx), ErrShuttingDown)
+		report := db.HealthReport(ctx)
+		assert.False(t, report.Healthy)
+		assert.Equal(t, []string{ErrShuttingDown.Error()}, report.Errors)
+		assert.ErrorIs(t, db.Backup(ctx, filepath.Join(t.TempDir(), "backup.db")), ErrShuttingDown)
+
+		assert.NoError(t, <-slow, "expected the in-flight query to complete")
+		assert.NoError(t, <-shutdown)
+		assert.Error(t, db.DB().PingContext(ctx), "expected the database to be closed")
+	})
+
+	t.Run("waits for an open transaction to commit", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		db := openDatabase(t)
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+
+		shutdown := make(chan error, 1)
+		go func() { shutdown <- db.Shutdown(ctx) }()
+
+		require.Eventually(t, func() bool {
+			_, err := db.ExecContext(ctx, "SELECT 1")
+			return errors.Is(err, ErrShuttingDown)
+		}, time.Second, time.Millisecond, "expected new queries to be rejected while draining")
+		select {
+		case err := <-shutdown:
+			t.Fatalf("shutdown returned with a transaction open: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		_, err = tx.ExecContext(ctx, "SELECT 1")
+		require.NoError(t, err, "expected the open transaction to keep working while draining")
+		require.NoError(t, tx.Commit())
+		assert.NoError(t, <-shutdown)
+	})
+
+	t.Run("waits for open rows to be closed", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		db := openDatabase(t)
+		rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+		require.NoError(t, err)
+
+		shutdown := make(chan error, 1)
+		go func() { shutdown <- db.Shutdown(ctx) }()
+
+		select {
+		case err := <-shutdown:
+			t.Fatalf("shutdown returned with rows open: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		for rows.Next() {
+		}
+		require.NoError(t, rows.Err())
+		require.NoError(t, rows.Close())
+		assert.NoError(t, <-shutdown)
+	})
+
+	t.Run("gives up waiting at the context deadline", func(t *testing.T) {
+		t.Parallel()
+
+		db := openDatabase(t)
+		slow := startSlowQuery(t, db)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		err := db.Shutdown(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		_, err = db.ExecContext(context.Background(), "SELECT 1")
+		assert.ErrorIs(t, err, ErrShuttingDown)
+
+		<-slow
+	})
+}
+
+func TestDatabase_Vacuum(t *testing.T) {
+	t.Parallel()
+
+	openDatabase := func(t *testing.T, autoVacuum bool) Database {
+		t.Helper()
+
+		config := DefaultConfig()
+		config.DatabasePath = filepath.Join(t.TempDir(), "vacuum.db")
+		config.AutoVacuum = autoVacuum
+		db, err := NewDatabase(config)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		require.NoError(t, db.Initialize(context.Background()))
+		return db
+	}
+
+	autoVacuumMode := func(t *testing.T, db Database) int {
+		t.Helper()
+
+		var mode int
+		require.NoError(t, db.QueryRowContext(context.Background(), "PRAGMA auto_vacuum").Scan(&mode))
+		return mode
+	}
+
+	t.Run("vacuums a populated database", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		db := openDatabase(t, false)
+		repo := NewPaymentRepository(db, system.NewTimeProvider())
+		for i := 0; i < 20; i++ {
+			require.NoError(t, repo.Save(ctx, newTestPayment(t, withID(fmt.Sprintf("vacuum_payment_%03d", i)))))
+		}
+		_, err := db.ExecContext(ctx, "DELETE FROM payments WHERE id > 'vacuum_payment_010'")
+		require.NoError(t, err)
+
+		require.NoError(t, db.Vacuum(ctx))
+
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 11, count)
+	})
+
+	t.Run("leaves auto-vacuum off by default", func(t *testing.T) {
+		t.Parallel()
+
+		db := openDatabase(t, false)
+		assert.Equal(t, 0, autoVacuumMode(t, db))
+	})
+
+	t.Run("enables incremental auto-vacuum when configured", func(t *testing.T) {
+		t.Parallel()
+
+		db := openDatabase(t, true)
+		assert.Equal(t, autoVacuumIncremental, autoVacuumMode(t, db))
+		assert.NoError(t, db.IncrementalVacuum(context.Background()))
+	})
+
+	t.Run("converts an existing database", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		config := DefaultConfig()
+		config.DatabasePath = filepath.Join(t.TempDir(), "existing.db")
+		db, err := NewDatabase(config)
+		require.NoError(t, err)
+		require.NoError(t, db.Initialize(ctx))
+		require.NoError(t, db.Close())
+
+		config.AutoVacuum = true
+		db, err = NewDatabase(config)
+		require.NoError(t, err)
+		defer db.Close()
+		require.NoError(t, db.Initialize(ctx))
+
+		assert.Equal(t, autoVacuumIncremental, autoVacuumMode(t, db))
+	})
+
+	t.Run("stops the periodic vacuum on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		db := openDatabase(t, true)
+
+		stopped := make(chan error, 1)
+		go func() { stopped <- db.StartIncrementalVacuum(context.Background(), time.Millisecond) }()
+
+		require.NoError(t, db.Shutdown(context.Background()))
+		select {
+		case err := <-stopped:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("expected StartIncrementalVacuum to return after shutdown")
+		}
+	})
+}
+
+func TestDatabase_HealthReport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("populates all fields for a healthy database", func(t *testing.T) {
+		t.Parallel()
+
+		db := createTestDatabase(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		require.NoError(t, db.Initialize(ctx))
+
+		migrations, err := db.GetMigrationStatus(ctx)
+		require.NoError(t, err)
+
+		before := time.Now().UTC()
+		status := db.HealthReport(ctx)
+
+		assert.True(t, status.Healthy, "unexpected errors: %v", status.Errors)
+		assert.Empty(t, status.Errors)
+		assert.Greater(t, status.PingLatency, time.Duration(0))
+		assert.GreaterOrEqual(t, status.OpenConnections, 1)
+		assert.Equal(t, len(migrations), status.MigrationsApplied)
+		assert.False(t, status.CheckedAt.Before(before), "CheckedAt should be set to the check time")
+	})
+
+	t.Run("reports every failing check on an uninitialized database", func(t *testing.T) {
+		t.Parallel()
+
+		db := createTestDatabase(t)
+		defer db.Close()
+
+		status := db.HealthReport(context.Background())
+
+		assert.False(t, status.Healthy)
+		assert.Len(t, status.Errors, 2, "both the payments and migrations checks should fail")
+		assert.Zero(t, status.MigrationsApplied)
+		assert.False(t, status.CheckedAt.IsZero())
+	})
+}
+
 func TestDatabase_GetMigrationStatus(t *testing.T) {
 	t.Parallel()
 
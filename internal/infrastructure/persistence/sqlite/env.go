@@ -0,0 +1,114 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ConfigFromEnv returns DefaultConfig overridden by the DB_* environment
+// variables below. Unset or empty variables keep their default.
+//
+//	DB_PATH                 database file, or the instance name when in memory
+//	DB_IN_MEMORY            start from DefaultInMemoryConfig instead (bool)
+//	DB_MAX_OPEN_CONNS       int
+//	DB_MAX_IDLE_CONNS       int
+//	DB_CONN_MAX_LIFETIME    duration, e.g. "5m"
+//	DB_CONN_MAX_IDLE_TIME   duration
+//	DB_BUSY_TIMEOUT         duration
+//	DB_QUERY_TIMEOUT        duration
+//	DB_ENABLE_WAL           bool
+//	DB_ENABLE_FOREIGN_KEYS  bool
+//	DB_AUTO_VACUUM          bool
+//	DB_SYNCHRONOUS          OFF, NORMAL or FULL
+//	DB_CACHE_SIZE_KB        int
+//
+// Every malformed value is reported, each wrapping ErrInvalidConfig.
+func ConfigFromEnv() (Config, error) {
+	var env envReader
+
+	var inMemory bool
+	env.bool("DB_IN_MEMORY", &inMemory)
+
+	config := DefaultConfig()
+	if inMemory {
+		config = DefaultInMemoryConfig()
+	}
+
+	env.string("DB_PATH", &config.DatabasePath)
+	env.int("DB_MAX_OPEN_CONNS", &config.MaxOpenConns)
+	env.int("DB_MAX_IDLE_CONNS", &config.MaxIdleConns)
+	env.duration("DB_CONN_MAX_LIFETIME", &config.ConnMaxLifetime)
+	env.duration("DB_CONN_MAX_IDLE_TIME", &config.ConnMaxIdleTime)
+	env.duration("DB_BUSY_TIMEOUT", &config.BusyTimeout)
+	env.duration("DB_QUERY_TIMEOUT", &config.QueryTimeout)
+	env.bool("DB_ENABLE_WAL", &config.EnableWAL)
+	env.bool("DB_ENABLE_FOREIGN_KEYS", &config.EnableForeignKeys)
+	env.bool("DB_AUTO_VACUUM", &config.AutoVacuum)
+	env.string("DB_SYNCHRONOUS", &config.Synchronous)
+	env.int("DB_CACHE_SIZE_KB", &config.CacheSizeKB)
+
+	if err := errors.Join(env.errs...); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// envReader overwrites config fields from set environment variables and
+// collects parse errors so they can be reported together.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) lookup(name string) (string, bool) {
+	value, ok := os.LookupEnv(name)
+	return value, ok && value != ""
+}
+
+func (e *envReader) string(name string, dst *string) {
+	if value, ok := e.lookup(name); ok {
+		*dst = value
+	}
+}
+
+func (e *envReader) int(name string, dst *int) {
+	value, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%w: %s=%q is not an integer", ErrInvalidConfig, name, value))
+		return
+	}
+	*dst = parsed
+}
+
+func (e *envReader) duration(name string, dst *time.Duration) {
+	value, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%w: %s=%q is not a duration such as \"30s\"", ErrInvalidConfig, name, value))
+		return
+	}
+	*dst = parsed
+}
+
+func (e *envReader) bool(name string, dst *bool) {
+	value, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%w: %s=%q is not a boolean", ErrInvalidConfig, name, value))
+		return
+	}
+	*dst = parsed
+}
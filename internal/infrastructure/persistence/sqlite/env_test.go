@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// These tests set process environment variables and so cannot run in parallel.
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("falls back to defaults", func(t *testing.T) {
+		t.Setenv("DB_PATH", "")
+
+		config, err := ConfigFromEnv()
+
+		require.NoError(t, err)
+		assert.Equal(t, DefaultConfig(), config)
+	})
+
+	t.Run("overrides defaults with set variables", func(t *testing.T) {
+		t.Setenv("DB_PATH", "/var/lib/payments/payments.db")
+		t.Setenv("DB_MAX_OPEN_CONNS", "10")
+		t.Setenv("DB_MAX_IDLE_CONNS", "2")
+		t.Setenv("DB_CONN_MAX_LIFETIME", "10m")
+		t.Setenv("DB_CONN_MAX_IDLE_TIME", "30s")
+		t.Setenv("DB_BUSY_TIMEOUT", "2s")
+		t.Setenv("DB_QUERY_TIMEOUT", "1500ms")
+		t.Setenv("DB_ENABLE_WAL", "false")
+		t.Setenv("DB_ENABLE_FOREIGN_KEYS", "0")
+		t.Setenv("DB_AUTO_VACUUM", "true")
+		t.Setenv("DB_SYNCHRONOUS", "FULL")
+		t.Setenv("DB_CACHE_SIZE_KB", "8000")
+
+		config, err := ConfigFromEnv()
+
+		require.NoError(t, err)
+		assert.Equal(t, Config{
+			DatabasePath:      "/var/lib/payments/payments.db",
+			MaxOpenConns:      10,
+			MaxIdleConns:      2,
+			ConnMaxLifetime:   10 * time.Minute,
+			ConnMaxIdleTime:   30 * time.Second,
+			BusyTimeout:       2 * time.Second,
+			QueryTimeout:      1500 * time.Millisecond,
+			EnableWAL:         false,
+			EnableForeignKeys: false,
+			AutoVacuum:        true,
+			Synchronous:       "FULL",
+			CacheSizeKB:       8000,
+		}, config)
+	})
+
+	t.Run("starts from the in-memory defaults", func(t *testing.T) {
+		t.Setenv("DB_IN_MEMORY", "true")
+		t.Setenv("DB_PATH", "payments-dev")
+
+		config, err := ConfigFromEnv()
+
+		require.NoError(t, err)
+		expected := DefaultInMemoryConfig()
+		expected.DatabasePath = "payments-dev"
+		assert.Equal(t, expected, config)
+	})
+
+	t.Run("rejects malformed values", func(t *testing.T) {
+		t.Setenv("DB_MAX_OPEN_CONNS", "twenty")
+		t.Setenv("DB_BUSY_TIMEOUT", "30")
+		t.Setenv("DB_ENABLE_WAL", "maybe")
+
+		config, err := ConfigFromEnv()
+
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, `DB_MAX_OPEN_CONNS="twenty" is not an integer`)
+		assert.ErrorContains(t, err, `DB_BUSY_TIMEOUT="30" is not a duration`)
+		assert.ErrorContains(t, err, `DB_ENABLE_WAL="maybe" is not a boolean`)
+		assert.Equal(t, Config{}, config)
+	})
+}
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS update_payments_updated_at;
+
+CREATE TRIGGER IF NOT EXISTS update_payments_updated_at
+    AFTER UPDATE ON payments
+    FOR EACH ROW
+BEGIN
+    UPDATE payments SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
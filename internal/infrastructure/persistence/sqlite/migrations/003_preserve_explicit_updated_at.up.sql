@@ -0,0 +1,11 @@
+-- Only fall back to CURRENT_TIMESTAMP when an update leaves updated_at untouched,
+-- so timestamps stamped by the application clock are kept.
+DROP TRIGGER IF EXISTS update_payments_updated_at;
+
+CREATE TRIGGER IF NOT EXISTS update_payments_updated_at
+    AFTER UPDATE ON payments
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE payments SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,44 @@
+-- SQLite cannot alter a CHECK constraint in place, so the table is rebuilt.
+
+CREATE TABLE payments_new (
+    id TEXT PRIMARY KEY NOT NULL,
+    debtor_iban TEXT NOT NULL,
+    debtor_name TEXT NOT NULL,
+    creditor_iban TEXT NOT NULL,
+    creditor_name TEXT NOT NULL,
+    amount_cents INTEGER NOT NULL CHECK(amount_cents > 0),
+    currency TEXT NOT NULL DEFAULT 'EUR',
+    idempotency_key TEXT NOT NULL UNIQUE,
+    status TEXT NOT NULL CHECK(status IN ('PENDING', 'PROCESSED', 'FAILED', 'CANCELLED')),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at DATETIME NULL
+);
+
+INSERT INTO payments_new (
+    id, debtor_iban, debtor_name, creditor_iban, creditor_name, amount_cents, currency,
+    idempotency_key, status, created_at, updated_at, version, deleted_at
+)
+SELECT
+    id, debtor_iban, debtor_name, creditor_iban, creditor_name, amount_cents, currency,
+    idempotency_key, status, created_at, updated_at, version, deleted_at
+FROM payments;
+
+DROP TABLE payments;
+ALTER TABLE payments_new RENAME TO payments;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_idempotency_key ON payments(idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status);
+CREATE INDEX IF NOT EXISTS idx_payments_created_at ON payments(created_at);
+CREATE INDEX IF NOT EXISTS idx_payments_updated_at ON payments(updated_at);
+CREATE INDEX IF NOT EXISTS idx_payments_debtor_iban ON payments(debtor_iban);
+CREATE INDEX IF NOT EXISTS idx_payments_creditor_iban ON payments(creditor_iban);
+
+CREATE TRIGGER IF NOT EXISTS update_payments_updated_at
+    AFTER UPDATE ON payments
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE payments SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1 @@
+ALTER TABLE payments ADD COLUMN reference TEXT NOT NULL DEFAULT '';
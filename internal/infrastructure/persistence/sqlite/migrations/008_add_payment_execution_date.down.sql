@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_execution_date;
+
+ALTER TABLE payments DROP COLUMN execution_date;
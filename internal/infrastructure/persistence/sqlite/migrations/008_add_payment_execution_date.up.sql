@@ -0,0 +1,3 @@
+ALTER TABLE payments ADD COLUMN execution_date DATETIME NULL;
+
+CREATE INDEX IF NOT EXISTS idx_payments_execution_date ON payments(execution_date);
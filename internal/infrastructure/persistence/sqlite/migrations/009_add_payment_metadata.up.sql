@@ -0,0 +1 @@
+ALTER TABLE payments ADD COLUMN metadata TEXT NOT NULL DEFAULT '{}' CHECK(json_valid(metadata));
@@ -0,0 +1,10 @@
+-- Stored timestamps are left in RFC 3339 form; both formats parse on read.
+DROP TRIGGER IF EXISTS update_payments_updated_at;
+
+CREATE TRIGGER IF NOT EXISTS update_payments_updated_at
+    AFTER UPDATE ON payments
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE payments SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,18 @@
+-- Rewrite timestamps as RFC 3339 UTC text with millisecond precision, matching
+-- what the repository writes, so that text comparisons order rows correctly.
+-- The trigger is dropped first so the rewrite does not bump updated_at.
+DROP TRIGGER IF EXISTS update_payments_updated_at;
+
+UPDATE payments SET
+    created_at = strftime('%Y-%m-%dT%H:%M:%fZ', created_at),
+    updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', updated_at),
+    execution_date = strftime('%Y-%m-%dT%H:%M:%fZ', execution_date),
+    deleted_at = strftime('%Y-%m-%dT%H:%M:%fZ', deleted_at);
+
+CREATE TRIGGER IF NOT EXISTS update_payments_updated_at
+    AFTER UPDATE ON payments
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE payments SET updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE id = NEW.id;
+END;
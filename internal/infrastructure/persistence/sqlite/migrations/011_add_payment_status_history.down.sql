@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS payment_status_history_no_delete;
+DROP TRIGGER IF EXISTS payment_status_history_no_update;
+DROP INDEX IF EXISTS idx_payment_status_history_payment_id;
+DROP TABLE IF EXISTS payment_status_history;
@@ -0,0 +1,25 @@
+-- Append-only audit trail of payment status changes. Rows are written by the
+-- repository in the same transaction as the status update; the triggers
+-- reject any attempt to rewrite or remove them.
+CREATE TABLE IF NOT EXISTS payment_status_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    payment_id TEXT NOT NULL REFERENCES payments(id),
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    changed_at DATETIME NOT NULL,
+    actor TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_status_history_payment_id ON payment_status_history(payment_id, changed_at);
+
+CREATE TRIGGER IF NOT EXISTS payment_status_history_no_update
+    BEFORE UPDATE ON payment_status_history
+BEGIN
+    SELECT RAISE(ABORT, 'payment status history is immutable');
+END;
+
+CREATE TRIGGER IF NOT EXISTS payment_status_history_no_delete
+    BEFORE DELETE ON payment_status_history
+BEGIN
+    SELECT RAISE(ABORT, 'payment status history is immutable');
+END;
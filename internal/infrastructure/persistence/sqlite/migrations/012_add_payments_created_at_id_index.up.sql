@@ -0,0 +1,2 @@
+-- Serves keyset pagination in ListAfter, which orders and seeks by (created_at, id).
+CREATE INDEX IF NOT EXISTS idx_payments_created_at_id ON payments(created_at, id);
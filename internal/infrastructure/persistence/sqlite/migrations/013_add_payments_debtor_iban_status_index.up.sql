@@ -0,0 +1,2 @@
+-- Serves FindByDebtorIBANAndStatus, e.g. looking up a debtor's failed payments.
+CREATE INDEX IF NOT EXISTS idx_payments_debtor_iban_status ON payments(debtor_iban, status);
@@ -0,0 +1,2 @@
+-- Serves FindByAmountRange, e.g. fraud review of payments above a threshold.
+CREATE INDEX IF NOT EXISTS idx_payments_amount_cents ON payments(amount_cents, id);
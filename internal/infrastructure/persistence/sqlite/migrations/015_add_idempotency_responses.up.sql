@@ -0,0 +1,10 @@
+-- Responses to create requests keyed by idempotency key, so that a retry is
+-- answered with the original response. A row without a status code is a
+-- reservation held by the request still being processed.
+CREATE TABLE IF NOT EXISTS idempotency_responses (
+    idempotency_key TEXT PRIMARY KEY,
+    status_code INTEGER,
+    body BLOB,
+    reserved_at DATETIME NOT NULL,
+    completed_at DATETIME
+);
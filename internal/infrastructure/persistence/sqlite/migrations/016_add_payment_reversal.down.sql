@@ -0,0 +1,88 @@
+-- Fails on the CHECK constraint while any REVERSED payments remain. Reversal
+-- entries themselves are kept, without their link.
+
+-- The history references payments, so dropping payments inside the migration
+-- transaction would violate its foreign key. It is set aside in a plain table
+-- and rebuilt once payments is back.
+CREATE TABLE payment_status_history_backup AS SELECT * FROM payment_status_history;
+DROP TABLE payment_status_history;
+
+CREATE TABLE payments_new (
+    id TEXT PRIMARY KEY NOT NULL,
+    debtor_iban TEXT NOT NULL,
+    debtor_name TEXT NOT NULL,
+    creditor_iban TEXT NOT NULL,
+    creditor_name TEXT NOT NULL,
+    amount_cents INTEGER NOT NULL CHECK(amount_cents > 0),
+    currency TEXT NOT NULL DEFAULT 'EUR',
+    idempotency_key TEXT NOT NULL UNIQUE,
+    status TEXT NOT NULL CHECK(status IN ('PENDING', 'PROCESSING', 'PROCESSED', 'FAILED', 'CANCELLED')),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at DATETIME NULL,
+    reference TEXT NOT NULL DEFAULT '',
+    execution_date DATETIME NULL,
+    metadata TEXT NOT NULL DEFAULT '{}' CHECK(json_valid(metadata))
+);
+
+INSERT INTO payments_new (
+    id, debtor_iban, debtor_name, creditor_iban, creditor_name, amount_cents, currency,
+    idempotency_key, status, created_at, updated_at, version, deleted_at, reference,
+    execution_date, metadata
+)
+SELECT
+    id, debtor_iban, debtor_name, creditor_iban, creditor_name, amount_cents, currency,
+    idempotency_key, status, created_at, updated_at, version, deleted_at, reference,
+    execution_date, metadata
+FROM payments;
+
+DROP TABLE payments;
+ALTER TABLE payments_new RENAME TO payments;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_idempotency_key ON payments(idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status);
+CREATE INDEX IF NOT EXISTS idx_payments_created_at ON payments(created_at);
+CREATE INDEX IF NOT EXISTS idx_payments_updated_at ON payments(updated_at);
+CREATE INDEX IF NOT EXISTS idx_payments_debtor_iban ON payments(debtor_iban);
+CREATE INDEX IF NOT EXISTS idx_payments_creditor_iban ON payments(creditor_iban);
+CREATE INDEX IF NOT EXISTS idx_payments_execution_date ON payments(execution_date);
+CREATE INDEX IF NOT EXISTS idx_payments_created_at_id ON payments(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_payments_debtor_iban_status ON payments(debtor_iban, status);
+CREATE INDEX IF NOT EXISTS idx_payments_amount_cents ON payments(amount_cents, id);
+
+CREATE TRIGGER IF NOT EXISTS update_payments_updated_at
+    AFTER UPDATE ON payments
+    FOR EACH ROW
+    WHEN NEW.updated_at = OLD.updated_at
+BEGIN
+    UPDATE payments SET updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now') WHERE id = NEW.id;
+END;
+
+CREATE TABLE payment_status_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    payment_id TEXT NOT NULL REFERENCES payments(id),
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    changed_at DATETIME NOT NULL,
+    actor TEXT NOT NULL
+);
+
+INSERT INTO payment_status_history (id, payment_id, from_status, to_status, changed_at, actor)
+SELECT id, payment_id, from_status, to_status, changed_at, actor FROM payment_status_history_backup;
+
+DROP TABLE payment_status_history_backup;
+
+CREATE INDEX IF NOT EXISTS idx_payment_status_history_payment_id ON payment_status_history(payment_id, changed_at);
+
+CREATE TRIGGER IF NOT EXISTS payment_status_history_no_update
+    BEFORE UPDATE ON payment_status_history
+BEGIN
+    SELECT RAISE(ABORT, 'payment status history is immutable');
+END;
+
+CREATE TRIGGER IF NOT EXISTS payment_status_history_no_delete
+    BEFORE DELETE ON payment_status_history
+BEGIN
+    SELECT RAISE(ABORT, 'payment status history is immutable');
+END;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_idempotency_responses_completed_at;
+ALTER TABLE idempotency_responses DROP COLUMN request_hash;
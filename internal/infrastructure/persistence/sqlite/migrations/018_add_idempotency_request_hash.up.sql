@@ -0,0 +1,7 @@
+-- Records a hash of the request each idempotency key was first used with, so
+-- that reusing the key for a different request can be refused, and indexes
+-- completion times for purging expired responses. Rows stored before this
+-- migration have an empty hash and are replayed to any request.
+ALTER TABLE idempotency_responses ADD COLUMN request_hash TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_responses_completed_at ON idempotency_responses(completed_at);
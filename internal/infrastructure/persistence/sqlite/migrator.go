@@ -2,10 +2,14 @@ package sqlite
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"embed"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"sort"
 	"strings"
 	"time"
@@ -16,18 +20,29 @@ import (
 //go:embed migrations/*.sql
 var migrationFiles embed.FS
 
+var ErrMigrationChecksumMismatch = errors.New("migration checksum mismatch")
+
+const (
+	upMigrationSuffix   = ".up.sql"
+	downMigrationSuffix = ".down.sql"
+)
+
 type Migration struct {
 	Version   int
+	Name      string
 	SQL       string
+	DownSQL   string
+	Checksum  string
 	AppliedAt *time.Time
 }
 
 type Migrator struct {
-	db *sql.DB
+	db    *sql.DB
+	files fs.FS
 }
 
 func NewMigrator(db *sql.DB) Migrator {
-	return Migrator{db: db}
+	return Migrator{db: db, files: migrationFiles}
 }
 
 func (m Migrator) Migrate(ctx context.Context) error {
@@ -45,6 +60,10 @@ func (m Migrator) Migrate(ctx context.Context) error {
 		return fmt.Errorf("failed to get applied migrations: %w", err)
 	}
 
+	if err := m.verifyChecksums(ctx, availableMigrations, appliedMigrations); err != nil {
+		return err
+	}
+
 	pendingMigrations := m.findPendingMigrations(availableMigrations, appliedMigrations)
 
 	for _, migration := range pendingMigrations {
@@ -56,6 +75,55 @@ func (m Migrator) Migrate(ctx context.Context) error {
 	return nil
 }
 
+// Rollback reverts the most recently applied migrations, newest first, running
+// each down migration in its own transaction. Legacy single-file migrations
+// have no down SQL and cannot be rolled back.
+func (m Migrator) Rollback(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("rollback steps must be positive, got %d", steps)
+	}
+
+	if err := m.createMigrationsTable(ctx); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	availableMigrations, err := m.getAvailableMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to get available migrations: %w", err)
+	}
+
+	appliedMigrations, err := m.getAppliedMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	availableMap := make(map[int]Migration)
+	for _, migration := range availableMigrations {
+		availableMap[migration.Version] = migration
+	}
+
+	for i := len(appliedMigrations) - 1; i >= 0 && steps > 0; i-- {
+		version := appliedMigrations[i].Version
+
+		migration, exists := availableMap[version]
+		if !exists {
+			return fmt.Errorf("applied migration %d has no matching migration file", version)
+		}
+
+		if migration.DownSQL == "" {
+			return fmt.Errorf("migration %d (%s) has no down migration", migration.Version, migration.Name)
+		}
+
+		if err := m.revertMigration(ctx, migration); err != nil {
+			return fmt.Errorf("failed to roll back migration %d: %w", migration.Version, err)
+		}
+
+		steps--
+	}
+
+	return nil
+}
+
 func (m Migrator) GetMigrationStatus(ctx context.Context) ([]Migration, error) {
 	if err := m.createMigrationsTable(ctx); err != nil {
 		return nil, fmt.Errorf("failed to create migrations table: %w", err)
@@ -100,24 +168,47 @@ func (m Migrator) createMigrationsTable(ctx context.Context) error {
 	query := `
 		CREATE TABLE IF NOT EXISTS schema_migrations (
 			version INTEGER PRIMARY KEY,
-			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			checksum TEXT NOT NULL DEFAULT ''
 		);
 		
 		CREATE INDEX IF NOT EXISTS idx_schema_migrations_applied_at 
 		ON schema_migrations(applied_at);
 	`
 
-	_, err := m.db.ExecContext(ctx, query)
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	return m.ensureChecksumColumn(ctx)
+}
+
+// ensureChecksumColumn upgrades schema_migrations tables created before
+// checksums were recorded.
+func (m Migrator) ensureChecksumColumn(ctx context.Context) error {
+	var count int
+	err := m.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('schema_migrations') WHERE name = 'checksum'`,
+	).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to inspect schema_migrations columns: %w", err)
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	_, err = m.db.ExecContext(ctx, `ALTER TABLE schema_migrations ADD COLUMN checksum TEXT NOT NULL DEFAULT ''`)
 	return err
 }
 
 func (m Migrator) getAvailableMigrations() ([]Migration, error) {
-	entries, err := migrationFiles.ReadDir("migrations")
+	entries, err := fs.ReadDir(m.files, "migrations")
 	if err != nil {
 		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
 	}
 
-	var migrations []Migration
+	migrationMap := make(map[int]Migration)
 	for _, entry := range entries {
 		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
 			continue
@@ -132,6 +223,27 @@ func (m Migrator) getAvailableMigrations() ([]Migration, error) {
 			return nil, fmt.Errorf("failed to parse migration file %s: %w", entry.Name(), err)
 		}
 
+		// Paired up/down files share a version and are merged into one migration
+		existing, exists := migrationMap[migration.Version]
+		if exists {
+			if migration.SQL == "" {
+				existing.DownSQL = migration.DownSQL
+			} else {
+				existing.Name = migration.Name
+				existing.SQL = migration.SQL
+				existing.Checksum = migration.Checksum
+			}
+			migration = existing
+		}
+
+		migrationMap[migration.Version] = migration
+	}
+
+	var migrations []Migration
+	for _, migration := range migrationMap {
+		if migration.SQL == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up migration", migration.Version, migration.Name)
+		}
 		migrations = append(migrations, migration)
 	}
 
@@ -153,20 +265,33 @@ func (m Migrator) parseMigrationFile(filename string) (Migration, error) {
 		return Migration{}, fmt.Errorf("failed to parse version from filename %s: %w", filename, err)
 	}
 
-	sqlBytes, err := migrationFiles.ReadFile(filepath.Join("migrations", filename))
+	sqlBytes, err := fs.ReadFile(m.files, path.Join("migrations", filename))
 	if err != nil {
 		return Migration{}, fmt.Errorf("failed to read migration file %s: %w", filename, err)
 	}
 
+	if strings.HasSuffix(parts[1], downMigrationSuffix) {
+		return Migration{
+			Version: version,
+			Name:    strings.TrimSuffix(parts[1], downMigrationSuffix),
+			DownSQL: string(sqlBytes),
+		}, nil
+	}
+
+	name := strings.TrimSuffix(parts[1], upMigrationSuffix)
+	name = strings.TrimSuffix(name, ".sql")
+
 	return Migration{
-		Version: version,
-		SQL:     string(sqlBytes),
+		Version:  version,
+		Name:     name,
+		SQL:      string(sqlBytes),
+		Checksum: checksum(sqlBytes),
 	}, nil
 }
 
 func (m Migrator) getAppliedMigrations(ctx context.Context) ([]Migration, error) {
 	query := `
-		SELECT version, applied_at 
+		SELECT version, applied_at, checksum
 		FROM schema_migrations 
 		ORDER BY version
 	`
@@ -182,7 +307,7 @@ func (m Migrator) getAppliedMigrations(ctx context.Context) ([]Migration, error)
 		var migration Migration
 		var appliedAt time.Time
 
-		err := rows.Scan(&migration.Version, &appliedAt)
+		err := rows.Scan(&migration.Version, &appliedAt, &migration.Checksum)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan migration row: %w", err)
 		}
@@ -194,6 +319,43 @@ func (m Migrator) getAppliedMigrations(ctx context.Context) ([]Migration, error)
 	return migrations, rows.Err()
 }
 
+// verifyChecksums fails when an already applied migration file has been edited
+// since it was applied. Rows recorded before checksums existed are backfilled.
+func (m Migrator) verifyChecksums(ctx context.Context, available, applied []Migration) error {
+	availableMap := make(map[int]Migration)
+	for _, migration := range available {
+		availableMap[migration.Version] = migration
+	}
+
+	for _, appliedMigration := range applied {
+		migration, exists := availableMap[appliedMigration.Version]
+		if !exists {
+			continue
+		}
+
+		if appliedMigration.Checksum == "" {
+			if err := m.recordChecksum(ctx, migration); err != nil {
+				return fmt.Errorf("failed to backfill checksum for migration %d: %w", migration.Version, err)
+			}
+			continue
+		}
+
+		if appliedMigration.Checksum != migration.Checksum {
+			return fmt.Errorf("%w: version %d (%s)", ErrMigrationChecksumMismatch, migration.Version, migration.Name)
+		}
+	}
+
+	return nil
+}
+
+func (m Migrator) recordChecksum(ctx context.Context, migration Migration) error {
+	_, err := m.db.ExecContext(ctx,
+		`UPDATE schema_migrations SET checksum = ? WHERE version = ?`,
+		migration.Checksum, migration.Version,
+	)
+	return err
+}
+
 func (m Migrator) findPendingMigrations(available, applied []Migration) []Migration {
 	appliedMap := make(map[int]bool)
 	for _, migration := range applied {
@@ -221,10 +383,34 @@ func (m Migrator) applyMigration(ctx context.Context, migration Migration) error
 		return fmt.Errorf("failed to execute migration SQL: %w", err)
 	}
 
-	insertQuery := `INSERT INTO schema_migrations (version) VALUES (?)`
-	if _, err := tx.ExecContext(ctx, insertQuery, migration.Version); err != nil {
+	insertQuery := `INSERT INTO schema_migrations (version, checksum) VALUES (?, ?)`
+	if _, err := tx.ExecContext(ctx, insertQuery, migration.Version, migration.Checksum); err != nil {
 		return fmt.Errorf("failed to record migration: %w", err)
 	}
 
 	return tx.Commit()
 }
+
+func (m Migrator) revertMigration(ctx context.Context, migration Migration) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.DownSQL); err != nil {
+		return fmt.Errorf("failed to execute down migration SQL: %w", err)
+	}
+
+	deleteQuery := `DELETE FROM schema_migrations WHERE version = ?`
+	if _, err := tx.ExecContext(ctx, deleteQuery, migration.Version); err != nil {
+		return fmt.Errorf("failed to remove migration record: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
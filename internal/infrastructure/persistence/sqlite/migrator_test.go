@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -52,11 +53,168 @@ func TestMigrator_Migrate(t *testing.T) {
 		err = migrator.Migrate(ctx)
 		require.NoError(t, err)
 
-		// Verify only one migration record exists
+		// Verify each migration was recorded exactly once
+		available, err := migrator.getAvailableMigrations()
+		require.NoError(t, err)
+
 		var count int
 		err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&count)
 		require.NoError(t, err)
-		assert.Equal(t, 1, count) // Should have exactly one migration record
+		assert.Equal(t, len(available), count) // Should have one record per migration
+	})
+}
+
+func TestMigrator_VerifyChecksums(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns mismatch error when an applied migration is modified", func(t *testing.T) {
+		t.Parallel()
+
+		db := createTestDatabase(t)
+		defer db.Close()
+
+		migrator := NewMigrator(db.DB())
+		migrator.files = fstest.MapFS{
+			"migrations/001_create_widgets.sql": {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);")},
+		}
+		ctx := context.Background()
+
+		err := migrator.Migrate(ctx)
+		require.NoError(t, err)
+
+		migrator.files = fstest.MapFS{
+			"migrations/001_create_widgets.sql": {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT);")},
+		}
+
+		err = migrator.Migrate(ctx)
+		assert.ErrorIs(t, err, ErrMigrationChecksumMismatch)
+		assert.Contains(t, err.Error(), "version 1")
+		assert.Contains(t, err.Error(), "create_widgets")
+	})
+
+	t.Run("backfills checksums recorded before they were tracked", func(t *testing.T) {
+		t.Parallel()
+
+		db := createTestDatabase(t)
+		defer db.Close()
+
+		migrator := NewMigrator(db.DB())
+		ctx := context.Background()
+
+		err := migrator.Migrate(ctx)
+		require.NoError(t, err)
+
+		_, err = db.ExecContext(ctx, "UPDATE schema_migrations SET checksum = ''")
+		require.NoError(t, err)
+
+		err = migrator.Migrate(ctx)
+		require.NoError(t, err)
+
+		var emptyCount int
+		err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE checksum = ''").Scan(&emptyCount)
+		require.NoError(t, err)
+		assert.Equal(t, 0, emptyCount)
+	})
+}
+
+func TestMigrator_Rollback(t *testing.T) {
+	t.Parallel()
+
+	pairedFiles := func() fstest.MapFS {
+		return fstest.MapFS{
+			"migrations/001_create_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);")},
+			"migrations/001_create_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")},
+			"migrations/002_create_gadgets.up.sql":   {Data: []byte("CREATE TABLE gadgets (id INTEGER PRIMARY KEY);")},
+			"migrations/002_create_gadgets.down.sql": {Data: []byte("DROP TABLE gadgets;")},
+		}
+	}
+
+	tableExists := func(t *testing.T, db *Database, name string) bool {
+		var count int
+		err := db.QueryRowContext(context.Background(),
+			"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+		require.NoError(t, err)
+		return count > 0
+	}
+
+	t.Run("rolls back the most recent migration", func(t *testing.T) {
+		t.Parallel()
+
+		db := createTestDatabase(t)
+		defer db.Close()
+
+		migrator := NewMigrator(db.DB())
+		migrator.files = pairedFiles()
+		ctx := context.Background()
+
+		err := migrator.Migrate(ctx)
+		require.NoError(t, err)
+		assert.True(t, tableExists(t, db, "gadgets"))
+
+		err = migrator.Rollback(ctx, 1)
+		require.NoError(t, err)
+
+		assert.False(t, tableExists(t, db, "gadgets"), "latest migration should be reverted")
+		assert.True(t, tableExists(t, db, "widgets"), "earlier migration should be kept")
+
+		var count int
+		err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&count)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		// Re-applying after a rollback restores the schema
+		err = migrator.Migrate(ctx)
+		require.NoError(t, err)
+		assert.True(t, tableExists(t, db, "gadgets"))
+	})
+
+	t.Run("rolls back multiple steps in reverse order", func(t *testing.T) {
+		t.Parallel()
+
+		db := createTestDatabase(t)
+		defer db.Close()
+
+		migrator := NewMigrator(db.DB())
+		migrator.files = pairedFiles()
+		ctx := context.Background()
+
+		require.NoError(t, migrator.Migrate(ctx))
+		require.NoError(t, migrator.Rollback(ctx, 2))
+
+		assert.False(t, tableExists(t, db, "gadgets"))
+		assert.False(t, tableExists(t, db, "widgets"))
+	})
+
+	t.Run("refuses to roll back legacy single-file migrations", func(t *testing.T) {
+		t.Parallel()
+
+		db := createTestDatabase(t)
+		defer db.Close()
+
+		migrator := NewMigrator(db.DB())
+		migrator.files = fstest.MapFS{
+			"migrations/001_create_widgets.sql": {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);")},
+		}
+		ctx := context.Background()
+
+		require.NoError(t, migrator.Migrate(ctx))
+
+		err := migrator.Rollback(ctx, 1)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "no down migration")
+		assert.True(t, tableExists(t, db, "widgets"))
+	})
+
+	t.Run("rejects non-positive steps", func(t *testing.T) {
+		t.Parallel()
+
+		db := createTestDatabase(t)
+		defer db.Close()
+
+		migrator := NewMigrator(db.DB())
+
+		err := migrator.Rollback(context.Background(), 0)
+		assert.Error(t, err)
 	})
 }
 
@@ -3,66 +3,317 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
 	"paymentprocessor/internal/domain/payment"
 	"paymentprocessor/internal/domain/shared"
 )
 
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+
+	// maxIDsPerQuery keeps the IN lists of FindByIDs well below SQLite's
+	// bound parameter limit.
+	maxIDsPerQuery = 500
+)
+
 type PaymentRepository struct {
-	db Database
+	db             Querier
+	tx             *sql.Tx
+	clock          shared.Clock
+	retry          RetryPolicy
+	tracer         trace.Tracer
+	includeDeleted bool
+}
+
+// NewPaymentRepository returns a repository issuing its statements through q,
+// usually a *Database. Over a *sql.Tx the repository joins that transaction:
+// its writes commit or roll back with it and are not retried on their own.
+func NewPaymentRepository(q Querier, clock shared.Clock) PaymentRepository {
+	r := PaymentRepository{
+		db:     q,
+		clock:  clock,
+		retry:  DefaultRetryPolicy(),
+		tracer: noop.NewTracerProvider().Tracer(""),
+	}
+	if tx, ok := q.(*sql.Tx); ok {
+		r.tx = tx
+	}
+	return r
+}
+
+// WithTracer returns a copy of the repository that records a span per
+// operation with tracer. Repositories handed out by WithTransaction inherit it.
+func (r PaymentRepository) WithTracer(tracer trace.Tracer) PaymentRepository {
+	r.tracer = tracer
+	return r
+}
+
+// IncludeDeleted returns a copy of the repository whose lookups, listings and
+// counts also take soft-deleted payments into account.
+func (r PaymentRepository) IncludeDeleted() PaymentRepository {
+	r.includeDeleted = true
+	return r
+}
+
+// visibleFilter restricts a query to payments that are not soft-deleted,
+// joined to the query with keyword ("WHERE" or "AND").
+func (r PaymentRepository) visibleFilter(keyword string) string {
+	if r.includeDeleted {
+		return ""
+	}
+	return keyword + " deleted_at IS NULL"
+}
+
+// Querier runs statements with the signatures of database/sql. *Database,
+// *sql.DB and *sql.Tx satisfy it.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// txBeginner is implemented by queriers that can start transactions, such as
+// *Database and *sql.DB.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// streamer is implemented by queriers that can run a query without their
+// query timeout, such as *Database.
+type streamer interface {
+	StreamContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// querier returns the transaction the repository is bound to, if any, so that
+// repositories handed out by WithTransaction run inside it.
+func (r PaymentRepository) querier() Querier {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// stream runs a query whose rows are handed out one at a time, so unlike
+// querier it is not bounded by the query timeout.
+func (r PaymentRepository) stream(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if r.tx != nil {
+		return r.tx.QueryContext(ctx, query, args...)
+	}
+	if s, ok := r.db.(streamer); ok {
+		return s.StreamContext(ctx, query, args...)
+	}
+	return r.db.QueryContext(ctx, query, args...)
+}
+
+// beginTx starts a transaction on the repository's querier.
+func (r PaymentRepository) beginTx(ctx context.Context) (*sql.Tx, error) {
+	b, ok := r.db.(txBeginner)
+	if !ok {
+		return nil, fmt.Errorf("failed to begin transaction: %T cannot start transactions", r.db)
+	}
+
+	tx, err := b.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// withRetry retries fn on transient busy and locked errors. Inside a
+// transaction fn runs once: the transaction as a whole has to be retried by
+// its owner.
+func (r PaymentRepository) withRetry(ctx context.Context, fn func() error) error {
+	if r.tx != nil {
+		return fn()
+	}
+	return withRetry(ctx, r.retry, fn)
+}
+
+// inTransaction runs fn in the transaction the repository is bound to or, if
+// there is none, in a new one that is retried as a whole while the database is
+// busy.
+func (r PaymentRepository) inTransaction(ctx context.Context, fn func(q Querier) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+
+	return withRetry(ctx, r.retry, func() error {
+		tx, err := r.beginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+
+		return nil
+	})
 }
 
-func NewPaymentRepository(db Database) PaymentRepository {
-	return PaymentRepository{db: db}
+// WithTransaction runs fn inside a single transaction with a repository bound
+// to it. The DSN sets _txlock=immediate, so the write lock is taken up front and
+// concurrent read-modify-write sequences are serialized. The transaction is
+// committed when fn returns nil and rolled back otherwise. A repository already
+// bound to a transaction runs fn in it and leaves ending it to its owner.
+func (r PaymentRepository) WithTransaction(ctx context.Context, fn func(repo payment.Repository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	txRepo := r
+	txRepo.tx = tx
+	if err := fn(txRepo); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
 }
 
-func (r PaymentRepository) Save(ctx context.Context, p payment.Payment) error {
+// Save inserts a new payment, retrying while the database is busy.
+func (r PaymentRepository) Save(ctx context.Context, p payment.Payment) (err error) {
+	ctx, span := r.startSpan(ctx, "Save",
+		attribute.String("payment.id", p.ID()),
+		attribute.String("payment.idempotency_key", p.IdempotencyKey().Value()))
+	defer func() { endSpan(span, err) }()
+
+	err = r.withRetry(ctx, func() error {
+		return insertPayment(ctx, r.querier(), p)
+	})
+	if err != nil {
+		if duplicateErr := uniqueConstraintError(err); duplicateErr != nil {
+			return duplicateErr
+		}
+		return fmt.Errorf("failed to save payment: %w", err)
+	}
+
+	return nil
+}
+
+// SaveBatch inserts all payments in a single transaction. If any insert fails
+// the whole batch is rolled back and the error reports the offending index.
+// Cancelling ctx stops the batch before the next insert.
+func (r PaymentRepository) SaveBatch(ctx context.Context, payments []payment.Payment) (err error) {
+	ctx, span := r.startSpan(ctx, "SaveBatch", attribute.Int("payment.count", len(payments)))
+	defer func() { endSpan(span, err) }()
+
+	if r.tx != nil {
+		return insertPayments(ctx, r.tx, payments)
+	}
 
+	tx, err := r.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertPayments(ctx, tx, payments); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit payment batch: %w", err)
+	}
+
+	return nil
+}
+
+func insertPayments(ctx context.Context, db Querier, payments []payment.Payment) error {
+	for i, p := range payments {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("payment batch aborted at index %d: %w", i, err)
+		}
+		if err := insertPayment(ctx, db, p); err != nil {
+			if duplicateErr := uniqueConstraintError(err); duplicateErr != nil {
+				return fmt.Errorf("%w: payment at index %d", duplicateErr, i)
+			}
+			return fmt.Errorf("failed to save payment at index %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func insertPayment(ctx context.Context, db Querier, p payment.Payment) error {
 	query := `
 		INSERT INTO payments (
 			id, debtor_iban, debtor_name, creditor_iban, creditor_name,
-			amount_cents, currency, idempotency_key, status, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	metadata := p.Metadata()
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	encodedMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, query,
 		p.ID(),
-		p.DebtorIBAN().Value(),
+		p.DebtorIBAN(),
 		p.DebtorName(),
-		p.CreditorIBAN().Value(),
+		p.CreditorIBAN(),
 		p.CreditorName(),
 		p.Amount().Cents(),
-		"EUR",
+		p.Amount().Currency().Code(),
 		p.IdempotencyKey().Value(),
+		p.Reference(),
+		nullableTime(p.ExecutionDate()),
+		string(encodedMetadata),
+		nullableString(p.ReversalOf()),
 		string(p.Status()),
-		p.CreatedAt(),
-		p.UpdatedAt(),
+		p.Version(),
+		formatTimestamp(p.CreatedAt()),
+		formatTimestamp(p.UpdatedAt()),
 	)
 
-	if err != nil {
-		if isUniqueConstraintError(err) {
-			return shared.ErrDuplicateIdempotencyKey
-		}
-		return fmt.Errorf("failed to save payment: %w", err)
-	}
-
-	return nil
+	return err
 }
 
-func (r PaymentRepository) FindByID(ctx context.Context, id string) (payment.Payment, error) {
+func (r PaymentRepository) FindByID(ctx context.Context, id string) (_ payment.Payment, err error) {
+	ctx, span := r.startSpan(ctx, "FindByID", attribute.String("payment.id", id))
+	defer func() { endSpan(span, err) }()
+
 	query := `
 		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
-			   amount_cents, idempotency_key, status, created_at, updated_at
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
 		FROM payments
-		WHERE id = ?
+		WHERE id = ? %s
 	`
 
-	row := r.db.QueryRowContext(ctx, query, id)
+	row := r.querier().QueryRowContext(ctx, fmt.Sprintf(query, r.visibleFilter("AND")), id)
 
-	p, err := r.scanPayment(row)
+	p, err := scanPayment(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return payment.Payment{}, shared.ErrPaymentNotFound
@@ -73,17 +324,76 @@ func (r PaymentRepository) FindByID(ctx context.Context, id string) (payment.Pay
 	return p, nil
 }
 
-func (r PaymentRepository) FindByIdempotencyKey(ctx context.Context, key shared.IdempotencyKey) (payment.Payment, error) {
+// FindByIDs loads the payments with the given ids, querying at most
+// maxIDsPerQuery ids at a time. Ids that are unknown or, unless the repository
+// includes deleted payments, soft-deleted are left out of the map.
+func (r PaymentRepository) FindByIDs(ctx context.Context, ids []string) (_ map[string]payment.Payment, err error) {
+	ctx, span := r.startSpan(ctx, "FindByIDs", attribute.Int("payment.count", len(ids)))
+	defer func() { endSpan(span, err) }()
+
+	found := make(map[string]payment.Payment, len(ids))
+	for start := 0; start < len(ids); start += maxIDsPerQuery {
+		chunk := ids[start:min(start+maxIDsPerQuery, len(ids))]
+
+		query := fmt.Sprintf(`
+			SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+				   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+			FROM payments
+			WHERE id IN (%s) %s
+		`, strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", "), r.visibleFilter("AND"))
+
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+
+		payments, err := r.queryPayments(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find payments by IDs: %w", err)
+		}
+		for _, p := range payments {
+			found[p.ID()] = p
+		}
+	}
+
+	return found, nil
+}
+
+// Exists reports whether a payment with id is visible to FindByID, so
+// soft-deleted payments only count on an IncludeDeleted repository.
+func (r PaymentRepository) Exists(ctx context.Context, id string) (_ bool, err error) {
+	ctx, span := r.startSpan(ctx, "Exists", attribute.String("payment.id", id))
+	defer func() { endSpan(span, err) }()
+
+	query := `SELECT EXISTS(SELECT 1 FROM payments WHERE id = ? %s)`
+
+	var exists bool
+	if err := r.querier().QueryRowContext(ctx, fmt.Sprintf(query, r.visibleFilter("AND")), id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check payment existence: %w", err)
+	}
+
+	return exists, nil
+}
+
+// FindByIdempotencyKey returns the payment holding key. On an IncludeDeleted
+// repository several payments may have held it; the live one wins, then the
+// most recently created.
+func (r PaymentRepository) FindByIdempotencyKey(ctx context.Context, key shared.IdempotencyKey) (_ payment.Payment, err error) {
+	ctx, span := r.startSpan(ctx, "FindByIdempotencyKey", attribute.String("payment.idempotency_key", key.Value()))
+	defer func() { endSpan(span, err) }()
+
 	query := `
 		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
-			   amount_cents, idempotency_key, status, created_at, updated_at
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
 		FROM payments
-		WHERE idempotency_key = ?
+		WHERE idempotency_key = ? %s
+		ORDER BY deleted_at IS NOT NULL, created_at DESC
+		LIMIT 1
 	`
 
-	row := r.db.QueryRowContext(ctx, query, key.Value())
+	row := r.querier().QueryRowContext(ctx, fmt.Sprintf(query, r.visibleFilter("AND")), key.Value())
 
-	p, err := r.scanPayment(row)
+	p, err := scanPayment(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return payment.Payment{}, shared.ErrPaymentNotFound
@@ -94,16 +404,476 @@ func (r PaymentRepository) FindByIdempotencyKey(ctx context.Context, key shared.
 	return p, nil
 }
 
-func (r PaymentRepository) UpdateStatus(ctx context.Context, id string, status payment.PaymentStatus) error {
+func (r PaymentRepository) List(ctx context.Context, offset, limit int) (_ []payment.Payment, err error) {
+	ctx, span := r.startSpan(ctx, "List")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		FROM payments
+		%s
+		ORDER BY created_at DESC, id
+		LIMIT ? OFFSET ?
+	`
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	payments, err := r.queryPayments(ctx, fmt.Sprintf(query, r.visibleFilter("WHERE")), boundLimit(limit), offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+// Iterate streams the payments matching filter, oldest first, to fn one row at
+// a time. It is bounded by ctx alone, not by the query timeout, so that long
+// exports can run to completion. An in-memory database has a single
+// connection, which the open rows hold until Iterate returns.
+func (r PaymentRepository) Iterate(ctx context.Context, filter payment.Filter, fn func(payment.Payment) error) (err error) {
+	ctx, span := r.startSpan(ctx, "Iterate")
+	defer func() { endSpan(span, err) }()
+
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, formatTimestamp(filter.From))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, formatTimestamp(filter.To))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, string(filter.Status))
+	}
+	if !r.includeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := `
+		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		FROM payments
+		%s
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.stream(ctx, fmt.Sprintf(query, where), args...)
+	if err != nil {
+		return fmt.Errorf("failed to iterate payments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("failed to iterate payments: %w", err)
+		}
+
+		p, err := scanPayment(rows)
+		if err != nil {
+			return fmt.Errorf("failed to iterate payments: %w", err)
+		}
+
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate payments: %w", err)
+	}
+
+	return nil
+}
+
+// ListAfter pages through payments oldest first using the (created_at, id) of
+// the last payment seen as a cursor, which unlike an OFFSET costs the same on
+// every page. A zero afterCreatedAt starts from the beginning.
+func (r PaymentRepository) ListAfter(ctx context.Context, afterCreatedAt time.Time, afterID string, limit int) (_ []payment.Payment, err error) {
+	ctx, span := r.startSpan(ctx, "ListAfter")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		FROM payments
+		WHERE (created_at, id) > (?, ?) %s
+		ORDER BY created_at, id
+		LIMIT ?
+	`
+
+	payments, err := r.queryPayments(ctx, fmt.Sprintf(query, r.visibleFilter("AND")), formatTimestamp(afterCreatedAt), afterID, boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments after cursor: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r PaymentRepository) FindByStatus(ctx context.Context, status payment.PaymentStatus, limit int) (_ []payment.Payment, err error) {
+	ctx, span := r.startSpan(ctx, "FindByStatus", attribute.String("payment.status", string(status)))
+	defer func() { endSpan(span, err) }()
+
+	if !status.IsValid() {
+		return nil, shared.ErrInvalidPaymentStatus
+	}
+
+	query := `
+		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		FROM payments
+		WHERE status = ? %s
+		ORDER BY created_at, id
+		LIMIT ?
+	`
+
+	payments, err := r.queryPayments(ctx, fmt.Sprintf(query, r.visibleFilter("AND")), string(status), boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments by status: %w", err)
+	}
+
+	return payments, nil
+}
+
+// FindByDebtorIBANAndStatus returns a debtor's payments in the given status,
+// oldest first. The zero IBAN is rejected with shared.ErrInvalidIBAN.
+func (r PaymentRepository) FindByDebtorIBANAndStatus(ctx context.Context, iban shared.IBAN, status payment.PaymentStatus, limit int) (_ []payment.Payment, err error) {
+	ctx, span := r.startSpan(ctx, "FindByDebtorIBANAndStatus", attribute.String("payment.status", string(status)))
+	defer func() { endSpan(span, err) }()
+
+	if iban.String() == "" {
+		return nil, shared.ErrInvalidIBAN
+	}
+	if !status.IsValid() {
+		return nil, shared.ErrInvalidPaymentStatus
+	}
+
+	query := `
+		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		FROM payments
+		WHERE debtor_iban = ? AND status = ? %s
+		ORDER BY created_at, id
+		LIMIT ?
+	`
+
+	payments, err := r.queryPayments(ctx, fmt.Sprintf(query, r.visibleFilter("AND")), iban.String(), string(status), boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments by debtor IBAN and status: %w", err)
+	}
+
+	return payments, nil
+}
+
+// FindByDateRange returns payments created in [from, to), oldest first.
+func (r PaymentRepository) FindByDateRange(ctx context.Context, from, to time.Time, limit int) (_ []payment.Payment, err error) {
+	ctx, span := r.startSpan(ctx, "FindByDateRange")
+	defer func() { endSpan(span, err) }()
+
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: to (%s) must be after from (%s)", shared.ErrInvalidDateRange, to, from)
+	}
+
+	query := `
+		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		FROM payments
+		WHERE created_at >= ? AND created_at < ? %s
+		ORDER BY created_at, id
+		LIMIT ?
+	`
+
+	payments, err := r.queryPayments(ctx, fmt.Sprintf(query, r.visibleFilter("AND")), formatTimestamp(from), formatTimestamp(to), boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments by date range: %w", err)
+	}
+
+	return payments, nil
+}
+
+// FindByAmountRange returns payments whose amount in cents lies in
+// [minCents, maxCents] and whose currency is currency, ordered by amount and
+// then id. A negative maxCents means no upper bound.
+func (r PaymentRepository) FindByAmountRange(ctx context.Context, currency shared.Currency, minCents, maxCents int64, limit int) (_ []payment.Payment, err error) {
+	ctx, span := r.startSpan(ctx, "FindByAmountRange", attribute.String("payment.currency", currency.Code()), attribute.Int64("payment.min_cents", minCents), attribute.Int64("payment.max_cents", maxCents))
+	defer func() { endSpan(span, err) }()
+
+	if err := validateAmountRange(currency, minCents, maxCents); err != nil {
+		return nil, err
+	}
+	if maxCents < 0 {
+		maxCents = shared.MaxAmount
+	}
+
+	query := `
+		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		FROM payments
+		WHERE currency = ? AND amount_cents BETWEEN ? AND ? %s
+		ORDER BY amount_cents, id
+		LIMIT ?
+	`
+
+	payments, err := r.queryPayments(ctx, fmt.Sprintf(query, r.visibleFilter("AND")), currency.Code(), minCents, maxCents, boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments by amount range: %w", err)
+	}
+
+	return payments, nil
+}
+
+// FindDueForExecution returns up to limit pending payments whose execution
+// date is at or before asOf, including those without an execution date,
+// earliest first. The limit is bounded like List's.
+func (r PaymentRepository) FindDueForExecution(ctx context.Context, asOf time.Time, limit int) (_ []payment.Payment, err error) {
+	ctx, span := r.startSpan(ctx, "FindDueForExecution")
+	defer func() { endSpan(span, err) }()
+
 	query := `
+		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		FROM payments
+		WHERE status = ? AND (execution_date IS NULL OR execution_date <= ?) %s
+		ORDER BY COALESCE(execution_date, created_at), id
+		LIMIT ?
+	`
+
+	payments, err := r.queryPayments(ctx, fmt.Sprintf(query, r.visibleFilter("AND")), string(payment.StatusPending), formatTimestamp(asOf), boundLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments due for execution: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r PaymentRepository) Count(ctx context.Context) (_ int, err error) {
+	ctx, span := r.startSpan(ctx, "Count")
+	defer func() { endSpan(span, err) }()
+
+	var count int
+	if err := r.querier().QueryRowContext(ctx, "SELECT COUNT(*) FROM payments "+r.visibleFilter("WHERE")).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count payments: %w", err)
+	}
+
+	return count, nil
+}
+
+func (r PaymentRepository) CountByStatus(ctx context.Context, status payment.PaymentStatus) (_ int, err error) {
+	ctx, span := r.startSpan(ctx, "CountByStatus", attribute.String("payment.status", string(status)))
+	defer func() { endSpan(span, err) }()
+
+	if !status.IsValid() {
+		return 0, shared.ErrInvalidPaymentStatus
+	}
+
+	var count int
+	err = r.querier().QueryRowContext(ctx, "SELECT COUNT(*) FROM payments WHERE status = ? "+r.visibleFilter("AND"), string(status)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count payments by status: %w", err)
+	}
+
+	return count, nil
+}
+
+// UpdateStatus changes the status only if the stored version still matches
+// expectedVersion, bumping the version on success. A stale version yields
+// shared.ErrConcurrentModification and an unknown or soft-deleted payment
+// shared.ErrPaymentNotFound. updated_at and the history entry are stamped
+// with updatedAt. The change is appended to the status history, attributed to
+// payment.ActorFromContext(ctx), in the same transaction as the update. Outside
+// a bound transaction the update runs in its own, retried while the database is
+// busy.
+//
+// The status is written as given without applying the domain transition rules;
+// application code should go through PaymentService.ProcessStatusUpdate, which
+// loads the payment and only persists legal transitions.
+func (r PaymentRepository) UpdateStatus(ctx context.Context, id string, status payment.PaymentStatus, expectedVersion int, updatedAt time.Time) (err error) {
+	ctx, span := r.startSpan(ctx, "UpdateStatus", attribute.String("payment.id", id), attribute.String("payment.status", string(status)))
+	defer func() { endSpan(span, err) }()
+
+	// The history row is written first, from the row as it is before the
+	// update, and only when the version still matches.
+	recordQuery := `
+		INSERT INTO payment_status_history (payment_id, from_status, to_status, changed_at, actor)
+		SELECT id, status, ?, ?, ? FROM payments
+		WHERE id = ? AND version = ? AND deleted_at IS NULL
+	`
+	updateQuery := `
 		UPDATE payments 
-		SET status = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ?
+		SET status = ?, version = version + 1, updated_at = ?
+		WHERE id = ? AND version = ? AND deleted_at IS NULL
+	`
+
+	changedAt := formatTimestamp(updatedAt)
+	actor := payment.ActorFromContext(ctx)
+	return r.inTransaction(ctx, func(q Querier) error {
+		result, err := q.ExecContext(ctx, recordQuery, string(status), changedAt, actor, id, expectedVersion)
+		if err != nil {
+			return fmt.Errorf("failed to record payment status change: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			return missingOrStale(ctx, q, id)
+		}
+
+		if _, err := q.ExecContext(ctx, updateQuery, string(status), changedAt, id, expectedVersion); err != nil {
+			return fmt.Errorf("failed to update payment status: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// UpdateStatusBatch moves every listed payment whose current status may
+// legally transition to status, bumping versions and recording each change in
+// the status history, all in one transaction. Ids are updated at most
+// maxIDsPerQuery at a time. Unknown, soft-deleted and ineligible payments are
+// skipped, so the returned count may be lower than len(ids).
+func (r PaymentRepository) UpdateStatusBatch(ctx context.Context, ids []string, status payment.PaymentStatus) (updated int, err error) {
+	ctx, span := r.startSpan(ctx, "UpdateStatusBatch", attribute.Int("payment.count", len(ids)), attribute.String("payment.status", string(status)))
+	defer func() { endSpan(span, err) }()
+
+	if !status.IsValid() {
+		return 0, shared.ErrInvalidPaymentStatus
+	}
+	sources := payment.StatusesLeadingTo(status)
+	if len(ids) == 0 || len(sources) == 0 {
+		return 0, nil
+	}
+
+	sourceArgs := make([]interface{}, len(sources))
+	for i, source := range sources {
+		sourceArgs[i] = string(source)
+	}
+	sourcePlaceholders := strings.TrimSuffix(strings.Repeat("?, ", len(sources)), ", ")
+
+	changedAt := formatTimestamp(r.clock.Now())
+	actor := payment.ActorFromContext(ctx)
+
+	err = r.inTransaction(ctx, func(q Querier) error {
+		updated = 0
+		for start := 0; start < len(ids); start += maxIDsPerQuery {
+			chunk := ids[start:min(start+maxIDsPerQuery, len(ids))]
+
+			condition := fmt.Sprintf("id IN (%s) AND status IN (%s) AND deleted_at IS NULL",
+				strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", "), sourcePlaceholders)
+			conditionArgs := make([]interface{}, 0, len(chunk)+len(sources))
+			for _, id := range chunk {
+				conditionArgs = append(conditionArgs, id)
+			}
+			conditionArgs = append(conditionArgs, sourceArgs...)
+
+			recordQuery := `
+				INSERT INTO payment_status_history (payment_id, from_status, to_status, changed_at, actor)
+				SELECT id, status, ?, ?, ? FROM payments
+				WHERE ` + condition
+			recordArgs := append([]interface{}{string(status), changedAt, actor}, conditionArgs...)
+			if _, err := q.ExecContext(ctx, recordQuery, recordArgs...); err != nil {
+				return fmt.Errorf("failed to record payment status changes: %w", err)
+			}
+
+			updateQuery := `
+				UPDATE payments
+				SET status = ?, version = version + 1, updated_at = ?
+				WHERE ` + condition
+			updateArgs := append([]interface{}{string(status), changedAt}, conditionArgs...)
+			result, err := q.ExecContext(ctx, updateQuery, updateArgs...)
+			if err != nil {
+				return fmt.Errorf("failed to update payment statuses: %w", err)
+			}
+
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to get rows affected: %w", err)
+			}
+			updated += int(rowsAffected)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return updated, nil
+}
+
+// FindStatusHistory returns the status changes recorded for a payment, oldest
+// first. An unknown id yields an empty history rather than an error.
+func (r PaymentRepository) FindStatusHistory(ctx context.Context, id string) (_ []payment.StatusChange, err error) {
+	ctx, span := r.startSpan(ctx, "FindStatusHistory", attribute.String("payment.id", id))
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		SELECT payment_id, from_status, to_status, changed_at, actor
+		FROM payment_status_history
+		WHERE payment_id = ?
+		ORDER BY changed_at, id
 	`
 
-	result, err := r.db.ExecContext(ctx, query, string(status), id)
+	rows, err := r.querier().QueryContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("failed to update payment status: %w", err)
+		return nil, fmt.Errorf("failed to query payment status history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []payment.StatusChange
+	for rows.Next() {
+		var (
+			change   payment.StatusChange
+			from, to string
+		)
+		if err := rows.Scan(&change.PaymentID, &from, &to, &change.ChangedAt, &change.Actor); err != nil {
+			return nil, fmt.Errorf("failed to scan payment status change: %w", err)
+		}
+		change.From = payment.PaymentStatus(from)
+		change.To = payment.PaymentStatus(to)
+		change.ChangedAt = change.ChangedAt.UTC()
+		history = append(history, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate payment status history: %w", err)
+	}
+
+	return history, nil
+}
+
+// SoftDelete hides a payment from default queries by stamping deleted_at from
+// the repository clock. The row itself is retained.
+func (r PaymentRepository) SoftDelete(ctx context.Context, id string) (err error) {
+	ctx, span := r.startSpan(ctx, "SoftDelete", attribute.String("payment.id", id))
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		UPDATE payments
+		SET deleted_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.querier().ExecContext(ctx, query, formatTimestamp(r.clock.Now()), id)
+	if err != nil {
+		return fmt.Errorf("failed to soft-delete payment: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -112,45 +882,119 @@ func (r PaymentRepository) UpdateStatus(ctx context.Context, id string, status p
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("payment with ID %s not found", id)
+		return shared.ErrPaymentNotFound
 	}
 
 	return nil
 }
 
-func (r PaymentRepository) scanPayment(row *sql.Row) (payment.Payment, error) {
+// HealthCheck runs a trivial query against the payments table, so that it
+// fails when the database is closed, unreachable or not migrated.
+func (r PaymentRepository) HealthCheck(ctx context.Context) (err error) {
+	ctx, span := r.startSpan(ctx, "HealthCheck")
+	defer func() { endSpan(span, err) }()
+
+	var one int
+	err = r.querier().QueryRowContext(ctx, `SELECT 1 FROM payments LIMIT 1`).Scan(&one)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("payments health check failed: %w", err)
+	}
+
+	return nil
+}
+
+func (r PaymentRepository) startSpan(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("db.system", "sqlite"))
+	return r.tracer.Start(ctx, "PaymentRepository."+operation, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, marking it as failed when err is set. Lookups that match
+// nothing are an expected outcome and are not treated as failures.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, shared.ErrPaymentNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// missingOrStale explains why a versioned update matched no rows. Soft-deleted
+// payments count as missing.
+func missingOrStale(ctx context.Context, q Querier, id string) error {
+	var version int
+	err := q.QueryRowContext(ctx, `SELECT version FROM payments WHERE id = ? AND deleted_at IS NULL`, id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", shared.ErrPaymentNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check payment version: %w", err)
+	}
+
+	return shared.ErrConcurrentModification
+}
+
+// queryPayments scans every row query returns, giving up between rows once ctx
+// is done rather than reading the rest of a large result.
+func (r PaymentRepository) queryPayments(ctx context.Context, query string, args ...interface{}) ([]payment.Payment, error) {
+	rows, err := r.querier().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []payment.Payment{}
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+
+	return payments, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPayment(row rowScanner) (payment.Payment, error) {
 	var (
 		id             string
-		debtorIBAN     string
+		debtorIBAN     shared.IBAN
 		debtorName     string
-		creditorIBAN   string
+		creditorIBAN   shared.IBAN
 		creditorName   string
 		amountCents    int64
+		currency       string
 		idempotencyKey string
+		reference      string
+		executionDate  sql.NullTime
+		metadataJSON   string
+		reversalOf     sql.NullString
 		status         string
+		version        int
 		createdAt      time.Time
 		updatedAt      time.Time
 	)
 
 	err := row.Scan(
 		&id, &debtorIBAN, &debtorName, &creditorIBAN, &creditorName,
-		&amountCents, &idempotencyKey, &status, &createdAt, &updatedAt,
+		&amountCents, &currency, &idempotencyKey, &reference, &executionDate, &metadataJSON, &reversalOf, &status, &version, &createdAt, &updatedAt,
 	)
 	if err != nil {
 		return payment.Payment{}, err
 	}
 
-	debtorIBANObj, err := shared.NewIBAN(debtorIBAN)
-	if err != nil {
-		return payment.Payment{}, fmt.Errorf("invalid debtor IBAN in database: %w", err)
-	}
-
-	creditorIBANObj, err := shared.NewIBAN(creditorIBAN)
+	currencyObj, err := shared.NewCurrency(currency)
 	if err != nil {
-		return payment.Payment{}, fmt.Errorf("invalid creditor IBAN in database: %w", err)
+		return payment.Payment{}, fmt.Errorf("invalid currency in database: %w", err)
 	}
 
-	amount, err := shared.NewAmountFromCents(amountCents)
+	amount, err := shared.NewAmountFromCentsWithCurrency(amountCents, currencyObj)
 	if err != nil {
 		return payment.Payment{}, fmt.Errorf("invalid amount in database: %w", err)
 	}
@@ -160,39 +1004,119 @@ func (r PaymentRepository) scanPayment(row *sql.Row) (payment.Payment, error) {
 		return payment.Payment{}, fmt.Errorf("invalid idempotency key in database: %w", err)
 	}
 
-	p, err := payment.NewPayment(
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return payment.Payment{}, fmt.Errorf("invalid metadata in database: %w", err)
+	}
+
+	p, err := payment.ReconstitutePayment(
 		id,
-		debtorIBANObj,
+		debtorIBAN,
 		debtorName,
-		creditorIBANObj,
+		creditorIBAN,
 		creditorName,
 		amount,
 		idempotencyKeyObj,
-		createdAt,
-		updatedAt,
+		reference,
+		executionDate.Time.UTC(),
+		metadata,
+		reversalOf.String,
+		payment.PaymentStatus(status),
+		version,
+		createdAt.UTC(),
+		updatedAt.UTC(),
 	)
 	if err != nil {
-		return payment.Payment{}, fmt.Errorf("failed to create payment domain object: %w", err)
+		return payment.Payment{}, fmt.Errorf("failed to reconstitute payment domain object: %w", err)
 	}
 
-	switch payment.PaymentStatus(status) {
-	case payment.StatusProcessed:
-		if err := p.MarkAsProcessed(updatedAt); err != nil {
-			return payment.Payment{}, fmt.Errorf("failed to set payment status to processed: %w", err)
-		}
-	case payment.StatusFailed:
-		if err := p.MarkAsFailed(updatedAt); err != nil {
-			return payment.Payment{}, fmt.Errorf("failed to set payment status to failed: %w", err)
+	return p, nil
+}
+
+// timestampLayout is RFC 3339 in UTC with fixed millisecond precision, the same
+// shape strftime('%Y-%m-%dT%H:%M:%fZ') produces, so stored timestamps compare
+// correctly as text regardless of the writer's time zone.
+const timestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// nullableTime stores the zero time as NULL and everything else as a UTC timestamp.
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return formatTimestamp(t)
+}
+
+// nullableString stores the empty string as NULL, e.g. for optional foreign keys.
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// validateAmountRange rejects a negative minimum and, unless the range is
+// open-ended, a minimum above the maximum.
+func validateAmountRange(currency shared.Currency, minCents, maxCents int64) error {
+	if currency.Code() == "" {
+		return fmt.Errorf("%w: a currency is required", shared.ErrInvalidCurrency)
+	}
+	if minCents < 0 {
+		return fmt.Errorf("%w: min (%d) must not be negative", shared.ErrInvalidAmountRange, minCents)
+	}
+	if maxCents >= 0 && minCents > maxCents {
+		return fmt.Errorf("%w: min (%d) must not exceed max (%d)", shared.ErrInvalidAmountRange, minCents, maxCents)
+	}
+	return nil
+}
+
+// boundLimit applies the default page size to non-positive limits and caps
+// larger ones so a single query cannot scan the whole table.
+func boundLimit(limit int) int {
+	if limit <= 0 {
+		return defaultListLimit
+	}
+	if limit > maxListLimit {
+		return maxListLimit
+	}
+	return limit
+}
+
+// uniqueConstraintError maps a SQLite unique or primary key violation on the
+// payments table to the matching domain error, or returns nil for any other error.
+func uniqueConstraintError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+
+	if sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique &&
+		sqliteErr.ExtendedCode != sqlite3.ErrConstraintPrimaryKey {
+		return nil
+	}
+
+	for _, column := range constraintColumns(sqliteErr) {
+		switch column {
+		case "payments.idempotency_key":
+			return shared.ErrDuplicateIdempotencyKey
+		case "payments.id":
+			return shared.ErrDuplicatePaymentID
 		}
-	case payment.StatusPending:
-	default:
-		return payment.Payment{}, fmt.Errorf("unknown payment status: %s", status)
 	}
 
-	return p, nil
+	return nil
 }
 
-func isUniqueConstraintError(err error) bool {
-	return err != nil && (fmt.Sprintf("%v", err) == "UNIQUE constraint failed: payments.idempotency_key" ||
-		fmt.Sprintf("%v", err) == "UNIQUE constraint failed: payments.id")
+// constraintColumns extracts the "table.column" list SQLite appends to
+// constraint failure messages, e.g. "UNIQUE constraint failed: payments.id".
+func constraintColumns(err sqlite3.Error) []string {
+	_, columns, found := strings.Cut(err.Error(), ": ")
+	if !found {
+		return nil
+	}
+
+	return strings.Split(columns, ", ")
 }
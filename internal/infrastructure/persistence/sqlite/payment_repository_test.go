@@ -2,8 +2,13 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -12,6 +17,14 @@ import (
 
 	"paymentprocessor/internal/domain/payment"
 	"paymentprocessor/internal/domain/shared"
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+var (
+	_ payment.Repository = PaymentRepository{}
+	_ payment.UnitOfWork = PaymentRepository{}
+	_ Querier            = (*Database)(nil)
+	_ Querier            = (*sql.Tx)(nil)
 )
 
 func TestPaymentRepository_Save(t *testing.T) {
@@ -24,7 +37,7 @@ func TestPaymentRepository_Save(t *testing.T) {
 		defer db.Close()
 
 		ctx := context.Background()
-		testPayment := createTestPayment(t)
+		testPayment := newTestPayment(t)
 
 		err := repo.Save(ctx, testPayment)
 		require.NoError(t, err)
@@ -43,8 +56,8 @@ func TestPaymentRepository_Save(t *testing.T) {
 		defer db.Close()
 
 		ctx := context.Background()
-		testPayment1 := createTestPayment(t)
-		testPayment2 := createTestPaymentWithIdempotencyKey(t, testPayment1.IdempotencyKey())
+		testPayment1 := newTestPayment(t)
+		testPayment2 := newTestPayment(t, withID("test_payment_duplicate"), withIdempotencyKey(testPayment1.IdempotencyKey()))
 
 		// Save first payment
 		err := repo.Save(ctx, testPayment1)
@@ -55,6 +68,220 @@ func TestPaymentRepository_Save(t *testing.T) {
 		assert.ErrorIs(t, err, shared.ErrDuplicateIdempotencyKey)
 	})
 
+	t.Run("reuses the idempotency key of a soft-deleted payment", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		deleted := newTestPayment(t)
+		reused := newTestPayment(t, withID("test_payment_duplicate"), withIdempotencyKey(deleted.IdempotencyKey()))
+
+		require.NoError(t, repo.Save(ctx, deleted))
+		require.NoError(t, repo.SoftDelete(ctx, deleted.ID()))
+
+		require.NoError(t, repo.Save(ctx, reused))
+
+		found, err := repo.FindByIdempotencyKey(ctx, reused.IdempotencyKey())
+		require.NoError(t, err)
+		assert.Equal(t, reused.ID(), found.ID())
+
+		// Both rows hold the key now; the live one wins.
+		found, err = repo.IncludeDeleted().FindByIdempotencyKey(ctx, reused.IdempotencyKey())
+		require.NoError(t, err)
+		assert.Equal(t, reused.ID(), found.ID())
+	})
+
+	t.Run("treats idempotency keys case-sensitively by default", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		lower, err := shared.NewIdempotencyKey("abc123XYZ0")
+		require.NoError(t, err)
+		upper, err := shared.NewIdempotencyKey("ABC123XYZ0")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.Save(ctx, newTestPayment(t, withID("test_payment_lower"), withIdempotencyKey(lower))))
+		assert.NoError(t, repo.Save(ctx, newTestPayment(t, withID("test_payment_upper"), withIdempotencyKey(upper))))
+	})
+
+	t.Run("detects duplicates among case-insensitive idempotency keys", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		lower, err := shared.NewIdempotencyKeyCaseInsensitive("abc123XYZ0")
+		require.NoError(t, err)
+		upper, err := shared.NewIdempotencyKeyCaseInsensitive("ABC123XYZ0")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.Save(ctx, newTestPayment(t, withID("test_payment_lower"), withIdempotencyKey(lower))))
+		err = repo.Save(ctx, newTestPayment(t, withID("test_payment_upper"), withIdempotencyKey(upper)))
+		assert.ErrorIs(t, err, shared.ErrDuplicateIdempotencyKey)
+
+		found, err := repo.FindByIdempotencyKey(ctx, upper)
+		require.NoError(t, err)
+		assert.Equal(t, "test_payment_lower", found.ID())
+	})
+
+	t.Run("returns distinct error for duplicate primary key", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+
+		err := repo.Save(ctx, testPayment)
+		require.NoError(t, err)
+
+		// Same ID but a different idempotency key
+		otherKey, err := shared.NewIdempotencyKey("otherKey01")
+		require.NoError(t, err)
+		duplicate, err := payment.NewPayment(
+			testPayment.ID(),
+			testPayment.DebtorIBAN(),
+			testPayment.DebtorName(),
+			testPayment.CreditorIBAN(),
+			testPayment.CreditorName(),
+			testPayment.Amount(),
+			otherKey,
+			"",
+			time.Time{},
+			nil,
+			testPayment.CreatedAt(),
+			testPayment.UpdatedAt(),
+		)
+		require.NoError(t, err)
+
+		err = repo.Save(ctx, duplicate)
+		assert.ErrorIs(t, err, shared.ErrDuplicatePaymentID)
+		assert.NotErrorIs(t, err, shared.ErrDuplicateIdempotencyKey)
+	})
+
+	t.Run("stores timestamps in UTC regardless of the writer's location", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		tokyo := time.FixedZone("UTC+9", 9*60*60)
+		createdAt := time.Date(2025, 3, 1, 8, 30, 0, 0, tokyo) // 2025-02-28T23:30:00Z
+		executionDate := createdAt.Add(48 * time.Hour)
+		testPayment := newTestPayment(t, withID("non_utc_payment"), withCreatedAt(createdAt), withExecutionDate(executionDate))
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		found, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, time.UTC, found.CreatedAt().Location(), "expected created_at to read back in UTC")
+		assert.Equal(t, time.UTC, found.UpdatedAt().Location(), "expected updated_at to read back in UTC")
+		assert.Equal(t, time.UTC, found.ExecutionDate().Location(), "expected execution_date to read back in UTC")
+		assert.True(t, found.CreatedAt().Equal(createdAt), "expected %v, got %v", createdAt, found.CreatedAt())
+		assert.True(t, found.ExecutionDate().Equal(executionDate), "expected %v, got %v", executionDate, found.ExecutionDate())
+		assert.True(t, found.Equals(testPayment), "expected payment to round-trip unchanged")
+
+		var stored string
+		err = db.QueryRowContext(ctx, "SELECT CAST(created_at AS TEXT) FROM payments WHERE id = ?", testPayment.ID()).Scan(&stored)
+		require.NoError(t, err)
+		assert.Equal(t, "2025-02-28T23:30:00.000Z", stored)
+
+		inFebruary, err := repo.FindByDateRange(ctx,
+			time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			10,
+		)
+		require.NoError(t, err)
+		assert.Len(t, inFebruary, 1, "expected the range query to compare UTC instants")
+	})
+}
+
+func TestPaymentRepository_SaveBatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("saves all payments in the batch", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		batch := []payment.Payment{
+			newTestPayment(t, withID("batch_payment_1")),
+			newTestPayment(t, withID("batch_payment_2")),
+			newTestPayment(t, withID("batch_payment_3")),
+		}
+
+		err := repo.SaveBatch(ctx, batch)
+		require.NoError(t, err)
+
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("rolls back the whole batch on a duplicate idempotency key", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		first := newTestPayment(t, withID("batch_payment_1"))
+		batch := []payment.Payment{
+			first,
+			newTestPayment(t, withID("batch_payment_2")),
+			newTestPayment(t, withID("test_payment_duplicate"), withIdempotencyKey(first.IdempotencyKey())),
+		}
+
+		err := repo.SaveBatch(ctx, batch)
+		assert.ErrorIs(t, err, shared.ErrDuplicateIdempotencyKey)
+		assert.Contains(t, err.Error(), "index 2")
+
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count, "no payment should persist when the batch fails")
+	})
+
+	t.Run("stops and rolls back when cancelled partway through", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		batch := make([]payment.Payment, 1000)
+		for i := range batch {
+			batch[i] = newTestPayment(t, withID(fmt.Sprintf("batch_payment_%d", i)))
+		}
+
+		err := repo.SaveBatch(newCancelAfterChecks(500), batch)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorContains(t, err, "payment batch aborted")
+
+		count, err := repo.Count(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, count, "no payment should persist when the batch is cancelled")
+	})
+
+	t.Run("fails once the deadline has passed", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		err := repo.SaveBatch(ctx, []payment.Payment{newTestPayment(t, withID("batch_payment_1"))})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
 }
 
 func TestPaymentRepository_FindByID(t *testing.T) {
@@ -67,7 +294,7 @@ func TestPaymentRepository_FindByID(t *testing.T) {
 		defer db.Close()
 
 		ctx := context.Background()
-		testPayment := createTestPayment(t)
+		testPayment := newTestPayment(t)
 
 		// Save payment first
 		err := repo.Save(ctx, testPayment)
@@ -80,13 +307,33 @@ func TestPaymentRepository_FindByID(t *testing.T) {
 
 		// Verify payment data
 		assert.Equal(t, testPayment.ID(), foundPayment.ID())
-		assert.Equal(t, testPayment.DebtorIBAN().Value(), foundPayment.DebtorIBAN().Value())
+		assert.Equal(t, testPayment.DebtorIBAN().String(), foundPayment.DebtorIBAN().String())
 		assert.Equal(t, testPayment.DebtorName(), foundPayment.DebtorName())
-		assert.Equal(t, testPayment.CreditorIBAN().Value(), foundPayment.CreditorIBAN().Value())
+		assert.Equal(t, testPayment.CreditorIBAN().String(), foundPayment.CreditorIBAN().String())
 		assert.Equal(t, testPayment.CreditorName(), foundPayment.CreditorName())
 		assert.Equal(t, testPayment.Amount().Cents(), foundPayment.Amount().Cents())
 		assert.Equal(t, testPayment.IdempotencyKey().Value(), foundPayment.IdempotencyKey().Value())
 		assert.Equal(t, testPayment.Status(), foundPayment.Status())
+		assert.True(t, testPayment.Equals(foundPayment), "round-tripped payment should equal the saved one")
+	})
+
+	t.Run("rejects legacy rows with identical debtor and creditor IBAN", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO payments (id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+				amount_cents, idempotency_key, status)
+			VALUES ('legacy_payment', 'DE89370400440532013000', 'John Doe', 'DE89370400440532013000', 'Jane Smith',
+				1000, 'legacyKey1', 'PENDING')
+		`)
+		require.NoError(t, err)
+
+		_, err = repo.FindByID(ctx, "legacy_payment")
+		assert.ErrorIs(t, err, shared.ErrSameDebtorCreditor)
 	})
 
 	t.Run("returns error for non-existent payment", func(t *testing.T) {
@@ -110,8 +357,9 @@ func TestPaymentRepository_FindByID(t *testing.T) {
 		ctx := context.Background()
 
 		// Test with processed payment
-		testPayment := createTestPayment(t)
-		err := testPayment.MarkAsProcessed(time.Now())
+		testPayment, err := newTestPayment(t).MarkAsProcessing(time.Now())
+		require.NoError(t, err)
+		testPayment, err = testPayment.MarkAsProcessed(time.Now())
 		require.NoError(t, err)
 
 		err = repo.Save(ctx, testPayment)
@@ -122,6 +370,241 @@ func TestPaymentRepository_FindByID(t *testing.T) {
 		require.NotNil(t, foundPayment)
 		assert.Equal(t, payment.StatusProcessed, foundPayment.Status())
 	})
+
+	t.Run("finds cancelled payment", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment, err := newTestPayment(t).Cancel(time.Now())
+		require.NoError(t, err)
+
+		err = repo.Save(ctx, testPayment)
+		require.NoError(t, err)
+
+		foundPayment, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusCancelled, foundPayment.Status())
+	})
+
+	t.Run("restores persisted reference", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+
+		base := newTestPayment(t)
+		testPayment, err := payment.NewPayment(
+			base.ID(),
+			base.DebtorIBAN(),
+			base.DebtorName(),
+			base.CreditorIBAN(),
+			base.CreditorName(),
+			base.Amount(),
+			base.IdempotencyKey(),
+			"Invoice 2025-0042",
+			time.Time{},
+			nil,
+			base.CreatedAt(),
+			base.UpdatedAt(),
+		)
+		require.NoError(t, err)
+
+		err = repo.Save(ctx, testPayment)
+		require.NoError(t, err)
+
+		foundPayment, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, "Invoice 2025-0042", foundPayment.Reference())
+	})
+
+	t.Run("restores persisted metadata", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		metadata := map[string]string{"order_id": "A-1001", "channel": "web"}
+
+		base := newTestPayment(t)
+		testPayment, err := payment.NewPayment(
+			base.ID(),
+			base.DebtorIBAN(),
+			base.DebtorName(),
+			base.CreditorIBAN(),
+			base.CreditorName(),
+			base.Amount(),
+			base.IdempotencyKey(),
+			"",
+			time.Time{},
+			metadata,
+			base.CreatedAt(),
+			base.UpdatedAt(),
+		)
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		foundPayment, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, metadata, foundPayment.Metadata())
+	})
+
+	t.Run("restores empty metadata", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		var stored string
+		err := db.QueryRowContext(ctx, "SELECT metadata FROM payments WHERE id = ?", testPayment.ID()).Scan(&stored)
+		require.NoError(t, err)
+		assert.Equal(t, "{}", stored)
+
+		foundPayment, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Empty(t, foundPayment.Metadata())
+	})
+
+	t.Run("restores persisted currency", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+
+		usd, err := shared.NewCurrency("USD")
+		require.NoError(t, err)
+		amount, err := shared.NewAmountFromCentsWithCurrency(2500, usd)
+		require.NoError(t, err)
+
+		base := newTestPayment(t)
+		testPayment, err := payment.NewPayment(
+			base.ID(),
+			base.DebtorIBAN(),
+			base.DebtorName(),
+			base.CreditorIBAN(),
+			base.CreditorName(),
+			amount,
+			base.IdempotencyKey(),
+			"",
+			time.Time{},
+			nil,
+			base.CreatedAt(),
+			base.UpdatedAt(),
+		)
+		require.NoError(t, err)
+
+		err = repo.Save(ctx, testPayment)
+		require.NoError(t, err)
+
+		foundPayment, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, "USD", foundPayment.Amount().Currency().Code())
+		assert.True(t, foundPayment.Amount().Equals(amount))
+	})
+}
+
+func TestPaymentRepository_FindByIDs(t *testing.T) {
+	t.Parallel()
+
+	repo, db := createTestRepository(t)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	first := newTestPayment(t, withID("payment-1"))
+	second := newTestPayment(t, withID("payment-2"))
+	deleted := newTestPayment(t, withID("payment-3"))
+	require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{first, second, deleted}))
+	require.NoError(t, repo.SoftDelete(ctx, deleted.ID()))
+
+	t.Run("returns existing payments keyed by id", func(t *testing.T) {
+		t.Parallel()
+
+		found, err := repo.FindByIDs(ctx, []string{"payment-1", "missing", "payment-2", "payment-3"})
+		require.NoError(t, err)
+		require.Len(t, found, 2)
+		assert.True(t, first.Equals(found["payment-1"]))
+		assert.True(t, second.Equals(found["payment-2"]))
+		assert.NotContains(t, found, "missing")
+		assert.NotContains(t, found, "payment-3", "expected soft-deleted payments to be left out")
+	})
+
+	t.Run("includes soft-deleted payments when asked to", func(t *testing.T) {
+		t.Parallel()
+
+		found, err := repo.IncludeDeleted().FindByIDs(ctx, []string{"payment-3"})
+		require.NoError(t, err)
+		assert.Contains(t, found, "payment-3")
+	})
+
+	t.Run("returns an empty map for no ids", func(t *testing.T) {
+		t.Parallel()
+
+		found, err := repo.FindByIDs(ctx, nil)
+		require.NoError(t, err)
+		assert.Empty(t, found)
+	})
+
+	t.Run("splits long id lists across queries", func(t *testing.T) {
+		t.Parallel()
+
+		ids := make([]string, 0, 2*maxIDsPerQuery+1)
+		for i := 0; i < 2*maxIDsPerQuery; i++ {
+			ids = append(ids, fmt.Sprintf("missing-%d", i))
+		}
+		ids = append(ids[:maxIDsPerQuery], append([]string{"payment-1"}, ids[maxIDsPerQuery:]...)...)
+		ids = append(ids, "payment-2")
+
+		found, err := repo.FindByIDs(ctx, ids)
+		require.NoError(t, err)
+		assert.Len(t, found, 2)
+		assert.Contains(t, found, "payment-1")
+		assert.Contains(t, found, "payment-2")
+	})
+}
+
+func TestPaymentRepository_Exists(t *testing.T) {
+	t.Parallel()
+
+	repo, db := createTestRepository(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	present := newTestPayment(t, withID("exists_present"))
+	deleted := newTestPayment(t, withID("exists_deleted"))
+	require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{present, deleted}))
+	require.NoError(t, repo.SoftDelete(ctx, deleted.ID()))
+
+	tests := []struct {
+		name     string
+		repo     PaymentRepository
+		id       string
+		expected bool
+	}{
+		{name: "present id", repo: repo, id: present.ID(), expected: true},
+		{name: "absent id", repo: repo, id: "exists_absent", expected: false},
+		{name: "empty id", repo: repo, id: "", expected: false},
+		{name: "soft-deleted id", repo: repo, id: deleted.ID(), expected: false},
+		{name: "soft-deleted id including deleted", repo: repo.IncludeDeleted(), id: deleted.ID(), expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exists, err := tt.repo.Exists(ctx, tt.id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, exists)
+		})
+	}
 }
 
 func TestPaymentRepository_FindByIdempotencyKey(t *testing.T) {
@@ -134,7 +617,7 @@ func TestPaymentRepository_FindByIdempotencyKey(t *testing.T) {
 		defer db.Close()
 
 		ctx := context.Background()
-		testPayment := createTestPayment(t)
+		testPayment := newTestPayment(t)
 
 		// Save payment first
 		err := repo.Save(ctx, testPayment)
@@ -164,6 +647,48 @@ func TestPaymentRepository_FindByIdempotencyKey(t *testing.T) {
 		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
 		assert.Equal(t, payment.Payment{}, foundPayment)
 	})
+
+	t.Run("restores persisted currency", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+
+		usd, err := shared.NewCurrency("USD")
+		require.NoError(t, err)
+		amount, err := shared.NewAmountFromCentsWithCurrency(2500, usd)
+		require.NoError(t, err)
+
+		base := newTestPayment(t)
+		testPayment, err := payment.NewPayment(
+			base.ID(),
+			base.DebtorIBAN(),
+			base.DebtorName(),
+			base.CreditorIBAN(),
+			base.CreditorName(),
+			amount,
+			base.IdempotencyKey(),
+			"",
+			time.Time{},
+			nil,
+			base.CreatedAt(),
+			base.UpdatedAt(),
+		)
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		var stored string
+		err = db.QueryRowContext(ctx, "SELECT currency FROM payments WHERE id = ?", testPayment.ID()).Scan(&stored)
+		require.NoError(t, err)
+		assert.Equal(t, "USD", stored)
+
+		foundPayment, err := repo.FindByIdempotencyKey(ctx, testPayment.IdempotencyKey())
+		require.NoError(t, err)
+		assert.Equal(t, "USD", foundPayment.Amount().Currency().Code())
+		assert.True(t, foundPayment.Amount().Equals(amount))
+	})
 }
 
 func TestPaymentRepository_UpdateStatus(t *testing.T) {
@@ -176,38 +701,1353 @@ func TestPaymentRepository_UpdateStatus(t *testing.T) {
 		defer db.Close()
 
 		ctx := context.Background()
-		testPayment := createTestPayment(t)
+		testPayment := newTestPayment(t)
 
 		// Save payment first
 		err := repo.Save(ctx, testPayment)
 		require.NoError(t, err)
 
 		// Update status
-		err = repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusProcessed)
+		err = repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusProcessed, testPayment.Version(), time.Now())
 		require.NoError(t, err)
 
-		// Verify status was updated in database
+		// Verify status and version were updated in database
 		var status string
-		err = db.QueryRowContext(ctx, "SELECT status FROM payments WHERE id = ?", testPayment.ID()).Scan(&status)
+		var version int
+		err = db.QueryRowContext(ctx, "SELECT status, version FROM payments WHERE id = ?", testPayment.ID()).Scan(&status, &version)
 		require.NoError(t, err)
 		assert.Equal(t, string(payment.StatusProcessed), status)
+		assert.Equal(t, testPayment.Version()+1, version)
 	})
 
-	t.Run("returns error for non-existent payment", func(t *testing.T) {
+	t.Run("stamps updated_at with the given time", func(t *testing.T) {
 		t.Parallel()
 
-		repo, db := createTestRepository(t)
+		clock := system.NewMockClock(time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC))
+		repo, db := createTestRepositoryWithClock(t, clock)
 		defer db.Close()
 
 		ctx := context.Background()
-		err := repo.UpdateStatus(ctx, "non-existent-id", payment.StatusProcessed)
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "not found")
-	})
-}
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		// The repository clock is ignored in favour of the given time.
+		updatedAt := clock.Now().Add(time.Hour)
+		err := repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusProcessed, testPayment.Version(), updatedAt)
+		require.NoError(t, err)
+
+		found, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.True(t, found.UpdatedAt().Equal(updatedAt), "expected %v, got %v", updatedAt, found.UpdatedAt())
+	})
+
+	t.Run("returns conflict error for stale version", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		// First writer succeeds and bumps the version
+		err := repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusProcessed, testPayment.Version(), time.Now())
+		require.NoError(t, err)
+
+		// Second writer still holds the original version
+		err = repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusFailed, testPayment.Version(), time.Now())
+		assert.ErrorIs(t, err, shared.ErrConcurrentModification)
+
+		found, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusProcessed, found.Status())
+		assert.Equal(t, testPayment.Version()+1, found.Version())
+	})
+
+	t.Run("returns error for non-existent payment", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		err := repo.UpdateStatus(ctx, "non-existent-id", payment.StatusProcessed, 1, time.Now())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+		assert.NotErrorIs(t, err, shared.ErrConcurrentModification)
+	})
+
+	t.Run("returns not found for a soft-deleted payment", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+		require.NoError(t, repo.SoftDelete(ctx, testPayment.ID()))
+
+		err := repo.IncludeDeleted().UpdateStatus(ctx, testPayment.ID(), payment.StatusProcessed, testPayment.Version(), time.Now())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+
+		found, err := repo.IncludeDeleted().FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusPending, found.Status())
+		assert.Equal(t, testPayment.Version(), found.Version())
+	})
+}
+
+func TestPaymentRepository_UpdateStatusBatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("updates existing payments and skips missing ones", func(t *testing.T) {
+		t.Parallel()
+
+		clock := system.NewMockClock(time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC))
+		repo, db := createTestRepositoryWithClock(t, clock)
+		defer db.Close()
+
+		ctx := context.Background()
+		first := newTestPayment(t, withID("test_payment_001"))
+		second := newTestPayment(t, withID("test_payment_002"))
+		untouched := newTestPayment(t, withID("test_payment_003"))
+		require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{first, second, untouched}))
+
+		clock.Advance(time.Minute)
+		updated, err := repo.UpdateStatusBatch(ctx, []string{first.ID(), "missing_payment", second.ID()}, payment.StatusProcessing)
+		require.NoError(t, err)
+		assert.Equal(t, 2, updated)
+
+		for _, p := range []payment.Payment{first, second} {
+			found, err := repo.FindByID(ctx, p.ID())
+			require.NoError(t, err)
+			assert.Equal(t, payment.StatusProcessing, found.Status())
+			assert.Equal(t, p.Version()+1, found.Version())
+			assert.Equal(t, clock.Now(), found.UpdatedAt())
+
+			history, err := repo.FindStatusHistory(ctx, p.ID())
+			require.NoError(t, err)
+			require.Len(t, history, 1)
+			assert.Equal(t, payment.StatusPending, history[0].From)
+			assert.Equal(t, payment.StatusProcessing, history[0].To)
+		}
+
+		found, err := repo.FindByID(ctx, untouched.ID())
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusPending, found.Status())
+	})
+
+	t.Run("does not count payments already in the target status", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		updated, err := repo.UpdateStatusBatch(ctx, []string{testPayment.ID()}, payment.StatusPending)
+		require.NoError(t, err)
+		assert.Zero(t, updated)
+
+		history, err := repo.FindStatusHistory(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Empty(t, history)
+	})
+
+	t.Run("skips payments that cannot legally move to the status", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		pending := newTestPayment(t, withID("test_payment_001"))
+		processed := newTestPayment(t, withID("test_payment_002"))
+		require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{pending, processed}))
+		_, err := repo.UpdateStatusBatch(ctx, []string{processed.ID()}, payment.StatusProcessing)
+		require.NoError(t, err)
+		_, err = repo.UpdateStatusBatch(ctx, []string{processed.ID()}, payment.StatusProcessed)
+		require.NoError(t, err)
+
+		updated, err := repo.UpdateStatusBatch(ctx, []string{pending.ID(), processed.ID()}, payment.StatusFailed)
+		require.NoError(t, err)
+		assert.Zero(t, updated, "neither a pending nor a processed payment can fail")
+
+		updated, err = repo.UpdateStatusBatch(ctx, []string{pending.ID(), processed.ID()}, payment.StatusPending)
+		require.NoError(t, err)
+		assert.Zero(t, updated, "no payment can move back to pending")
+
+		found, err := repo.FindByID(ctx, processed.ID())
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusProcessed, found.Status())
+	})
+
+	t.Run("skips soft-deleted payments", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+		require.NoError(t, repo.SoftDelete(ctx, testPayment.ID()))
+
+		updated, err := repo.IncludeDeleted().UpdateStatusBatch(ctx, []string{testPayment.ID()}, payment.StatusCancelled)
+		require.NoError(t, err)
+		assert.Zero(t, updated)
+
+		found, err := repo.IncludeDeleted().FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusPending, found.Status())
+	})
+
+	t.Run("updates more payments than fit in one query", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		count := maxIDsPerQuery + 5
+		ids := make([]string, count)
+		payments := make([]payment.Payment, count)
+		for i := range payments {
+			ids[i] = fmt.Sprintf("batch_payment_%04d", i)
+			key, err := shared.NewIdempotencyKey(fmt.Sprintf("batchK%04d", i))
+			require.NoError(t, err)
+			payments[i] = newTestPayment(t, withID(ids[i]), withIdempotencyKey(key))
+		}
+		require.NoError(t, repo.SaveBatch(ctx, payments))
+
+		updated, err := repo.UpdateStatusBatch(ctx, ids, payment.StatusCancelled)
+		require.NoError(t, err)
+		assert.Equal(t, count, updated)
+
+		cancelled, err := repo.CountByStatus(ctx, payment.StatusCancelled)
+		require.NoError(t, err)
+		assert.Equal(t, count, cancelled)
+	})
+
+	t.Run("rejects an invalid status", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		updated, err := repo.UpdateStatusBatch(context.Background(), []string{"test_payment_001"}, payment.PaymentStatus("UNKNOWN"))
+		assert.ErrorIs(t, err, shared.ErrInvalidPaymentStatus)
+		assert.Zero(t, updated)
+	})
+
+	t.Run("accepts an empty batch", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		updated, err := repo.UpdateStatusBatch(context.Background(), nil, payment.StatusFailed)
+		require.NoError(t, err)
+		assert.Zero(t, updated)
+	})
+}
+
+func TestPaymentRepository_FindStatusHistory(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records exactly one change per status update", func(t *testing.T) {
+		t.Parallel()
+
+		clock := system.NewMockClock(time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC))
+		repo, db := createTestRepositoryWithClock(t, clock)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		clock.Advance(time.Minute)
+		require.NoError(t, repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusProcessed, testPayment.Version(), clock.Now()))
+
+		var rows int
+		err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM payment_status_history WHERE payment_id = ?", testPayment.ID()).Scan(&rows)
+		require.NoError(t, err)
+		assert.Equal(t, 1, rows)
+
+		history, err := repo.FindStatusHistory(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, []payment.StatusChange{{
+			PaymentID: testPayment.ID(),
+			From:      payment.StatusPending,
+			To:        payment.StatusProcessed,
+			ChangedAt: clock.Now(),
+			Actor:     payment.SystemActor,
+		}}, history)
+	})
+
+	t.Run("returns changes in chronological order", func(t *testing.T) {
+		t.Parallel()
+
+		clock := system.NewMockClock(time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC))
+		repo, db := createTestRepositoryWithClock(t, clock)
+		defer db.Close()
+
+		ctx := payment.WithActor(context.Background(), "ops@example.com")
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		clock.Advance(time.Minute)
+		claimedAt := clock.Now()
+		require.NoError(t, repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusProcessing, testPayment.Version(), clock.Now()))
+		clock.Advance(time.Minute)
+		require.NoError(t, repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusFailed, testPayment.Version()+1, clock.Now()))
+
+		history, err := repo.FindStatusHistory(ctx, testPayment.ID())
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, payment.StatusPending, history[0].From)
+		assert.Equal(t, payment.StatusProcessing, history[0].To)
+		assert.True(t, history[0].ChangedAt.Equal(claimedAt))
+		assert.Equal(t, payment.StatusProcessing, history[1].From)
+		assert.Equal(t, payment.StatusFailed, history[1].To)
+		assert.True(t, history[1].ChangedAt.After(history[0].ChangedAt))
+		assert.Equal(t, "ops@example.com", history[1].Actor)
+	})
+
+	t.Run("records nothing for a stale update", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		err := repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusProcessed, testPayment.Version()+1, time.Now())
+		require.ErrorIs(t, err, shared.ErrConcurrentModification)
+
+		history, err := repo.FindStatusHistory(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Empty(t, history)
+	})
+
+	t.Run("rejects rewriting recorded changes", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+		require.NoError(t, repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusProcessed, testPayment.Version(), time.Now()))
+
+		_, err := db.ExecContext(ctx, "UPDATE payment_status_history SET actor = 'someone else'")
+		assert.ErrorContains(t, err, "immutable")
+		_, err = db.ExecContext(ctx, "DELETE FROM payment_status_history")
+		assert.ErrorContains(t, err, "immutable")
+	})
+}
+
+func TestPaymentRepository_List(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stops reading rows once cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		batch := make([]payment.Payment, 20)
+		for i := range batch {
+			batch[i] = newTestPayment(t, withID(fmt.Sprintf("list_payment_%d", i)))
+		}
+		require.NoError(t, repo.SaveBatch(context.Background(), batch))
+
+		payments, err := repo.List(newCancelAfterChecks(5), 0, len(batch))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, payments)
+	})
+
+	t.Run("returns payments newest first with limit and offset", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		base := time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+		for i := 0; i < 5; i++ {
+			p := newTestPayment(t, withID(fmt.Sprintf("list_payment_%d", i)), withCreatedAt(base.Add(time.Duration(i)*time.Minute)))
+			require.NoError(t, repo.Save(ctx, p))
+		}
+
+		firstPage, err := repo.List(ctx, 0, 2)
+		require.NoError(t, err)
+		require.Len(t, firstPage, 2)
+		assert.Equal(t, "list_payment_4", firstPage[0].ID())
+		assert.Equal(t, "list_payment_3", firstPage[1].ID())
+
+		secondPage, err := repo.List(ctx, 2, 2)
+		require.NoError(t, err)
+		require.Len(t, secondPage, 2)
+		assert.Equal(t, "list_payment_2", secondPage[0].ID())
+		assert.Equal(t, "list_payment_1", secondPage[1].ID())
+
+		lastPage, err := repo.List(ctx, 4, 2)
+		require.NoError(t, err)
+		require.Len(t, lastPage, 1)
+		assert.Equal(t, "list_payment_0", lastPage[0].ID())
+	})
+
+	t.Run("applies default limit for non-positive limit", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.Save(ctx, newTestPayment(t, withID(fmt.Sprintf("default_limit_%d", i)))))
+		}
+
+		payments, err := repo.List(ctx, 0, 0)
+		require.NoError(t, err)
+		assert.Len(t, payments, 3)
+	})
+
+	t.Run("returns empty slice when there are no payments", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		payments, err := repo.List(context.Background(), 0, 10)
+		require.NoError(t, err)
+		assert.NotNil(t, payments)
+		assert.Empty(t, payments)
+	})
+}
+
+func TestPaymentRepository_Iterate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	base := time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+
+	repo, db := createTestRepository(t)
+	t.Cleanup(func() { db.Close() })
+
+	processed, err := newTestPayment(t, withID("iterate_payment_2"), withCreatedAt(base.Add(2*time.Minute))).MarkAsProcessing(base)
+	require.NoError(t, err)
+	processed, err = processed.MarkAsProcessed(base)
+	require.NoError(t, err)
+	require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{
+		newTestPayment(t, withID("iterate_payment_3"), withCreatedAt(base.Add(3*time.Minute))),
+		newTestPayment(t, withID("iterate_payment_0"), withCreatedAt(base)),
+		processed,
+		newTestPayment(t, withID("iterate_payment_1"), withCreatedAt(base.Add(time.Minute))),
+		newTestPayment(t, withID("iterate_deleted"), withCreatedAt(base.Add(30*time.Second))),
+	}))
+	require.NoError(t, repo.SoftDelete(ctx, "iterate_deleted"))
+
+	collect := func(t *testing.T, repo PaymentRepository, filter payment.Filter) []string {
+		t.Helper()
+		var ids []string
+		err := repo.Iterate(ctx, filter, func(p payment.Payment) error {
+			ids = append(ids, p.ID())
+			return nil
+		})
+		require.NoError(t, err)
+		return ids
+	}
+
+	t.Run("visits every payment oldest first", func(t *testing.T) {
+		t.Parallel()
+
+		ids := collect(t, repo, payment.Filter{})
+		assert.Equal(t, []string{"iterate_payment_0", "iterate_payment_1", "iterate_payment_2", "iterate_payment_3"}, ids)
+	})
+
+	t.Run("applies the filter", func(t *testing.T) {
+		t.Parallel()
+
+		ids := collect(t, repo, payment.Filter{From: base.Add(time.Minute), To: base.Add(3 * time.Minute)})
+		assert.Equal(t, []string{"iterate_payment_1", "iterate_payment_2"}, ids)
+
+		ids = collect(t, repo, payment.Filter{Status: payment.StatusProcessed})
+		assert.Equal(t, []string{"iterate_payment_2"}, ids)
+	})
+
+	t.Run("includes soft-deleted payments when asked to", func(t *testing.T) {
+		t.Parallel()
+
+		ids := collect(t, repo.IncludeDeleted(), payment.Filter{To: base.Add(time.Minute)})
+		assert.Equal(t, []string{"iterate_payment_0", "iterate_deleted"}, ids)
+	})
+
+	t.Run("stops at the first callback error", func(t *testing.T) {
+		t.Parallel()
+
+		stop := errors.New("stop")
+		var ids []string
+		err := repo.Iterate(ctx, payment.Filter{}, func(p payment.Payment) error {
+			ids = append(ids, p.ID())
+			if len(ids) == 2 {
+				return stop
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, []string{"iterate_payment_0", "iterate_payment_1"}, ids)
+	})
+
+	t.Run("stops once the context is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		cancelCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		calls := 0
+		err := repo.Iterate(cancelCtx, payment.Filter{}, func(payment.Payment) error {
+			calls++
+			cancel()
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("outlives the query timeout", func(t *testing.T) {
+		t.Parallel()
+
+		config := DefaultConfig()
+		config.DatabasePath = filepath.Join(t.TempDir(), "iterate.db")
+		config.QueryTimeout = 20 * time.Millisecond
+		slowDB, err := NewDatabase(config)
+		require.NoError(t, err)
+		defer slowDB.Close()
+		require.NoError(t, slowDB.Initialize(ctx))
+
+		slowRepo := NewPaymentRepository(slowDB, system.NewTimeProvider())
+		for i := 0; i < 3; i++ {
+			require.NoError(t, slowRepo.Save(ctx, newTestPayment(t, withID(fmt.Sprintf("slow_payment_%d", i)), withCreatedAt(base.Add(time.Duration(i)*time.Minute)))))
+		}
+
+		start := time.Now()
+		visited := 0
+		err = slowRepo.Iterate(ctx, payment.Filter{}, func(payment.Payment) error {
+			visited++
+			time.Sleep(config.QueryTimeout)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, visited)
+		assert.Greater(t, time.Since(start), config.QueryTimeout)
+	})
+
+	t.Run("rejects an invalid filter", func(t *testing.T) {
+		t.Parallel()
+
+		noop := func(payment.Payment) error { return nil }
+		err := repo.Iterate(ctx, payment.Filter{From: base, To: base}, noop)
+		assert.ErrorIs(t, err, shared.ErrInvalidDateRange)
+
+		err = repo.Iterate(ctx, payment.Filter{Status: "UNKNOWN"}, noop)
+		assert.ErrorIs(t, err, shared.ErrInvalidPaymentStatus)
+	})
+}
+
+func TestPaymentRepository_ListAfter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("pages through every payment without overlap or gaps", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		// Payments share creation times in threes, so pages must break ties by id.
+		ctx := context.Background()
+		base := time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+		var expected []string
+		for i := 0; i < 25; i++ {
+			id := fmt.Sprintf("cursor_payment_%02d", i)
+			require.NoError(t, repo.Save(ctx, newTestPayment(t, withID(id), withCreatedAt(base.Add(time.Duration(i/3)*time.Minute)))))
+			expected = append(expected, id)
+		}
+
+		var (
+			seen         []string
+			afterCreated time.Time
+			afterID      string
+			pages        int
+		)
+		for {
+			page, err := repo.ListAfter(ctx, afterCreated, afterID, 4)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+			pages++
+			for _, p := range page {
+				seen = append(seen, p.ID())
+			}
+			last := page[len(page)-1]
+			afterCreated, afterID = last.CreatedAt(), last.ID()
+		}
+
+		assert.Equal(t, expected, seen)
+		assert.Equal(t, 7, pages)
+	})
+
+	t.Run("skips soft-deleted payments", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		base := time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.Save(ctx, newTestPayment(t, withID(fmt.Sprintf("cursor_payment_%d", i)), withCreatedAt(base.Add(time.Duration(i)*time.Minute)))))
+		}
+		require.NoError(t, repo.SoftDelete(ctx, "cursor_payment_1"))
+
+		payments, err := repo.ListAfter(ctx, time.Time{}, "", 10)
+		require.NoError(t, err)
+		require.Len(t, payments, 2)
+		assert.Equal(t, "cursor_payment_0", payments[0].ID())
+		assert.Equal(t, "cursor_payment_2", payments[1].ID())
+	})
+
+	t.Run("returns empty slice past the last payment", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		p := newTestPayment(t, withID("cursor_payment_0"), withCreatedAt(time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)))
+		require.NoError(t, repo.Save(ctx, p))
+
+		payments, err := repo.ListAfter(ctx, p.CreatedAt(), p.ID(), 10)
+		require.NoError(t, err)
+		assert.NotNil(t, payments)
+		assert.Empty(t, payments)
+	})
+}
+
+func TestPaymentRepository_FindByStatus(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns only payments in the requested status", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		seedPaymentsWithStatuses(t, repo, map[string]payment.PaymentStatus{
+			"status_pending_1":   payment.StatusPending,
+			"status_pending_2":   payment.StatusPending,
+			"status_processed_1": payment.StatusProcessed,
+			"status_failed_1":    payment.StatusFailed,
+		})
+
+		pending, err := repo.FindByStatus(ctx, payment.StatusPending, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 2)
+		for _, p := range pending {
+			assert.Equal(t, payment.StatusPending, p.Status())
+		}
+
+		processed, err := repo.FindByStatus(ctx, payment.StatusProcessed, 10)
+		require.NoError(t, err)
+		require.Len(t, processed, 1)
+		assert.Equal(t, "status_processed_1", processed[0].ID())
+
+		failed, err := repo.FindByStatus(ctx, payment.StatusFailed, 10)
+		require.NoError(t, err)
+		require.Len(t, failed, 1)
+		assert.Equal(t, "status_failed_1", failed[0].ID())
+
+		limited, err := repo.FindByStatus(ctx, payment.StatusPending, 1)
+		require.NoError(t, err)
+		assert.Len(t, limited, 1)
+	})
+
+	t.Run("rejects unknown status", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		payments, err := repo.FindByStatus(context.Background(), payment.PaymentStatus("UNKNOWN"), 10)
+		assert.ErrorIs(t, err, shared.ErrInvalidPaymentStatus)
+		assert.Nil(t, payments)
+	})
+}
+
+func TestPaymentRepository_FindByDebtorIBANAndStatus(t *testing.T) {
+	t.Parallel()
+
+	debtor, err := shared.NewIBAN("DE89370400440532013000")
+	require.NoError(t, err)
+	otherDebtor, err := shared.NewIBAN("GB82WEST12345698765432")
+	require.NoError(t, err)
+
+	t.Run("returns only the debtor's payments in the status", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		base := time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+		seed := []struct {
+			id     string
+			debtor shared.IBAN
+		}{
+			{"debtor_failed_1", debtor},
+			{"debtor_failed_2", debtor},
+			{"debtor_pending_1", debtor},
+			{"other_failed_1", otherDebtor},
+			{"other_pending_1", otherDebtor},
+		}
+		for i, s := range seed {
+			require.NoError(t, repo.Save(ctx, newTestPayment(t, withID(s.id), withDebtor(s.debtor), withCreatedAt(base.Add(time.Duration(i)*time.Minute)))))
+		}
+		for _, status := range []payment.PaymentStatus{payment.StatusProcessing, payment.StatusFailed} {
+			_, err := repo.UpdateStatusBatch(ctx, []string{"debtor_failed_1", "debtor_failed_2", "other_failed_1"}, status)
+			require.NoError(t, err)
+		}
+
+		failed, err := repo.FindByDebtorIBANAndStatus(ctx, debtor, payment.StatusFailed, 10)
+		require.NoError(t, err)
+		require.Len(t, failed, 2)
+		assert.Equal(t, "debtor_failed_1", failed[0].ID())
+		assert.Equal(t, "debtor_failed_2", failed[1].ID())
+
+		pending, err := repo.FindByDebtorIBANAndStatus(ctx, otherDebtor, payment.StatusPending, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, "other_pending_1", pending[0].ID())
+
+		processed, err := repo.FindByDebtorIBANAndStatus(ctx, debtor, payment.StatusProcessed, 10)
+		require.NoError(t, err)
+		assert.Empty(t, processed)
+
+		limited, err := repo.FindByDebtorIBANAndStatus(ctx, debtor, payment.StatusFailed, 1)
+		require.NoError(t, err)
+		assert.Len(t, limited, 1)
+	})
+
+	t.Run("rejects invalid input", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		payments, err := repo.FindByDebtorIBANAndStatus(ctx, shared.IBAN{}, payment.StatusFailed, 10)
+		assert.ErrorIs(t, err, shared.ErrInvalidIBAN)
+		assert.Nil(t, payments)
+
+		payments, err = repo.FindByDebtorIBANAndStatus(ctx, debtor, payment.PaymentStatus("UNKNOWN"), 10)
+		assert.ErrorIs(t, err, shared.ErrInvalidPaymentStatus)
+		assert.Nil(t, payments)
+	})
+}
+
+func TestPaymentRepository_FindByAmountRange(t *testing.T) {
+	t.Parallel()
+
+	repo, db := createTestRepository(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	seed := map[string]int64{
+		"amount_small":   500,
+		"amount_medium":  10000,
+		"amount_tied_a":  25000,
+		"amount_tied_b":  25000,
+		"amount_large":   1000000,
+		"amount_largest": 50000000,
+	}
+	for id, cents := range seed {
+		require.NoError(t, repo.Save(ctx, newTestPayment(t, withID(id), withAmount(cents))))
+	}
+	usd, err := shared.NewCurrency("USD")
+	require.NoError(t, err)
+	require.NoError(t, repo.Save(ctx, newTestPayment(t, withID("amount_usd"), withAmount(25000), withCurrency(usd))))
+
+	ids := func(payments []payment.Payment) []string {
+		result := make([]string, len(payments))
+		for i, p := range payments {
+			result[i] = p.ID()
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		min, max int64
+		limit    int
+		expected []string
+	}{
+		{name: "bounded range is inclusive", min: 10000, max: 25000, limit: 10, expected: []string{"amount_medium", "amount_tied_a", "amount_tied_b"}},
+		{name: "negative max is unbounded", min: 1000000, max: -1, limit: 10, expected: []string{"amount_large", "amount_largest"}},
+		{name: "single amount", min: 25000, max: 25000, limit: 10, expected: []string{"amount_tied_a", "amount_tied_b"}},
+		{name: "limit keeps the smallest", min: 0, max: -1, limit: 2, expected: []string{"amount_small", "amount_medium"}},
+		{name: "empty range", min: 501, max: 9999, limit: 10, expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments, err := repo.FindByAmountRange(ctx, shared.EUR, tt.min, tt.max, tt.limit)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ids(payments))
+
+			again, err := repo.FindByAmountRange(ctx, shared.EUR, tt.min, tt.max, tt.limit)
+			require.NoError(t, err)
+			assert.Equal(t, ids(payments), ids(again), "expected a stable order")
+		})
+	}
+
+	t.Run("filters by currency", func(t *testing.T) {
+		payments, err := repo.FindByAmountRange(ctx, usd, 0, -1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"amount_usd"}, ids(payments))
+	})
+
+	t.Run("rejects an invalid range", func(t *testing.T) {
+		payments, err := repo.FindByAmountRange(ctx, shared.EUR, 25000, 10000, 10)
+		assert.ErrorIs(t, err, shared.ErrInvalidAmountRange)
+		assert.Nil(t, payments)
+
+		payments, err = repo.FindByAmountRange(ctx, shared.EUR, -1, 10000, 10)
+		assert.ErrorIs(t, err, shared.ErrInvalidAmountRange)
+		assert.Nil(t, payments)
+
+		payments, err = repo.FindByAmountRange(ctx, shared.Currency{}, 0, 10000, 10)
+		assert.ErrorIs(t, err, shared.ErrInvalidCurrency)
+		assert.Nil(t, payments)
+	})
+}
+
+func TestPaymentRepository_FindByDateRange(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns payments in range ordered by creation time", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		from := time.Date(2025, 1, 21, 0, 0, 0, 0, time.UTC)
+		to := from.Add(24 * time.Hour)
+
+		seed := map[string]time.Time{
+			"range_before":   from.Add(-time.Second),
+			"range_at_from":  from,
+			"range_midday":   from.Add(12 * time.Hour),
+			"range_last_sec": to.Add(-time.Second),
+			"range_at_to":    to,
+		}
+		for id, createdAt := range seed {
+			require.NoError(t, repo.Save(ctx, newTestPayment(t, withID(id), withCreatedAt(createdAt))))
+		}
+
+		payments, err := repo.FindByDateRange(ctx, from, to, 10)
+		require.NoError(t, err)
+		require.Len(t, payments, 3)
+		assert.Equal(t, "range_at_from", payments[0].ID())
+		assert.Equal(t, "range_midday", payments[1].ID())
+		assert.Equal(t, "range_last_sec", payments[2].ID())
+	})
+
+	t.Run("leaves out soft-deleted payments", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		from := time.Date(2025, 1, 21, 0, 0, 0, 0, time.UTC)
+		to := from.Add(24 * time.Hour)
+		require.NoError(t, repo.Save(ctx, newTestPayment(t, withID("range_kept"), withCreatedAt(from))))
+		require.NoError(t, repo.Save(ctx, newTestPayment(t, withID("range_deleted"), withCreatedAt(from.Add(time.Hour)))))
+		require.NoError(t, repo.SoftDelete(ctx, "range_deleted"))
+
+		payments, err := repo.FindByDateRange(ctx, from, to, 10)
+		require.NoError(t, err)
+		require.Len(t, payments, 1)
+		assert.Equal(t, "range_kept", payments[0].ID())
+
+		payments, err = repo.IncludeDeleted().FindByDateRange(ctx, from, to, 10)
+		require.NoError(t, err)
+		assert.Len(t, payments, 2)
+	})
+
+	t.Run("rejects an empty or inverted range", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		now := time.Now()
+
+		_, err := repo.FindByDateRange(ctx, now, now, 10)
+		assert.ErrorIs(t, err, shared.ErrInvalidDateRange)
+
+		_, err = repo.FindByDateRange(ctx, now, now.Add(-time.Hour), 10)
+		assert.ErrorIs(t, err, shared.ErrInvalidDateRange)
+	})
+}
+
+func TestPaymentRepository_FindDueForExecution(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns pending payments whose execution date has arrived", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		createdAt := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+		asOf := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+		payments := []payment.Payment{
+			newTestPayment(t, withID("due_immediately"), withCreatedAt(createdAt), withExecutionDate(time.Time{})),
+			newTestPayment(t, withID("due_yesterday"), withCreatedAt(createdAt), withExecutionDate(asOf.Add(-24*time.Hour))),
+			newTestPayment(t, withID("due_now"), withCreatedAt(createdAt), withExecutionDate(asOf)),
+			newTestPayment(t, withID("due_tomorrow"), withCreatedAt(createdAt), withExecutionDate(asOf.Add(24*time.Hour))),
+		}
+		claimed, err := newTestPayment(t, withID("due_but_processing"), withCreatedAt(createdAt), withExecutionDate(asOf.Add(-time.Hour))).MarkAsProcessing(createdAt)
+		require.NoError(t, err)
+		payments = append(payments, claimed)
+		require.NoError(t, repo.SaveBatch(ctx, payments))
+
+		due, err := repo.FindDueForExecution(ctx, asOf, 10)
+		require.NoError(t, err)
+
+		ids := make([]string, len(due))
+		for i, p := range due {
+			ids[i] = p.ID()
+		}
+		assert.Equal(t, []string{"due_immediately", "due_yesterday", "due_now"}, ids)
+		assert.True(t, due[1].ExecutionDate().Equal(asOf.Add(-24*time.Hour)), "execution date should round-trip")
+		assert.True(t, due[0].ExecutionDate().IsZero(), "missing execution date should read back as zero")
+	})
+
+	t.Run("returns at most limit payments, earliest first", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		createdAt := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+		asOf := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{
+			newTestPayment(t, withID("due_third"), withCreatedAt(createdAt), withExecutionDate(asOf)),
+			newTestPayment(t, withID("due_first"), withCreatedAt(createdAt), withExecutionDate(asOf.Add(-48*time.Hour))),
+			newTestPayment(t, withID("due_second"), withCreatedAt(createdAt), withExecutionDate(asOf.Add(-24*time.Hour))),
+		}))
+
+		due, err := repo.FindDueForExecution(ctx, asOf, 2)
+		require.NoError(t, err)
+		require.Len(t, due, 2)
+		assert.Equal(t, "due_first", due[0].ID())
+		assert.Equal(t, "due_second", due[1].ID())
+	})
+}
+
+func TestPaymentRepository_Count(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns zero for an empty database", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		pendingCount, err := repo.CountByStatus(ctx, payment.StatusPending)
+		require.NoError(t, err)
+		assert.Equal(t, 0, pendingCount)
+	})
+
+	t.Run("counts a known mix of payments", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		seedPaymentsWithStatuses(t, repo, map[string]payment.PaymentStatus{
+			"count_pending_1":    payment.StatusPending,
+			"count_pending_2":    payment.StatusPending,
+			"count_pending_3":    payment.StatusPending,
+			"count_processing_1": payment.StatusProcessing,
+			"count_processed_1":  payment.StatusProcessed,
+			"count_failed_1":     payment.StatusFailed,
+			"count_failed_2":     payment.StatusFailed,
+		})
+
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 7, count)
+
+		expected := map[payment.PaymentStatus]int{
+			payment.StatusPending:    3,
+			payment.StatusProcessing: 1,
+			payment.StatusProcessed:  1,
+			payment.StatusFailed:     2,
+		}
+		for status, want := range expected {
+			got, err := repo.CountByStatus(ctx, status)
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "unexpected count for status %s", status)
+		}
+	})
+
+	t.Run("leaves out soft-deleted payments", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		require.NoError(t, repo.Save(ctx, newTestPayment(t, withID("count_kept"))))
+		require.NoError(t, repo.Save(ctx, newTestPayment(t, withID("count_deleted"))))
+		require.NoError(t, repo.SoftDelete(ctx, "count_deleted"))
+
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		pendingCount, err := repo.CountByStatus(ctx, payment.StatusPending)
+		require.NoError(t, err)
+		assert.Equal(t, 1, pendingCount)
+
+		count, err = repo.IncludeDeleted().Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		pendingCount, err = repo.IncludeDeleted().CountByStatus(ctx, payment.StatusPending)
+		require.NoError(t, err)
+		assert.Equal(t, 2, pendingCount)
+	})
+
+	t.Run("rejects unknown status", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		_, err := repo.CountByStatus(context.Background(), payment.PaymentStatus("UNKNOWN"))
+		assert.ErrorIs(t, err, shared.ErrInvalidPaymentStatus)
+	})
+}
+
+func TestPaymentRepository_SoftDelete(t *testing.T) {
+	t.Parallel()
+
+	t.Run("hides the payment from default queries", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		err := repo.SoftDelete(ctx, testPayment.ID())
+		require.NoError(t, err)
+
+		_, err = repo.FindByID(ctx, testPayment.ID())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+
+		listed, err := repo.List(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, listed)
+
+		byStatus, err := repo.FindByStatus(ctx, payment.StatusPending, 10)
+		require.NoError(t, err)
+		assert.Empty(t, byStatus)
+	})
+
+	t.Run("keeps the payment visible when including deleted", func(t *testing.T) {
+		t.Parallel()
+
+		clock := system.NewMockClock(time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC))
+		repo, db := createTestRepositoryWithClock(t, clock)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+		require.NoError(t, repo.SoftDelete(ctx, testPayment.ID()))
+
+		found, err := repo.IncludeDeleted().FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, testPayment.ID(), found.ID())
+
+		listed, err := repo.IncludeDeleted().List(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, listed, 1)
+
+		var deletedAt time.Time
+		err = db.QueryRowContext(ctx, "SELECT deleted_at FROM payments WHERE id = ?", testPayment.ID()).Scan(&deletedAt)
+		require.NoError(t, err)
+		assert.True(t, deletedAt.Equal(clock.Now()), "expected %v, got %v", clock.Now(), deletedAt)
+	})
+
+	t.Run("returns not found for missing or already deleted payments", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		err := repo.SoftDelete(ctx, "non-existent-id")
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+		require.NoError(t, repo.SoftDelete(ctx, testPayment.ID()))
+
+		err = repo.SoftDelete(ctx, testPayment.ID())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+	})
+}
+
+func TestPaymentRepository_HealthCheck(t *testing.T) {
+	t.Parallel()
+
+	t.Run("passes after initialization", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		assert.NoError(t, repo.HealthCheck(context.Background()))
+	})
+
+	t.Run("fails on a closed database", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		require.NoError(t, db.Close())
+
+		assert.Error(t, repo.HealthCheck(context.Background()))
+	})
+
+	t.Run("fails before migrations have run", func(t *testing.T) {
+		t.Parallel()
+
+		db := createTestDatabase(t)
+		defer db.Close()
+
+		repo := NewPaymentRepository(*db, system.NewTimeProvider())
+		assert.Error(t, repo.HealthCheck(context.Background()))
+	})
+}
+
+func TestPaymentRepository_WrapsConnectionErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	forced := errors.New("forced failure")
+	db := sql.OpenDB(failingConnector{err: forced})
+	t.Cleanup(func() { db.Close() })
+	repo := NewPaymentRepository(db, system.NewTimeProvider())
+
+	t.Run("query", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := repo.List(ctx, 0, 10)
+		assert.ErrorIs(t, err, forced)
+		assert.ErrorContains(t, err, "failed to list payments")
+	})
+
+	t.Run("query row", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := repo.FindByID(ctx, "payment-123")
+		assert.ErrorIs(t, err, forced)
+		assert.ErrorContains(t, err, "failed to find payment by ID")
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		t.Parallel()
+
+		err := repo.SoftDelete(ctx, "payment-123")
+		assert.ErrorIs(t, err, forced)
+		assert.ErrorContains(t, err, "failed to soft-delete payment")
+	})
+
+	t.Run("transaction", func(t *testing.T) {
+		t.Parallel()
+
+		err := repo.WithTransaction(ctx, func(payment.Repository) error {
+			t.Error("expected the callback not to run")
+			return nil
+		})
+		assert.ErrorIs(t, err, forced)
+	})
+}
+
+func TestPaymentRepository_WithTransaction(t *testing.T) {
+	t.Parallel()
+
+	t.Run("commits when the callback succeeds", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+
+		err := repo.WithTransaction(ctx, func(txRepo payment.Repository) error {
+			return txRepo.Save(ctx, testPayment)
+		})
+		require.NoError(t, err)
+
+		_, err = repo.FindByID(ctx, testPayment.ID())
+		assert.NoError(t, err)
+	})
+
+	t.Run("joins the transaction of a repository built over a *sql.Tx", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+
+		testPayment := newTestPayment(t)
+		txRepo := NewPaymentRepository(tx, system.NewTimeProvider())
+		err = txRepo.WithTransaction(ctx, func(inner payment.Repository) error {
+			return inner.Save(ctx, testPayment)
+		})
+		require.NoError(t, err)
+		require.NoError(t, tx.Rollback())
+
+		_, err = repo.FindByID(ctx, testPayment.ID())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound, "expected the save to be rolled back with the caller's transaction")
+	})
+
+	t.Run("rolls back when the callback fails", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		callbackErr := errors.New("callback failed")
+
+		err := repo.WithTransaction(ctx, func(txRepo payment.Repository) error {
+			if err := txRepo.Save(ctx, testPayment); err != nil {
+				return err
+			}
+			return callbackErr
+		})
+		assert.ErrorIs(t, err, callbackErr)
+
+		_, err = repo.FindByID(ctx, testPayment.ID())
+		assert.ErrorIs(t, err, shared.ErrPaymentNotFound)
+	})
+
+	t.Run("serializes concurrent status transitions", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment, err := newTestPayment(t).MarkAsProcessing(time.Now())
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		transition := func(txRepo payment.Repository, target payment.PaymentStatus) error {
+			existing, err := txRepo.FindByID(ctx, testPayment.ID())
+			if err != nil {
+				return err
+			}
+
+			var updated payment.Payment
+			if target == payment.StatusProcessed {
+				updated, err = existing.MarkAsProcessed(time.Now())
+			} else {
+				updated, err = existing.MarkAsFailed(time.Now())
+			}
+			if err != nil {
+				return err
+			}
+
+			// Widen the window in which a lost update could occur
+			time.Sleep(20 * time.Millisecond)
+
+			return txRepo.UpdateStatus(ctx, updated.ID(), updated.Status(), updated.Version(), time.Now())
+		}
+
+		targets := []payment.PaymentStatus{payment.StatusProcessed, payment.StatusFailed}
+		errs := make([]error, len(targets))
+
+		var wg sync.WaitGroup
+		for i, target := range targets {
+			wg.Add(1)
+			go func(i int, target payment.PaymentStatus) {
+				defer wg.Done()
+				errs[i] = repo.WithTransaction(ctx, func(txRepo payment.Repository) error {
+					return transition(txRepo, target)
+				})
+			}(i, target)
+		}
+		wg.Wait()
+
+		var succeeded, rejected int
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, shared.ErrInvalidStatusTransition):
+				rejected++
+			default:
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		assert.Equal(t, 1, succeeded, "exactly one transition should succeed")
+		assert.Equal(t, 1, rejected, "the other transition should be rejected")
+	})
+}
 
-// createTestRepository creates a test repository with an initialized database
 func createTestRepository(t *testing.T) (PaymentRepository, *Database) {
+	return createTestRepositoryWithClock(t, system.NewTimeProvider())
+}
+
+// createTestRepositoryWithClock creates a test repository stamping updates from clock
+func createTestRepositoryWithClock(t *testing.T, clock shared.Clock) (PaymentRepository, *Database) {
 	tempDir := t.TempDir()
 	dbPath := filepath.Join(tempDir, "test_repo.db")
 
@@ -221,77 +2061,158 @@ func createTestRepository(t *testing.T) (PaymentRepository, *Database) {
 	err = db.Initialize(ctx)
 	require.NoError(t, err)
 
-	repo := NewPaymentRepository(db)
+	repo := NewPaymentRepository(db, clock)
 	return repo, &db
 }
 
-// createTestPayment creates a test payment with valid data
-func createTestPayment(t *testing.T) payment.Payment {
-	return createTestPaymentWithID(t, "test_payment_001")
+// testPaymentSpec describes the payment newTestPayment builds.
+type testPaymentSpec struct {
+	id            string
+	debtor        string
+	amountCents   int64
+	currency      shared.Currency
+	key           shared.IdempotencyKey
+	createdAt     time.Time
+	executionDate time.Time
 }
 
-// createTestPaymentWithID creates a test payment with a specific ID
-func createTestPaymentWithID(t *testing.T, id string) payment.Payment {
-	debtorIBAN, err := shared.NewIBAN("DE89370400440532013000")
-	require.NoError(t, err)
+// testPaymentOption adjusts the payment newTestPayment builds.
+type testPaymentOption func(*testPaymentSpec)
 
-	creditorIBAN, err := shared.NewIBAN("FR1420041010050500013M02606")
-	require.NoError(t, err)
+func withID(id string) testPaymentOption {
+	return func(s *testPaymentSpec) { s.id = id }
+}
 
-	amount, err := shared.NewAmountFromCents(10050) // €100.50
-	require.NoError(t, err)
+func withDebtor(iban shared.IBAN) testPaymentOption {
+	return func(s *testPaymentSpec) { s.debtor = iban.String() }
+}
 
-	// Create a valid 10-character idempotency key
-	// Use a simple hash to ensure uniqueness
-	hash := 0
-	for _, c := range id {
-		hash = hash*31 + int(c)
-	}
-	keyValue := fmt.Sprintf("test%06d", hash%1000000)
-	idempotencyKey, err := shared.NewIdempotencyKey(keyValue)
-	require.NoError(t, err)
+func withAmount(cents int64) testPaymentOption {
+	return func(s *testPaymentSpec) { s.amountCents = cents }
+}
 
-	now := time.Now().UTC() // Use UTC to match SQLite's CURRENT_TIMESTAMP
-	testPayment, err := payment.NewPayment(
-		id,
-		debtorIBAN,
-		"John Doe",
-		creditorIBAN,
-		"Jane Smith",
-		amount,
-		idempotencyKey,
-		now,
-		now,
-	)
-	require.NoError(t, err)
+func withCurrency(currency shared.Currency) testPaymentOption {
+	return func(s *testPaymentSpec) { s.currency = currency }
+}
 
-	return testPayment
+// withIdempotencyKey replaces the key newTestPayment derives from the id.
+func withIdempotencyKey(key shared.IdempotencyKey) testPaymentOption {
+	return func(s *testPaymentSpec) { s.key = key }
 }
 
-// createTestPaymentWithIdempotencyKey creates a test payment with a specific idempotency key
-func createTestPaymentWithIdempotencyKey(t *testing.T, key shared.IdempotencyKey) payment.Payment {
-	debtorIBAN, err := shared.NewIBAN("DE89370400440532013000")
-	require.NoError(t, err)
+func withCreatedAt(createdAt time.Time) testPaymentOption {
+	return func(s *testPaymentSpec) { s.createdAt = createdAt }
+}
+
+func withExecutionDate(executionDate time.Time) testPaymentOption {
+	return func(s *testPaymentSpec) { s.executionDate = executionDate }
+}
+
+// newTestPayment builds a valid pending payment of €100.50, created now, whose
+// idempotency key is derived from its id unless opts say otherwise.
+func newTestPayment(t *testing.T, opts ...testPaymentOption) payment.Payment {
+	t.Helper()
+
+	spec := testPaymentSpec{
+		id:          "test_payment_001",
+		debtor:      "DE89370400440532013000",
+		amountCents: 10050,
+		currency:    shared.EUR,
+		createdAt:   time.Now().UTC(), // UTC to match SQLite's CURRENT_TIMESTAMP
+	}
+	for _, opt := range opts {
+		opt(&spec)
+	}
 
+	if spec.key.Value() == "" {
+		// A simple hash keeps keys of different ids apart.
+		var hash uint32
+		for _, c := range spec.id {
+			hash = hash*31 + uint32(c)
+		}
+		key, err := shared.NewIdempotencyKey(fmt.Sprintf("test%06d", hash%1000000))
+		require.NoError(t, err)
+		spec.key = key
+	}
+
+	debtorIBAN, err := shared.NewIBAN(spec.debtor)
+	require.NoError(t, err)
 	creditorIBAN, err := shared.NewIBAN("FR1420041010050500013M02606")
 	require.NoError(t, err)
-
-	amount, err := shared.NewAmountFromCents(10050) // €100.50
+	amount, err := shared.NewAmountFromCentsWithCurrency(spec.amountCents, spec.currency)
 	require.NoError(t, err)
 
-	now := time.Now()
-	testPayment, err := payment.NewPayment(
-		"test_payment_duplicate",
-		debtorIBAN,
-		"John Doe",
-		creditorIBAN,
-		"Jane Smith",
-		amount,
-		key,
-		now,
-		now,
-	)
+	testPayment, err := payment.NewPayment(spec.id, debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+		amount, spec.key, "", spec.executionDate, nil, spec.createdAt, spec.createdAt)
 	require.NoError(t, err)
 
 	return testPayment
 }
+
+// seedPaymentsWithStatuses saves one payment per ID, transitioned to the given status
+func seedPaymentsWithStatuses(t *testing.T, repo PaymentRepository, statuses map[string]payment.PaymentStatus) {
+	ctx := context.Background()
+
+	for id, status := range statuses {
+		p := newTestPayment(t, withID(id))
+
+		var err error
+		if status != payment.StatusPending && status != payment.StatusCancelled {
+			p, err = p.MarkAsProcessing(p.UpdatedAt())
+			require.NoError(t, err)
+		}
+		switch status {
+		case payment.StatusProcessed:
+			p, err = p.MarkAsProcessed(p.UpdatedAt())
+		case payment.StatusFailed:
+			p, err = p.MarkAsFailed(p.UpdatedAt())
+		case payment.StatusCancelled:
+			p, err = p.Cancel(p.UpdatedAt())
+		}
+		require.NoError(t, err)
+
+		require.NoError(t, repo.Save(ctx, p))
+	}
+}
+
+// failingConnector fails every connection attempt with err, standing in for a
+// broken database behind a *sql.DB.
+type failingConnector struct {
+	err error
+}
+
+func (c failingConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func (c failingConnector) Driver() driver.Driver {
+	return failingDriver(c)
+}
+
+type failingDriver failingConnector
+
+func (d failingDriver) Open(string) (driver.Conn, error) {
+	return nil, d.err
+}
+
+// cancelAfterChecks is a context that cancels itself on the nth call to Err,
+// so tests can stop a long operation at a chosen point.
+type cancelAfterChecks struct {
+	context.Context
+	cancel    context.CancelFunc
+	remaining atomic.Int32
+}
+
+func newCancelAfterChecks(n int32) *cancelAfterChecks {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &cancelAfterChecks{Context: ctx, cancel: cancel}
+	c.remaining.Store(n)
+	return c
+}
+
+func (c *cancelAfterChecks) Err() error {
+	if c.remaining.Add(-1) == 0 {
+		c.cancel()
+	}
+	return c.Context.Err()
+}
@@ -0,0 +1,179 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"paymentprocessor/internal/domain/shared"
+)
+
+// DefaultReservationLease is how long a reserved idempotency key stays with
+// the request that reserved it before another request may take it over.
+const DefaultReservationLease = time.Minute
+
+// DefaultResponseRetention is how long a stored response is replayed before
+// its key may be used afresh.
+const DefaultResponseRetention = 24 * time.Hour
+
+// ResponseStore records the response to each create request by idempotency
+// key, so that a retry can be answered with exactly the original response
+// instead of being processed again. Each key also remembers a hash of the
+// request it was first used with, so that reusing it for a different request
+// can be told apart from a retry.
+type ResponseStore struct {
+	db        Database
+	clock     shared.Clock
+	lease     time.Duration
+	retention time.Duration
+	retry     RetryPolicy
+}
+
+// NewResponseStore returns a store whose reservations expire after lease, or
+// DefaultReservationLease if it is not positive. Responses are kept for
+// DefaultResponseRetention.
+func NewResponseStore(db Database, clock shared.Clock, lease time.Duration) ResponseStore {
+	if lease <= 0 {
+		lease = DefaultReservationLease
+	}
+
+	return ResponseStore{db: db, clock: clock, lease: lease, retention: DefaultResponseRetention, retry: DefaultRetryPolicy()}
+}
+
+// WithRetention returns a copy of the store that keeps responses for
+// retention, or DefaultResponseRetention if it is not positive.
+func (s ResponseStore) WithRetention(retention time.Duration) ResponseStore {
+	if retention <= 0 {
+		retention = DefaultResponseRetention
+	}
+	s.retention = retention
+	return s
+}
+
+// Reserve claims key for a request hashing to requestHash. It reports false
+// while another request holds the key and once a response has been stored for
+// it. A reservation older than the lease, e.g. one left behind by a crashed
+// process, is taken over, as is a response older than the retention.
+func (s ResponseStore) Reserve(ctx context.Context, key, requestHash string) (reserved bool, err error) {
+	now := s.clock.Now()
+	query := `
+		INSERT INTO idempotency_responses (idempotency_key, request_hash, reserved_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(idempotency_key) DO UPDATE SET
+			request_hash = excluded.request_hash,
+			reserved_at = excluded.reserved_at,
+			status_code = NULL,
+			body = NULL,
+			completed_at = NULL
+		WHERE (idempotency_responses.status_code IS NULL AND idempotency_responses.reserved_at < ?)
+			OR idempotency_responses.completed_at < ?
+	`
+
+	err = withRetry(ctx, s.retry, func() error {
+		result, err := s.db.ExecContext(ctx, query, key, requestHash, formatTimestamp(now),
+			formatTimestamp(now.Add(-s.lease)), formatTimestamp(now.Add(-s.retention)))
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		reserved = rows == 1
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	return reserved, nil
+}
+
+// Complete stores the response for a key the caller reserved.
+func (s ResponseStore) Complete(ctx context.Context, key string, statusCode int, body []byte) error {
+	query := `
+		UPDATE idempotency_responses
+		SET status_code = ?, body = ?, completed_at = ?
+		WHERE idempotency_key = ? AND status_code IS NULL
+	`
+
+	err := withRetry(ctx, s.retry, func() error {
+		_, err := s.db.ExecContext(ctx, query, statusCode, body, formatTimestamp(s.clock.Now()), key)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+
+	return nil
+}
+
+// Release drops the caller's reservation of key without storing a response,
+// so that a retry is processed again.
+func (s ResponseStore) Release(ctx context.Context, key string) error {
+	err := withRetry(ctx, s.retry, func() error {
+		_, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_responses WHERE idempotency_key = ? AND status_code IS NULL`, key)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// Find returns the hash of the request holding key and the response stored
+// for it. The hash is empty while the key is unknown, and the status code is
+// zero while the key is unknown or still reserved. Responses older than the
+// retention are treated as unknown.
+func (s ResponseStore) Find(ctx context.Context, key string) (requestHash string, statusCode int, body []byte, err error) {
+	query := `
+		SELECT request_hash, status_code, body FROM idempotency_responses
+		WHERE idempotency_key = ? AND (completed_at IS NULL OR completed_at >= ?)
+	`
+
+	var code sql.NullInt64
+	err = s.db.QueryRowContext(ctx, query, key, formatTimestamp(s.clock.Now().Add(-s.retention))).
+		Scan(&requestHash, &code, &body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, nil, nil
+	}
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("failed to find idempotent response: %w", err)
+	}
+
+	return requestHash, int(code.Int64), body, nil
+}
+
+// PurgeExpired deletes responses older than the retention and reservations
+// older than the lease, returning how many were deleted. Expired rows are
+// already ignored, so this only reclaims space; run it periodically.
+func (s ResponseStore) PurgeExpired(ctx context.Context) (purged int, err error) {
+	now := s.clock.Now()
+	query := `
+		DELETE FROM idempotency_responses
+		WHERE completed_at < ? OR (status_code IS NULL AND reserved_at < ?)
+	`
+
+	err = withRetry(ctx, s.retry, func() error {
+		result, err := s.db.ExecContext(ctx, query, formatTimestamp(now.Add(-s.retention)), formatTimestamp(now.Add(-s.lease)))
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		purged = int(rows)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotent responses: %w", err)
+	}
+
+	return purged, nil
+}
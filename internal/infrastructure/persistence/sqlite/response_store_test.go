@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+func TestResponseStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("stores the response of a reserved key", func(t *testing.T) {
+		t.Parallel()
+
+		_, db := createTestRepository(t)
+		store := NewResponseStore(*db, system.NewMockClock(time.Now()), time.Minute)
+
+		reserved, err := store.Reserve(ctx, "abc123XYZ0", "hash-1")
+		require.NoError(t, err)
+		assert.True(t, reserved)
+
+		reserved, err = store.Reserve(ctx, "abc123XYZ0", "hash-1")
+		require.NoError(t, err)
+		assert.False(t, reserved, "expected a held key not to be reserved twice")
+
+		requestHash, status, _, err := store.Find(ctx, "abc123XYZ0")
+		require.NoError(t, err)
+		assert.Equal(t, "hash-1", requestHash)
+		assert.Zero(t, status, "expected no response while the key is reserved")
+
+		require.NoError(t, store.Complete(ctx, "abc123XYZ0", 201, []byte(`{"id":"1"}`)))
+
+		requestHash, status, body, err := store.Find(ctx, "abc123XYZ0")
+		require.NoError(t, err)
+		assert.Equal(t, "hash-1", requestHash)
+		assert.Equal(t, 201, status)
+		assert.Equal(t, `{"id":"1"}`, string(body))
+
+		reserved, err = store.Reserve(ctx, "abc123XYZ0", "hash-1")
+		require.NoError(t, err)
+		assert.False(t, reserved, "expected a completed key not to be reserved again")
+	})
+
+	t.Run("reports an unknown key as having no response", func(t *testing.T) {
+		t.Parallel()
+
+		_, db := createTestRepository(t)
+		store := NewResponseStore(*db, system.NewMockClock(time.Now()), time.Minute)
+
+		requestHash, status, body, err := store.Find(ctx, "missing000")
+		require.NoError(t, err)
+		assert.Empty(t, requestHash)
+		assert.Zero(t, status)
+		assert.Nil(t, body)
+	})
+
+	t.Run("lets a released key be reserved again", func(t *testing.T) {
+		t.Parallel()
+
+		_, db := createTestRepository(t)
+		store := NewResponseStore(*db, system.NewMockClock(time.Now()), time.Minute)
+
+		reserved, err := store.Reserve(ctx, "abc123XYZ0", "hash-1")
+		require.NoError(t, err)
+		require.True(t, reserved)
+		require.NoError(t, store.Release(ctx, "abc123XYZ0"))
+
+		reserved, err = store.Reserve(ctx, "abc123XYZ0", "hash-1")
+		require.NoError(t, err)
+		assert.True(t, reserved)
+	})
+
+	t.Run("takes over a reservation older than the lease", func(t *testing.T) {
+		t.Parallel()
+
+		_, db := createTestRepository(t)
+		clock := system.NewMockClock(time.Now())
+		store := NewResponseStore(*db, clock, time.Minute)
+
+		reserved, err := store.Reserve(ctx, "abc123XYZ0", "hash-1")
+		require.NoError(t, err)
+		require.True(t, reserved)
+
+		clock.Advance(30 * time.Second)
+		reserved, err = store.Reserve(ctx, "abc123XYZ0", "hash-1")
+		require.NoError(t, err)
+		assert.False(t, reserved, "expected the reservation to hold within the lease")
+
+		clock.Advance(time.Minute)
+		reserved, err = store.Reserve(ctx, "abc123XYZ0", "hash-1")
+		require.NoError(t, err)
+		assert.True(t, reserved, "expected an expired reservation to be taken over")
+	})
+
+	t.Run("forgets a response older than the retention", func(t *testing.T) {
+		t.Parallel()
+
+		_, db := createTestRepository(t)
+		clock := system.NewMockClock(time.Now())
+		store := NewResponseStore(*db, clock, time.Minute).WithRetention(time.Hour)
+
+		reserved, err := store.Reserve(ctx, "abc123XYZ0", "hash-1")
+		require.NoError(t, err)
+		require.True(t, reserved)
+		require.NoError(t, store.Complete(ctx, "abc123XYZ0", 201, []byte(`{"id":"1"}`)))
+
+		clock.Advance(time.Hour + time.Second)
+		requestHash, status, _, err := store.Find(ctx, "abc123XYZ0")
+		require.NoError(t, err)
+		assert.Empty(t, requestHash)
+		assert.Zero(t, status, "expected an expired response not to be replayed")
+
+		reserved, err = store.Reserve(ctx, "abc123XYZ0", "hash-2")
+		require.NoError(t, err)
+		assert.True(t, reserved, "expected an expired key to be reserved afresh")
+
+		requestHash, status, _, err = store.Find(ctx, "abc123XYZ0")
+		require.NoError(t, err)
+		assert.Equal(t, "hash-2", requestHash)
+		assert.Zero(t, status)
+	})
+
+	t.Run("purges expired responses and reservations", func(t *testing.T) {
+		t.Parallel()
+
+		_, db := createTestRepository(t)
+		clock := system.NewMockClock(time.Now())
+		store := NewResponseStore(*db, clock, time.Minute).WithRetention(time.Hour)
+
+		for _, key := range []string{"completed0", "reserved00"} {
+			reserved, err := store.Reserve(ctx, key, "hash-1")
+			require.NoError(t, err)
+			require.True(t, reserved)
+		}
+		require.NoError(t, store.Complete(ctx, "completed0", 201, []byte(`{"id":"1"}`)))
+
+		clock.Advance(30 * time.Minute)
+		reserved, err := store.Reserve(ctx, "recent0000", "hash-1")
+		require.NoError(t, err)
+		require.True(t, reserved)
+
+		purged, err := store.PurgeExpired(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged, "expected only the stale reservation to go")
+
+		clock.Advance(31 * time.Minute)
+		purged, err = store.PurgeExpired(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, purged, "expected the expired response and the now stale reservation to go")
+
+		var remaining int
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM idempotency_responses").Scan(&remaining))
+		assert.Zero(t, remaining)
+	})
+}
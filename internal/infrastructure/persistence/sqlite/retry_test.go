@@ -0,0 +1,163 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+func TestPaymentRepository_RetriesBusyWrites(t *testing.T) {
+	t.Parallel()
+
+	t.Run("save succeeds once the database is no longer busy", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		busy := &busyExecutor{Database: db, failures: 2, err: sqlite3.Error{Code: sqlite3.ErrBusy}}
+		repo = NewPaymentRepository(busy, system.NewTimeProvider())
+		repo.retry = fastRetryPolicy()
+
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		assert.Equal(t, 3, busy.calls)
+		found, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, testPayment.ID(), found.ID())
+	})
+
+	t.Run("update status succeeds once the database is no longer locked", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		testPayment := newTestPayment(t)
+		require.NoError(t, repo.Save(ctx, testPayment))
+
+		busy := &busyExecutor{Database: db, failures: 3, err: sqlite3.Error{Code: sqlite3.ErrLocked}}
+		repo = NewPaymentRepository(busy, system.NewTimeProvider())
+		repo.retry = fastRetryPolicy()
+
+		require.NoError(t, repo.UpdateStatus(ctx, testPayment.ID(), payment.StatusProcessed, testPayment.Version(), time.Now()))
+
+		assert.Equal(t, 4, busy.calls)
+		found, err := repo.FindByID(ctx, testPayment.ID())
+		require.NoError(t, err)
+		assert.Equal(t, payment.StatusProcessed, found.Status())
+	})
+
+	t.Run("gives up after the maximum number of attempts", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		busy := &busyExecutor{Database: db, failures: 10, err: sqlite3.Error{Code: sqlite3.ErrBusy}}
+		repo = NewPaymentRepository(busy, system.NewTimeProvider())
+		repo.retry = fastRetryPolicy()
+
+		err := repo.Save(context.Background(), newTestPayment(t))
+
+		var sqliteErr sqlite3.Error
+		require.ErrorAs(t, err, &sqliteErr)
+		assert.Equal(t, sqlite3.ErrBusy, sqliteErr.Code)
+		assert.Equal(t, fastRetryPolicy().MaxAttempts, busy.calls)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		busy := &busyExecutor{Database: db, failures: 1, err: errors.New("disk I/O error")}
+		repo = NewPaymentRepository(busy, system.NewTimeProvider())
+		repo.retry = fastRetryPolicy()
+
+		err := repo.Save(context.Background(), newTestPayment(t))
+
+		assert.ErrorContains(t, err, "disk I/O error")
+		assert.Equal(t, 1, busy.calls)
+	})
+}
+
+func TestWithRetry(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stops waiting when the context is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+
+		calls := 0
+		err := withRetry(ctx, policy, func() error {
+			calls++
+			cancel()
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("caps the backoff", func(t *testing.T) {
+		t.Parallel()
+
+		policy := RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+		started := time.Now()
+		calls := 0
+		err := withRetry(context.Background(), policy, func() error {
+			calls++
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 4, calls)
+		assert.Less(t, time.Since(started), time.Second, "expected waits of 1ms, 2ms and 2ms")
+	})
+}
+
+func fastRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+}
+
+// busyExecutor fails the first failures writes and transactions with err
+// before delegating to the wrapped database.
+type busyExecutor struct {
+	*Database
+	failures int
+	err      error
+	calls    int
+}
+
+func (e *busyExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	e.calls++
+	if e.calls <= e.failures {
+		return nil, e.err
+	}
+	return e.Database.ExecContext(ctx, query, args...)
+}
+
+func (e *busyExecutor) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	e.calls++
+	if e.calls <= e.failures {
+		return nil, e.err
+	}
+	return e.Database.BeginTx(ctx, opts)
+}
@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"paymentprocessor/internal/domain/payment"
+)
+
+// DataIssue is a rule a stored payment breaks, e.g. because validation was
+// tightened after it was written. A payment breaking several rules has an
+// issue for each.
+type DataIssue struct {
+	PaymentID string
+	Reason    string
+}
+
+// ValidateData reads every payment, soft-deleted ones included, back through
+// the domain constructors, checks it with payment.Validate and reports each
+// violation, oldest payment first. A row that cannot be read back at all is
+// reported once. It only reads, so operators can run it against a live
+// database to find legacy rows that need fixing.
+func (d Database) ValidateData(ctx context.Context) ([]DataIssue, error) {
+	done, err := d.operations.start()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	query := `
+		SELECT id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+			   amount_cents, currency, idempotency_key, reference, execution_date, metadata, reversal_of, status, version, created_at, updated_at
+		FROM payments
+		ORDER BY created_at, id
+	`
+
+	rows, err := d.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payments: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payment columns: %w", err)
+	}
+
+	issues := []DataIssue{}
+	for rows.Next() {
+		// Read the id on its own first, so that a row failing the full scan
+		// can still be named.
+		var id string
+		dest := make([]interface{}, len(columns))
+		dest[0] = &id
+		for i := 1; i < len(dest); i++ {
+			dest[i] = new(interface{})
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to read payment id: %w", err)
+		}
+
+		p, err := scanPayment(rows)
+		if err != nil {
+			issues = append(issues, DataIssue{PaymentID: id, Reason: err.Error()})
+			continue
+		}
+
+		err = payment.Validate(p)
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, violation := range joined.Unwrap() {
+				issues = append(issues, DataIssue{PaymentID: id, Reason: violation.Error()})
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to validate payments: %w", err)
+	}
+
+	return issues, nil
+}
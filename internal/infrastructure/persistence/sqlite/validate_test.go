@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/domain/shared"
+)
+
+func TestDatabase_ValidateData(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports rows that fail domain validation", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		require.NoError(t, repo.Save(ctx, newTestPayment(t, withID("valid_payment"))))
+
+		// Rows written before the current rules, or by hand, that the
+		// repository cannot turn back into payments.
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO payments (id, debtor_iban, debtor_name, creditor_iban, creditor_name,
+				amount_cents, idempotency_key, status, created_at, deleted_at)
+			VALUES
+				('same_parties', 'DE89370400440532013000', 'John Doe', 'DE89370400440532013000', 'Jane Smith',
+					1000, 'legacyKey1', 'PENDING', '2024-01-01T00:00:00.000Z', NULL),
+				('bad_checksum', 'DE00370400440532013000', 'John Doe', 'FR1420041010050500013M02606', 'Jane Smith',
+					1000, 'legacyKey2', 'PENDING', '2024-01-02T00:00:00.000Z', NULL),
+				('deleted_bad_key', 'DE89370400440532013000', 'John Doe', 'FR1420041010050500013M02606', 'Jane Smith',
+					1000, 'short', 'PENDING', '2024-01-03T00:00:00.000Z', '2024-02-01T00:00:00.000Z'),
+				('broken_rules', 'DE89370400440532013000', 'Jo', 'FR1420041010050500013M02606', 'Al',
+					1000, 'legacyKey3', 'PENDING', '2024-01-04T00:00:00.000Z', NULL)
+		`)
+		require.NoError(t, err)
+
+		issues, err := db.ValidateData(ctx)
+		require.NoError(t, err)
+		require.Len(t, issues, 5)
+
+		assert.Equal(t, "same_parties", issues[0].PaymentID)
+		assert.Contains(t, issues[0].Reason, "debtor and creditor IBAN are identical")
+		assert.Equal(t, "bad_checksum", issues[1].PaymentID)
+		assert.Contains(t, issues[1].Reason, "invalid IBAN format")
+		assert.Equal(t, "deleted_bad_key", issues[2].PaymentID)
+		assert.Contains(t, issues[2].Reason, "invalid idempotency key")
+		assert.Equal(t, "broken_rules", issues[3].PaymentID)
+		assert.Equal(t, shared.ErrInvalidDebtorName.Error(), issues[3].Reason)
+		assert.Equal(t, "broken_rules", issues[4].PaymentID)
+		assert.Equal(t, shared.ErrInvalidCreditorName.Error(), issues[4].Reason)
+
+		count, err := repo.IncludeDeleted().Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 5, count, "validation must not modify data")
+	})
+
+	t.Run("reports nothing for valid data", func(t *testing.T) {
+		t.Parallel()
+
+		repo, db := createTestRepository(t)
+		defer db.Close()
+
+		ctx := context.Background()
+		require.NoError(t, repo.Save(ctx, newTestPayment(t, withID("valid_payment"))))
+
+		issues, err := db.ValidateData(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+	})
+}
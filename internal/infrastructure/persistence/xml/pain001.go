@@ -0,0 +1,162 @@
+package xml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+// Pain001Namespace identifies the ISO 20022 customer credit transfer initiation
+// message, version 3, as used for SEPA credit transfers.
+const Pain001Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+
+// maxIdentifierLength is the Max35Text limit on message and end-to-end ids.
+const maxIdentifierLength = 35
+
+var (
+	ErrNoPayments        = errors.New("no payments to include")
+	ErrInvalidIdentifier = errors.New("invalid pain.001 identifier")
+)
+
+// GroupHeader carries the message-level fields of a pain.001 document.
+// InitiatingPartyName defaults to the debtor name of the first payment.
+type GroupHeader struct {
+	MessageID           string
+	CreationDateTime    time.Time
+	InitiatingPartyName string
+}
+
+// GeneratePain001 renders payments as a pain.001.001.03 document. Payments are
+// grouped into one payment information block per debtor account and execution
+// date, in the order they first appear. Payments without an execution date
+// are requested for the creation date of the message. All payments must share
+// a currency.
+func GeneratePain001(payments []payment.Payment, groupHeader GroupHeader) ([]byte, error) {
+	if len(payments) == 0 {
+		return nil, ErrNoPayments
+	}
+	if err := validateIdentifier("message id", groupHeader.MessageID); err != nil {
+		return nil, err
+	}
+
+	currency := payments[0].Amount().Currency()
+	zero, err := shared.NewAmountFromCentsWithCurrency(0, currency)
+	if err != nil {
+		return nil, err
+	}
+	total := zero
+
+	var (
+		blocks      []paymentInformation
+		blockTotals []shared.Amount
+		blockIndex  = map[string]int{}
+	)
+	for _, p := range payments {
+		if !p.Amount().Currency().Equals(currency) {
+			return nil, fmt.Errorf("%w: payment %s is in %s, expected %s", shared.ErrCurrencyMismatch, p.ID(), p.Amount().Currency().Code(), currency.Code())
+		}
+		if err := validateIdentifier("end-to-end id", p.ID()); err != nil {
+			return nil, err
+		}
+
+		executionDate := p.ExecutionDate()
+		if executionDate.IsZero() {
+			executionDate = groupHeader.CreationDateTime
+		}
+		requestedDate := executionDate.UTC().Format(time.DateOnly)
+
+		key := p.DebtorIBAN().String() + "|" + requestedDate
+		i, ok := blockIndex[key]
+		if !ok {
+			i = len(blocks)
+			blockIndex[key] = i
+			blocks = append(blocks, paymentInformation{
+				ID:                 paymentInformationID(groupHeader.MessageID, i+1),
+				Method:             "TRF",
+				PaymentType:        paymentTypeInformation{ServiceLevel: code{Code: "SEPA"}},
+				RequestedExecution: requestedDate,
+				Debtor:             party{Name: p.DebtorName()},
+				DebtorAccount:      account{ID: accountID{IBAN: p.DebtorIBAN().String()}},
+				DebtorAgent:        agentNotProvided(),
+				ChargeBearer:       "SLEV",
+			})
+			blockTotals = append(blockTotals, zero)
+		}
+
+		if blockTotals[i], err = blockTotals[i].Add(p.Amount()); err != nil {
+			return nil, err
+		}
+		if total, err = total.Add(p.Amount()); err != nil {
+			return nil, err
+		}
+		blocks[i].Transactions = append(blocks[i].Transactions, newCreditTransfer(p))
+	}
+
+	for i := range blocks {
+		blocks[i].NumberOfTxs = strconv.Itoa(len(blocks[i].Transactions))
+		blocks[i].ControlSum = blockTotals[i].String()
+	}
+
+	initiatingParty := groupHeader.InitiatingPartyName
+	if initiatingParty == "" {
+		initiatingParty = payments[0].DebtorName()
+	}
+
+	doc := document{
+		Namespace: Pain001Namespace,
+		Initiation: customerCreditTransferInitiation{
+			GroupHeader: groupHeaderElement{
+				MessageID:        groupHeader.MessageID,
+				CreationDateTime: groupHeader.CreationDateTime.UTC().Format("2006-01-02T15:04:05"),
+				NumberOfTxs:      strconv.Itoa(len(payments)),
+				ControlSum:       total.String(),
+				InitiatingParty:  party{Name: initiatingParty},
+			},
+			PaymentInformation: blocks,
+		},
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pain.001 document: %w", err)
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
+
+func newCreditTransfer(p payment.Payment) creditTransferTransaction {
+	tx := creditTransferTransaction{
+		PaymentID:       paymentID{EndToEndID: p.ID()},
+		Amount:          amount{InstructedAmount: instructedAmount{Currency: p.Amount().Currency().Code(), Value: p.Amount().String()}},
+		Creditor:        party{Name: p.CreditorName()},
+		CreditorAccount: account{ID: accountID{IBAN: p.CreditorIBAN().String()}},
+	}
+	if p.Reference() != "" {
+		tx.RemittanceInformation = &remittanceInformation{Unstructured: p.Reference()}
+	}
+	return tx
+}
+
+// paymentInformationID derives the id of the n-th payment information block
+// from the message id, shortening the message id to stay within Max35Text.
+func paymentInformationID(messageID string, n int) string {
+	suffix := "-" + strconv.Itoa(n)
+	runes := []rune(messageID)
+	if len(runes)+len(suffix) > maxIdentifierLength {
+		runes = runes[:maxIdentifierLength-len(suffix)]
+	}
+	return string(runes) + suffix
+}
+
+func validateIdentifier(name, value string) error {
+	if value == "" || utf8.RuneCountInString(value) > maxIdentifierLength {
+		return fmt.Errorf("%w: %s must be 1 to %d characters", ErrInvalidIdentifier, name, maxIdentifierLength)
+	}
+	return nil
+}
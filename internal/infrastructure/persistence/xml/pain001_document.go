@@ -0,0 +1,102 @@
+package xml
+
+import "encoding/xml"
+
+// The types below mirror the subset of the pain.001.001.03 schema needed for
+// SEPA credit transfers. Element names follow the ISO 20022 abbreviations.
+
+type document struct {
+	XMLName    xml.Name                         `xml:"Document"`
+	Namespace  string                           `xml:"xmlns,attr"`
+	Initiation customerCreditTransferInitiation `xml:"CstmrCdtTrfInitn"`
+}
+
+type customerCreditTransferInitiation struct {
+	GroupHeader        groupHeaderElement   `xml:"GrpHdr"`
+	PaymentInformation []paymentInformation `xml:"PmtInf"`
+}
+
+type groupHeaderElement struct {
+	MessageID        string `xml:"MsgId"`
+	CreationDateTime string `xml:"CreDtTm"`
+	NumberOfTxs      string `xml:"NbOfTxs"`
+	ControlSum       string `xml:"CtrlSum"`
+	InitiatingParty  party  `xml:"InitgPty"`
+}
+
+type paymentInformation struct {
+	ID                 string                      `xml:"PmtInfId"`
+	Method             string                      `xml:"PmtMtd"`
+	NumberOfTxs        string                      `xml:"NbOfTxs"`
+	ControlSum         string                      `xml:"CtrlSum"`
+	PaymentType        paymentTypeInformation      `xml:"PmtTpInf"`
+	RequestedExecution string                      `xml:"ReqdExctnDt"`
+	Debtor             party                       `xml:"Dbtr"`
+	DebtorAccount      account                     `xml:"DbtrAcct"`
+	DebtorAgent        agent                       `xml:"DbtrAgt"`
+	ChargeBearer       string                      `xml:"ChrgBr"`
+	Transactions       []creditTransferTransaction `xml:"CdtTrfTxInf"`
+}
+
+type paymentTypeInformation struct {
+	ServiceLevel code `xml:"SvcLvl"`
+}
+
+type code struct {
+	Code string `xml:"Cd"`
+}
+
+type party struct {
+	Name string `xml:"Nm"`
+}
+
+type account struct {
+	ID accountID `xml:"Id"`
+}
+
+type accountID struct {
+	IBAN string `xml:"IBAN"`
+}
+
+type agent struct {
+	FinancialInstitution financialInstitution `xml:"FinInstnId"`
+}
+
+type financialInstitution struct {
+	Other *otherIdentification `xml:"Othr,omitempty"`
+}
+
+type otherIdentification struct {
+	ID string `xml:"Id"`
+}
+
+// agentNotProvided is the debtor agent to use when the BIC is unknown, which
+// SEPA allows for IBAN-only transfers.
+func agentNotProvided() agent {
+	return agent{FinancialInstitution: financialInstitution{Other: &otherIdentification{ID: "NOTPROVIDED"}}}
+}
+
+type creditTransferTransaction struct {
+	PaymentID             paymentID              `xml:"PmtId"`
+	Amount                amount                 `xml:"Amt"`
+	Creditor              party                  `xml:"Cdtr"`
+	CreditorAccount       account                `xml:"CdtrAcct"`
+	RemittanceInformation *remittanceInformation `xml:"RmtInf,omitempty"`
+}
+
+type paymentID struct {
+	EndToEndID string `xml:"EndToEndId"`
+}
+
+type amount struct {
+	InstructedAmount instructedAmount `xml:"InstdAmt"`
+}
+
+type instructedAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type remittanceInformation struct {
+	Unstructured string `xml:"Ustrd"`
+}
@@ -0,0 +1,153 @@
+package xml
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+var testNow = time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+
+func TestGeneratePain001(t *testing.T) {
+	t.Parallel()
+
+	header := GroupHeader{MessageID: "MSG-20250121-001", CreationDateTime: testNow}
+
+	t.Run("renders credit transfers that parse back", func(t *testing.T) {
+		t.Parallel()
+
+		payments := []payment.Payment{
+			createTestPayment(t, "payment-1", "GB82WEST12345698765432", "FR1420041010050500013M02606", "100.50", "Invoice 1"),
+			createTestPayment(t, "payment-2", "GB82WEST12345698765432", "DE89370400440532013000", "20.25", ""),
+		}
+
+		generated, err := GeneratePain001(payments, header)
+		require.NoError(t, err)
+
+		out := string(generated)
+		assert.True(t, strings.HasPrefix(out, xml.Header), "expected an XML declaration")
+		assert.Contains(t, out, `<Document xmlns="`+Pain001Namespace+`">`)
+		assert.Contains(t, out, "<IBAN>GB82WEST12345698765432</IBAN>")
+		assert.Contains(t, out, "<IBAN>FR1420041010050500013M02606</IBAN>")
+		assert.Contains(t, out, `<InstdAmt Ccy="EUR">100.50</InstdAmt>`)
+		assert.Contains(t, out, `<InstdAmt Ccy="EUR">20.25</InstdAmt>`)
+		assert.Contains(t, out, "<CtrlSum>120.75</CtrlSum>")
+
+		var doc document
+		require.NoError(t, xml.Unmarshal(generated, &doc))
+		grpHdr := doc.Initiation.GroupHeader
+		assert.Equal(t, "MSG-20250121-001", grpHdr.MessageID)
+		assert.Equal(t, "2025-01-21T10:00:00", grpHdr.CreationDateTime)
+		assert.Equal(t, "2", grpHdr.NumberOfTxs)
+		assert.Equal(t, "120.75", grpHdr.ControlSum)
+		assert.Equal(t, "John Doe", grpHdr.InitiatingParty.Name, "expected the debtor to be the initiating party")
+
+		require.Len(t, doc.Initiation.PaymentInformation, 1)
+		block := doc.Initiation.PaymentInformation[0]
+		assert.Equal(t, "MSG-20250121-001-1", block.ID)
+		assert.Equal(t, "2025-01-21", block.RequestedExecution)
+		assert.Equal(t, "GB82WEST12345698765432", block.DebtorAccount.ID.IBAN)
+		assert.Equal(t, "120.75", block.ControlSum)
+		require.Len(t, block.Transactions, 2)
+		assert.Equal(t, "payment-1", block.Transactions[0].PaymentID.EndToEndID)
+		assert.Equal(t, "Invoice 1", block.Transactions[0].RemittanceInformation.Unstructured)
+		assert.Nil(t, block.Transactions[1].RemittanceInformation, "expected no remittance information without a reference")
+		assert.Equal(t, "DE89370400440532013000", block.Transactions[1].CreditorAccount.ID.IBAN)
+	})
+
+	t.Run("groups payments by debtor account and execution date", func(t *testing.T) {
+		t.Parallel()
+
+		scheduled := withExecutionDate(t,
+			createTestPayment(t, "payment-3", "GB82WEST12345698765432", "FR1420041010050500013M02606", "3.00", ""),
+			testNow.Add(72*time.Hour))
+		payments := []payment.Payment{
+			createTestPayment(t, "payment-1", "GB82WEST12345698765432", "FR1420041010050500013M02606", "1.00", ""),
+			createTestPayment(t, "payment-2", "DE89370400440532013000", "FR1420041010050500013M02606", "2.00", ""),
+			scheduled,
+			createTestPayment(t, "payment-4", "GB82WEST12345698765432", "DE89370400440532013000", "4.00", ""),
+		}
+
+		generated, err := GeneratePain001(payments, GroupHeader{MessageID: "MSG-1", CreationDateTime: testNow, InitiatingPartyName: "ACME Corp"})
+		require.NoError(t, err)
+
+		var doc document
+		require.NoError(t, xml.Unmarshal(generated, &doc))
+		assert.Equal(t, "ACME Corp", doc.Initiation.GroupHeader.InitiatingParty.Name)
+		assert.Equal(t, "10.00", doc.Initiation.GroupHeader.ControlSum)
+
+		blocks := doc.Initiation.PaymentInformation
+		require.Len(t, blocks, 3)
+		assert.Equal(t, []string{"MSG-1-1", "MSG-1-2", "MSG-1-3"}, []string{blocks[0].ID, blocks[1].ID, blocks[2].ID})
+		assert.Equal(t, "2", blocks[0].NumberOfTxs)
+		assert.Equal(t, "5.00", blocks[0].ControlSum)
+		assert.Equal(t, "DE89370400440532013000", blocks[1].DebtorAccount.ID.IBAN)
+		assert.Equal(t, "2025-01-24", blocks[2].RequestedExecution)
+	})
+
+	t.Run("rejects payments in different currencies", func(t *testing.T) {
+		t.Parallel()
+
+		payments := []payment.Payment{
+			createTestPayment(t, "payment-1", "GB82WEST12345698765432", "FR1420041010050500013M02606", "1.00", ""),
+			createTestPayment(t, "payment-2", "GB82WEST12345698765432", "FR1420041010050500013M02606", "1.00 USD", ""),
+		}
+
+		_, err := GeneratePain001(payments, header)
+		assert.ErrorIs(t, err, shared.ErrCurrencyMismatch)
+	})
+
+	t.Run("rejects an empty batch and invalid identifiers", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := GeneratePain001(nil, header)
+		assert.ErrorIs(t, err, ErrNoPayments)
+
+		payments := []payment.Payment{createTestPayment(t, "payment-1", "GB82WEST12345698765432", "FR1420041010050500013M02606", "1.00", "")}
+		_, err = GeneratePain001(payments, GroupHeader{MessageID: strings.Repeat("M", 36), CreationDateTime: testNow})
+		assert.ErrorIs(t, err, ErrInvalidIdentifier)
+	})
+}
+
+func TestPaymentInformationID_StaysWithinMax35Text(t *testing.T) {
+	t.Parallel()
+
+	id := paymentInformationID(strings.Repeat("M", 35), 12)
+
+	assert.Len(t, id, 35)
+	assert.True(t, strings.HasSuffix(id, "-12"))
+}
+
+func createTestPayment(t *testing.T, id, debtor, creditor, amountText, reference string) payment.Payment {
+	t.Helper()
+
+	debtorIBAN, err := shared.NewIBAN(debtor)
+	require.NoError(t, err)
+	creditorIBAN, err := shared.NewIBAN(creditor)
+	require.NoError(t, err)
+	amount, err := shared.ParseAmount(amountText)
+	require.NoError(t, err)
+	key, err := shared.NewIdempotencyKey("pain001Ky" + id[len(id)-1:])
+	require.NoError(t, err)
+
+	p, err := payment.NewPayment(id, debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+		amount, key, reference, time.Time{}, nil, testNow, testNow)
+	require.NoError(t, err)
+	return p
+}
+
+func withExecutionDate(t *testing.T, p payment.Payment, executionDate time.Time) payment.Payment {
+	t.Helper()
+
+	scheduled, err := payment.ReconstitutePayment(p.ID(), p.DebtorIBAN(), p.DebtorName(), p.CreditorIBAN(), p.CreditorName(),
+		p.Amount(), p.IdempotencyKey(), p.Reference(), executionDate, p.Metadata(), p.ReversalOf(), p.Status(), p.Version(), p.CreatedAt(), p.UpdatedAt())
+	require.NoError(t, err)
+	return scheduled
+}
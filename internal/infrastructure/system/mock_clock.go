@@ -0,0 +1,34 @@
+package system
+
+import (
+	"sync"
+	"time"
+)
+
+// FixedClock is a Clock that only moves when told to, for deterministic tests.
+type FixedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewMockClock(now time.Time) *FixedClock {
+	return &FixedClock{now: now}
+}
+
+func (c *FixedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FixedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *FixedClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
@@ -0,0 +1,44 @@
+package system
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"paymentprocessor/internal/domain/shared"
+)
+
+var (
+	_ shared.Clock = TimeProvider{}
+	_ shared.Clock = (*FixedClock)(nil)
+)
+
+func TestFixedClock_Now(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+	clock := NewMockClock(start)
+
+	assert.True(t, clock.Now().Equal(start), "clock should return the initial time")
+	assert.True(t, clock.Now().Equal(clock.Now()), "clock should not move on its own")
+}
+
+func TestFixedClock_Advance(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+	clock := NewMockClock(start)
+
+	clock.Advance(90 * time.Minute)
+
+	assert.True(t, clock.Now().Equal(start.Add(90*time.Minute)), "clock should move by the advanced duration")
+}
+
+func TestFixedClock_Set(t *testing.T) {
+	t.Parallel()
+	clock := NewMockClock(time.Now())
+	target := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	clock.Set(target)
+
+	assert.True(t, clock.Now().Equal(target), "clock should return the set time")
+}
@@ -0,0 +1,60 @@
+package system
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"paymentprocessor/internal/domain/shared"
+)
+
+// crockfordAlphabet is the Crockford base32 alphabet used by ULIDs.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator produces lexicographically sortable identifiers: a 48-bit
+// millisecond timestamp followed by 80 bits of crypto/rand entropy, encoded as
+// 26 Crockford base32 characters.
+type ULIDGenerator struct {
+	clock shared.Clock
+}
+
+func NewULIDGenerator(clock shared.Clock) ULIDGenerator {
+	return ULIDGenerator{clock: clock}
+}
+
+func (g ULIDGenerator) NewID() (string, error) {
+	var id [16]byte
+
+	ms := uint64(g.clock.Now().UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		id[i] = byte(ms)
+		ms >>= 8
+	}
+
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", fmt.Errorf("failed to generate ULID entropy: %w", err)
+	}
+
+	return encodeULID(id), nil
+}
+
+// encodeULID writes the 128-bit value as 26 base32 characters, five bits at a
+// time starting from the most significant bit (the first character carries 3 bits).
+func encodeULID(id [16]byte) string {
+	out := make([]byte, 26)
+
+	var bitBuffer uint32
+	bitCount := 2 // 26*5 = 130 bits, so the value is left-padded with two zero bits
+	pos := 0
+	for _, b := range id {
+		bitBuffer = bitBuffer<<8 | uint32(b)
+		bitCount += 8
+		for bitCount >= 5 {
+			bitCount -= 5
+			out[pos] = crockfordAlphabet[(bitBuffer>>uint(bitCount))&0x1F]
+			pos++
+		}
+	}
+
+	return string(out)
+}
@@ -0,0 +1,66 @@
+package system
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ulidRegex = regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`)
+
+type stubTimeProvider struct {
+	now time.Time
+}
+
+func (s stubTimeProvider) Now() time.Time {
+	return s.now
+}
+
+func TestULIDGenerator_NewID(t *testing.T) {
+	t.Parallel()
+	generator := NewULIDGenerator(NewTimeProvider())
+
+	id, err := generator.NewID()
+	require.NoError(t, err)
+
+	assert.Regexp(t, ulidRegex, id, "ULID should be 26 Crockford base32 characters")
+}
+
+func TestULIDGenerator_NewID_EncodesTimestamp(t *testing.T) {
+	t.Parallel()
+	// Reference value from the ULID specification
+	generator := NewULIDGenerator(stubTimeProvider{now: time.UnixMilli(1469918176385)})
+
+	id, err := generator.NewID()
+	require.NoError(t, err)
+
+	assert.Equal(t, "01ARYZ6S41", id[:10], "timestamp component should match the spec")
+}
+
+func TestULIDGenerator_NewID_SortsByTime(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+
+	earlier, err := NewULIDGenerator(stubTimeProvider{now: base}).NewID()
+	require.NoError(t, err)
+	later, err := NewULIDGenerator(stubTimeProvider{now: base.Add(time.Millisecond)}).NewID()
+	require.NoError(t, err)
+
+	assert.Less(t, earlier, later, "later ULIDs should sort after earlier ones")
+}
+
+func TestULIDGenerator_NewID_Unique(t *testing.T) {
+	t.Parallel()
+	generator := NewULIDGenerator(stubTimeProvider{now: time.Now()})
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id, err := generator.NewID()
+		require.NoError(t, err)
+		assert.False(t, seen[id], "generated duplicate ULID %q", id)
+		seen[id] = true
+	}
+}
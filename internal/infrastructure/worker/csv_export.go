@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+var exportColumns = []string{"id", "created_at", "debtor_iban", "debtor_name", "creditor_iban", "creditor_name", "amount", "currency", "status"}
+
+// ExportFilter selects the payments to export. From and To are required and
+// bound the creation time to [From, To). An empty Status exports every status.
+type ExportFilter struct {
+	From   time.Time
+	To     time.Time
+	Status payment.PaymentStatus
+}
+
+type CSVExporter struct {
+	repository payment.Repository
+}
+
+func NewCSVExporter(repository payment.Repository) CSVExporter {
+	return CSVExporter{repository: repository}
+}
+
+// ExportCSV writes a header and one row per payment matching filter, oldest
+// first. Soft-deleted payments are left out. Payments are streamed from the
+// repository and written as they arrive, so memory use does not grow with the
+// size of the export.
+func (e CSVExporter) ExportCSV(ctx context.Context, w io.Writer, filter ExportFilter) error {
+	if !filter.To.After(filter.From) {
+		return fmt.Errorf("%w: to (%s) must be after from (%s)", shared.ErrInvalidDateRange, filter.To, filter.From)
+	}
+	selection := payment.Filter{From: filter.From, To: filter.To, Status: filter.Status}
+	if err := selection.Validate(); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	err := e.repository.Iterate(ctx, selection, func(p payment.Payment) error {
+		if err := writer.Write(exportRow(p)); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export payments: %w", err)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return nil
+}
+
+func exportRow(p payment.Payment) []string {
+	return []string{
+		p.ID(),
+		p.CreatedAt().UTC().Format(time.RFC3339),
+		p.DebtorIBAN().String(),
+		p.DebtorName(),
+		p.CreditorIBAN().String(),
+		p.CreditorName(),
+		p.Amount().String(),
+		p.Amount().Currency().Code(),
+		string(p.Status()),
+	}
+}
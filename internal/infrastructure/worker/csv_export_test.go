@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+func TestCSVExporter_ExportCSV(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes a header and one row per payment in the range", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		repo := createTestRepository(t)
+		processed, err := createPaymentAt(t, "payment-2", testNow.Add(time.Hour), 12345).MarkAsProcessing(testNow)
+		require.NoError(t, err)
+		processed, err = processed.MarkAsProcessed(testNow)
+		require.NoError(t, err)
+		require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{
+			createPaymentAt(t, "payment-1", testNow, 10050),
+			processed,
+			createPaymentAt(t, "payment-3", testNow.Add(48*time.Hour), 700),
+		}))
+
+		var buf bytes.Buffer
+		err = NewCSVExporter(repo).ExportCSV(ctx, &buf, ExportFilter{From: testNow, To: testNow.Add(24 * time.Hour)})
+		require.NoError(t, err)
+
+		assert.Equal(t, strings.Join([]string{
+			"id,created_at,debtor_iban,debtor_name,creditor_iban,creditor_name,amount,currency,status",
+			"payment-1,2025-01-21T10:00:00Z,GB82WEST12345698765432,John Doe,FR1420041010050500013M02606,Jane Smith,100.50,EUR,PENDING",
+			"payment-2,2025-01-21T11:00:00Z,GB82WEST12345698765432,John Doe,FR1420041010050500013M02606,Jane Smith,123.45,EUR,PROCESSED",
+		}, "\n")+"\n", buf.String())
+	})
+
+	t.Run("filters by status", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		repo := createTestRepository(t)
+		cancelled, err := createPaymentAt(t, "payment-2", testNow.Add(time.Minute), 100).Cancel(testNow)
+		require.NoError(t, err)
+		require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{createPaymentAt(t, "payment-1", testNow, 100), cancelled}))
+
+		var buf bytes.Buffer
+		err = NewCSVExporter(repo).ExportCSV(ctx, &buf, ExportFilter{
+			From:   testNow,
+			To:     testNow.Add(time.Hour),
+			Status: payment.StatusCancelled,
+		})
+		require.NoError(t, err)
+
+		rows := readCSV(t, &buf)
+		require.Len(t, rows, 2)
+		assert.Equal(t, "payment-2", rows[1][0])
+		assert.Equal(t, "CANCELLED", rows[1][8])
+	})
+
+	t.Run("exports many payments sharing a creation time", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		repo := createTestRepository(t)
+		payments := []payment.Payment{
+			createPaymentAt(t, "payment-1", testNow, 100),
+			createPaymentAt(t, "payment-2", testNow.Add(time.Second), 100),
+			createPaymentAt(t, "payment-3", testNow.Add(time.Second), 100),
+			createPaymentAt(t, "payment-4", testNow.Add(time.Second), 100),
+			createPaymentAt(t, "payment-5", testNow.Add(2*time.Second), 100),
+		}
+		require.NoError(t, repo.SaveBatch(ctx, payments))
+
+		var buf bytes.Buffer
+		require.NoError(t, NewCSVExporter(repo).ExportCSV(ctx, &buf, ExportFilter{From: testNow, To: testNow.Add(time.Hour)}))
+
+		var ids []string
+		for _, row := range readCSV(t, &buf)[1:] {
+			ids = append(ids, row[0])
+		}
+		assert.Equal(t, []string{"payment-1", "payment-2", "payment-3", "payment-4", "payment-5"}, ids)
+	})
+
+	t.Run("leaves out soft-deleted payments", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		repo := createTestRepository(t)
+		require.NoError(t, repo.SaveBatch(ctx, []payment.Payment{
+			createPaymentAt(t, "payment-1", testNow, 100),
+			createPaymentAt(t, "payment-2", testNow.Add(time.Minute), 100),
+		}))
+		require.NoError(t, repo.SoftDelete(ctx, "payment-1"))
+
+		var buf bytes.Buffer
+		require.NoError(t, NewCSVExporter(repo).ExportCSV(ctx, &buf, ExportFilter{From: testNow, To: testNow.Add(time.Hour)}))
+
+		rows := readCSV(t, &buf)
+		require.Len(t, rows, 2)
+		assert.Equal(t, "payment-2", rows[1][0])
+	})
+
+	t.Run("rejects an invalid range or status", func(t *testing.T) {
+		t.Parallel()
+
+		exporter := NewCSVExporter(createTestRepository(t))
+
+		err := exporter.ExportCSV(context.Background(), &bytes.Buffer{}, ExportFilter{From: testNow, To: testNow})
+		assert.ErrorIs(t, err, shared.ErrInvalidDateRange)
+
+		err = exporter.ExportCSV(context.Background(), &bytes.Buffer{}, ExportFilter{From: testNow, To: testNow.Add(time.Hour), Status: "UNKNOWN"})
+		assert.ErrorIs(t, err, shared.ErrInvalidPaymentStatus)
+	})
+}
+
+func createPaymentAt(t *testing.T, id string, createdAt time.Time, cents int64) payment.Payment {
+	t.Helper()
+
+	debtorIBAN, err := shared.NewIBAN("GB82WEST12345698765432")
+	require.NoError(t, err)
+	creditorIBAN, err := shared.NewIBAN("FR1420041010050500013M02606")
+	require.NoError(t, err)
+	amount, err := shared.NewAmountFromCents(cents)
+	require.NoError(t, err)
+	key, err := shared.NewIdempotencyKey(fmt.Sprintf("exportKey%c", id[len(id)-1]))
+	require.NoError(t, err)
+
+	p, err := payment.NewPayment(id, debtorIBAN, "John Doe", creditorIBAN, "Jane Smith",
+		amount, key, "", time.Time{}, nil, createdAt, createdAt)
+	require.NoError(t, err)
+	return p
+}
+
+func readCSV(t *testing.T, buf *bytes.Buffer) [][]string {
+	t.Helper()
+
+	rows, err := csv.NewReader(buf).ReadAll()
+	require.NoError(t, err)
+	return rows
+}
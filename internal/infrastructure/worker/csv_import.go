@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"paymentprocessor/internal/application/command"
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+// importColumns is the expected column order of an import file. A first row
+// matching these names is treated as a header and skipped.
+var importColumns = []string{"debtor_iban", "debtor_name", "creditor_iban", "creditor_name", "amount", "idempotency_key"}
+
+// PaymentCreator is the application behaviour the importer depends on.
+type PaymentCreator interface {
+	CreatePayment(ctx context.Context, cmd command.CreatePaymentCommand) (payment.Payment, error)
+}
+
+// ImportResult summarizes an import. Rows that failed do not stop the rest of
+// the file from being imported.
+type ImportResult struct {
+	Imported int
+	Failed   []RowError
+}
+
+// RowError records why the row on Line of the input could not be imported.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+type CSVImporter struct {
+	creator PaymentCreator
+}
+
+func NewCSVImporter(creator PaymentCreator) CSVImporter {
+	return CSVImporter{creator: creator}
+}
+
+// ImportCSV creates a payment for every row of r. Invalid rows, including rows
+// whose idempotency key was already used, are collected in the result's Failed
+// list. An error is returned only when reading fails or ctx is cancelled, in
+// which case the result covers the rows processed so far.
+func (i CSVImporter) ImportCSV(ctx context.Context, r io.Reader) (ImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var result ImportResult
+	for first := true; ; first = false {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			result.Failed = append(result.Failed, RowError{Line: parseErr.Line, Err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if first && isImportHeader(record) {
+			continue
+		}
+
+		if err := i.importRow(ctx, record); err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			result.Failed = append(result.Failed, RowError{Line: line, Err: err})
+			continue
+		}
+		result.Imported++
+	}
+}
+
+func (i CSVImporter) importRow(ctx context.Context, record []string) error {
+	if len(record) != len(importColumns) {
+		return fmt.Errorf("expected %d columns, got %d", len(importColumns), len(record))
+	}
+
+	for j := range record {
+		record[j] = strings.TrimSpace(record[j])
+	}
+
+	// The amount may carry a currency suffix such as "12.00 USD"; without
+	// one it is in EUR.
+	amount, err := shared.ParseAmount(record[4])
+	if err != nil {
+		return err
+	}
+
+	_, err = i.creator.CreatePayment(ctx, command.CreatePaymentCommand{
+		DebtorIBAN:     record[0],
+		DebtorName:     record[1],
+		CreditorIBAN:   record[2],
+		CreditorName:   record[3],
+		Amount:         amount,
+		IdempotencyKey: record[5],
+	})
+	return err
+}
+
+func isImportHeader(record []string) bool {
+	if len(record) != len(importColumns) {
+		return false
+	}
+
+	for j, column := range importColumns {
+		if !strings.EqualFold(strings.TrimSpace(record[j]), column) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"paymentprocessor/internal/application/service"
+	"paymentprocessor/internal/application/service/mocks"
+	"paymentprocessor/internal/domain/shared"
+	"paymentprocessor/internal/infrastructure/persistence/sqlite"
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+var testNow = time.Date(2025, 1, 21, 10, 0, 0, 0, time.UTC)
+
+func TestCSVImporter_ImportCSV(t *testing.T) {
+	t.Parallel()
+
+	t.Run("imports valid rows and collects errors for invalid ones", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+
+		repo := createTestRepository(t)
+		importer := NewCSVImporter(newTestService(t, repo))
+
+		input := strings.Join([]string{
+			"debtor_iban,debtor_name,creditor_iban,creditor_name,amount,idempotency_key",
+			"GB82WEST12345698765432,John Doe,FR1420041010050500013M02606,Jane Smith,100.50,importKey1",
+			"GB00INVALID,John Doe,FR1420041010050500013M02606,Jane Smith,10.00,importKey2",
+			"GB82WEST12345698765432,John Doe,FR1420041010050500013M02606,Jane Smith,ten,importKey3",
+			"GB82WEST12345698765432,John Doe,FR1420041010050500013M02606",
+			"GB82WEST12345698765432,John Doe,FR1420041010050500013M02606,Jane Smith,12.00 USD,importKey4",
+			"GB82WEST12345698765432,John Doe,FR1420041010050500013M02606,Jane Smith,5.00,importKey1",
+			`GB82WEST12345698765432, "Doe, John",FR1420041010050500013M02606,Jane Smith,"1,234.99",importKey5`,
+			"GB82WEST12345698765432,John Doe,FR1420041010050500013M02606,Jane Smith,12.00 ABC,importKey6",
+			"GB82WEST12345698765432,John Doe,FR1420041010050500013M02606,Jane Smith,90071992547409.93,importKey7",
+		}, "\n")
+
+		result, err := importer.ImportCSV(ctx, strings.NewReader(input))
+		require.NoError(t, err)
+
+		assert.Equal(t, 4, result.Imported)
+		require.Len(t, result.Failed, 5)
+		assert.Equal(t, []int{3, 4, 5, 7, 9}, failedLines(result))
+		assert.ErrorIs(t, result.Failed[0], shared.ErrInvalidIBAN)
+		assert.ErrorIs(t, result.Failed[1], shared.ErrInvalidAmount)
+		assert.Contains(t, result.Failed[2].Error(), "expected 6 columns, got 3")
+		assert.ErrorIs(t, result.Failed[3], shared.ErrDuplicatePayment)
+		assert.ErrorIs(t, result.Failed[4], shared.ErrInvalidCurrency)
+
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 4, count, "expected only the valid rows to be persisted")
+
+		key, err := shared.NewIdempotencyKey("importKey5")
+		require.NoError(t, err)
+		stored, err := repo.FindByIdempotencyKey(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, "Doe, John", stored.DebtorName())
+		assert.Equal(t, int64(123499), stored.Amount().Cents())
+
+		key, err = shared.NewIdempotencyKey("importKey4")
+		require.NoError(t, err)
+		stored, err = repo.FindByIdempotencyKey(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, "USD", stored.Amount().Currency().Code())
+		assert.Equal(t, int64(1200), stored.Amount().Cents())
+
+		// 2^53+1 cents has no exact float64 representation.
+		key, err = shared.NewIdempotencyKey("importKey7")
+		require.NoError(t, err)
+		stored, err = repo.FindByIdempotencyKey(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, int64(9007199254740993), stored.Amount().Cents())
+	})
+
+	t.Run("imports files without a header", func(t *testing.T) {
+		t.Parallel()
+
+		importer := NewCSVImporter(newTestService(t, createTestRepository(t)))
+		input := "GB82WEST12345698765432,John Doe,FR1420041010050500013M02606,Jane Smith,100.50,importKey1\n"
+
+		result, err := importer.ImportCSV(context.Background(), strings.NewReader(input))
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Imported)
+		assert.Empty(t, result.Failed)
+	})
+
+	t.Run("records malformed CSV lines and keeps going", func(t *testing.T) {
+		t.Parallel()
+
+		importer := NewCSVImporter(newTestService(t, createTestRepository(t)))
+		input := strings.Join([]string{
+			`GB82WEST12345698765432,John "Doe,FR1420041010050500013M02606,Jane Smith,1.00,importKey1`,
+			"GB82WEST12345698765432,John Doe,FR1420041010050500013M02606,Jane Smith,2.00,importKey2",
+		}, "\n")
+
+		result, err := importer.ImportCSV(context.Background(), strings.NewReader(input))
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Imported)
+		assert.Equal(t, []int{1}, failedLines(result))
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		importer := NewCSVImporter(newTestService(t, createTestRepository(t)))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result, err := importer.ImportCSV(ctx, strings.NewReader("a,b,c,d,e,f\n"))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, result.Imported)
+	})
+}
+
+func failedLines(result ImportResult) []int {
+	lines := make([]int, len(result.Failed))
+	for i, rowErr := range result.Failed {
+		lines[i] = rowErr.Line
+	}
+	return lines
+}
+
+func createTestRepository(t *testing.T) sqlite.PaymentRepository {
+	t.Helper()
+
+	config := sqlite.DefaultInMemoryConfig()
+	config.DatabasePath = t.Name()
+	db, err := sqlite.NewDatabase(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, db.Initialize(context.Background()))
+
+	return sqlite.NewPaymentRepository(db, system.NewMockClock(testNow))
+}
+
+func newTestService(t *testing.T, repo sqlite.PaymentRepository) service.PaymentService {
+	t.Helper()
+
+	publisher := mocks.NewMockEventPublisher(gomock.NewController(t))
+	publisher.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	clock := system.NewMockClock(testNow)
+	return service.NewPaymentService(repo, repo, clock, system.NewULIDGenerator(clock), publisher)
+}
@@ -0,0 +1,206 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+)
+
+// ErrRunInProgress is returned by RunOnce when another run has not finished.
+var ErrRunInProgress = errors.New("processor run already in progress")
+
+// ProcessFunc performs the actual work for a claimed payment. A nil error marks
+// the payment PROCESSED, anything else marks it FAILED, unless the error comes
+// from ctx being cancelled, which leaves the claim in place.
+type ProcessFunc func(ctx context.Context, p payment.Payment) error
+
+// StatusUpdater applies status transitions; PaymentService implements it.
+type StatusUpdater interface {
+	ProcessStatusUpdate(ctx context.Context, paymentID string, newStatus payment.PaymentStatus) (payment.Payment, error)
+}
+
+type ProcessorConfig struct {
+	Interval  time.Duration
+	BatchSize int
+	// ClaimTimeout is how long a payment may stay PROCESSING before its claim
+	// is considered abandoned, e.g. by a worker that was stopped mid-payment.
+	ClaimTimeout time.Duration
+}
+
+func DefaultProcessorConfig() ProcessorConfig {
+	return ProcessorConfig{
+		Interval:     10 * time.Second,
+		BatchSize:    50,
+		ClaimTimeout: 5 * time.Minute,
+	}
+}
+
+// Processor periodically picks up pending payments that are due and advances
+// them through PROCESSING to PROCESSED or FAILED. Payments whose claim went
+// stale are marked FAILED rather than processed again, since the work may
+// already have happened.
+type Processor struct {
+	repository payment.Repository
+	updater    StatusUpdater
+	process    ProcessFunc
+	clock      shared.Clock
+	config     ProcessorConfig
+	logger     *slog.Logger
+	running    sync.Mutex
+}
+
+// NewProcessor returns a processor for config. Non-positive values fall back to
+// DefaultProcessorConfig and a nil logger uses slog.Default().
+func NewProcessor(
+	repository payment.Repository,
+	updater StatusUpdater,
+	process ProcessFunc,
+	clock shared.Clock,
+	config ProcessorConfig,
+	logger *slog.Logger,
+) *Processor {
+	defaults := DefaultProcessorConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.ClaimTimeout <= 0 {
+		config.ClaimTimeout = defaults.ClaimTimeout
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Processor{
+		repository: repository,
+		updater:    updater,
+		process:    process,
+		clock:      clock,
+		config:     config,
+		logger:     logger,
+	}
+}
+
+// Start runs a batch every Interval until ctx is cancelled. A tick that fires
+// while the previous batch is still running is skipped. Start blocks; run it in
+// its own goroutine.
+func (p *Processor) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			processed, err := p.RunOnce(ctx)
+			if errors.Is(err, ErrRunInProgress) || errors.Is(err, context.Canceled) {
+				continue
+			}
+			if err != nil {
+				p.logger.ErrorContext(ctx, "payment processing run failed", "processed", processed, "error", err)
+			}
+		}
+	}
+}
+
+// RunOnce fails stale claims, then loads and processes up to BatchSize due
+// payments, and returns how many reached a final status. Failures on
+// individual payments do not stop the batch; they are joined into the returned
+// error. Cancelling ctx stops the batch between payments.
+func (p *Processor) RunOnce(ctx context.Context) (int, error) {
+	if !p.running.TryLock() {
+		return 0, ErrRunInProgress
+	}
+	defer p.running.Unlock()
+
+	now := p.clock.Now()
+	stale, err := p.findStaleClaims(ctx, now.Add(-p.config.ClaimTimeout))
+	if err != nil {
+		return 0, fmt.Errorf("failed to load stale claims: %w", err)
+	}
+
+	due, err := p.repository.FindDueForExecution(ctx, now, p.config.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load due payments: %w", err)
+	}
+
+	var (
+		processed int
+		errs      []error
+	)
+	for _, claimed := range stale {
+		if err := ctx.Err(); err != nil {
+			return processed, err
+		}
+
+		p.logger.WarnContext(ctx, "failing payment with a stale processing claim", "payment_id", claimed.ID(), "claimed_at", claimed.UpdatedAt())
+		if _, err := p.updater.ProcessStatusUpdate(ctx, claimed.ID(), payment.StatusFailed); err != nil {
+			errs = append(errs, fmt.Errorf("payment %s: failed to release stale claim: %w", claimed.ID(), err))
+			continue
+		}
+		processed++
+	}
+
+	for _, pending := range due {
+		if err := ctx.Err(); err != nil {
+			return processed, err
+		}
+
+		if err := p.processPayment(ctx, pending); err != nil {
+			errs = append(errs, fmt.Errorf("payment %s: %w", pending.ID(), err))
+			continue
+		}
+		processed++
+	}
+
+	return processed, errors.Join(errs...)
+}
+
+// processPayment claims the payment so no other worker picks it up, runs the
+// processing function and records the outcome.
+func (p *Processor) processPayment(ctx context.Context, pending payment.Payment) error {
+	claimed, err := p.updater.ProcessStatusUpdate(ctx, pending.ID(), payment.StatusProcessing)
+	if err != nil {
+		return fmt.Errorf("failed to claim: %w", err)
+	}
+
+	outcome := payment.StatusProcessed
+	if err := p.process(ctx, claimed); err != nil {
+		// An interrupted payment did not fail; its claim is left to go stale.
+		if ctx.Err() != nil {
+			return fmt.Errorf("processing interrupted: %w", err)
+		}
+		p.logger.WarnContext(ctx, "payment processing failed", "payment_id", claimed.ID(), "error", err)
+		outcome = payment.StatusFailed
+	}
+
+	// The outcome has happened by now, so it is recorded even if ctx was
+	// cancelled in the meantime.
+	if _, err := p.updater.ProcessStatusUpdate(context.WithoutCancel(ctx), claimed.ID(), outcome); err != nil {
+		return fmt.Errorf("failed to mark %s: %w", outcome, err)
+	}
+
+	return nil
+}
+
+// findStaleClaims returns up to BatchSize PROCESSING payments claimed before
+// cutoff.
+func (p *Processor) findStaleClaims(ctx context.Context, cutoff time.Time) ([]payment.Payment, error) {
+	var stale []payment.Payment
+	err := p.repository.Iterate(ctx, payment.Filter{Status: payment.StatusProcessing}, func(claimed payment.Payment) error {
+		if claimed.UpdatedAt().Before(cutoff) && len(stale) < p.config.BatchSize {
+			stale = append(stale, claimed)
+		}
+		return nil
+	})
+	return stale, err
+}
@@ -0,0 +1,329 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"paymentprocessor/internal/application/service/mocks"
+	"paymentprocessor/internal/domain/payment"
+	"paymentprocessor/internal/domain/shared"
+	"paymentprocessor/internal/infrastructure/system"
+)
+
+func TestProcessor_RunOnce(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fetches due payments and transitions each of them", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		expectClaimed(mockRepo)
+		mockRepo.EXPECT().FindDueForExecution(ctx, testNow, DefaultProcessorConfig().BatchSize).Return([]payment.Payment{
+			createPaymentAt(t, "payment-1", testNow, 100),
+			createPaymentAt(t, "payment-2", testNow, 100),
+		}, nil)
+		updater := &recordingUpdater{}
+		process := func(ctx context.Context, p payment.Payment) error {
+			if p.ID() == "payment-2" {
+				return errors.New("bank rejected transfer")
+			}
+			return nil
+		}
+
+		processor := NewProcessor(mockRepo, updater, process, system.NewMockClock(testNow), DefaultProcessorConfig(), nil)
+		processed, err := processor.RunOnce(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, processed)
+		assert.Equal(t, []statusUpdate{
+			{"payment-1", payment.StatusProcessing},
+			{"payment-1", payment.StatusProcessed},
+			{"payment-2", payment.StatusProcessing},
+			{"payment-2", payment.StatusFailed},
+		}, updater.calls())
+	})
+
+	t.Run("loads no more than the batch size", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		expectClaimed(mockRepo)
+		mockRepo.EXPECT().FindDueForExecution(ctx, testNow, 2).Return([]payment.Payment{
+			createPaymentAt(t, "payment-1", testNow, 100),
+			createPaymentAt(t, "payment-2", testNow, 100),
+		}, nil)
+		updater := &recordingUpdater{}
+
+		config := ProcessorConfig{Interval: time.Second, BatchSize: 2}
+		processor := NewProcessor(mockRepo, updater, succeed, system.NewMockClock(testNow), config, nil)
+		processed, err := processor.RunOnce(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, processed)
+		assert.Len(t, updater.calls(), 4)
+	})
+
+	t.Run("skips payments it cannot claim and reports them", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		expectClaimed(mockRepo)
+		mockRepo.EXPECT().FindDueForExecution(ctx, testNow, gomock.Any()).Return([]payment.Payment{
+			createPaymentAt(t, "payment-1", testNow, 100),
+			createPaymentAt(t, "payment-2", testNow, 100),
+		}, nil)
+		updater := &recordingUpdater{failClaim: map[string]error{"payment-1": shared.ErrConcurrentModification}}
+
+		processor := NewProcessor(mockRepo, updater, succeed, system.NewMockClock(testNow), DefaultProcessorConfig(), nil)
+		processed, err := processor.RunOnce(ctx)
+
+		assert.ErrorIs(t, err, shared.ErrConcurrentModification)
+		assert.Contains(t, err.Error(), "payment payment-1")
+		assert.Equal(t, 1, processed)
+		assert.Equal(t, []statusUpdate{
+			{"payment-1", payment.StatusProcessing},
+			{"payment-2", payment.StatusProcessing},
+			{"payment-2", payment.StatusProcessed},
+		}, updater.calls())
+	})
+
+	t.Run("returns repository errors", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		expectClaimed(mockRepo)
+		mockRepo.EXPECT().FindDueForExecution(ctx, testNow, gomock.Any()).Return(nil, errors.New("database is locked"))
+
+		processor := NewProcessor(mockRepo, &recordingUpdater{}, succeed, system.NewMockClock(testNow), DefaultProcessorConfig(), nil)
+		_, err := processor.RunOnce(ctx)
+
+		assert.ErrorContains(t, err, "database is locked")
+	})
+
+	t.Run("fails stale claims and leaves fresh ones alone", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		config := DefaultProcessorConfig()
+		mockRepo := mocks.NewMockRepository(ctrl)
+		expectClaimed(mockRepo,
+			claimPaymentAt(t, "payment-1", testNow.Add(-config.ClaimTimeout-time.Second)),
+			claimPaymentAt(t, "payment-2", testNow.Add(-config.ClaimTimeout+time.Second)),
+		)
+		mockRepo.EXPECT().FindDueForExecution(ctx, testNow, gomock.Any()).Return(nil, nil)
+		updater := &recordingUpdater{}
+
+		processor := NewProcessor(mockRepo, updater, succeed, system.NewMockClock(testNow), config, nil)
+		processed, err := processor.RunOnce(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, processed)
+		assert.Equal(t, []statusUpdate{{"payment-1", payment.StatusFailed}}, updater.calls())
+	})
+
+	t.Run("leaves the claim of an interrupted payment in place", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		expectClaimed(mockRepo)
+		mockRepo.EXPECT().FindDueForExecution(ctx, testNow, gomock.Any()).Return([]payment.Payment{
+			createPaymentAt(t, "payment-1", testNow, 100),
+			createPaymentAt(t, "payment-2", testNow, 100),
+		}, nil)
+		updater := &recordingUpdater{}
+		interrupted := func(ctx context.Context, p payment.Payment) error {
+			cancel()
+			return ctx.Err()
+		}
+
+		processor := NewProcessor(mockRepo, updater, interrupted, system.NewMockClock(testNow), DefaultProcessorConfig(), nil)
+		processed, err := processor.RunOnce(ctx)
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Zero(t, processed)
+		assert.Equal(t, []statusUpdate{{"payment-1", payment.StatusProcessing}}, updater.calls(),
+			"expected the payment not to be marked failed")
+	})
+
+	t.Run("records the outcome when cancelled after processing", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		expectClaimed(mockRepo)
+		mockRepo.EXPECT().FindDueForExecution(ctx, testNow, gomock.Any()).Return([]payment.Payment{
+			createPaymentAt(t, "payment-1", testNow, 100),
+		}, nil)
+		updater := &recordingUpdater{}
+		cancelling := func(ctx context.Context, p payment.Payment) error {
+			cancel()
+			return nil
+		}
+
+		processor := NewProcessor(mockRepo, updater, cancelling, system.NewMockClock(testNow), DefaultProcessorConfig(), nil)
+		processed, err := processor.RunOnce(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, processed)
+		assert.Equal(t, []statusUpdate{
+			{"payment-1", payment.StatusProcessing},
+			{"payment-1", payment.StatusProcessed},
+		}, updater.calls())
+	})
+
+	t.Run("refuses to overlap with a run in progress", func(t *testing.T) {
+		t.Parallel()
+		ctx := context.Background()
+		ctrl := gomock.NewController(t)
+
+		mockRepo := mocks.NewMockRepository(ctrl)
+		expectClaimed(mockRepo)
+		mockRepo.EXPECT().FindDueForExecution(ctx, testNow, gomock.Any()).Return([]payment.Payment{createPaymentAt(t, "payment-1", testNow, 100)}, nil)
+
+		started, release := make(chan struct{}), make(chan struct{})
+		blocking := func(ctx context.Context, p payment.Payment) error {
+			close(started)
+			<-release
+			return nil
+		}
+		processor := NewProcessor(mockRepo, &recordingUpdater{}, blocking, system.NewMockClock(testNow), DefaultProcessorConfig(), nil)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = processor.RunOnce(ctx)
+		}()
+		<-started
+
+		_, err := processor.RunOnce(ctx)
+		assert.ErrorIs(t, err, ErrRunInProgress)
+
+		close(release)
+		<-done
+	})
+}
+
+func TestProcessor_Start(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	expectClaimed(mockRepo).AnyTimes()
+	mockRepo.EXPECT().FindDueForExecution(gomock.Any(), testNow, gomock.Any()).Return([]payment.Payment{createPaymentAt(t, "payment-1", testNow, 100)}, nil).MinTimes(1)
+	updater := &recordingUpdater{}
+
+	config := ProcessorConfig{Interval: 5 * time.Millisecond, BatchSize: 10}
+	processor := NewProcessor(mockRepo, updater, succeed, system.NewMockClock(testNow), config, nil)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		processor.Start(ctx)
+	}()
+
+	assert.Eventually(t, func() bool { return len(updater.calls()) >= 2 }, time.Second, time.Millisecond,
+		"expected a tick to process the due payment")
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected Start to return after cancellation")
+	}
+}
+
+// expectClaimed makes the repository report claimed as the payments in
+// PROCESSING.
+func expectClaimed(mockRepo *mocks.MockRepository, claimed ...payment.Payment) *gomock.Call {
+	return mockRepo.EXPECT().
+		Iterate(gomock.Any(), payment.Filter{Status: payment.StatusProcessing}, gomock.Any()).
+		DoAndReturn(func(ctx context.Context, filter payment.Filter, fn func(payment.Payment) error) error {
+			for _, p := range claimed {
+				if err := fn(p); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+}
+
+// claimPaymentAt returns a payment that was claimed for processing at claimedAt.
+func claimPaymentAt(t *testing.T, id string, claimedAt time.Time) payment.Payment {
+	t.Helper()
+
+	claimed, err := createPaymentAt(t, id, claimedAt, 100).MarkAsProcessing(claimedAt)
+	require.NoError(t, err)
+	return claimed
+}
+
+func succeed(ctx context.Context, p payment.Payment) error {
+	return nil
+}
+
+type statusUpdate struct {
+	id     string
+	status payment.PaymentStatus
+}
+
+// recordingUpdater records status updates and fails claims for selected ids.
+type recordingUpdater struct {
+	mu        sync.Mutex
+	updates   []statusUpdate
+	failClaim map[string]error
+}
+
+func (u *recordingUpdater) ProcessStatusUpdate(ctx context.Context, paymentID string, newStatus payment.PaymentStatus) (payment.Payment, error) {
+	if err := ctx.Err(); err != nil {
+		return payment.Payment{}, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.updates = append(u.updates, statusUpdate{paymentID, newStatus})
+	if err, ok := u.failClaim[paymentID]; ok && newStatus == payment.StatusProcessing {
+		return payment.Payment{}, err
+	}
+
+	p, err := payment.ReconstitutePayment(paymentID, mustIBAN("GB82WEST12345698765432"), "John Doe",
+		mustIBAN("FR1420041010050500013M02606"), "Jane Smith", shared.Amount{}, shared.IdempotencyKey{},
+		"", time.Time{}, nil, "", newStatus, 2, testNow, testNow)
+	return p, err
+}
+
+func (u *recordingUpdater) calls() []statusUpdate {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]statusUpdate(nil), u.updates...)
+}
+
+func mustIBAN(value string) shared.IBAN {
+	iban, err := shared.NewIBAN(value)
+	if err != nil {
+		panic(err)
+	}
+	return iban
+}